@@ -0,0 +1,149 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// FindAuthoritative returns the zone containing the provided name and the addresses of the
+// authoritative name servers for the zone, which are discovered using the resolver pool.
+func (r *Resolvers) FindAuthoritative(ctx context.Context, name string) (string, []string, error) {
+	return r.findAuthoritative(ctx, name, "53")
+}
+
+func (r *Resolvers) findAuthoritative(ctx context.Context, name, port string) (string, []string, error) {
+	name = strings.ToLower(RemoveLastDot(name))
+	labels := strings.Split(name, ".")
+
+	// the closest enclosing zone is found by walking up the name one label at a time
+	for i := 0; i < len(labels); i++ {
+		zone := strings.Join(labels[i:], ".")
+
+		resp, err := r.QueryBlocking(ctx, QueryMsg(zone, dns.TypeNS))
+		if err != nil {
+			return "", nil, err
+		}
+		if resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		var addrs []string
+		for _, ns := range AnswersByType(ExtractAnswers(resp), dns.TypeNS) {
+			if !strings.EqualFold(RemoveLastDot(ns.Name), zone) {
+				continue
+			}
+
+			opts := &LookupOptions{NoSearch: true}
+			// both address families are collected, so the queries can rotate across all of them
+			v4, _ := r.LookupA(ctx, ns.Data, opts)
+			v6, _ := r.LookupAAAA(ctx, ns.Data, opts)
+			for _, ip := range append(v4, v6...) {
+				addrs = append(addrs, net.JoinHostPort(ip.String(), port))
+			}
+		}
+		if len(addrs) > 0 {
+			return zone, addrs, nil
+		}
+	}
+	return "", nil, fmt.Errorf("failed to find the authoritative servers for %s", name)
+}
+
+// SetAuthoritativeWildcards enables sending the wildcard tests directly to the authoritative
+// name servers of the zone, which avoids the inconsistent results caused by the cache of the
+// recursive detection resolver. The detection resolver is still used when the authoritative
+// servers cannot be found.
+func (r *Resolvers) SetAuthoritativeWildcards(enable bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.authPort = ""
+	if enable {
+		r.authPort = "53"
+	}
+}
+
+// wildcardServers returns the authoritative servers for the subdomain when the wildcard tests
+// are sent to the authoritative servers.
+func (r *Resolvers) wildcardServers(ctx context.Context, sub string) []string {
+	r.Lock()
+	port := r.authPort
+	r.Unlock()
+
+	if port == "" {
+		return nil
+	}
+
+	_, servers, err := r.findAuthoritative(ctx, sub, port)
+	if err != nil {
+		return nil
+	}
+	return servers
+}
+
+// authQueryAttempts sends the query to the authoritative servers, beginning with the next
+// server in the rotation and moving on to the others when a server fails.
+func (r *Resolvers) authQueryAttempts(ctx context.Context, servers []string, name string, qtype uint16) []*ExtractedAnswer {
+	for _, server := range r.nsRotate.order(servers, r.clock.Now()) {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		msg := WalkMsg(name, qtype)
+		msg.RecursionDesired = false
+
+		resp, err := r.directExchange(ctx, msg, server)
+		if err != nil || resp.Rcode == dns.RcodeServerFailure || resp.Rcode == dns.RcodeRefused {
+			r.nsRotate.failure(server, r.clock.Now())
+			continue
+		}
+		r.nsRotate.success(server)
+		if resp.Rcode == dns.RcodeSuccess {
+			return ExtractAnswers(resp)
+		}
+		break
+	}
+	return nil
+}
+
+// directExchange sends the query to a server outside of the pool using the transport configured
+// for the pool, so the socket options, hooks, privacy profile and Tor proxy also apply.
+func (r *Resolvers) directExchange(ctx context.Context, msg *dns.Msg, server string) (*dns.Msg, error) {
+	if err := runHook(ctx, &r.preSend, msg); err != nil {
+		return nil, err
+	}
+	if r.privacy.Load() {
+		RemoveClientSubnet(msg)
+		PadMsg(msg)
+	}
+
+	var err error
+	var resp *dns.Msg
+	if cfg := r.tor.Load(); cfg != nil {
+		resp, err = r.torDirect(ctx, msg, cfg, server)
+	} else {
+		client := dns.Client{
+			Net:     "udp",
+			Timeout: DefaultTimeout,
+			Dialer:  r.conns.dialer(DefaultTimeout),
+		}
+		resp, _, err = client.ExchangeContext(ctx, msg, server)
+		if err == nil && resp.Truncated {
+			client.Net = "tcp"
+			resp, _, err = client.ExchangeContext(ctx, msg, server)
+		}
+	}
+	if err == nil {
+		err = runHook(ctx, &r.postReceive, resp)
+	}
+	return resp, err
+}
@@ -0,0 +1,152 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// recursiveCacheHandler answers the delegation of caffix.net and returns a stale record for
+// every other name, which resembles the artifacts of a recursive cache.
+func recursiveCacheHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	switch {
+	case q.Name == "caffix.net." && q.Qtype == dns.TypeNS:
+		m.Answer = []dns.RR{&dns.NS{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300},
+			Ns:  "ns1.caffix.net.",
+		}}
+	case q.Name == "ns1.caffix.net." && q.Qtype == dns.TypeA:
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("127.0.0.1"),
+		}}
+	case q.Qtype == dns.TypeA:
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.168.1.1"),
+		}}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func nxdomainHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetRcode(req, dns.RcodeNameError)
+	m.Authoritative = true
+	_ = w.WriteMsg(m)
+}
+
+func TestFindAuthoritative(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(recursiveCacheHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	zone, servers, err := r.FindAuthoritative(context.Background(), "www.sub.caffix.net.")
+	if err != nil {
+		t.Fatalf("failed to find the authoritative servers: %v", err)
+	}
+	if zone != "caffix.net" || len(servers) != 1 || servers[0] != "127.0.0.1:53" {
+		t.Errorf("the authoritative servers were not correct: %s %v", zone, servers)
+	}
+}
+
+func TestAuthoritativeWildcards(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(recursiveCacheHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	auth, authAddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(nxdomainHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = auth.Shutdown() }()
+	_, port, _ := net.SplitHostPort(authAddr)
+
+	resp := QueryMsg("www.caffix.net", dns.TypeA)
+	resp.Answer = []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+		A:   net.ParseIP("192.168.1.1"),
+	}}
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	if !r.WildcardDetected(context.Background(), resp, "caffix.net") {
+		t.Errorf("the recursive detection resolver did not report the wildcard")
+	}
+
+	r2 := NewResolvers()
+	_ = r2.AddResolvers(10, addr)
+	defer r2.Stop()
+
+	r2.SetAuthoritativeWildcards(true)
+	if r2.authPort != "53" {
+		t.Fatalf("failed to enable the authoritative wildcard tests")
+	}
+	r2.authPort = port
+
+	if r2.WildcardDetected(context.Background(), resp, "caffix.net") {
+		t.Errorf("the wildcard was reported although the authoritative servers returned NXDOMAIN")
+	}
+}
+
+func TestAuthQueryTransport(t *testing.T) {
+	s, addr, _, err := RunLocalTCPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	proxyAddr, records := runSOCKSServer(t)
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	var hooked atomic.Int32
+	r.SetPreSendHook(func(msg *dns.Msg) error {
+		hooked.Add(1)
+		return nil
+	})
+	if err := r.UseTor(proxyAddr); err != nil {
+		t.Fatalf("failed to configure the Tor proxy: %v", err)
+	}
+
+	// the server only listens on TCP, so the answers can only arrive through the proxy
+	if ans := r.authQueryAttempts(context.Background(), []string{addr}, "www.caffix.net", dns.TypeA); len(ans) == 0 {
+		t.Fatalf("the query to the authoritative server was not sent through the proxy")
+	}
+	if recs := records(); len(recs) != 1 || recs[0].Dest != addr || recs[0].User != "caffix.net" {
+		t.Errorf("the proxy did not carry the query to the authoritative server: %+v", recs)
+	}
+	if hooked.Load() != 1 {
+		t.Errorf("the pre-send hook was not applied to the authoritative query")
+	}
+}
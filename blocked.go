@@ -0,0 +1,157 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// RcodeBlocked is a special status code used to indicate the resolver blocked the name.
+const RcodeBlocked int = 51
+
+// DefaultBlockAddresses contains the addresses returned by filtering resolvers in place of
+// the answers for blocked names.
+var DefaultBlockAddresses = []string{
+	"0.0.0.0",
+	"::",
+	"146.112.61.104/29",
+}
+
+// BlockOptions specifies the evidence used to classify responses as blocked. The extended
+// DNS errors for blocked, censored, filtered and prohibited names are always used.
+type BlockOptions struct {
+	// Addresses contains the IP addresses and CIDR blocks returned in place of the answers
+	Addresses []string
+	// Filtering contains the addresses of the resolvers known to answer NXDOMAIN for blocked
+	// names, whose NXDOMAIN responses are only trusted when they include the zone SOA record
+	Filtering []string
+}
+
+type blockConfig struct {
+	nets      []*net.IPNet
+	filtering map[string]struct{}
+}
+
+// SetBlockDetection causes the pool to translate the responses for names blocked by the
+// resolvers into RcodeBlocked, which distinguishes them from names that do not exist. The
+// answers of the blocked responses are removed, and the evidence is reported in the
+// response metadata. Providing nil disables the translation.
+func (r *Resolvers) SetBlockDetection(opts *BlockOptions) error {
+	defer r.syncSubPools()
+
+	if opts == nil {
+		r.blocking.Store(nil)
+		return nil
+	}
+
+	cfg := &blockConfig{filtering: make(map[string]struct{})}
+	for _, entry := range opts.Addresses {
+		ipnet := parseIPNet(strings.TrimSpace(entry))
+		if ipnet == nil {
+			return fmt.Errorf("%s is not a valid IP address or CIDR block", entry)
+		}
+		cfg.nets = append(cfg.nets, ipnet)
+	}
+	for _, addr := range opts.Filtering {
+		host := addr
+		if h, _, err := net.SplitHostPort(addr); err == nil {
+			host = h
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("%s is not a valid resolver address", addr)
+		}
+		cfg.filtering[ip.String()] = struct{}{}
+	}
+
+	r.blocking.Store(cfg)
+	return nil
+}
+
+func (r *Resolvers) checkBlocked(req *request, msg *dns.Msg) {
+	cfg := r.blocking.Load()
+	if cfg == nil || msg == nil {
+		return
+	}
+
+	var resolver net.IP
+	if req.Res != nil {
+		resolver = req.Res.address.IP
+	}
+	reason := cfg.classify(msg, resolver)
+	if reason == "" {
+		return
+	}
+
+	msg.Rcode = RcodeBlocked
+	msg.Answer = nil
+	req.Meta.update(func(meta *Response) { meta.Blocked = reason })
+}
+
+// classify returns the evidence that the response was blocked, or an empty string.
+func (cfg *blockConfig) classify(msg *dns.Msg, resolver net.IP) string {
+	if opt := msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if ede, ok := o.(*dns.EDNS0_EDE); ok {
+				switch ede.InfoCode {
+				case dns.ExtendedErrorCodeBlocked, dns.ExtendedErrorCodeCensored,
+					dns.ExtendedErrorCodeFiltered, dns.ExtendedErrorCodeProhibited:
+					return "EDE " + dns.ExtendedErrorCodeToString[ede.InfoCode]
+				}
+			}
+		}
+	}
+
+	if msg.Rcode == dns.RcodeSuccess {
+		// every address in the answer must belong to a block page
+		var last net.IP
+		for _, rr := range msg.Answer {
+			var ip net.IP
+
+			switch t := rr.(type) {
+			case *dns.A:
+				ip = t.A
+			case *dns.AAAA:
+				ip = t.AAAA
+			default:
+				continue
+			}
+			if !cfg.blockAddress(ip) {
+				return ""
+			}
+			last = ip
+		}
+		if last != nil {
+			return "block address " + last.String()
+		}
+		return ""
+	}
+
+	if msg.Rcode == dns.RcodeNameError && resolver != nil {
+		if _, found := cfg.filtering[resolver.String()]; !found {
+			return ""
+		}
+		for _, rr := range msg.Ns {
+			if _, ok := rr.(*dns.SOA); ok {
+				return ""
+			}
+		}
+		return "NXDOMAIN without SOA from filtering resolver " + resolver.String()
+	}
+	return ""
+}
+
+func (cfg *blockConfig) blockAddress(ip net.IP) bool {
+	for _, ipnet := range cfg.nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
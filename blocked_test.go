@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func filteringHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	name := req.Question[0].Name
+	switch name {
+	case "ads.caffix.net.":
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP("0.0.0.0"),
+		}}
+	case "ede.caffix.net.":
+		m.Rcode = dns.RcodeNameError
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		opt := m.IsEdns0()
+		opt.Option = append(opt.Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeFiltered})
+	case "gone.caffix.net.":
+		m.Rcode = dns.RcodeNameError
+	case "missing.caffix.net.":
+		m.Rcode = dns.RcodeNameError
+		m.Ns = []dns.RR{&dns.SOA{
+			Hdr:    dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 0},
+			Ns:     "ns1.caffix.net.",
+			Mbox:   "admin.caffix.net.",
+			Serial: 1,
+		}}
+	default:
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP("192.168.1.1"),
+		}}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestBlockDetection(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(filteringHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	if err := r.SetBlockDetection(&BlockOptions{Addresses: []string{"bad entry"}}); err == nil {
+		t.Errorf("an invalid block address was accepted")
+	}
+	if err := r.SetBlockDetection(&BlockOptions{
+		Addresses: DefaultBlockAddresses,
+		Filtering: []string{addrstr},
+	}); err != nil {
+		t.Fatalf("failed to enable the block detection: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		rcode   int
+		blocked bool
+	}{
+		{"www.caffix.net", dns.RcodeSuccess, false},
+		{"ads.caffix.net", RcodeBlocked, true},
+		{"ede.caffix.net", RcodeBlocked, true},
+		{"gone.caffix.net", RcodeBlocked, true},
+		{"missing.caffix.net", dns.RcodeNameError, false},
+	}
+	for _, c := range cases {
+		resp, err := r.QueryWithMetadata(context.Background(), QueryMsg(c.name, dns.TypeA))
+		if err != nil {
+			t.Errorf("the query for %s failed: %v", c.name, err)
+			continue
+		}
+		if resp.Msg.Rcode != c.rcode {
+			t.Errorf("the response for %s had rcode %d instead of %d", c.name, resp.Msg.Rcode, c.rcode)
+		}
+		if blocked := resp.Blocked != ""; blocked != c.blocked {
+			t.Errorf("the metadata for %s reported the block evidence %q", c.name, resp.Blocked)
+		}
+		if c.blocked && len(resp.Msg.Answer) > 0 {
+			t.Errorf("the answers for the blocked name %s were not removed", c.name)
+		}
+	}
+
+	// the NXDOMAIN responses are trusted when the resolver is not known to filter
+	_ = r.SetBlockDetection(&BlockOptions{Addresses: DefaultBlockAddresses})
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("gone.caffix.net", dns.TypeA)); err != nil || resp.Rcode != dns.RcodeNameError {
+		t.Errorf("the NXDOMAIN response was classified as blocked")
+	}
+}
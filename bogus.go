@@ -0,0 +1,116 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// BogusRanges contains the address ranges that never appear in legitimate public answers,
+// such as the unspecified, loopback, link-local, documentation and multicast ranges.
+var BogusRanges = []string{
+	"0.0.0.0/8",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"192.0.2.0/24",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fe80::/10",
+	"ff00::/8",
+	"2001:db8::/32",
+}
+
+type addrValidation struct {
+	bogus []*net.IPNet
+	allow []*net.IPNet
+}
+
+// SetAddressValidation causes the pool to remove the A and AAAA records containing bogus
+// addresses, which are the addresses in BogusRanges and the address of the resolver that
+// provided the response. The removed records are reported in the response metadata and
+// counted in the statistics of the resolver. The allow entries are IP addresses and CIDR
+// blocks accepted regardless, such as the loopback range for a local test environment.
+func (r *Resolvers) SetAddressValidation(enable bool, allow ...string) error {
+	defer r.syncSubPools()
+
+	if !enable {
+		r.addrCheck.Store(nil)
+		return nil
+	}
+
+	v := new(addrValidation)
+	for _, entry := range BogusRanges {
+		if ipnet := parseIPNet(entry); ipnet != nil {
+			v.bogus = append(v.bogus, ipnet)
+		}
+	}
+	for _, entry := range allow {
+		ipnet := parseIPNet(strings.TrimSpace(entry))
+		if ipnet == nil {
+			return fmt.Errorf("%s is not a valid IP address or CIDR block", entry)
+		}
+		v.allow = append(v.allow, ipnet)
+	}
+
+	r.addrCheck.Store(v)
+	return nil
+}
+
+func (v *addrValidation) isBogus(ip, resolver net.IP) bool {
+	for _, ipnet := range v.allow {
+		if ipnet.Contains(ip) {
+			return false
+		}
+	}
+	if ip.Equal(resolver) {
+		return true
+	}
+	for _, ipnet := range v.bogus {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolvers) checkAddresses(req *request, msg *dns.Msg) {
+	v := r.addrCheck.Load()
+	if v == nil || msg == nil || req.Res == nil {
+		return
+	}
+
+	var kept, bogus []dns.RR
+	for _, rr := range msg.Answer {
+		var ip net.IP
+
+		switch t := rr.(type) {
+		case *dns.A:
+			ip = t.A
+		case *dns.AAAA:
+			ip = t.AAAA
+		}
+		if ip != nil && v.isBogus(ip, req.Res.address.IP) {
+			bogus = append(bogus, rr)
+		} else {
+			kept = append(kept, rr)
+		}
+	}
+	if len(bogus) == 0 {
+		return
+	}
+
+	msg.Answer = kept
+	req.Res.recordBogus(len(bogus))
+	req.Meta.update(func(meta *Response) { meta.Bogus = bogus })
+	r.logf(req.context(), "Bogus addresses removed: Resolver %s: %s", req.Res.address, msg.Question[0].Name)
+}
@@ -0,0 +1,75 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func bogusHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	name := req.Question[0].Name
+	for _, addr := range []string{"0.0.0.0", "127.0.0.1", "192.168.1.1", "240.1.2.3"} {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP(addr),
+		})
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestAddressValidation(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(bogusHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	if err := r.SetAddressValidation(true, "bad entry"); err == nil {
+		t.Errorf("an invalid allow list entry was accepted")
+	}
+	if err := r.SetAddressValidation(true); err != nil {
+		t.Fatalf("failed to enable the address validation: %v", err)
+	}
+
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if ans := resp.Msg.Answer; len(ans) != 1 || ans[0].(*dns.A).A.String() != "192.168.1.1" {
+		t.Errorf("the bogus addresses were not removed: %v", ans)
+	}
+	if len(resp.Bogus) != 3 {
+		t.Errorf("the metadata reported %d bogus records instead of 3", len(resp.Bogus))
+	}
+	if stats := r.Stats(); len(stats) != 1 || stats[0].BogusAnswers != 3 {
+		t.Errorf("the bogus answers were not counted for the resolver")
+	}
+
+	// the allow list overrides the ranges and the address of the resolver
+	_ = r.SetAddressValidation(true, "127.0.0.0/8")
+	resp, err = r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Msg.Answer) != 2 {
+		t.Errorf("the allowed address was removed")
+	}
+
+	_ = r.SetAddressValidation(false)
+	resp, err = r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Msg.Answer) != 4 || len(resp.Bogus) != 0 {
+		t.Errorf("the answers were modified after disabling the validation")
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+// DefaultMaxWildcards is the number of wildcard test results retained by the pool.
+const DefaultMaxWildcards = 100000
+
+// xchgShrinkSize is the number of outstanding exchanges after which the empty map is replaced,
+// since the memory of a map is not released when the entries are deleted.
+const xchgShrinkSize = 4096
+
+// MapStats contains the sizes of the data structures kept by the pool for long-running scans.
+type MapStats struct {
+	// Wildcards is the number of subdomains with wildcard test results
+	Wildcards    int
+	MaxWildcards int
+	// WildcardEvictions counts the least recently used results removed to respect MaxWildcards
+	WildcardEvictions uint64
+	// Exchanges is the number of queries awaiting responses from the resolvers
+	Exchanges int
+}
+
+// SetMaxWildcards sets the number of wildcard test results retained by the pool. The least
+// recently used results are removed beyond the limit, and the subdomains are tested again when
+// they are used. Zero removes the limit.
+func (r *Resolvers) SetMaxWildcards(n int) {
+	r.Lock()
+	defer r.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	r.maxWildcards = n
+	r.evictWildcards()
+}
+
+// MapStats returns the sizes of the data structures kept by the pool.
+func (r *Resolvers) MapStats() *MapStats {
+	r.Lock()
+	s := &MapStats{
+		Wildcards:    len(r.wildcards),
+		MaxWildcards: r.maxWildcards,
+	}
+	r.Unlock()
+
+	s.WildcardEvictions = r.wildEvictions.Load()
+	// the detection resolver is also added to the pool
+	for _, res := range r.pool.AllResolvers() {
+		s.Exchanges += res.xchgs.len()
+	}
+	return s
+}
+
+// lookupWildcard returns the result for the subdomain and marks it as recently used.
+// The caller must hold the pool lock.
+func (r *Resolvers) lookupWildcard(sub string) (*wildcard, bool) {
+	w, found := r.wildcards[sub]
+	if found && w.elem != nil {
+		r.wildLRU.MoveToFront(w.elem)
+	}
+	return w, found
+}
+
+// storeWildcard adds the result for the subdomain and removes the least recently used results
+// beyond the limit. The caller must hold the pool lock.
+func (r *Resolvers) storeWildcard(sub string, w *wildcard) {
+	if old, found := r.wildcards[sub]; found && old.elem != nil {
+		r.wildLRU.Remove(old.elem)
+	}
+
+	w.elem = r.wildLRU.PushFront(sub)
+	r.wildcards[sub] = w
+	r.evictWildcards()
+}
+
+// evictWildcards removes the least recently used results beyond the limit.
+// The caller must hold the pool lock.
+func (r *Resolvers) evictWildcards() {
+	for r.maxWildcards > 0 && len(r.wildcards) > r.maxWildcards {
+		back := r.wildLRU.Back()
+		if back == nil {
+			return
+		}
+
+		r.wildLRU.Remove(back)
+		delete(r.wildcards, back.Value.(string))
+		r.wildEvictions.Add(1)
+	}
+}
+
+func (r *xchgMgr) len() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return len(r.xchgs)
+}
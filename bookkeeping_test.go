@@ -0,0 +1,59 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestWildcardEviction(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetMaxWildcards(2)
+	r.Lock()
+	r.storeWildcard("caffix.net", &wildcard{})
+	r.storeWildcard("owasp.org", &wildcard{})
+	// the use of caffix.net leaves owasp.org as the least recently used
+	_, _ = r.lookupWildcard("caffix.net")
+	r.storeWildcard("example.com", &wildcard{Detected: true})
+	_, caffix := r.wildcards["caffix.net"]
+	_, owasp := r.wildcards["owasp.org"]
+	r.Unlock()
+
+	if !caffix || owasp {
+		t.Errorf("the least recently used result was not evicted")
+	}
+	if s := r.MapStats(); s.Wildcards != 2 || s.MaxWildcards != 2 || s.WildcardEvictions != 1 {
+		t.Errorf("the map stats were not reported correctly: %+v", s)
+	}
+
+	r.SetMaxWildcards(1)
+	if s := r.MapStats(); s.Wildcards != 1 || s.WildcardEvictions != 2 {
+		t.Errorf("lowering the limit did not evict the results: %+v", s)
+	}
+}
+
+func TestXchgShrink(t *testing.T) {
+	xchg := newXchgMgr(DefaultTimeout, realClock{})
+
+	for i := 0; i <= xchgShrinkSize; i++ {
+		msg := QueryMsg(fmt.Sprintf("%d.caffix.net", i), dns.TypeA)
+		if err := xchg.add(&request{Msg: msg}); err != nil {
+			t.Fatalf("failed to add the request: %v", err)
+		}
+	}
+	if n := xchg.len(); n != xchgShrinkSize+1 {
+		t.Errorf("%d exchanges were tracked instead of %d", n, xchgShrinkSize+1)
+	}
+
+	_ = xchg.removeAll()
+	if xchg.len() != 0 || xchg.peak != 0 {
+		t.Errorf("the map was not replaced once the exchanges were removed")
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// DefaultBulkConcurrency is the number of resolvers validated at once by AddResolversBulk.
+const DefaultBulkConcurrency = 100
+
+// ResolverSpec describes a resolver provided to AddResolversBulk.
+type ResolverSpec struct {
+	Address string
+	QPS     int
+}
+
+// BulkOptions contains the settings for AddResolversBulk.
+type BulkOptions struct {
+	// Concurrency limits the resolvers processed at once, and defaults to DefaultBulkConcurrency
+	Concurrency int
+	// Validate sends the health probe used by Warmup before adding each resolver
+	Validate bool
+	// Progress is called after each resolver is processed, one call at a time
+	Progress func(done, total int, addr string, err error)
+}
+
+// AddResolversBulk concurrently validates and adds the resolvers to the pool, which is much
+// faster than adding large lists serially when the health checks are enabled. The failures do
+// not stop the remaining resolvers from being added, and are returned keyed by the address
+// provided in the spec. The opts parameter can be nil to add the resolvers without validation.
+func (r *Resolvers) AddResolversBulk(ctx context.Context, specs []ResolverSpec, opts *BulkOptions) map[string]error {
+	if opts == nil {
+		opts = new(BulkOptions)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBulkConcurrency
+	}
+
+	var done int
+	var lock sync.Mutex
+	failures := make(map[string]error)
+	finish := func(addr string, err error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		done++
+		if err != nil {
+			failures[addr] = err
+		}
+		if opts.Progress != nil {
+			opts.Progress(done, len(specs), addr, err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	seen := make(map[string]struct{})
+	for _, spec := range specs {
+		addr, err := specAddress(spec)
+		if err == nil {
+			if _, found := seen[addr.IP.String()]; found {
+				err = fmt.Errorf("the resolver %s was provided more than once", spec.Address)
+			}
+			seen[addr.IP.String()] = struct{}{}
+		}
+		if err != nil {
+			finish(spec.Address, err)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			finish(spec.Address, ctx.Err())
+			continue
+		case sem <- struct{}{}:
+		}
+		// the select picks randomly when the context expired while waiting on the semaphore
+		if err := ctx.Err(); err != nil {
+			<-sem
+			finish(spec.Address, err)
+			continue
+		}
+
+		wg.Add(1)
+		go func(spec ResolverSpec, addr *net.UDPAddr) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			finish(spec.Address, r.addSpec(ctx, spec.QPS, addr, opts.Validate))
+		}(spec, addr)
+	}
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+	return failures
+}
+
+func specAddress(spec ResolverSpec) (*net.UDPAddr, error) {
+	if spec.QPS <= 0 {
+		return nil, errors.New("failed to provide a maximum number of queries per second greater than zero")
+	}
+
+	addr := spec.Address
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		// add the default port number to the IP address
+		addr = net.JoinHostPort(addr, "53")
+	}
+	uaddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid resolver address: %v", spec.Address, err)
+	}
+	return uaddr, nil
+}
+
+func (r *Resolvers) addSpec(ctx context.Context, qps int, addr *net.UDPAddr, validate bool) error {
+	if r.pool.LookupResolver(addr.IP.String()) != nil {
+		return fmt.Errorf("the resolver %s is already in the pool", addr.IP)
+	}
+
+	if validate {
+		probe := r.healthProbe()
+		resp, err := r.directExchange(ctx, probe.Msg(), addr.String())
+		if err != nil {
+			resp = nil
+		}
+		if err := probe.Check(addr.String(), resp); err != nil {
+			return err
+		}
+	}
+	return r.AddResolvers(qps, addr.String())
+}
@@ -0,0 +1,139 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestAddResolversBulk(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(refusedHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	// the third resolver never responds
+	pc, err := net.ListenPacket("udp", "127.0.0.3:0")
+	if err != nil {
+		t.Skipf("unable to listen on the third address: %v", err)
+	}
+	defer pc.Close()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	specs := []ResolverSpec{
+		{Address: addr1, QPS: 10},
+		{Address: addr2, QPS: 10},
+		{Address: pc.LocalAddr().String(), QPS: 10},
+		{Address: "300.300.300.300", QPS: 10},
+		{Address: "192.168.1.1", QPS: 0},
+		{Address: addr1, QPS: 10},
+	}
+
+	var calls, last int
+	failures := r.AddResolversBulk(context.Background(), specs, &BulkOptions{
+		Concurrency: 2,
+		Validate:    true,
+		Progress: func(done, total int, addr string, err error) {
+			calls++
+			last = done
+			if total != len(specs) {
+				t.Errorf("the progress reported %d resolvers instead of %d", total, len(specs))
+			}
+		},
+	})
+	if calls != len(specs) || last != len(specs) {
+		t.Errorf("the progress was reported %d times instead of %d", calls, len(specs))
+	}
+	if len(failures) != 5 {
+		t.Errorf("%d failures were returned instead of 5: %v", len(failures), failures)
+	}
+	if r.Len() != 1 || r.pool.LookupResolver("127.0.0.1") == nil {
+		t.Errorf("the valid resolver was not added to the pool")
+	}
+
+	// the resolvers are added without validation and the existing resolver is reported
+	failures = r.AddResolversBulk(context.Background(), []ResolverSpec{
+		{Address: addr1, QPS: 10},
+		{Address: addr2, QPS: 10},
+	}, nil)
+	if len(failures) != 1 || failures[addr1] == nil {
+		t.Errorf("the resolver already in the pool was not reported: %v", failures)
+	}
+	if r.Len() != 2 {
+		t.Errorf("the resolvers were not added without validation")
+	}
+}
+
+func TestAddResolversBulkCanceled(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var specs []ResolverSpec
+	for _, addr := range []string{"192.168.1.1", "192.168.1.2", "192.168.1.3", "192.168.1.4"} {
+		specs = append(specs, ResolverSpec{Address: addr, QPS: 10})
+	}
+	// the semaphore never blocks, so the expired context must be checked after it is acquired
+	failures := r.AddResolversBulk(ctx, specs, &BulkOptions{Concurrency: len(specs)})
+	if len(failures) != len(specs) {
+		t.Errorf("%d failures were returned instead of %d: %v", len(failures), len(specs), failures)
+	}
+	for addr, err := range failures {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("the resolver %s failed with the wrong error: %v", addr, err)
+		}
+	}
+	if r.Len() != 0 {
+		t.Errorf("the resolvers were added after the context expired")
+	}
+}
+
+func TestAddResolversBulkTransport(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	var hooked atomic.Int32
+	r.SetPreSendHook(func(msg *dns.Msg) error {
+		hooked.Add(1)
+		return nil
+	})
+
+	failures := r.AddResolversBulk(context.Background(), []ResolverSpec{{Address: addr, QPS: 10}}, &BulkOptions{Validate: true})
+	if len(failures) != 0 || r.Len() != 1 {
+		t.Fatalf("the valid resolver was not added to the pool: %v", failures)
+	}
+	if hooked.Load() != 1 {
+		t.Errorf("the health probe was not sent using the transport configured for the pool")
+	}
+}
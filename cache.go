@@ -0,0 +1,300 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	cacheCheckInterval = 250 * time.Millisecond
+	minPrefetchWindow  = time.Second
+	prefetchRatio      = 10
+)
+
+// StaleAnswerTTL is the TTL in seconds of the expired answers served by the cache, which is
+// the value recommended by RFC 8767.
+const StaleAnswerTTL = 30
+
+type cacheEntry struct {
+	Msg      *dns.Msg
+	Fetched  time.Time
+	Expires  time.Time
+	LastUsed time.Time
+	Pending  bool
+	Size     int64
+	elem     *list.Element
+}
+
+// Cache stores the responses obtained through the pool until the TTLs expire. Entries that
+// were used since the last fetch are re-queried slightly before expiring, which keeps the
+// cache hot for proxy and server deployments.
+type Cache struct {
+	sync.Mutex
+	done    chan struct{}
+	pool    *Resolvers
+	entries map[monitorKey]*cacheEntry
+	lru     list.List
+	stale   time.Duration
+	bytes   int64
+	limit   int64
+}
+
+// NewCache returns an active Cache that uses the provided pool to resolve the names.
+func NewCache(pool *Resolvers) *Cache {
+	c := &Cache{
+		done:    make(chan struct{}, 1),
+		pool:    pool,
+		entries: make(map[monitorKey]*cacheEntry),
+	}
+
+	go c.refreshes()
+	return c
+}
+
+// Stop will release the Cache resources.
+func (c *Cache) Stop() {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+// SetServeStale causes the cache to retain the entries for up to max after they expire, and
+// serve them as described in RFC 8767 when the pool fails to obtain a response or receives
+// SERVFAIL. The stale answers have the TTLs set to StaleAnswerTTL, contain the Stale Answer
+// extended DNS error when the response has an OPT record, and set the Stale field of the
+// metadata. Providing zero disables serving the expired entries.
+func (c *Cache) SetServeStale(max time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	if max < 0 {
+		max = 0
+	}
+	c.stale = max
+}
+
+// Len returns the number of entries in the cache, including the expired entries retained
+// for serving stale answers.
+func (c *Cache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.entries)
+}
+
+// Lookup returns the cached response for the name and type with the TTLs reduced by the time
+// spent in the cache, or resolves the name using the pool when a fresh response is not cached.
+// The cache stores a copy of each response and returns a new copy of the entry for each lookup,
+// so the callers can modify the messages without affecting each other.
+func (c *Cache) Lookup(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	resp, _, err := c.lookup(ctx, name, qtype)
+	return resp, err
+}
+
+// lookup also reports whether the response was obtained from the cache.
+func (c *Cache) lookup(ctx context.Context, name string, qtype uint16) (*dns.Msg, bool, error) {
+	key := monitorKey{Name: strings.ToLower(RemoveLastDot(name)), Qtype: qtype}
+
+	if msg := c.get(key); msg != nil {
+		return msg, true, nil
+	}
+
+	resp, err := c.pool.QueryBlocking(ctx, QueryMsg(key.Name, qtype))
+	if err != nil || resp.Rcode == RcodeNoResponse || resp.Rcode == dns.RcodeServerFailure {
+		if msg := c.getStale(key); msg != nil {
+			metadataFromContext(ctx).update(func(meta *Response) { meta.Stale = true })
+			return msg, true, nil
+		}
+	}
+	if err != nil {
+		return resp, false, err
+	}
+	if resp.Rcode == RcodeNoResponse {
+		return resp, false, errors.New("the query failed to obtain a response")
+	}
+
+	c.put(key, resp)
+	return resp, false, nil
+}
+
+func (c *Cache) get(key monitorKey) *dns.Msg {
+	c.Lock()
+	defer c.Unlock()
+
+	e, found := c.entries[key]
+	if !found {
+		return nil
+	}
+
+	now := time.Now()
+	if !now.Before(e.Expires) {
+		if !now.Before(e.Expires.Add(c.stale)) {
+			c.deleteEntry(key)
+		}
+		return nil
+	}
+
+	e.LastUsed = now
+	c.lru.MoveToFront(e.elem)
+	msg := e.Msg.Copy()
+	elapsed := uint32(now.Sub(e.Fetched).Seconds())
+	eachRR(msg, func(hdr *dns.RR_Header) {
+		if hdr.Ttl > elapsed {
+			hdr.Ttl -= elapsed
+		} else {
+			hdr.Ttl = 0
+		}
+	})
+	return msg
+}
+
+// getStale returns the expired entry within the maximum staleness with the TTLs set to
+// StaleAnswerTTL.
+func (c *Cache) getStale(key monitorKey) *dns.Msg {
+	c.Lock()
+	defer c.Unlock()
+
+	e, found := c.entries[key]
+	if !found || c.stale == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(e.Expires) || !now.Before(e.Expires.Add(c.stale)) {
+		return nil
+	}
+
+	msg := e.Msg.Copy()
+	eachRR(msg, func(hdr *dns.RR_Header) {
+		hdr.Ttl = StaleAnswerTTL
+	})
+	if opt := msg.IsEdns0(); opt != nil {
+		opt.Option = append(opt.Option, &dns.EDNS0_EDE{InfoCode: dns.ExtendedErrorCodeStaleAnswer})
+	}
+	return msg
+}
+
+func (c *Cache) put(key monitorKey, resp *dns.Msg) {
+	ttl, ok := cacheTTL(resp)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	c.setEntry(key, &cacheEntry{
+		Msg:      resp.Copy(),
+		Fetched:  now,
+		Expires:  now.Add(time.Duration(ttl) * time.Second),
+		LastUsed: now,
+	})
+}
+
+func (c *Cache) refreshes() {
+	t := time.NewTicker(cacheCheckInterval)
+	defer t.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+
+		for _, key := range c.prefetchKeys(time.Now()) {
+			go c.refresh(ctx, key)
+		}
+	}
+}
+
+// prefetchKeys removes the entries beyond the maximum staleness and returns the keys of the entries that must be
+// re-queried, since they are about to expire and were used since the last fetch.
+func (c *Cache) prefetchKeys(now time.Time) []monitorKey {
+	c.Lock()
+	defer c.Unlock()
+
+	var keys []monitorKey
+	for key, e := range c.entries {
+		if !now.Before(e.Expires) {
+			if !now.Before(e.Expires.Add(c.stale)) {
+				c.deleteEntry(key)
+			}
+			continue
+		}
+		if e.Pending || !e.LastUsed.After(e.Fetched) {
+			continue
+		}
+
+		window := e.Expires.Sub(e.Fetched) / prefetchRatio
+		if window < minPrefetchWindow {
+			window = minPrefetchWindow
+		}
+		if now.After(e.Expires.Add(-window)) {
+			e.Pending = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (c *Cache) refresh(ctx context.Context, key monitorKey) {
+	resp, err := c.pool.QueryBlocking(ctx, QueryMsg(key.Name, key.Qtype))
+	if err == nil && resp.Rcode != RcodeNoResponse {
+		c.put(key, resp)
+		return
+	}
+
+	c.Lock()
+	if e, found := c.entries[key]; found {
+		e.Pending = false
+	}
+	c.Unlock()
+}
+
+// cacheTTL returns the number of seconds the response can be cached. Negative responses use
+// the TTL from the SOA record as described in RFC 2308.
+func cacheTTL(resp *dns.Msg) (uint32, bool) {
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return 0, false
+	}
+
+	var ttl uint32
+	var found bool
+	min := func(t uint32) {
+		if !found || t < ttl {
+			ttl = t
+			found = true
+		}
+	}
+
+	if resp.Rcode == dns.RcodeSuccess && len(resp.Answer) > 0 {
+		for _, rr := range resp.Answer {
+			min(rr.Header().Ttl)
+		}
+		return ttl, found
+	}
+	for _, rr := range resp.Ns {
+		if soa, ok := rr.(*dns.SOA); ok {
+			min(soa.Hdr.Ttl)
+			min(soa.Minttl)
+		}
+	}
+	return ttl, found
+}
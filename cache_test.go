@@ -0,0 +1,205 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestCache(t *testing.T) {
+	var queries atomic.Int32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries.Add(1)
+			_ = w.WriteMsg(ttlReply(req, 2))
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	c := NewCache(r)
+	defer c.Stop()
+
+	for i := 0; i < 3; i++ {
+		if resp, err := c.Lookup(context.Background(), "caffix.net", dns.TypeA); err != nil || len(resp.Answer) == 0 {
+			t.Fatalf("the lookup failed: %v", err)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("the cache sent %d queries instead of one", n)
+	}
+	// the entry was used, so it should be refreshed before expiring
+	time.Sleep(1500 * time.Millisecond)
+	if n := queries.Load(); n != 2 {
+		t.Errorf("the cache sent %d queries instead of prefetching the entry", n)
+	}
+	if resp, err := c.Lookup(context.Background(), "caffix.net", dns.TypeA); err != nil || resp.Answer[0].Header().Ttl == 0 {
+		t.Errorf("the refreshed entry was not returned from the cache")
+	}
+	if n := queries.Load(); n != 2 {
+		t.Errorf("the lookup after the prefetch sent another query")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := &Cache{entries: make(map[monitorKey]*cacheEntry)}
+	key := monitorKey{Name: "caffix.net", Qtype: dns.TypeA}
+
+	c.put(key, ttlReply(QueryMsg("caffix.net", dns.TypeA), 10))
+	if msg := c.get(key); msg == nil {
+		t.Fatalf("the entry was not returned from the cache")
+	}
+
+	e := c.entries[key]
+	e.Fetched = e.Fetched.Add(-5 * time.Second)
+	if msg := c.get(key); msg == nil || msg.Answer[0].Header().Ttl > 5 {
+		t.Errorf("the TTL was not reduced by the time spent in the cache")
+	}
+
+	e.Expires = time.Now().Add(-time.Second)
+	if msg := c.get(key); msg != nil || c.Len() != 0 {
+		t.Errorf("the expired entry was returned from the cache")
+	}
+
+	c.put(key, ttlReply(QueryMsg("caffix.net", dns.TypeA), 0))
+	if c.Len() != 0 {
+		t.Errorf("the response with a zero TTL was cached")
+	}
+}
+
+func TestCacheCopies(t *testing.T) {
+	c := &Cache{entries: make(map[monitorKey]*cacheEntry)}
+	key := monitorKey{Name: "caffix.net", Qtype: dns.TypeA}
+
+	resp := ttlReply(QueryMsg("caffix.net", dns.TypeA), 10)
+	c.put(key, resp)
+	resp.Answer = nil
+
+	first := c.get(key)
+	if first == nil || len(first.Answer) == 0 {
+		t.Fatalf("modifying the response changed the cache entry")
+	}
+	first.Answer[0].(*dns.A).A = net.ParseIP("10.0.0.1")
+
+	if second := c.get(key); second == nil || second.Answer[0].(*dns.A).A.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("modifying the returned message changed the cache entry")
+	}
+}
+
+func TestCacheServeStale(t *testing.T) {
+	var failing atomic.Bool
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			if failing.Load() {
+				m := new(dns.Msg)
+				m.SetRcode(req, dns.RcodeServerFailure)
+				_ = w.WriteMsg(m)
+				return
+			}
+			m := ttlReply(req, 300)
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	c := NewCache(r)
+	defer c.Stop()
+
+	key := monitorKey{Name: "caffix.net", Qtype: dns.TypeA}
+	if _, err := c.Lookup(context.Background(), "caffix.net", dns.TypeA); err != nil {
+		t.Fatalf("the lookup failed: %v", err)
+	}
+	// expire the entry while the upstream is failing
+	failing.Store(true)
+	c.Lock()
+	c.entries[key].Expires = time.Now().Add(-time.Minute)
+	c.Unlock()
+
+	c.SetServeStale(time.Hour)
+	meta, err := c.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || meta.Msg.Rcode != dns.RcodeSuccess || len(meta.Msg.Answer) == 0 {
+		t.Fatalf("the stale answer was not served: %v", err)
+	}
+	if !meta.Stale || !meta.CacheHit {
+		t.Errorf("the response was not marked as stale")
+	}
+	if ttl := meta.Msg.Answer[0].Header().Ttl; ttl != StaleAnswerTTL {
+		t.Errorf("the stale answer had a TTL of %d instead of %d", ttl, StaleAnswerTTL)
+	}
+	var stale bool
+	if opt := meta.Msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if ede, ok := o.(*dns.EDNS0_EDE); ok && ede.InfoCode == dns.ExtendedErrorCodeStaleAnswer {
+				stale = true
+			}
+		}
+	}
+	if !stale {
+		t.Errorf("the stale answer did not contain the extended DNS error")
+	}
+
+	// the entry is removed after the maximum staleness
+	c.SetServeStale(30 * time.Second)
+	if resp, err := c.Lookup(context.Background(), "caffix.net", dns.TypeA); err != nil || resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("the entry beyond the maximum staleness was served")
+	}
+	if c.Len() != 0 {
+		t.Errorf("the entry beyond the maximum staleness was retained")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	msg := ttlReply(QueryMsg("caffix.net", dns.TypeA), 300)
+	msg.Answer = append(msg.Answer, ttlReply(QueryMsg("caffix.net", dns.TypeA), 60).Answer...)
+	if ttl, ok := cacheTTL(msg); !ok || ttl != 60 {
+		t.Errorf("the TTL was %d instead of the minimum", ttl)
+	}
+
+	nx := new(dns.Msg)
+	nx.SetRcode(QueryMsg("caffix.net", dns.TypeA), dns.RcodeNameError)
+	nx.Ns = []dns.RR{&dns.SOA{
+		Hdr:    dns.RR_Header{Name: "net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 900},
+		Minttl: 120,
+	}}
+	if ttl, ok := cacheTTL(nx); !ok || ttl != 120 {
+		t.Errorf("the negative caching TTL was %d instead of 120", ttl)
+	}
+
+	nx.Rcode = dns.RcodeServerFailure
+	if _, ok := cacheTTL(nx); ok {
+		t.Errorf("the server failure was considered cacheable")
+	}
+}
+
+func ttlReply(req *dns.Msg, ttl uint32) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	m.Answer = []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+		A:   net.ParseIP("192.168.1.1"),
+	}}
+	return m
+}
@@ -0,0 +1,107 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Canonicalize returns a copy of the message with lowercased names, normalized whitespace
+// in TXT records and the records of each section sorted, so equivalent responses are equal.
+func Canonicalize(msg *dns.Msg) *dns.Msg {
+	if msg == nil {
+		return nil
+	}
+
+	m := msg.Copy()
+	for i := range m.Question {
+		m.Question[i].Name = strings.ToLower(m.Question[i].Name)
+	}
+	for _, section := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		for _, rr := range section {
+			canonicalRR(rr)
+		}
+		sort.SliceStable(section, func(i, j int) bool {
+			return rrKey(section[i]) < rrKey(section[j])
+		})
+	}
+	return m
+}
+
+// CanonicalAnswers returns the sorted and deduplicated records of the answer section in
+// canonical form, without the TTLs, which makes the results suitable for comparisons.
+func CanonicalAnswers(msg *dns.Msg) []string {
+	if msg == nil {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	var records []string
+	for _, rr := range Canonicalize(msg).Answer {
+		if key := rrKey(rr); key != "" {
+			if _, found := seen[key]; !found {
+				seen[key] = struct{}{}
+				records = append(records, key)
+			}
+		}
+	}
+	sort.Strings(records)
+	return records
+}
+
+// AnswerHash returns a stable hash of the canonical answer set, which does not change when
+// only the TTLs or the order of the records differ.
+func AnswerHash(msg *dns.Msg) string {
+	sum := sha256.Sum256([]byte(strings.Join(CanonicalAnswers(msg), "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func canonicalRR(rr dns.RR) {
+	hdr := rr.Header()
+	hdr.Name = strings.ToLower(hdr.Name)
+
+	switch t := rr.(type) {
+	case *dns.CNAME:
+		t.Target = strings.ToLower(t.Target)
+	case *dns.DNAME:
+		t.Target = strings.ToLower(t.Target)
+	case *dns.NS:
+		t.Ns = strings.ToLower(t.Ns)
+	case *dns.PTR:
+		t.Ptr = strings.ToLower(t.Ptr)
+	case *dns.MX:
+		t.Mx = strings.ToLower(t.Mx)
+	case *dns.SRV:
+		t.Target = strings.ToLower(t.Target)
+	case *dns.SOA:
+		t.Ns = strings.ToLower(t.Ns)
+		t.Mbox = strings.ToLower(t.Mbox)
+	case *dns.TXT:
+		for i, s := range t.Txt {
+			t.Txt[i] = strings.Join(strings.Fields(s), " ")
+		}
+	}
+}
+
+// rrKey returns the owner name, class, type and data of the record without the TTL.
+func rrKey(rr dns.RR) string {
+	hdr := rr.Header()
+	if hdr.Rrtype == dns.TypeOPT {
+		return ""
+	}
+
+	data := strings.TrimPrefix(rr.String(), hdr.String())
+	return strings.Join([]string{
+		hdr.Name,
+		dns.ClassToString[hdr.Class],
+		dns.TypeToString[hdr.Rrtype],
+		data,
+	}, " ")
+}
@@ -0,0 +1,76 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCanonicalize(t *testing.T) {
+	msg := canonicalMsg(t, []string{
+		"WWW.Caffix.NET. 300 IN CNAME Web.Caffix.NET.",
+		"web.caffix.net. 60 IN TXT \"v=spf1   include:caffix.net  -all\"",
+		"web.caffix.net. 60 IN A 192.168.1.2",
+		"web.caffix.net. 60 IN A 192.168.1.1",
+	})
+
+	c := Canonicalize(msg)
+	if c.Question[0].Name != "www.caffix.net." {
+		t.Errorf("the question name was not lowercased: %s", c.Question[0].Name)
+	}
+	if cname, ok := c.Answer[3].(*dns.CNAME); !ok || cname.Hdr.Name != "www.caffix.net." || cname.Target != "web.caffix.net." {
+		t.Errorf("the CNAME record was not lowercased or sorted: %v", c.Answer[3])
+	}
+	if a, ok := c.Answer[0].(*dns.A); !ok || a.A.String() != "192.168.1.1" {
+		t.Errorf("the A records were not sorted: %v", c.Answer)
+	}
+	if txt, ok := c.Answer[2].(*dns.TXT); !ok || txt.Txt[0] != "v=spf1 include:caffix.net -all" {
+		t.Errorf("the whitespace in the TXT record was not normalized: %v", c.Answer[2])
+	}
+	if msg.Answer[0].Header().Name != "WWW.Caffix.NET." {
+		t.Errorf("the original message was modified")
+	}
+}
+
+func TestAnswerHash(t *testing.T) {
+	first := canonicalMsg(t, []string{
+		"caffix.net. 300 IN A 192.168.1.1",
+		"caffix.net. 300 IN A 192.168.1.2",
+	})
+	second := canonicalMsg(t, []string{
+		"CAFFIX.net. 60 IN A 192.168.1.2",
+		"caffix.net. 60 IN A 192.168.1.1",
+		"caffix.net. 60 IN A 192.168.1.1",
+	})
+	changed := canonicalMsg(t, []string{
+		"caffix.net. 300 IN A 192.168.1.1",
+		"caffix.net. 300 IN A 192.168.1.3",
+	})
+
+	if AnswerHash(first) != AnswerHash(second) {
+		t.Errorf("equivalent answer sets returned different hashes")
+	}
+	if AnswerHash(first) == AnswerHash(changed) {
+		t.Errorf("different answer sets returned the same hash")
+	}
+	if records := CanonicalAnswers(second); len(records) != 2 || records[0] != "caffix.net. IN A 192.168.1.1" {
+		t.Errorf("the canonical answers were not sorted and deduplicated: %v", records)
+	}
+}
+
+func canonicalMsg(t *testing.T, records []string) *dns.Msg {
+	msg := QueryMsg("WWW.Caffix.NET", dns.TypeA)
+
+	for _, s := range records {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("failed to parse the record %s: %v", s, err)
+		}
+		msg.Answer = append(msg.Answer, rr)
+	}
+	return msg
+}
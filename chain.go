@@ -0,0 +1,47 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// FallbackChain is the ordered list of record types attempted until one of them returns answers.
+type FallbackChain []uint16
+
+// Fallback chains for callers that are only interested in connectivity.
+var (
+	ChainAThenAAAA      = FallbackChain{dns.TypeA, dns.TypeAAAA}
+	ChainAAAAThenA      = FallbackChain{dns.TypeAAAA, dns.TypeA}
+	ChainHTTPSThenAddrs = FallbackChain{dns.TypeHTTPS, dns.TypeA, dns.TypeAAAA}
+)
+
+// LookupChain attempts the record types of the chain in order and returns the first response
+// containing answers, along with the record type that was used. The search list of the pool
+// is applied to each attempt, and the opts parameter can be nil to use the pool settings.
+func (r *Resolvers) LookupChain(ctx context.Context, name string, chain FallbackChain, opts *LookupOptions) (*dns.Msg, uint16, error) {
+	if len(chain) == 0 {
+		return nil, 0, errors.New("the fallback chain does not contain any record types")
+	}
+
+	var err error
+	for _, qtype := range chain {
+		select {
+		case <-ctx.Done():
+			return nil, 0, fmt.Errorf("lookup %s: the context expired", name)
+		default:
+		}
+
+		var resp *dns.Msg
+		if resp, _, err = r.lookup(ctx, name, qtype, opts); err == nil {
+			return resp, qtype, nil
+		}
+	}
+	return nil, 0, err
+}
@@ -0,0 +1,37 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupChain(t *testing.T) {
+	r, stop := lookupPool(t)
+	defer stop()
+
+	// the test server does not return HTTPS records, so the chain falls back to the A records
+	resp, qtype, err := r.LookupChain(context.Background(), "www.caffix.net", ChainHTTPSThenAddrs, nil)
+	if err != nil || qtype != dns.TypeA {
+		t.Fatalf("the chain did not fall back to the A records: %v", err)
+	}
+	if ips := answerIPs(resp, dns.TypeA); len(ips) != 1 || ips[0].String() != "192.168.1.1" {
+		t.Errorf("the chain returned the wrong answers")
+	}
+
+	if _, qtype, err := r.LookupChain(context.Background(), "www.caffix.net", ChainAAAAThenA, nil); err != nil || qtype != dns.TypeAAAA {
+		t.Errorf("the chain did not stop at the first record type with answers: %v", err)
+	}
+	if _, _, err := r.LookupChain(context.Background(), "www.owasp.org", ChainAThenAAAA, nil); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the chain did not fail for the missing name: %v", err)
+	}
+	if _, _, err := r.LookupChain(context.Background(), "www.caffix.net", nil, nil); err == nil {
+		t.Errorf("the empty chain did not return an error")
+	}
+}
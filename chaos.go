@@ -0,0 +1,318 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ChaosOptions describes the network pathology injected by a ChaosProxy. The probabilities
+// are applied independently to each response and range from zero to one.
+type ChaosOptions struct {
+	// Loss is the probability of dropping the response
+	Loss float64
+	// Duplicate is the probability of sending the response twice
+	Duplicate float64
+	// Truncate is the probability of replacing the response with an empty truncated response
+	Truncate float64
+	// Reorder is the probability of holding the response for ReorderDelay, so responses
+	// to later queries overtake it
+	Reorder      float64
+	ReorderDelay time.Duration
+	// Latency returns the delay added to each response, such as UniformLatency or NormalLatency
+	Latency func() time.Duration
+	// Seed makes the injected faults repeatable when not zero
+	Seed int64
+}
+
+// ChaosStats contains the counts of the faults injected by a ChaosProxy.
+type ChaosStats struct {
+	Queries    uint64
+	Dropped    uint64
+	Duplicated uint64
+	Truncated  uint64
+	Reordered  uint64
+}
+
+// ChaosProxy is a test transport that forwards DNS queries to an upstream server and injects
+// packet loss, duplication, truncation, reordering and latency into the UDP responses. TCP
+// connections on the same port are forwarded without faults, so truncated responses can be
+// retried. Adding the address of the proxy to a pool validates the retry and timeout
+// policies against realistic network pathology.
+type ChaosProxy struct {
+	done     chan struct{}
+	upstream string
+	opts     ChaosOptions
+	pc       net.PacketConn
+	l        net.Listener
+	rlock    sync.Mutex
+	rand     *rand.Rand
+	wg       sync.WaitGroup
+	queries  atomic.Uint64
+	dropped  atomic.Uint64
+	dups     atomic.Uint64
+	truncs   atomic.Uint64
+	reorders atomic.Uint64
+}
+
+// UniformLatency returns a latency distribution that is uniform between min and max.
+func UniformLatency(min, max time.Duration) func() time.Duration {
+	var lock sync.Mutex
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return func() time.Duration {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(r.Int63n(int64(max-min)))
+	}
+}
+
+// NormalLatency returns a latency distribution that is normal with the provided mean and
+// standard deviation, which is never below zero.
+func NormalLatency(mean, stddev time.Duration) func() time.Duration {
+	var lock sync.Mutex
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return func() time.Duration {
+		lock.Lock()
+		defer lock.Unlock()
+
+		d := float64(mean) + r.NormFloat64()*float64(stddev)
+		return time.Duration(math.Max(d, 0))
+	}
+}
+
+// NewChaosProxy returns an active ChaosProxy listening on the provided local address and
+// forwarding the queries to the upstream server.
+func NewChaosProxy(laddr, upstream string, opts ChaosOptions) (*ChaosProxy, error) {
+	if _, _, err := net.SplitHostPort(upstream); err != nil {
+		upstream = net.JoinHostPort(upstream, "53")
+	}
+
+	pc, err := net.ListenPacket("udp", laddr)
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		return nil, err
+	}
+
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	p := &ChaosProxy{
+		done:     make(chan struct{}),
+		upstream: upstream,
+		opts:     opts,
+		pc:       pc,
+		l:        l,
+		rand:     rand.New(rand.NewSource(seed)),
+	}
+
+	p.wg.Add(2)
+	go p.serveUDP()
+	go p.serveTCP()
+	return p, nil
+}
+
+// Addr returns the address of the proxy to be used as a resolver.
+func (p *ChaosProxy) Addr() string {
+	return p.pc.LocalAddr().String()
+}
+
+// Stats returns the counts of the faults injected by the proxy.
+func (p *ChaosProxy) Stats() *ChaosStats {
+	return &ChaosStats{
+		Queries:    p.queries.Load(),
+		Dropped:    p.dropped.Load(),
+		Duplicated: p.dups.Load(),
+		Truncated:  p.truncs.Load(),
+		Reordered:  p.reorders.Load(),
+	}
+}
+
+// Close stops the proxy and releases the sockets.
+func (p *ChaosProxy) Close() error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+		close(p.done)
+	}
+
+	err := errors.Join(p.pc.Close(), p.l.Close())
+	p.wg.Wait()
+	return err
+}
+
+func (p *ChaosProxy) chance(prob float64) bool {
+	if prob <= 0 {
+		return false
+	}
+
+	p.rlock.Lock()
+	defer p.rlock.Unlock()
+
+	return p.rand.Float64() < prob
+}
+
+func (p *ChaosProxy) serveUDP() {
+	defer p.wg.Done()
+
+	for {
+		b := make([]byte, dns.MaxMsgSize)
+		n, addr, err := p.pc.ReadFrom(b)
+		if err != nil {
+			select {
+			case <-p.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		p.queries.Add(1)
+		p.wg.Add(1)
+		go p.exchange(b[:n], addr)
+	}
+}
+
+func (p *ChaosProxy) exchange(query []byte, addr net.Addr) {
+	defer p.wg.Done()
+
+	conn, err := net.Dial("udp", p.upstream)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(DefaultTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return
+	}
+
+	b := make([]byte, dns.MaxMsgSize)
+	n, err := conn.Read(b)
+	if err != nil {
+		return
+	}
+	p.respond(b[:n], addr)
+}
+
+func (p *ChaosProxy) respond(b []byte, addr net.Addr) {
+	if p.chance(p.opts.Loss) {
+		p.dropped.Add(1)
+		return
+	}
+	if p.chance(p.opts.Truncate) {
+		if t := truncateResponse(b); t != nil {
+			p.truncs.Add(1)
+			b = t
+		}
+	}
+
+	var delay time.Duration
+	if p.opts.Latency != nil {
+		delay = p.opts.Latency()
+	}
+	if p.chance(p.opts.Reorder) {
+		p.reorders.Add(1)
+		delay += p.opts.ReorderDelay
+	}
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		defer t.Stop()
+
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+		}
+	}
+
+	_, _ = p.pc.WriteTo(b, addr)
+	if p.chance(p.opts.Duplicate) {
+		p.dups.Add(1)
+		_, _ = p.pc.WriteTo(b, addr)
+	}
+}
+
+// truncateResponse returns the response with the TC bit set and only the question section.
+func truncateResponse(b []byte) []byte {
+	m := new(dns.Msg)
+	if err := m.Unpack(b); err != nil {
+		return nil
+	}
+
+	m.Truncated = true
+	m.Answer = nil
+	m.Ns = nil
+	m.Extra = nil
+	t, err := m.Pack()
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+func (p *ChaosProxy) serveTCP() {
+	defer p.wg.Done()
+
+	for {
+		c, err := p.l.Accept()
+		if err != nil {
+			select {
+			case <-p.done:
+				return
+			default:
+				continue
+			}
+		}
+
+		p.wg.Add(1)
+		go p.forwardTCP(c)
+	}
+}
+
+func (p *ChaosProxy) forwardTCP(c net.Conn) {
+	defer p.wg.Done()
+	defer c.Close()
+
+	up, err := net.DialTimeout("tcp", p.upstream, DefaultTimeout)
+	if err != nil {
+		return
+	}
+	defer up.Close()
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-p.done:
+			_ = c.Close()
+			_ = up.Close()
+		case <-finished:
+		}
+	}()
+	go func() { _, _ = io.Copy(up, c) }()
+	_, _ = io.Copy(c, up)
+}
@@ -0,0 +1,178 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// runUDPAndTCPServer runs the handler on the same port using both UDP and TCP.
+func runUDPAndTCPServer(t *testing.T, handler dns.HandlerFunc) string {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = handler
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Shutdown() })
+
+	st, _, _, err := RunLocalTCPServer(addr, func(s *dns.Server) {
+		s.Handler = handler
+	})
+	if err != nil {
+		t.Skipf("unable to run the TCP test server on the same port: %v", err)
+	}
+	t.Cleanup(func() { _ = st.Shutdown() })
+	return addr
+}
+
+func TestChaosProxyPassthrough(t *testing.T) {
+	upstream := runUDPAndTCPServer(t, typeAHandler)
+
+	p, err := NewChaosProxy("127.0.0.1:0", upstream, ChaosOptions{
+		Duplicate: 1,
+		Latency:   UniformLatency(50*time.Millisecond, 60*time.Millisecond),
+	})
+	if err != nil {
+		t.Fatalf("failed to start the chaos proxy: %v", err)
+	}
+	defer p.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, p.Addr())
+	defer r.Stop()
+
+	start := time.Now()
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+		t.Fatalf("the query through the chaos proxy failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("the latency was not injected: %s", elapsed)
+	}
+	if stats := p.Stats(); stats.Queries != 1 || stats.Duplicated != 1 {
+		t.Errorf("the proxy stats were not correct: %+v", stats)
+	}
+}
+
+func TestChaosProxyLoss(t *testing.T) {
+	upstream := runUDPAndTCPServer(t, typeAHandler)
+
+	p, err := NewChaosProxy("127.0.0.1:0", upstream, ChaosOptions{Loss: 1})
+	if err != nil {
+		t.Fatalf("failed to start the chaos proxy: %v", err)
+	}
+	defer p.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, p.Addr())
+	r.SetTimeout(200 * time.Millisecond)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err == nil && resp.Rcode != RcodeNoResponse {
+		t.Errorf("a response was received although all were dropped")
+	}
+	if stats := p.Stats(); stats.Dropped != 1 {
+		t.Errorf("the proxy did not report the dropped response: %+v", stats)
+	}
+}
+
+func TestChaosProxyTruncate(t *testing.T) {
+	upstream := runUDPAndTCPServer(t, typeAHandler)
+
+	p, err := NewChaosProxy("127.0.0.1:0", upstream, ChaosOptions{Truncate: 1})
+	if err != nil {
+		t.Fatalf("failed to start the chaos proxy: %v", err)
+	}
+	defer p.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, p.Addr())
+	defer r.Stop()
+
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Msg.Rcode != dns.RcodeSuccess || len(resp.Msg.Answer) == 0 {
+		t.Fatalf("the truncated response was not retried over TCP: %v", err)
+	}
+	if resp.Transport != TransportTCP || p.Stats().Truncated != 1 {
+		t.Errorf("the response was not truncated by the proxy")
+	}
+}
+
+func TestChaosProxyReorder(t *testing.T) {
+	upstream := runUDPAndTCPServer(t, typeAHandler)
+
+	p, err := NewChaosProxy("127.0.0.1:0", upstream, ChaosOptions{
+		Reorder:      0.5,
+		ReorderDelay: 200 * time.Millisecond,
+		Seed:         1,
+	})
+	if err != nil {
+		t.Fatalf("failed to start the chaos proxy: %v", err)
+	}
+	defer p.Close()
+
+	conn, err := net.Dial("udp", p.Addr())
+	if err != nil {
+		t.Fatalf("failed to dial the proxy: %v", err)
+	}
+	defer conn.Close()
+
+	dc := &dns.Conn{Conn: conn}
+	for i := 0; i < 10; i++ {
+		msg := QueryMsg("caffix.net", dns.TypeA)
+		msg.Id = uint16(i)
+		if err := dc.WriteMsg(msg); err != nil {
+			t.Fatalf("failed to send the query: %v", err)
+		}
+	}
+
+	var ids []uint16
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for i := 0; i < 10; i++ {
+		m, err := dc.ReadMsg()
+		if err != nil {
+			t.Fatalf("failed to read the response: %v", err)
+		}
+		ids = append(ids, m.Id)
+	}
+
+	stats := p.Stats()
+	if stats.Reordered == 0 || stats.Reordered == 10 {
+		t.Fatalf("the seeded proxy reordered %d of the responses", stats.Reordered)
+	}
+	// the held responses arrive after all the others
+	if last := ids[len(ids)-int(stats.Reordered):]; len(last) > 0 {
+		held := make(map[uint16]bool)
+		for _, id := range last {
+			held[id] = true
+		}
+		for _, id := range ids[:len(ids)-len(last)] {
+			if held[id] {
+				t.Errorf("the reordered responses did not arrive last: %v", ids)
+			}
+		}
+	}
+}
+
+func TestLatencyDistributions(t *testing.T) {
+	uniform := UniformLatency(10*time.Millisecond, 20*time.Millisecond)
+	normal := NormalLatency(10*time.Millisecond, 50*time.Millisecond)
+
+	for i := 0; i < 100; i++ {
+		if d := uniform(); d < 10*time.Millisecond || d >= 20*time.Millisecond {
+			t.Errorf("the uniform latency %s was outside of the range", d)
+		}
+		if d := normal(); d < 0 {
+			t.Errorf("the normal latency %s was negative", d)
+		}
+	}
+}
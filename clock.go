@@ -0,0 +1,228 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides the time used by the rate limiters and timers of the package.
+// It is compatible with the clock accepted by the go.uber.org/ratelimit package.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers the time on a channel at the interval provided to the Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{t: time.NewTicker(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time { return t.t.C }
+
+func (t *realTicker) Reset(d time.Duration) { t.t.Reset(d) }
+
+func (t *realTicker) Stop() { t.t.Stop() }
+
+// SimClock is a virtual Clock for simulations that only moves forward when Advance is called.
+// Goroutines sleeping on the clock are released and tickers fire in the order of virtual time.
+type SimClock struct {
+	sync.Mutex
+	cond     *sync.Cond
+	now      time.Time
+	sleepers []*simSleeper
+	tickers  []*simTicker
+}
+
+type simSleeper struct {
+	until time.Time
+	done  chan struct{}
+}
+
+type simTicker struct {
+	clock   *SimClock
+	period  time.Duration
+	next    time.Time
+	ch      chan time.Time
+	stopped bool
+}
+
+// NewSimClock returns a SimClock set to the provided start time.
+func NewSimClock(start time.Time) *SimClock {
+	c := &SimClock{now: start}
+	c.cond = sync.NewCond(&c.Mutex)
+	return c
+}
+
+// Now returns the current virtual time.
+func (c *SimClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.now
+}
+
+// Sleep blocks the caller until the virtual time has advanced by the duration.
+func (c *SimClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	c.Lock()
+	s := &simSleeper{
+		until: c.now.Add(d),
+		done:  make(chan struct{}),
+	}
+	c.sleepers = append(c.sleepers, s)
+	c.cond.Broadcast()
+	c.Unlock()
+
+	<-s.done
+}
+
+// NewTicker returns a Ticker that fires each time the virtual time advances by the duration.
+func (c *SimClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for SimClock.NewTicker")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	t := &simTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		ch:     make(chan time.Time, 1),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the virtual time forward by the duration, releasing the sleepers
+// and firing the tickers that become due along the way.
+func (c *SimClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	end := c.now.Add(d)
+	for c.fireNext(end) {
+	}
+	c.now = end
+}
+
+// BlockUntil waits until at least n goroutines are sleeping on the clock.
+func (c *SimClock) BlockUntil(n int) {
+	c.Lock()
+	defer c.Unlock()
+
+	for len(c.sleepers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Sleepers returns the number of goroutines currently sleeping on the clock.
+func (c *SimClock) Sleepers() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.sleepers)
+}
+
+// fireNext releases the earliest sleeper or ticker due at or before end.
+// The caller must hold the clock lock.
+func (c *SimClock) fireNext(end time.Time) bool {
+	sidx, tidx := -1, -1
+	var at time.Time
+
+	for i, s := range c.sleepers {
+		if !s.until.After(end) && (sidx == -1 || s.until.Before(at)) {
+			sidx, at = i, s.until
+		}
+	}
+	for i, t := range c.tickers {
+		if !t.next.After(end) && ((sidx == -1 && tidx == -1) || t.next.Before(at)) {
+			sidx, tidx, at = -1, i, t.next
+		}
+	}
+
+	switch {
+	case sidx != -1:
+		s := c.sleepers[sidx]
+		c.sleepers = append(c.sleepers[:sidx], c.sleepers[sidx+1:]...)
+		if at.After(c.now) {
+			c.now = at
+		}
+		close(s.done)
+	case tidx != -1:
+		t := c.tickers[tidx]
+		if at.After(c.now) {
+			c.now = at
+		}
+		t.next = t.next.Add(t.period)
+		// Drop the tick when the receiver has fallen behind, as time.Ticker does
+		select {
+		case t.ch <- c.now:
+		default:
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func (t *simTicker) C() <-chan time.Time { return t.ch }
+
+func (t *simTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+
+	c := t.clock
+	c.Lock()
+	defer c.Unlock()
+
+	t.period = d
+	t.next = c.now.Add(d)
+	if t.stopped {
+		t.stopped = false
+		c.tickers = append(c.tickers, t)
+	}
+}
+
+func (t *simTicker) Stop() {
+	c := t.clock
+	c.Lock()
+	defer c.Unlock()
+
+	if t.stopped {
+		return
+	}
+	t.stopped = true
+	for i, other := range c.tickers {
+		if other == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			break
+		}
+	}
+}
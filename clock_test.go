@@ -0,0 +1,190 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSimClock(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	c := NewSimClock(start)
+
+	woke := make(chan time.Time, 1)
+	go func() {
+		c.Sleep(time.Minute)
+		woke <- c.Now()
+	}()
+
+	tick := c.NewTicker(20 * time.Second)
+	defer tick.Stop()
+
+	c.BlockUntil(1)
+	c.Advance(59 * time.Second)
+	select {
+	case <-woke:
+		t.Fatalf("the sleeper was released before the virtual time elapsed")
+	default:
+	}
+	// the ticker drops the ticks that were not received
+	select {
+	case now := <-tick.C():
+		if !now.Equal(start.Add(20 * time.Second)) {
+			t.Errorf("the ticker fired at %v instead of the first interval", now)
+		}
+	default:
+		t.Errorf("the ticker did not fire")
+	}
+
+	c.Advance(time.Second)
+	if now := <-woke; !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("the sleeper was released at %v", now)
+	}
+	if c.Sleepers() != 0 {
+		t.Errorf("the clock still has %d sleepers", c.Sleepers())
+	}
+
+	<-tick.C()
+	tick.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-tick.C():
+		t.Errorf("the ticker fired after being stopped")
+	default:
+	}
+}
+
+func TestSimulatedRateLimits(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	c := NewSimClock(time.Now())
+	r := NewResolversWithClock(c)
+	defer r.Stop()
+	// the resolver rate limiter allows one query every 100ms of virtual time
+	if err := r.AddResolvers(10, addrstr); err != nil {
+		t.Fatalf("failed to add the resolver: %v", err)
+	}
+
+	num := 3
+	ch := make(chan *dns.Msg, num)
+	for i := 0; i < num; i++ {
+		r.Query(context.Background(), QueryMsg(name, dns.TypeA), ch)
+	}
+
+	for i := 0; i < num; i++ {
+		if i > 0 {
+			c.BlockUntil(1)
+			select {
+			case <-ch:
+				t.Fatalf("query %d was sent before the virtual time advanced", i+1)
+			case <-time.After(50 * time.Millisecond):
+			}
+			c.Advance(100 * time.Millisecond)
+		}
+
+		select {
+		case resp := <-ch:
+			if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+				t.Errorf("query %d failed", i+1)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("query %d was not sent after the virtual time advanced", i+1)
+		}
+	}
+}
+
+func TestSimulatedRateTracker(t *testing.T) {
+	c := NewSimClock(time.Now())
+	rt := NewRateTrackerWithClock(true, c)
+	defer rt.Stop()
+
+	qpsAfter := func(success, timeout int) int {
+		for i := 0; i < success; i++ {
+			rt.Success("caffix.net")
+		}
+		for i := 0; i < timeout; i++ {
+			rt.Timeout("caffix.net")
+		}
+		c.Advance(rateUpdateInterval)
+
+		for i := 0; i < 100; i++ {
+			rt.catchLimiter.Lock()
+			qps, pending := rt.catchLimiter.qps, rt.catchLimiter.success+rt.catchLimiter.timeout
+			rt.catchLimiter.Unlock()
+
+			if pending == 0 {
+				return qps
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("the rate limits were not updated after the virtual interval")
+		return 0
+	}
+
+	if qps := qpsAfter(50, 50); qps != maxQPSPerNameserver/2 {
+		t.Errorf("the QPS was %d after half the queries timed out", qps)
+	}
+	if qps := qpsAfter(100, 0); qps != maxQPSPerNameserver/2+10 {
+		t.Errorf("the QPS was %d after all the queries succeeded", qps)
+	}
+}
+
+func TestSimulatedTimeouts(t *testing.T) {
+	// the server never responds to the queries
+	pc, err := net.ListenPacket("udp", "localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer pc.Close()
+
+	c := NewSimClock(time.Now())
+	r := NewResolversWithClock(c)
+	defer r.Stop()
+
+	timeout := time.Minute
+	r.SetTimeout(timeout)
+	if err := r.AddResolvers(10, pc.LocalAddr().String()); err != nil {
+		t.Fatalf("failed to add the resolver: %v", err)
+	}
+
+	ch := make(chan *dns.Msg, 1)
+	r.Query(context.Background(), QueryMsg("caffix.net", dns.TypeA), ch)
+	for i := 0; len(r.Inflight()) == 0; i++ {
+		if i == 100 {
+			t.Fatalf("the query was not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for elapsed := time.Second; elapsed <= 2*timeout; elapsed += time.Second {
+		c.Advance(time.Second)
+
+		select {
+		case resp := <-ch:
+			if elapsed < timeout {
+				t.Errorf("the query expired after %v of virtual time", elapsed)
+			}
+			if resp.Rcode != RcodeNoResponse {
+				t.Errorf("the expired query returned rcode %d", resp.Rcode)
+			}
+			return
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	t.Errorf("the query did not expire within %v of virtual time", 2*timeout)
+}
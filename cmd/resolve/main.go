@@ -194,8 +194,8 @@ func EventLoop(p *params) {
 			// Check if there was an error or timeout requiring another attempt
 			if resp.Rcode == resolve.RcodeNoResponse {
 				queries[k]++
-				if queries[k] <= p.Retries {
-					p.Pool.Query(context.Background(), resolve.QueryMsg(name, resp.Question[0].Qtype), responses)
+				if queries[k] <= p.Retries && p.Pool.RetryAllowed() {
+					p.Pool.Query(resolve.WithRetry(context.Background()), resolve.QueryMsg(name, resp.Question[0].Qtype), responses)
 					continue
 				}
 			} else {
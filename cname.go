@@ -0,0 +1,139 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultCNAMEDepth is the number of CNAME targets followed by FollowCNAMEs and ResolveIPs.
+const DefaultCNAMEDepth = 8
+
+var (
+	// ErrCNAMEDepth is returned when a CNAME chain exceeds the depth set by WithCNAMEDepth.
+	ErrCNAMEDepth = errors.New("the CNAME chain exceeded the maximum depth")
+	// ErrCNAMELoop is returned when a CNAME chain returns to a name already followed.
+	ErrCNAMELoop = errors.New("the CNAME chain contains a loop")
+)
+
+type cnameDepthCtxKey struct{}
+
+// WithCNAMEDepth returns a context limiting the number of CNAME targets followed by
+// FollowCNAMEs and ResolveIPs. A depth of zero only queries the provided name.
+func WithCNAMEDepth(ctx context.Context, depth int) context.Context {
+	if depth < 0 {
+		depth = 0
+	}
+	return context.WithValue(ctx, cnameDepthCtxKey{}, depth)
+}
+
+func cnameDepth(ctx context.Context) int {
+	if depth, ok := ctx.Value(cnameDepthCtxKey{}).(int); ok {
+		return depth
+	}
+	return DefaultCNAMEDepth
+}
+
+// CNAMEChain is the result of following the CNAME records from a name.
+type CNAMEChain struct {
+	// Names contains the queried name followed by each CNAME target, in order
+	Names []string
+	// Response is the last response obtained, which is nil when no response was obtained
+	Response *dns.Msg
+}
+
+// Target returns the last name of the chain.
+func (c *CNAMEChain) Target() string {
+	return c.Names[len(c.Names)-1]
+}
+
+// FollowCNAMEs queries the name and follows the CNAME targets until a response contains the
+// records of the type or no further target is provided. When the chain terminates early, the
+// partial chain is returned along with the error: a NXDOMAIN response terminates the chain
+// immediately with ErrNoSuchHost, a chain beyond the depth set by WithCNAMEDepth terminates
+// with ErrCNAMEDepth, and a chain returning to a name already followed with ErrCNAMELoop.
+func (r *Resolvers) FollowCNAMEs(ctx context.Context, name string, qtype uint16) (*CNAMEChain, error) {
+	return r.followCNAMEs(ctx, name, qtype, nil)
+}
+
+// followCNAMEs implements FollowCNAMEs, and the check is called with the response for the
+// queried name before any targets are followed.
+func (r *Resolvers) followCNAMEs(ctx context.Context, name string, qtype uint16, check func(*dns.Msg) error) (*CNAMEChain, error) {
+	depth := cnameDepth(ctx)
+	name = strings.ToLower(RemoveLastDot(name))
+	chain := &CNAMEChain{Names: []string{name}}
+	seen := map[string]struct{}{name: {}}
+
+	for target := name; ; {
+		resp, err := r.QueryBlocking(ctx, QueryMsg(target, qtype))
+		if err != nil {
+			return chain, fmt.Errorf("lookup %s: %v", target, err)
+		}
+		chain.Response = resp
+
+		switch resp.Rcode {
+		case dns.RcodeSuccess, dns.RcodeNameError:
+		case RcodeNoResponse:
+			return chain, fmt.Errorf("lookup %s: the query failed to obtain a response", target)
+		default:
+			return chain, fmt.Errorf("lookup %s: the server returned %s", target, dns.RcodeToString[resp.Rcode])
+		}
+		if check != nil && target == name {
+			if err := check(resp); err != nil {
+				return chain, err
+			}
+		}
+
+		// the resolver can provide the chain in the answer section, even along with NXDOMAIN
+		targets := cnameTargets(resp, target)
+		for _, next := range targets {
+			if _, found := seen[next]; found {
+				return chain, fmt.Errorf("lookup %s: %w", name, ErrCNAMELoop)
+			}
+			if len(chain.Names) > depth {
+				return chain, fmt.Errorf("lookup %s: %w", name, ErrCNAMEDepth)
+			}
+
+			seen[next] = struct{}{}
+			chain.Names = append(chain.Names, next)
+		}
+		if resp.Rcode == dns.RcodeNameError {
+			return chain, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+		}
+		if len(targets) == 0 || qtype == dns.TypeCNAME || hasAnswerType(resp, qtype) {
+			return chain, nil
+		}
+		target = chain.Target()
+	}
+}
+
+// cnameTargets follows the CNAME records in the answer section starting at the name, and
+// returns the targets in order.
+func cnameTargets(resp *dns.Msg, name string) []string {
+	var targets []string
+
+	cur := strings.ToLower(dns.Fqdn(name))
+	for i := 0; i < len(resp.Answer); i++ {
+		var next string
+		for _, rr := range resp.Answer {
+			if c, ok := rr.(*dns.CNAME); ok && strings.EqualFold(c.Hdr.Name, cur) {
+				next = strings.ToLower(c.Target)
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+
+		targets = append(targets, RemoveLastDot(next))
+		cur = next
+	}
+	return targets
+}
@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestFollowCNAMEs(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(cnameChainHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	chain, err := r.FollowCNAMEs(context.Background(), "3.chain.caffix.net", dns.TypeA)
+	if err != nil {
+		t.Fatalf("the chain was not followed: %v", err)
+	}
+	expected := []string{"3.chain.caffix.net", "2.chain.caffix.net", "1.chain.caffix.net", "0.chain.caffix.net"}
+	if !reflect.DeepEqual(chain.Names, expected) {
+		t.Errorf("the chain was %v instead of %v", chain.Names, expected)
+	}
+	if !hasAnswerType(chain.Response, dns.TypeA) {
+		t.Errorf("the response for %s did not contain the address", chain.Target())
+	}
+
+	chain, err = r.FollowCNAMEs(WithCNAMEDepth(context.Background(), 2), "3.chain.caffix.net", dns.TypeA)
+	if !errors.Is(err, ErrCNAMEDepth) {
+		t.Errorf("the chain beyond the depth did not fail: %v", err)
+	}
+	if n := len(chain.Names); n != 3 {
+		t.Errorf("the partial chain contained %d names instead of three", n)
+	}
+
+	chain, err = r.FollowCNAMEs(context.Background(), "dangling.caffix.net", dns.TypeA)
+	if !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the chain ending with NXDOMAIN did not fail: %v", err)
+	}
+	if expected := []string{"dangling.caffix.net", "missing.caffix.net"}; !reflect.DeepEqual(chain.Names, expected) {
+		t.Errorf("the partial chain was %v instead of %v", chain.Names, expected)
+	}
+
+	if _, err := r.FollowCNAMEs(context.Background(), "loop.chain.caffix.net", dns.TypeA); !errors.Is(err, ErrCNAMELoop) {
+		t.Errorf("the CNAME loop was not detected: %v", err)
+	}
+}
+
+// cnameChainHandler answers N.chain.caffix.net with a CNAME to N-1.chain.caffix.net and
+// 0.chain.caffix.net with an address, while dangling.caffix.net points at a missing name
+func cnameChainHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	cname := func(target string) {
+		m.Answer = append(m.Answer, &dns.CNAME{
+			Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET, Ttl: 60},
+			Target: target,
+		})
+	}
+
+	switch {
+	case q.Name == "0.chain.caffix.net.":
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.168.1.1"),
+		}}
+	case q.Name == "loop.chain.caffix.net.":
+		cname("loop.chain.caffix.net.")
+	case strings.HasSuffix(q.Name, ".chain.caffix.net."):
+		n, _ := strconv.Atoi(strings.TrimSuffix(q.Name, ".chain.caffix.net."))
+		cname(strconv.Itoa(n-1) + ".chain.caffix.net.")
+	case q.Name == "dangling.caffix.net.":
+		// the resolver followed the chain and the target does not exist
+		cname("missing.caffix.net.")
+		m.Rcode = dns.RcodeNameError
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(m)
+}
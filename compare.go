@@ -0,0 +1,148 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/miekg/dns"
+)
+
+// ResolverAnswer contains the response provided by a single resolver during a comparison.
+type ResolverAnswer struct {
+	Resolver string
+	Rcode    int
+	Time     time.Time
+	RTT      time.Duration
+	Answers  []*ExtractedAnswer
+}
+
+// ComparisonReport contains the differences in responses from the resolvers in the pool.
+type ComparisonReport struct {
+	Name    string
+	Qtype   uint16
+	Results []*ResolverAnswer
+	// Consensus contains the record data returned by all resolvers that successfully responded
+	Consensus []string
+	// Differences maps resolver addresses to the record data not found in the consensus
+	Differences map[string][]string
+	// Rcodes tallies the response codes returned across the resolvers
+	Rcodes map[int]int
+}
+
+// CompareAcrossResolvers sends the query to every resolver in the pool and reports on the
+// differences in the answers, response codes and latencies.
+func (r *Resolvers) CompareAcrossResolvers(ctx context.Context, name string, qtype uint16) *ComparisonReport {
+	all := r.pool.AllResolvers()
+	report := &ComparisonReport{
+		Name:        strings.ToLower(RemoveLastDot(name)),
+		Qtype:       qtype,
+		Differences: make(map[string][]string),
+		Rcodes:      make(map[int]int),
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for _, res := range all {
+		wg.Add(1)
+
+		go func(res *resolver) {
+			defer wg.Done()
+
+			result := res.compareQuery(ctx, name, qtype)
+
+			lock.Lock()
+			report.Results = append(report.Results, result)
+			lock.Unlock()
+		}(res)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Resolver < report.Results[j].Resolver
+	})
+	report.buildConsensus()
+	return report
+}
+
+func (r *resolver) compareQuery(ctx context.Context, name string, qtype uint16) *ResolverAnswer {
+	start := time.Now()
+	resp := r.exchangeQuery(ctx, QueryMsg(name, qtype))
+
+	result := &ResolverAnswer{
+		Resolver: r.address.IP.String(),
+		Rcode:    RcodeNoResponse,
+		Time:     start,
+		RTT:      time.Since(start),
+	}
+	if resp != nil {
+		result.Rcode = resp.Rcode
+		result.Answers = ExtractAnswers(resp)
+	}
+	return result
+}
+
+// exchangeQuery sends the message directly to the resolver, and returns nil when the context
+// expires before the response is received.
+func (r *resolver) exchangeQuery(ctx context.Context, msg *dns.Msg) *dns.Msg {
+	ch := make(chan *dns.Msg, 1)
+
+	_ = r.rate.Take()
+	r.writeReq(&request{
+		Ctx:    ctx,
+		Res:    r,
+		Msg:    msg,
+		Result: ch,
+	})
+
+	select {
+	case <-ctx.Done():
+	case resp := <-ch:
+		return resp
+	}
+	return nil
+}
+
+func (c *ComparisonReport) buildConsensus() {
+	set := stringset.New()
+	defer set.Close()
+
+	first := true
+	for _, res := range c.Results {
+		c.Rcodes[res.Rcode]++
+		if res.Rcode != dns.RcodeSuccess {
+			continue
+		}
+		if first {
+			insertRecordData(set, res.Answers)
+			first = false
+		} else {
+			intersectRecordData(set, res.Answers)
+		}
+	}
+	c.Consensus = set.Slice()
+	sort.Strings(c.Consensus)
+
+	for _, res := range c.Results {
+		if res.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		var diff []string
+		for _, a := range res.Answers {
+			if d := strings.Trim(a.Data, "."); !set.Has(d) {
+				diff = append(diff, d)
+			}
+		}
+		if len(diff) > 0 {
+			c.Differences[res.Resolver] = diff
+		}
+	}
+}
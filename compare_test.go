@@ -0,0 +1,92 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestCompareAcrossResolvers(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr1, addr2)
+	defer r.Stop()
+
+	report := r.CompareAcrossResolvers(context.Background(), "caffix.net", dns.TypeA)
+	if len(report.Results) != 2 || report.Rcodes[dns.RcodeSuccess] != 2 {
+		t.Fatalf("the report did not include responses from both resolvers")
+	}
+	if len(report.Consensus) != 1 || report.Consensus[0] != "192.168.1.1" {
+		t.Errorf("the consensus was not correctly computed: %v", report.Consensus)
+	}
+	if diff := report.Differences["127.0.0.2"]; len(diff) != 1 || diff[0] != "192.168.1.2" {
+		t.Errorf("the differences were not correctly computed: %v", report.Differences)
+	}
+	if _, found := report.Differences["127.0.0.1"]; found {
+		t.Errorf("a resolver that agreed with the consensus was reported as different")
+	}
+}
+
+func otherAHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	m.Answer = []dns.RR{
+		&dns.A{
+			Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP("192.168.1.1"),
+		},
+		&dns.A{
+			Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP("192.168.1.2"),
+		},
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestCompareAcrossResolversTimeout(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(timeoutHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	report := r.CompareAcrossResolvers(ctx, "caffix.net", dns.TypeA)
+	if len(report.Results) != 1 || report.Rcodes[RcodeNoResponse] != 1 {
+		t.Errorf("the resolver that failed to respond was not included in the report")
+	}
+	if len(report.Consensus) != 0 {
+		t.Errorf("the resolver that failed to respond contributed to the consensus")
+	}
+}
@@ -9,37 +9,76 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/caffix/queue"
 	"github.com/miekg/dns"
 )
 
-const headerSize = 12
+const (
+	headerSize     = 12
+	maxConnErrors  = 10
+	redialDelay    = 50 * time.Millisecond
+	maxRedialDelay = 5 * time.Second
+)
+
+// ConnectionStats contains the counters for the UDP sockets shared by the resolver pool.
+type ConnectionStats struct {
+	ReadErrors  uint64
+	WriteErrors uint64
+	Redials     uint64
+	// KernelDrops counts the responses dropped due to full receive buffers, when available
+	KernelDrops uint64
+}
 
 type resp struct {
 	Msg  *dns.Msg
 	Addr net.Addr
+	Size int
+	Raw  []byte
+	Err  error
 }
 
 type connection struct {
-	conn net.PacketConn
-	done chan struct{}
+	conn      net.PacketConn
+	done      chan struct{}
+	errs      atomic.Int32
+	redialing atomic.Bool
 }
 
 type connections struct {
 	sync.Mutex
-	done      chan struct{}
-	conns     []*connection
-	resps     queue.Queue
-	nextWrite int
-	cpus      int
+	done        chan struct{}
+	pause       chan struct{}
+	suspended   bool
+	conns       []*connection
+	resps       queue.Queue
+	nextWrite   int
+	cpus        int
+	opts        socketOpts
+	readErrors  atomic.Uint64
+	writeErrors atomic.Uint64
+	redials     atomic.Uint64
+	kernelDrops atomic.Uint64
+	raw         atomic.Bool
+	dropCounts  map[uint64]uint64
+}
+
+// ConnectionStats returns the error and re-dial counters for the UDP sockets used by the pool.
+func (r *Resolvers) ConnectionStats() *ConnectionStats {
+	if r.conns == nil {
+		return new(ConnectionStats)
+	}
+	return r.conns.stats()
 }
 
-func newConnections(cpus int, resps queue.Queue) *connections {
+func newConnections(cpus int, resps queue.Queue) (*connections, error) {
 	conns := &connections{
 		resps: resps,
 		done:  make(chan struct{}),
+		pause: make(chan struct{}),
 		cpus:  cpus,
 	}
 
@@ -48,12 +87,15 @@ func newConnections(cpus int, resps queue.Queue) *connections {
 
 	for i := 0; i < cpus; i++ {
 		if err := conns.Add(); err != nil {
-			conns.Close()
-			return nil
+			for _, c := range conns.conns {
+				close(c.done)
+				_ = c.conn.Close()
+			}
+			return nil, fmt.Errorf("failed to open the UDP sockets for the pool: %w", err)
 		}
 	}
-	go conns.rotations()
-	return conns
+	go conns.rotations(conns.pause)
+	return conns, nil
 }
 
 func (r *connections) Close() {
@@ -69,15 +111,62 @@ func (r *connections) Close() {
 	}
 }
 
-func (r *connections) rotations() {
+// suspend closes the sockets and stops the rotations until resume is called.
+func (r *connections) suspend() {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conns == nil || r.suspended {
+		return
+	}
+
+	r.suspended = true
+	close(r.pause)
+	for _, c := range r.conns {
+		close(c.done)
+		// unblocks the reader waiting on the socket
+		_ = c.conn.Close()
+	}
+	r.conns = []*connection{}
+}
+
+// resume opens new sockets after the connections were suspended.
+func (r *connections) resume() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.conns == nil || !r.suspended {
+		return nil
+	}
+
+	r.suspended = false
+	r.pause = make(chan struct{})
+	go r.rotations(r.pause)
+	for i := 0; i < r.cpus; i++ {
+		if err := r.Add(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *connections) rotations(pause chan struct{}) {
 	t := time.NewTicker(30 * time.Second)
 	defer t.Stop()
 
+	drops := time.NewTicker(dropCheckInterval)
+	defer drops.Stop()
+
 	for {
 		select {
 		case <-r.done:
 			return
+		case <-pause:
+			return
+		case <-drops.C:
+			r.updateDrops()
 		case <-t.C:
+			r.updateDrops()
 			r.rotate()
 		}
 	}
@@ -87,14 +176,13 @@ func (r *connections) rotate() {
 	r.Lock()
 	defer r.Unlock()
 
-	for _, c := range r.conns {
-		go func(c *connection) {
-			t := time.NewTimer(10 * time.Second)
-			defer t.Stop()
+	// the rotation could have been waiting on the lock while the sockets were suspended
+	if r.conns == nil || r.suspended {
+		return
+	}
 
-			<-t.C
-			close(c.done)
-		}(c)
+	for _, c := range r.conns {
+		go r.retire(c)
 	}
 
 	r.conns = []*connection{}
@@ -103,7 +191,16 @@ func (r *connections) rotate() {
 	}
 }
 
-func (r *connections) Next() net.PacketConn {
+// retire closes the connection after the responses to recently sent queries had time to arrive.
+func (r *connections) retire(c *connection) {
+	t := time.NewTimer(10 * time.Second)
+	defer t.Stop()
+
+	<-t.C
+	close(c.done)
+}
+
+func (r *connections) Next() *connection {
 	r.Lock()
 	defer r.Unlock()
 
@@ -113,26 +210,132 @@ func (r *connections) Next() net.PacketConn {
 
 	cur := r.nextWrite
 	r.nextWrite = (r.nextWrite + 1) % len(r.conns)
-	return r.conns[cur].conn
+	return r.conns[cur]
 }
 
 func (r *connections) Add() error {
-	conn, err := r.ListenPacket()
+	c, err := r.open()
 	if err != nil {
 		return err
 	}
 
+	r.conns = append(r.conns, c)
+	go runLabeled(func() { r.responses(c) }, labelSubsystem, "reader")
+	return nil
+}
+
+func (r *connections) open() (*connection, error) {
+	conn, err := r.ListenPacket()
+	if err != nil {
+		return nil, err
+	}
+
 	_ = conn.SetDeadline(time.Time{})
-	c := &connection{
+	if r.opts.rcvbuf > 0 {
+		if udp, ok := conn.(*net.UDPConn); ok {
+			if err := udp.SetReadBuffer(r.opts.rcvbuf); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+		}
+	}
+	return &connection{
 		conn: conn,
 		done: make(chan struct{}),
+	}, nil
+}
+
+// socketError returns true when the error reveals a problem with the socket. Errors caused by
+// the destination, such as a missing route to the resolver, are not fixed by a new socket.
+func socketError(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ENETUNREACH, syscall.EHOSTUNREACH, syscall.ECONNREFUSED} {
+		if errors.Is(err, errno) {
+			return false
+		}
+	}
+	return true
+}
+
+// connError counts the error and starts the re-dial of the connection once the errors persist.
+// Only the errors reported for the socket count toward the re-dial.
+func (r *connections) connError(c *connection, err error) {
+	if !socketError(err) {
+		return
+	}
+	if c.errs.Add(1) >= maxConnErrors {
+		r.redial(c)
+	}
+}
+
+// redial replaces the connection with a new socket, using backoff while the attempts fail.
+func (r *connections) redial(old *connection) {
+	if !old.redialing.CompareAndSwap(false, true) {
+		return
+	}
+	_ = old.conn.Close()
+
+	go func() {
+		for attempt := 0; ; attempt++ {
+			t := time.NewTimer(TruncatedExponentialBackoff(attempt, redialDelay, maxRedialDelay))
+
+			select {
+			case <-r.done:
+				t.Stop()
+				return
+			case <-old.done:
+				t.Stop()
+				return
+			case <-t.C:
+			}
+
+			if r.replace(old) {
+				return
+			}
+		}
+	}()
+}
+
+func (r *connections) replace(old *connection) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	idx := -1
+	for i, c := range r.conns {
+		if c == old {
+			idx = i
+			break
+		}
+	}
+	// the connection was already removed by a rotation or the close
+	if idx == -1 {
+		return true
+	}
+
+	c, err := r.open()
+	if err != nil {
+		return false
+	}
+
+	r.conns[idx] = c
+	r.redials.Add(1)
+	go runLabeled(func() { r.responses(c) }, labelSubsystem, "reader")
+	return true
+}
+
+func (r *connections) stats() *ConnectionStats {
+	return &ConnectionStats{
+		ReadErrors:  r.readErrors.Load(),
+		WriteErrors: r.writeErrors.Load(),
+		Redials:     r.redials.Load(),
+		KernelDrops: r.kernelDrops.Load(),
 	}
-	r.conns = append(r.conns, c)
-	go r.responses(c)
-	return nil
 }
 
 func (r *connections) WriteMsg(msg *dns.Msg, addr net.Addr) error {
+	if r == nil {
+		return errors.New("the resolver pool has no connections")
+	}
+
 	var n int
 	var err error
 	var out []byte
@@ -140,11 +343,18 @@ func (r *connections) WriteMsg(msg *dns.Msg, addr net.Addr) error {
 	if out, err = msg.Pack(); err == nil {
 		err = errors.New("failed to obtain a connection")
 
-		if conn := r.Next(); conn != nil {
-			_ = conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
-			if n, err = conn.WriteTo(out, addr); err == nil && n < len(out) {
+		if c := r.Next(); c != nil {
+			_ = c.conn.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+			if n, err = c.conn.WriteTo(out, addr); err == nil && n < len(out) {
 				err = fmt.Errorf("only wrote %d bytes of the %d byte message", n, len(out))
 			}
+
+			if err != nil {
+				r.writeErrors.Add(1)
+				r.connError(c, err)
+			} else {
+				c.errs.Store(0)
+			}
 		}
 	}
 	return err
@@ -160,15 +370,31 @@ func (r *connections) responses(c *connection) {
 			return
 		default:
 		}
-		if n, addr, err := c.conn.ReadFrom(b); err == nil && n >= headerSize {
-			m := new(dns.Msg)
-
-			if err := m.Unpack(b[:n]); err == nil && len(m.Question) > 0 {
-				r.resps.Append(&resp{
-					Msg:  m,
-					Addr: addr,
-				})
+		n, addr, err := c.conn.ReadFrom(b)
+		if err != nil {
+			select {
+			case <-c.done:
+				_ = c.conn.Close()
+				return
+			default:
 			}
+			if c.redialing.Load() {
+				return
+			}
+
+			r.readErrors.Add(1)
+			if errors.Is(err, net.ErrClosed) {
+				r.redial(c)
+				return
+			}
+			r.connError(c, err)
+			time.Sleep(TruncatedExponentialBackoff(int(c.errs.Load()), time.Millisecond, redialDelay))
+			continue
+		}
+
+		c.errs.Store(0)
+		if response := r.newResp(b[:n], addr); response != nil {
+			r.resps.Append(response)
 		}
 	}
 }
@@ -5,8 +5,11 @@
 package resolve
 
 import (
+	"errors"
 	"net"
+	"os"
 	"runtime"
+	"syscall"
 	"testing"
 	"time"
 
@@ -26,7 +29,10 @@ func TestConnections(t *testing.T) {
 	defer func() { _ = s.Shutdown() }()
 
 	resps := queue.NewQueue()
-	conn := newConnections(runtime.NumCPU(), resps)
+	conn, err := newConnections(runtime.NumCPU(), resps)
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
 	defer conn.Close()
 
 	for i := 0; i < 100; i++ {
@@ -58,3 +64,103 @@ loop:
 		t.Errorf("received only %f%% of the DNS responses", percent)
 	}
 }
+
+func TestConnectionRedial(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	resps := queue.NewQueue()
+	conns, err := newConnections(1, resps)
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+	// simulate a socket that has become unusable
+	_ = conns.Next().conn.Close()
+
+	for i := 0; i < 20 && conns.stats().Redials == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if stats := conns.stats(); stats.Redials != 1 || stats.ReadErrors == 0 {
+		t.Fatalf("the connection was not re-established: %+v", stats)
+	}
+
+	addr, _ := net.ResolveUDPAddr("udp", addrstr)
+	if err := conns.WriteMsg(QueryMsg(name, 1), addr); err != nil {
+		t.Fatalf("failed to write the message on the new connection: %v", err)
+	}
+
+	timer := time.NewTimer(time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		t.Errorf("failed to receive the response on the new connection")
+	case <-resps.Signal():
+	}
+}
+
+func TestConnectionWriteErrors(t *testing.T) {
+	conns, err := newConnections(1, queue.NewQueue())
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+
+	// the operating system rejects datagrams sent to port zero
+	addr, _ := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	for i := 0; i < maxConnErrors; i++ {
+		if err := conns.WriteMsg(QueryMsg("caffix.net", 1), addr); err == nil {
+			t.Skipf("the write to port zero did not fail")
+		}
+	}
+
+	for i := 0; i < 20 && conns.stats().Redials == 0; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if stats := conns.stats(); stats.WriteErrors != uint64(maxConnErrors) || stats.Redials != 1 {
+		t.Errorf("the persistent write errors did not cause a redial: %+v", stats)
+	}
+}
+
+func TestSocketError(t *testing.T) {
+	for _, test := range []struct {
+		err    error
+		socket bool
+	}{
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.ENETUNREACH)}, false},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.EHOSTUNREACH)}, false},
+		{&net.OpError{Op: "read", Err: os.NewSyscallError("recvfrom", syscall.ECONNREFUSED)}, false},
+		{&net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.EBADF)}, true},
+		{net.ErrClosed, true},
+		{errors.New("only wrote 10 bytes of the 20 byte message"), true},
+	} {
+		if got := socketError(test.err); got != test.socket {
+			t.Errorf("socketError(%v) returned %t instead of %t", test.err, got, test.socket)
+		}
+	}
+}
+
+func TestConnectionUnreachableErrors(t *testing.T) {
+	conns, err := newConnections(1, queue.NewQueue())
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+
+	c := conns.Next()
+	unreachable := &net.OpError{Op: "write", Err: os.NewSyscallError("sendto", syscall.EHOSTUNREACH)}
+	for i := 0; i < 2*maxConnErrors; i++ {
+		conns.connError(c, unreachable)
+	}
+	if c.errs.Load() != 0 || c.redialing.Load() {
+		t.Errorf("the errors caused by the destination counted toward a redial")
+	}
+}
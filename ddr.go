@@ -0,0 +1,208 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/miekg/dns"
+)
+
+// The special-use name queried to discover the designated resolvers, as defined by RFC 9462.
+const ddrName = "_dns.resolver.arpa."
+
+// DesignatedResolver is an encrypted resolver advertised by an unencrypted resolver through
+// Discovery of Designated Resolvers (DDR).
+type DesignatedResolver struct {
+	Priority uint16
+	Target   string
+	ALPN     []string
+	// Port is zero when the default port of the protocol is used
+	Port  uint16
+	Addrs []net.IP
+	// DoHPath is the URI template of the DNS over HTTPS endpoint, when advertised
+	DoHPath string
+}
+
+// Supports returns true when the designated resolver advertised the ALPN protocol ID,
+// such as "dot", "h2" or "h3".
+func (d *DesignatedResolver) Supports(alpn string) bool {
+	for _, a := range d.ALPN {
+		if a == alpn {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverDesignatedResolvers queries the resolver at the provided address for the SVCB
+// records of _dns.resolver.arpa and returns the designated resolvers by priority.
+func DiscoverDesignatedResolvers(ctx context.Context, addr string) ([]*DesignatedResolver, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	return queryDesignated(ctx, addr, &net.Dialer{Timeout: DefaultTimeout})
+}
+
+func queryDesignated(ctx context.Context, addr string, d *net.Dialer) ([]*DesignatedResolver, error) {
+	client := dns.Client{
+		Net:     "udp",
+		Timeout: d.Timeout,
+		Dialer:  d,
+	}
+
+	resp, _, err := client.ExchangeContext(ctx, QueryMsg(ddrName, dns.TypeSVCB), addr)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return nil, errors.New("the resolver did not designate any encrypted resolvers")
+	}
+
+	var designated []*DesignatedResolver
+	for _, rr := range resp.Answer {
+		svcb, ok := rr.(*dns.SVCB)
+		// alias mode records are not used for the discovery
+		if !ok || svcb.Priority == 0 {
+			continue
+		}
+
+		d := &DesignatedResolver{
+			Priority: svcb.Priority,
+			Target:   RemoveLastDot(svcb.Target),
+		}
+		for _, kv := range svcb.Value {
+			switch v := kv.(type) {
+			case *dns.SVCBAlpn:
+				d.ALPN = append(d.ALPN, v.Alpn...)
+			case *dns.SVCBPort:
+				d.Port = v.Port
+			case *dns.SVCBIPv4Hint:
+				d.Addrs = append(d.Addrs, v.Hint...)
+			case *dns.SVCBIPv6Hint:
+				d.Addrs = append(d.Addrs, v.Hint...)
+			case *dns.SVCBDoHPath:
+				d.DoHPath = v.Template
+			}
+		}
+		designated = append(designated, d)
+	}
+	if len(designated) == 0 {
+		return nil, errors.New("the resolver did not designate any encrypted resolvers")
+	}
+
+	sort.SliceStable(designated, func(i, j int) bool {
+		return designated[i].Priority < designated[j].Priority
+	})
+	return designated, nil
+}
+
+// SetDDRUpgrade enables the Discovery of Designated Resolvers for each resolver added to the
+// pool. Resolvers that designate a DNS over TLS endpoint are upgraded to send all queries using
+// the encrypted transport, once the certificate of the endpoint is verified to cover the IP
+// address of the unencrypted resolver. The discovery is skipped while UseTor is in effect.
+func (r *Resolvers) SetDDRUpgrade(enable bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.ddr = enable
+}
+
+func (r *resolver) upgradeViaDDR() {
+	// the discovery connects to the resolver directly, which would bypass the Tor proxy
+	if r.pool.tor.Load() != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	_ = r.rate.Take()
+	timeout := r.xchgs.getTimeout()
+	designated, err := queryDesignated(ctx, r.address.String(), r.pool.conns.dialer(timeout))
+	if err != nil {
+		return
+	}
+
+	for _, d := range designated {
+		if !d.Supports("dot") {
+			continue
+		}
+
+		port := dotPort
+		if d.Port != 0 {
+			port = strconv.Itoa(int(d.Port))
+		}
+
+		addrs := d.Addrs
+		if len(addrs) == 0 {
+			addrs = []net.IP{r.address.IP}
+		}
+		for _, ip := range addrs {
+			addr := net.JoinHostPort(ip.String(), port)
+
+			if r.verifyDesignated(ctx, addr) == nil {
+				r.setDoTAddr(addr)
+				r.encrypted.Store(true)
+				return
+			}
+		}
+	}
+}
+
+// verifyDesignated checks that the certificate of the designated resolver covers the IP
+// address of the unencrypted resolver, as required for verified discovery.
+func (r *resolver) verifyDesignated(ctx context.Context, addr string) error {
+	d := tls.Dialer{
+		NetDialer: r.pool.conns.dialer(r.xchgs.getTimeout()),
+		Config:    r.ddrClientConfig(),
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// ddrClientConfig returns the TLS configuration of the pool verifying the certificate against
+// the IP address of the unencrypted resolver, regardless of the server name set for the pool.
+func (r *resolver) ddrClientConfig() *tls.Config {
+	cfg := r.tlsClientConfig()
+	cfg.ServerName = r.address.IP.String()
+	return cfg
+}
+
+func (r *resolver) setDoTAddr(addr string) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.dotAddr = addr
+}
+
+func (r *resolver) getDoTAddr() string {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.dotAddr
+}
+
+// useTLS returns true when queries to the resolver are sent using DNS over TLS.
+func (r *resolver) useTLS() bool {
+	return r.pool.privacy.Load() || r.encrypted.Load()
+}
@@ -0,0 +1,187 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func ddrHandler(port uint16, hint string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.Question[0].Name != ddrName || req.Question[0].Qtype != dns.TypeSVCB {
+			typeAHandler(w, req)
+			return
+		}
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = []dns.RR{
+			&dns.SVCB{
+				Hdr:      dns.RR_Header{Name: ddrName, Rrtype: dns.TypeSVCB, Class: dns.ClassINET, Ttl: 300},
+				Priority: 2,
+				Target:   "doh.caffix.net.",
+				Value: []dns.SVCBKeyValue{
+					&dns.SVCBAlpn{Alpn: []string{"h2"}},
+					&dns.SVCBDoHPath{Template: "/dns-query{?dns}"},
+				},
+			},
+			&dns.SVCB{
+				Hdr:      dns.RR_Header{Name: ddrName, Rrtype: dns.TypeSVCB, Class: dns.ClassINET, Ttl: 300},
+				Priority: 1,
+				Target:   "dot.caffix.net.",
+				Value: []dns.SVCBKeyValue{
+					&dns.SVCBAlpn{Alpn: []string{"dot"}},
+					&dns.SVCBPort{Port: port},
+					&dns.SVCBIPv4Hint{Hint: []net.IP{net.ParseIP(hint)}},
+				},
+			},
+		}
+		_ = w.WriteMsg(m)
+	}
+}
+
+func TestDiscoverDesignatedResolvers(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = ddrHandler(8853, "127.0.0.1")
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	designated, err := DiscoverDesignatedResolvers(context.Background(), addr)
+	if err != nil || len(designated) != 2 {
+		t.Fatalf("failed to discover the designated resolvers: %v", err)
+	}
+
+	dot, doh := designated[0], designated[1]
+	if !dot.Supports("dot") || dot.Port != 8853 || dot.Target != "dot.caffix.net" ||
+		len(dot.Addrs) != 1 || !dot.Addrs[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("the DNS over TLS resolver was not correctly parsed: %+v", dot)
+	}
+	if !doh.Supports("h2") || doh.Supports("dot") || doh.DoHPath != "/dns-query{?dns}" {
+		t.Errorf("the DNS over HTTPS resolver was not correctly parsed: %+v", doh)
+	}
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	if _, err := DiscoverDesignatedResolvers(context.Background(), addr2); err == nil {
+		t.Errorf("designated resolvers were returned by a resolver without the records")
+	}
+}
+
+func TestDDRUpgrade(t *testing.T) {
+	cert, roots, err := localCertificate()
+	if err != nil {
+		t.Fatalf("failed to create the test certificate: %v", err)
+	}
+
+	// the designated resolver is located at another address, and presents the certificate
+	// covering the address of the unencrypted resolver
+	l, err := tls.Listen("tcp", "127.0.0.2:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to run the test listener: %v", err)
+	}
+
+	st, dotAddr, _, err := RunLocalServer(nil, l, func(s *dns.Server) {
+		s.Net = "tcp-tls"
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = st.Shutdown() }()
+
+	_, portstr, _ := net.SplitHostPort(dotAddr)
+	port, _ := strconv.Atoi(portstr)
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = ddrHandler(uint16(port), "127.0.0.2")
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	// the server name of the pool is not used for the verified discovery
+	r.tlsConfig = &tls.Config{RootCAs: roots, ServerName: "dot.caffix.net"}
+	r.SetDDRUpgrade(true)
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	res := r.pool.LookupResolver("127.0.0.1")
+	for i := 0; i < 50 && !res.encrypted.Load(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !res.encrypted.Load() || res.getDoTAddr() != dotAddr {
+		t.Fatalf("the resolver was not upgraded to the designated resolver")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := r.QueryWithMetadata(ctx, QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the query to the upgraded resolver failed: %v", err)
+	}
+	if resp.Transport != TransportTLS {
+		t.Errorf("the query was sent using %s", resp.Transport)
+	}
+}
+
+func TestDDRUpgradeUnverified(t *testing.T) {
+	cert, _, err := localCertificate()
+	if err != nil {
+		t.Fatalf("failed to create the test certificate: %v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to run the test listener: %v", err)
+	}
+
+	st, dotAddr, _, err := RunLocalServer(nil, l, func(s *dns.Server) {
+		s.Net = "tcp-tls"
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = st.Shutdown() }()
+
+	_, portstr, _ := net.SplitHostPort(dotAddr)
+	port, _ := strconv.Atoi(portstr)
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = ddrHandler(uint16(port), "127.0.0.1")
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	// the certificate is not trusted, so the designated resolver cannot be verified
+	res := r.pool.LookupResolver("127.0.0.1")
+	res.upgradeViaDDR()
+	if res.encrypted.Load() {
+		t.Errorf("the resolver was upgraded to an unverified designated resolver")
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"time"
+)
+
+// SetQueryDeadline sets the default amount of time that QueryBlocking waits for a response
+// when the provided context does not have a deadline. A zero duration removes the default.
+func (r *Resolvers) SetQueryDeadline(d time.Duration) {
+	r.deadline.Store(int64(d))
+}
+
+// queryContext applies the default deadline of the pool to a context without a deadline.
+func (r *Resolvers) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	if d := time.Duration(r.deadline.Load()); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return ctx, func() {}
+}
@@ -0,0 +1,68 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetQueryDeadline(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(timeoutHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	r.SetTimeout(5 * time.Second)
+	defer r.Stop()
+
+	r.SetQueryDeadline(200 * time.Millisecond)
+	start := time.Now()
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err == nil || resp.Rcode != RcodeNoResponse {
+		t.Errorf("the query did not fail when the default deadline expired")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the query waited %s despite the default deadline", elapsed)
+	}
+
+	// an explicit deadline on the context takes precedence over the default
+	ctx, cancel := context.WithTimeout(context.Background(), 600*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	if _, err := r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA)); err == nil {
+		t.Errorf("the query did not fail when the context expired")
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("the default deadline replaced the deadline of the context")
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	ctx, cancel := r.queryContext(context.Background())
+	if _, ok := ctx.Deadline(); ok {
+		t.Errorf("a deadline was applied without a default")
+	}
+	cancel()
+
+	r.SetQueryDeadline(time.Second)
+	ctx, cancel = r.queryContext(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+		t.Errorf("the default deadline was not applied")
+	}
+}
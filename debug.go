@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+)
+
+// DebugStats contains the statistics of the pool served by the debug handler.
+type DebugStats struct {
+	Resolvers   int
+	Connections *ConnectionStats
+}
+
+// DebugResolver contains the health of a single resolver served by the debug handler.
+type DebugResolver struct {
+	*ResolverStats
+	QPS       int
+	Transport string
+	// PayloadSize is the EDNS buffer size found by payload discovery, when enabled
+	PayloadSize uint16 `json:",omitempty"`
+}
+
+// DebugRateLimits contains the rate limits of the pool served by the debug handler.
+type DebugRateLimits struct {
+	QPS int
+	// MaxSet is true when the QPS was provided by SetMaxQPS
+	MaxSet bool
+	// Leased is the QPS reserved by the active leases
+	Leased    int
+	Resolvers map[string]int
+}
+
+// DebugReport contains the complete state of the pool served by the debug handler.
+type DebugReport struct {
+	Stats      *DebugStats
+	Resolvers  []*DebugResolver
+	Inflight   []*InflightQuery
+	Wildcards  map[string]*WildcardSnapshot
+	RateLimits *DebugRateLimits
+}
+
+// DebugHandler returns an http.Handler that serves the state of the pool as JSON, which can
+// be mounted into an existing mux for operational visibility. The last element of the request
+// path selects the stats, resolvers, inflight, wildcards or ratelimits, and any other path
+// returns the complete DebugReport.
+func (r *Resolvers) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var v interface{}
+		switch path.Base(req.URL.Path) {
+		case "stats":
+			v = r.debugStats()
+		case "resolvers":
+			v = r.debugResolvers()
+		case "inflight":
+			v = r.Inflight()
+		case "wildcards":
+			v = r.wildcardSnapshot()
+		case "ratelimits":
+			v = r.debugRateLimits()
+		default:
+			v = &DebugReport{
+				Stats:      r.debugStats(),
+				Resolvers:  r.debugResolvers(),
+				Inflight:   r.Inflight(),
+				Wildcards:  r.wildcardSnapshot(),
+				RateLimits: r.debugRateLimits(),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(v)
+	})
+}
+
+func (r *Resolvers) debugStats() *DebugStats {
+	return &DebugStats{
+		Resolvers:   r.Len(),
+		Connections: r.ConnectionStats(),
+	}
+}
+
+func (r *Resolvers) debugResolvers() []*DebugResolver {
+	var all []*DebugResolver
+
+	for _, res := range r.pool.AllResolvers() {
+		transport := TransportUDP
+		if res.useTLS() {
+			transport = TransportTLS
+		}
+		all = append(all, &DebugResolver{
+			ResolverStats: res.getStats(),
+			QPS:           res.qps,
+			Transport:     transport,
+			PayloadSize:   res.payloadSize(),
+		})
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Address < all[j].Address
+	})
+	return all
+}
+
+func (r *Resolvers) debugRateLimits() *DebugRateLimits {
+	limits := &DebugRateLimits{Resolvers: make(map[string]int)}
+
+	for _, res := range r.pool.AllResolvers() {
+		limits.Resolvers[res.address.String()] = res.qps
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	limits.QPS = r.qps
+	limits.MaxSet = r.maxSet
+	limits.Leased = r.leased
+	return limits
+}
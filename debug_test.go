@@ -0,0 +1,95 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDebugHandler(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	if _, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	_ = r.LoadWildcards(strings.NewReader(`{"wildcard.caffix.net":{"detected":true}}`))
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/resolve/", r.DebugHandler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var report DebugReport
+	getJSON(t, srv.URL+"/debug/resolve/", &report)
+	if report.Stats == nil || report.Stats.Resolvers != 1 || report.RateLimits == nil || report.RateLimits.QPS != 10 {
+		t.Errorf("the report did not include the pool stats and rate limits")
+	}
+	if len(report.Resolvers) != 1 || report.Resolvers[0].ResolverStats == nil ||
+		report.Resolvers[0].Address != addr || report.Resolvers[0].Responses != 1 {
+		t.Errorf("the report did not include the resolver health")
+	}
+	if w := report.Wildcards["wildcard.caffix.net"]; w == nil || !w.Detected {
+		t.Errorf("the report did not include the wildcard cache")
+	}
+
+	var resolvers []*DebugResolver
+	getJSON(t, srv.URL+"/debug/resolve/resolvers", &resolvers)
+	if len(resolvers) != 1 || resolvers[0].QPS != 10 || resolvers[0].Transport != TransportUDP {
+		t.Errorf("the resolvers were not correctly served")
+	}
+
+	var limits DebugRateLimits
+	getJSON(t, srv.URL+"/debug/resolve/ratelimits", &limits)
+	if limits.Resolvers[addr] != 10 {
+		t.Errorf("the rate limits were not correctly served: %+v", limits)
+	}
+
+	var inflight []*InflightQuery
+	getJSON(t, srv.URL+"/debug/resolve/inflight", &inflight)
+	if len(inflight) != 0 {
+		t.Errorf("inflight queries were served without any outstanding queries")
+	}
+
+	resp, err := http.Post(srv.URL+"/debug/resolve/stats", "application/json", nil)
+	if err != nil {
+		t.Fatalf("the request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("the POST request returned status %d", resp.StatusCode)
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("the request for %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "application/json" {
+		t.Fatalf("the request for %s returned status %d and content type %s", url, resp.StatusCode, ct)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode the response for %s: %v", url, err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The environment variables configuring the pool returned by DefaultPool.
+const (
+	// EnvResolvers contains the resolver addresses separated by commas or spaces, and the
+	// name servers of the system configuration are used when it is not set
+	EnvResolvers = "RESOLVE_RESOLVERS"
+	// EnvQPS is the maximum queries per second of each resolver
+	EnvQPS = "RESOLVE_QPS"
+	// EnvMaxQPS is the maximum queries per second of the pool, as provided to SetMaxQPS
+	EnvMaxQPS = "RESOLVE_MAX_QPS"
+	// EnvTimeout is the duration waited for the responses, such as 2s, as provided to SetTimeout
+	EnvTimeout = "RESOLVE_TIMEOUT"
+)
+
+var defaultPool struct {
+	sync.Mutex
+	pool *Resolvers
+}
+
+// DefaultPool returns the pool shared by all the callers in the process, which is created on
+// the first call and configured from the environment variables. Libraries using the shared
+// pool avoid opening their own sockets and rate limiters against the same upstreams. Stop is
+// ignored for the shared pool, which is only released by StopDefaultPool.
+func DefaultPool() (*Resolvers, error) {
+	defaultPool.Lock()
+	defer defaultPool.Unlock()
+
+	if defaultPool.pool != nil {
+		return defaultPool.pool, nil
+	}
+
+	r, err := newPoolFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	r.shared.Store(true)
+	defaultPool.pool = r
+	return r, nil
+}
+
+// StopDefaultPool releases the pool returned by DefaultPool, and the next call creates a new pool.
+func StopDefaultPool() {
+	defaultPool.Lock()
+	defer defaultPool.Unlock()
+
+	if r := defaultPool.pool; r != nil {
+		r.shared.Store(false)
+		r.Stop()
+		defaultPool.pool = nil
+	}
+}
+
+func newPoolFromEnv() (*Resolvers, error) {
+	qps := systemResolverQPS
+	if v := os.Getenv(EnvQPS); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s must be a positive number of queries per second: %s", EnvQPS, v)
+		}
+		qps = n
+	}
+
+	var max int
+	if v := os.Getenv(EnvMaxQPS); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%s must be a positive number of queries per second: %s", EnvMaxQPS, v)
+		}
+		max = n
+	}
+
+	var timeout time.Duration
+	if v := os.Getenv(EnvTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("%s must be a positive duration: %s", EnvTimeout, v)
+		}
+		timeout = d
+	}
+
+	var conf *SystemConfig
+	addrs := strings.FieldsFunc(os.Getenv(EnvResolvers), func(c rune) bool {
+		return c == ',' || c == ' '
+	})
+	if len(addrs) == 0 {
+		var err error
+		if conf, err = SystemResolverConfig(); err != nil {
+			return nil, errors.Join(fmt.Errorf("%s was not set", EnvResolvers), err)
+		}
+		addrs = conf.Servers
+	}
+
+	r, err := OpenResolvers(realClock{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.AddResolvers(qps, addrs...)
+	if err == nil && r.Len() == 0 {
+		err = errors.New("no valid resolver addresses were configured")
+	}
+	if err != nil {
+		r.Stop()
+		return nil, err
+	}
+	if conf != nil {
+		r.SetSearchDomains(conf.Ndots, conf.Search...)
+	}
+
+	if max > 0 {
+		r.SetMaxQPS(max)
+	}
+	if timeout > 0 {
+		r.SetTimeout(timeout)
+	}
+	return r, nil
+}
@@ -0,0 +1,61 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultPool(t *testing.T) {
+	t.Setenv(EnvResolvers, "192.168.1.1, 192.168.1.2:5353")
+	t.Setenv(EnvQPS, "20")
+	t.Setenv(EnvTimeout, "500ms")
+	defer StopDefaultPool()
+
+	r, err := DefaultPool()
+	if err != nil {
+		t.Fatalf("failed to create the default pool: %v", err)
+	}
+	if r.Len() != 2 || r.QPS() != 40 || r.timeout != 500*time.Millisecond {
+		t.Errorf("the environment was not applied: %d resolvers, %d QPS and a timeout of %s", r.Len(), r.QPS(), r.timeout)
+	}
+	if again, _ := DefaultPool(); again != r {
+		t.Errorf("a second pool was created")
+	}
+
+	r.Stop()
+	select {
+	case <-r.done:
+		t.Errorf("the shared pool was stopped by a caller")
+	default:
+	}
+
+	StopDefaultPool()
+	select {
+	case <-r.done:
+	default:
+		t.Errorf("the shared pool was not stopped by StopDefaultPool")
+	}
+	if again, err := DefaultPool(); err != nil || again == r {
+		t.Errorf("a new pool was not created after StopDefaultPool: %v", err)
+	}
+}
+
+func TestDefaultPoolErrors(t *testing.T) {
+	t.Setenv(EnvResolvers, "192.168.1.1")
+	t.Setenv(EnvQPS, "none")
+	defer StopDefaultPool()
+
+	if _, err := DefaultPool(); err == nil {
+		t.Errorf("the invalid QPS did not return an error")
+	}
+
+	t.Setenv(EnvQPS, "")
+	t.Setenv(EnvResolvers, "300.300.300.300")
+	if _, err := DefaultPool(); err == nil {
+		t.Errorf("the invalid resolver address did not return an error")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// DefaultFormatErrorLimit is the number of consecutive FORMERR and NOTIMP responses to
+// standard queries that causes a resolver to be removed from the pool.
+const DefaultFormatErrorLimit uint64 = 100
+
+// DemotionHook is called with the address of a resolver removed from the pool and the reason.
+type DemotionHook func(addr string, reason string)
+
+// SetFormatErrorLimit sets the number of consecutive FORMERR and NOTIMP responses to standard
+// queries that causes a resolver to be removed from the pool. Resolvers answering this way are
+// often behind broken middleboxes, and retrying the queries only consumes the retry budget.
+// Providing zero disables the removals.
+func (r *Resolvers) SetFormatErrorLimit(limit uint64) {
+	r.demoteLimit.Store(limit)
+}
+
+// SetDemotionHook sets the hook called when a resolver is removed from the pool due to
+// persistent format errors. Providing nil removes the hook.
+func (r *Resolvers) SetDemotionHook(hook DemotionHook) {
+	if hook == nil {
+		r.demoteHook.Store(nil)
+		return
+	}
+	r.demoteHook.Store(&hook)
+}
+
+func (r *Resolvers) demoteBrokenResolvers() {
+	limit := r.demoteLimit.Load()
+	if limit == 0 {
+		return
+	}
+
+	for _, res := range r.pool.AllResolvers() {
+		res.stats.Lock()
+		count := res.stats.FormatFailures
+		res.stats.Unlock()
+
+		if count < limit {
+			continue
+		}
+
+		res.stop()
+		addr := res.address.String()
+		reason := fmt.Sprintf("%d consecutive FORMERR or NOTIMP responses", count)
+		r.log.Printf("Resolver %s removed from the pool: %s", addr, reason)
+		r.emitEvent(&Event{Type: EventResolverRemoved, Resolver: addr, Reason: reason})
+		if hook := r.demoteHook.Load(); hook != nil {
+			(*hook)(addr, reason)
+		}
+	}
+}
+
+// standardQuery returns true when the message is for a query that every resolver is expected
+// to support, so FORMERR and NOTIMP responses cannot be blamed on the query type.
+func standardQuery(m *dns.Msg) bool {
+	if m.Opcode != dns.OpcodeQuery || len(m.Question) != 1 {
+		return false
+	}
+
+	switch m.Question[0].Qtype {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestFormatErrorDemotion(t *testing.T) {
+	name := "formerr.org."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(req, dns.RcodeFormatError)
+		_ = w.WriteMsg(m)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	limit := 5
+	r.SetFormatErrorLimit(uint64(limit))
+	demoted := make(chan string, 1)
+	r.SetDemotionHook(func(addr string, reason string) {
+		demoted <- addr
+	})
+
+	for i := 0; i < limit; i++ {
+		if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || resp.Rcode != dns.RcodeFormatError {
+			t.Fatalf("the query did not return the format error")
+		}
+	}
+
+	select {
+	case addr := <-demoted:
+		if addr != addrstr {
+			t.Errorf("the demotion hook received %s instead of %s", addr, addrstr)
+		}
+	case <-time.After(thresholdCheckInterval + time.Second):
+		t.Fatalf("the resolver was not demoted")
+	}
+	if r.Len() != 0 {
+		t.Errorf("the demoted resolver remains in the pool")
+	}
+}
+
+func TestFormatFailuresCount(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.168.1.1")
+	defer r.Stop()
+	res := r.pool.AllResolvers()[0]
+
+	reply := func(qtype uint16, rcode int) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetRcode(QueryMsg("caffix.net", qtype), rcode)
+		return m
+	}
+	failures := func() uint64 {
+		res.stats.Lock()
+		defer res.stats.Unlock()
+
+		return res.stats.FormatFailures
+	}
+
+	res.collectStats(reply(dns.TypeA, dns.RcodeFormatError))
+	res.collectStats(reply(dns.TypeAAAA, dns.RcodeNotImplemented))
+	// the other query types and timeouts are ignored
+	res.collectStats(reply(dns.TypeANY, dns.RcodeNotImplemented))
+	res.collectStats(reply(dns.TypeA, RcodeNoResponse))
+	if n := failures(); n != 2 {
+		t.Errorf("counted %d format failures instead of 2", n)
+	}
+
+	res.collectStats(reply(dns.TypeA, dns.RcodeNameError))
+	if n := failures(); n != 0 {
+		t.Errorf("the format failures were not reset by a valid response: %d", n)
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+)
+
+// SetBindInterface binds the sockets used by the pool to the named network interface, which
+// is needed on multi-homed hosts and inside network namespaces without policy routing.
+// Providing an empty name removes the binding. This is only supported on Linux.
+func (r *Resolvers) SetBindInterface(name string) error {
+	if r.conns == nil {
+		return errors.New("the resolver pool has no connections")
+	}
+	if name != "" {
+		if !deviceBindingSupported {
+			return errors.New("binding to a network interface is not supported on this platform")
+		}
+		if _, err := net.InterfaceByName(name); err != nil {
+			return err
+		}
+	}
+
+	return r.conns.reconfigure(func(opts *socketOpts) {
+		opts.device = name
+	})
+}
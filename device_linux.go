@@ -0,0 +1,15 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import "golang.org/x/sys/unix"
+
+const deviceBindingSupported = true
+
+func bindToDevice(fd uintptr, device string) error {
+	return unix.BindToDevice(int(fd), device)
+}
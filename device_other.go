@@ -0,0 +1,15 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package resolve
+
+import "errors"
+
+const deviceBindingSupported = false
+
+func bindToDevice(fd uintptr, device string) error {
+	return errors.New("binding to a network interface is not supported on this platform")
+}
@@ -0,0 +1,71 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetBindInterface(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.SetBindInterface("not-an-interface0"); err == nil {
+		t.Errorf("a missing network interface was accepted")
+	}
+	if !deviceBindingSupported {
+		t.Skip("binding to a network interface is not supported on this platform")
+	}
+
+	lo := loopbackInterface()
+	if lo == "" {
+		t.Skip("unable to identify the loopback interface")
+	}
+	if err := r.SetBindInterface(lo); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			t.Skipf("binding to the interface requires privileges: %v", err)
+		}
+		t.Fatalf("failed to bind to the %s interface: %v", lo, err)
+	}
+
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	_ = r.AddResolvers(10, addrstr)
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); err != nil || resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("the query failed using the sockets bound to %s: %v", lo, err)
+	}
+	if device := r.conns.getOptions().device; device != lo {
+		t.Errorf("the connections were bound to %s instead of %s", device, lo)
+	}
+	if err := r.SetBindInterface(""); err != nil || r.conns.getOptions().device != "" {
+		t.Errorf("failed to remove the interface binding: %v", err)
+	}
+}
+
+func loopbackInterface() string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// maxDomainStats is the number of registered domains tracked by DomainStats, which keeps the
+// memory bounded during scans of many unrelated names.
+const maxDomainStats = 10000
+
+// DomainStats contains the distribution of the rcodes returned for the names below a
+// registered domain, such as example.com for www.example.com.
+type DomainStats struct {
+	Domain string
+	// Rcodes counts the responses for each rcode, including RcodeNoResponse for the timeouts
+	Rcodes map[int]uint64
+	// Resolvers holds the distribution of each resolver, keyed by the resolver address
+	Resolvers map[string]map[int]uint64
+	Total     uint64
+}
+
+// Ratio returns the fraction of the responses that had the rcode.
+func (d *DomainStats) Ratio(rcode int) float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Rcodes[rcode]) / float64(d.Total)
+}
+
+// NXDomainRatio returns the fraction of the responses that were NXDOMAIN.
+func (d *DomainStats) NXDomainRatio() float64 {
+	return d.Ratio(dns.RcodeNameError)
+}
+
+// ServFailRatio returns the fraction of the responses that were SERVFAIL.
+func (d *DomainStats) ServFailRatio() float64 {
+	return d.Ratio(dns.RcodeServerFailure)
+}
+
+type domainStats struct {
+	sync.Mutex
+	domains map[string]*DomainStats
+}
+
+func (ds *domainStats) record(addr, name string, rcode int) {
+	domain := strings.ToLower(RemoveLastDot(name))
+	if d, err := publicsuffix.EffectiveTLDPlusOne(domain); err == nil {
+		domain = d
+	}
+
+	ds.Lock()
+	defer ds.Unlock()
+
+	if ds.domains == nil {
+		ds.domains = make(map[string]*DomainStats)
+	}
+
+	d, found := ds.domains[domain]
+	if !found {
+		if len(ds.domains) >= maxDomainStats {
+			return
+		}
+		d = &DomainStats{
+			Domain:    domain,
+			Rcodes:    make(map[int]uint64),
+			Resolvers: make(map[string]map[int]uint64),
+		}
+		ds.domains[domain] = d
+	}
+
+	d.Total++
+	d.Rcodes[rcode]++
+	if d.Resolvers[addr] == nil {
+		d.Resolvers[addr] = make(map[int]uint64)
+	}
+	d.Resolvers[addr][rcode]++
+}
+
+// DomainStats returns the rcode distributions collected for each registered domain queried
+// through the pool. A rising NXDOMAIN or SERVFAIL ratio for a single domain reveals targets
+// deploying rate limiting, and the distributions of the resolvers reveal those failing only
+// for the zone.
+func (r *Resolvers) DomainStats() []*DomainStats {
+	ds := r.domains
+	ds.Lock()
+	defer ds.Unlock()
+
+	var all []*DomainStats
+	for _, d := range ds.domains {
+		c := &DomainStats{
+			Domain:    d.Domain,
+			Rcodes:    make(map[int]uint64, len(d.Rcodes)),
+			Resolvers: make(map[string]map[int]uint64, len(d.Resolvers)),
+			Total:     d.Total,
+		}
+		for rcode, count := range d.Rcodes {
+			c.Rcodes[rcode] = count
+		}
+		for addr, rcodes := range d.Resolvers {
+			c.Resolvers[addr] = make(map[int]uint64, len(rcodes))
+			for rcode, count := range rcodes {
+				c.Resolvers[addr][rcode] = count
+			}
+		}
+		all = append(all, c)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Domain < all[j].Domain
+	})
+	return all
+}
+
+// ResetDomainStats removes the rcode distributions collected for the registered domains.
+func (r *Resolvers) ResetDomainStats() {
+	ds := r.domains
+	ds.Lock()
+	defer ds.Unlock()
+
+	ds.domains = nil
+}
@@ -0,0 +1,48 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestDomainStats(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.168.1.1")
+	defer r.Stop()
+	res := r.pool.AllResolvers()[0]
+
+	reply := func(name string, rcode int) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetRcode(QueryMsg(name, dns.TypeA), rcode)
+		return m
+	}
+
+	res.collectStats(reply("www.caffix.net", dns.RcodeSuccess))
+	res.collectStats(reply("foo.caffix.net", dns.RcodeNameError))
+	res.collectStats(reply("bar.caffix.net", dns.RcodeNameError))
+	res.collectStats(reply("mail.caffix.net", dns.RcodeServerFailure))
+	res.collectStats(reply("www.owasp.org", dns.RcodeSuccess))
+
+	all := r.DomainStats()
+	if len(all) != 2 || all[0].Domain != "caffix.net" || all[1].Domain != "owasp.org" {
+		t.Fatalf("the stats were not collected for the registered domains: %v", all)
+	}
+
+	d := all[0]
+	if d.Total != 4 || d.NXDomainRatio() != 0.5 || d.ServFailRatio() != 0.25 {
+		t.Errorf("the distribution for %s was unexpected: %+v", d.Domain, d)
+	}
+	if n := d.Resolvers["192.168.1.1:53"][dns.RcodeNameError]; n != 2 {
+		t.Errorf("the resolver was counted for %d NXDOMAIN responses instead of two", n)
+	}
+
+	r.ResetDomainStats()
+	if all := r.DomainStats(); len(all) != 0 {
+		t.Errorf("the stats remained after the reset")
+	}
+}
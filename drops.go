@@ -0,0 +1,55 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+const dropCheckInterval = 5 * time.Second
+
+// SetReadBuffer sets the size in bytes of the kernel receive buffer for the UDP sockets used
+// by the pool, which prevents responses from being dropped at very high QPS. Providing zero
+// restores the default size of the operating system.
+func (r *Resolvers) SetReadBuffer(bytes int) error {
+	if r.conns == nil {
+		return errors.New("the resolver pool has no connections")
+	}
+	if bytes < 0 {
+		return errors.New("the read buffer size cannot be negative")
+	}
+
+	return r.conns.reconfigure(func(opts *socketOpts) {
+		opts.rcvbuf = bytes
+	})
+}
+
+// updateDrops adds the responses dropped by the kernel since the last check to the counter.
+// The counts are only available on the platforms that expose them for each socket.
+func (r *connections) updateDrops() {
+	r.Lock()
+	var pcs []net.PacketConn
+	for _, c := range r.conns {
+		pcs = append(pcs, c.conn)
+	}
+	r.Unlock()
+
+	counts := socketDrops(pcs)
+	if counts == nil {
+		return
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for inode, n := range counts {
+		if last := r.dropCounts[inode]; n > last {
+			r.kernelDrops.Add(n - last)
+		}
+	}
+	r.dropCounts = counts
+}
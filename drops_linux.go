@@ -0,0 +1,87 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build linux
+
+package resolve
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// socketDrops returns the number of datagrams dropped by the kernel for each of the sockets,
+// keyed by the socket inode and obtained from /proc/net/udp and /proc/net/udp6.
+func socketDrops(pcs []net.PacketConn) map[uint64]uint64 {
+	inodes := make(map[uint64]struct{})
+	for _, pc := range pcs {
+		if inode, ok := socketInode(pc); ok {
+			inodes[inode] = struct{}{}
+		}
+	}
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	counts := make(map[uint64]uint64)
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		parseSocketDrops(path, inodes, counts)
+	}
+	return counts
+}
+
+func socketInode(pc net.PacketConn) (uint64, bool) {
+	sc, ok := pc.(syscall.Conn)
+	if !ok {
+		return 0, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return 0, false
+	}
+
+	var st unix.Stat_t
+	var staterr error
+	if err := raw.Control(func(fd uintptr) {
+		staterr = unix.Fstat(int(fd), &st)
+	}); err != nil || staterr != nil {
+		return 0, false
+	}
+	return st.Ino, true
+}
+
+func parseSocketDrops(path string, inodes map[uint64]struct{}, counts map[uint64]uint64) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// skip the header line
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, found := inodes[inode]; !found {
+			continue
+		}
+		if drops, err := strconv.ParseUint(fields[12], 10, 64); err == nil {
+			counts[inode] = drops
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux
+
+package resolve
+
+import "net"
+
+func socketDrops(pcs []net.PacketConn) map[uint64]uint64 {
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSetReadBuffer(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.SetReadBuffer(-1); err == nil {
+		t.Errorf("a negative read buffer size was accepted")
+	}
+	if err := r.SetReadBuffer(1 << 20); err != nil {
+		t.Fatalf("failed to set the read buffer size: %v", err)
+	}
+	if opts := r.conns.getOptions(); opts.rcvbuf != 1<<20 {
+		t.Errorf("the read buffer size was not stored: %d", opts.rcvbuf)
+	}
+	if stats := r.ConnectionStats(); stats.KernelDrops != 0 {
+		t.Errorf("drops were reported before any responses were received")
+	}
+}
+
+func TestSocketDrops(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for UDP datagrams: %v", err)
+	}
+	defer pc.Close()
+
+	if socketDrops([]net.PacketConn{pc}) == nil {
+		t.Skip("the kernel drop counters are not available on this platform")
+	}
+	_ = pc.(*net.UDPConn).SetReadBuffer(1)
+
+	sender, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("unable to dial the UDP socket: %v", err)
+	}
+	defer sender.Close()
+
+	// overflow the receive buffer, since the datagrams are never read
+	payload := make([]byte, 512)
+	for i := 0; i < 256; i++ {
+		_, _ = sender.Write(payload)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	var drops uint64
+	for _, n := range socketDrops([]net.PacketConn{pc}) {
+		drops += n
+	}
+	if drops == 0 {
+		t.Errorf("the datagrams dropped by the kernel were not counted")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+)
+
+const maxDSCP = 63
+
+// SetDSCP marks the queries sent by the pool with the provided differentiated services code
+// point, so the scanner traffic can be classified and shaped separately from production DNS.
+// Providing zero removes the marking. This is only supported on Unix platforms.
+func (r *Resolvers) SetDSCP(dscp int) error {
+	if r.conns == nil {
+		return errors.New("the resolver pool has no connections")
+	}
+	if dscp < 0 || dscp > maxDSCP {
+		return fmt.Errorf("the DSCP value %d is outside the range of 0 to %d", dscp, maxDSCP)
+	}
+	if dscp > 0 && !tosMarkingSupported {
+		return errors.New("marking the traffic class is not supported on this platform")
+	}
+
+	return r.conns.reconfigure(func(opts *socketOpts) {
+		// the DSCP occupies the upper six bits of the TOS and traffic class fields
+		opts.tos = dscp << 2
+	})
+}
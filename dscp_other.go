@@ -0,0 +1,15 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !unix
+
+package resolve
+
+import "errors"
+
+const tosMarkingSupported = false
+
+func setTOS(fd uintptr, network string, tos int) error {
+	return errors.New("marking the traffic class is not supported on this platform")
+}
@@ -0,0 +1,51 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+func TestSetDSCP(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	for _, dscp := range []int{-1, 64} {
+		if err := r.SetDSCP(dscp); err == nil {
+			t.Errorf("the invalid DSCP value %d was accepted", dscp)
+		}
+	}
+	if !tosMarkingSupported {
+		t.Skip("marking the traffic class is not supported on this platform")
+	}
+
+	// expedited forwarding
+	if err := r.SetDSCP(46); err != nil {
+		t.Fatalf("failed to set the DSCP value: %v", err)
+	}
+	for _, c := range r.conns.conns {
+		if tos := trafficClass(c.conn); tos != 46<<2 {
+			t.Errorf("the socket %s has the traffic class %d", c.conn.LocalAddr(), tos)
+		}
+	}
+
+	if err := r.SetDSCP(0); err != nil || r.conns.getOptions().tos != 0 {
+		t.Errorf("failed to remove the DSCP marking: %v", err)
+	}
+}
+
+func trafficClass(conn net.PacketConn) int {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		tos, _ := ipv4.NewPacketConn(conn).TOS()
+		return tos
+	}
+
+	tc, _ := ipv6.NewPacketConn(conn).TrafficClass()
+	return tc
+}
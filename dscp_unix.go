@@ -0,0 +1,28 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build unix
+
+package resolve
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const tosMarkingSupported = true
+
+func setTOS(fd uintptr, network string, tos int) error {
+	if !strings.HasSuffix(network, "6") {
+		return unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	}
+
+	if err := unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS, tos); err != nil {
+		return err
+	}
+	// dual-stack sockets use the TOS field for the queries sent to IPv4 addresses
+	_ = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_TOS, tos)
+	return nil
+}
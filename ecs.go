@@ -30,6 +30,7 @@ func (r *Resolvers) ClientSubnetCheck() {
 			msgsToRes[key] = res
 			msglock.Unlock()
 			res.writeReq(&request{
+				Ctx:    r.ctx,
 				Res:    res,
 				Msg:    msg,
 				Result: ch,
@@ -0,0 +1,112 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the change in the lifecycle of the pool described by an Event.
+type EventType int
+
+const (
+	EventResolverAdded EventType = iota
+	EventResolverRemoved
+	EventResolverQuarantined
+	EventRateChanged
+	EventWildcardDetected
+	EventShutdownStarted
+	EventShutdownCompleted
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventResolverAdded:
+		return "resolver added"
+	case EventResolverRemoved:
+		return "resolver removed"
+	case EventResolverQuarantined:
+		return "resolver quarantined"
+	case EventRateChanged:
+		return "rate changed"
+	case EventWildcardDetected:
+		return "wildcard detected"
+	case EventShutdownStarted:
+		return "shutdown started"
+	case EventShutdownCompleted:
+		return "shutdown completed"
+	}
+	return "unknown"
+}
+
+// Event describes a change in the lifecycle of the pool.
+type Event struct {
+	Type EventType
+	// Resolver is the address of the resolver for the resolver events
+	Resolver string
+	// Reason explains the removal of a resolver
+	Reason string
+	// QPS is the rate of the pool after a rate change
+	QPS int
+	// Until is the end of the quarantine
+	Until time.Time
+	// Subdomain is the name having the detected wildcard
+	Subdomain string
+	Time      time.Time
+}
+
+type eventSubscribers struct {
+	sync.Mutex
+	subs map[chan *Event]struct{}
+}
+
+// SubscribeEvents returns a channel receiving the lifecycle events of the pool, along with the
+// function that ends the subscription. Events are dropped when the channel buffer is full, so
+// the pool is never delayed by a slow reader.
+func (r *Resolvers) SubscribeEvents() (<-chan *Event, func()) {
+	ch := make(chan *Event, monitorEventsBuffer)
+
+	es := r.events
+	es.Lock()
+	if es.subs == nil {
+		es.subs = make(map[chan *Event]struct{})
+	}
+	es.subs[ch] = struct{}{}
+	es.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			es.Lock()
+			delete(es.subs, ch)
+			es.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (r *Resolvers) emitEvent(ev *Event) {
+	es := r.events
+	if es == nil {
+		return
+	}
+
+	es.Lock()
+	defer es.Unlock()
+
+	if len(es.subs) == 0 {
+		return
+	}
+
+	ev.Time = r.clock.Now()
+	for ch := range es.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSubscribeEvents(t *testing.T) {
+	r := NewResolvers()
+	events, unsubscribe := r.SubscribeEvents()
+	defer unsubscribe()
+
+	next := func(typ EventType) *Event {
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == typ {
+					return ev
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("no %s event was received", typ)
+				return nil
+			}
+		}
+	}
+
+	_ = r.AddResolvers(10, "192.168.1.1")
+	if ev := next(EventResolverAdded); ev.Resolver != "192.168.1.1:53" {
+		t.Errorf("the event provided the resolver %s", ev.Resolver)
+	}
+	if ev := next(EventRateChanged); ev.QPS != 10 {
+		t.Errorf("the event provided %d QPS instead of 10", ev.QPS)
+	}
+
+	_ = r.Quarantine("192.168.1.1", time.Minute)
+	if ev := next(EventResolverQuarantined); ev.Resolver != "192.168.1.1:53" || ev.Until.IsZero() {
+		t.Errorf("the quarantine event was incomplete: %+v", ev)
+	}
+
+	r.SetMaxQPS(5)
+	if ev := next(EventRateChanged); ev.QPS != 5 {
+		t.Errorf("the event provided %d QPS instead of 5", ev.QPS)
+	}
+
+	r.Stop()
+	next(EventShutdownStarted)
+	next(EventShutdownCompleted)
+}
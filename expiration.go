@@ -0,0 +1,90 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+const minTimeoutCheckInterval = 10 * time.Millisecond
+
+// SetQtypeTimeout updates the amount of time the pool will wait for responses to queries of
+// the provided type, such as a longer duration for ANY and a shorter one for A queries.
+// Providing a duration of zero restores the timeout used for the other types.
+func (r *Resolvers) SetQtypeTimeout(qtype uint16, d time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	if d <= 0 {
+		delete(r.qtypeTOs, qtype)
+	} else {
+		r.qtypeTOs[qtype] = d
+	}
+	r.updateResolverTimeouts()
+}
+
+// SetResolverTimeout updates the amount of time the pool will wait for response messages from
+// the resolver at the provided address, overriding the timeout set for the pool. Providing a
+// duration of zero restores the timeout used for the pool.
+func (r *Resolvers) SetResolverTimeout(addr string, d time.Duration) error {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("%s is not a valid resolver address", addr)
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if d <= 0 {
+		delete(r.resTOs, ip.String())
+	} else {
+		r.resTOs[ip.String()] = d
+	}
+	r.updateResolverTimeouts()
+	return nil
+}
+
+// resolverTimeout returns the timeout for the resolver at the IP address. The caller must hold the lock.
+func (r *Resolvers) resolverTimeout(ip string) time.Duration {
+	if d, found := r.resTOs[ip]; found {
+		return d
+	}
+	return r.timeout
+}
+
+// timeoutCheckInterval returns half of the shortest timeout configured on the pool, so the
+// expired requests are identified with sufficient precision.
+func (r *Resolvers) timeoutCheckInterval() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.checkInterval()
+}
+
+// checkInterval is the implementation of timeoutCheckInterval. The caller must hold the pool lock.
+func (r *Resolvers) checkInterval() time.Duration {
+	d := r.timeout
+	for _, t := range r.qtypeTOs {
+		if t < d {
+			d = t
+		}
+	}
+	for _, t := range r.resTOs {
+		if t < d {
+			d = t
+		}
+	}
+
+	if d /= 2; d < minTimeoutCheckInterval {
+		d = minTimeoutCheckInterval
+	}
+	return d
+}
@@ -0,0 +1,76 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetQtypeTimeout(t *testing.T) {
+	dns.HandleFunc("timeout.org.", timeoutHandler)
+	defer dns.HandleRemove("timeout.org.")
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetTimeout(5 * time.Second)
+	r.SetQtypeTimeout(dns.TypeA, 100*time.Millisecond)
+
+	start := time.Now()
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("timeout.org", dns.TypeA))
+	if err == nil && resp.Rcode != RcodeNoResponse {
+		t.Errorf("the query did not time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the qtype timeout was not used: the query expired after %s", elapsed)
+	}
+
+	res := r.pool.AllResolvers()[0]
+	if d := res.xchgs.getQtypeTimeout(dns.TypeAAAA); d != 5*time.Second {
+		t.Errorf("the AAAA queries expire after %s instead of the pool timeout", d)
+	}
+	r.SetQtypeTimeout(dns.TypeA, 0)
+	if d := res.xchgs.getQtypeTimeout(dns.TypeA); d != 5*time.Second {
+		t.Errorf("the qtype timeout was not removed: %s", d)
+	}
+}
+
+func TestSetResolverTimeout(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.168.1.1", "192.168.1.2")
+	defer r.Stop()
+
+	if err := r.SetResolverTimeout("bad address", time.Second); err == nil {
+		t.Errorf("an invalid resolver address was accepted")
+	}
+	if err := r.SetResolverTimeout("192.168.1.1:53", 500*time.Millisecond); err != nil {
+		t.Fatalf("failed to set the resolver timeout: %v", err)
+	}
+	r.SetTimeout(3 * time.Second)
+
+	for _, res := range r.pool.AllResolvers() {
+		expected := 3 * time.Second
+		if res.address.IP.String() == "192.168.1.1" {
+			expected = 500 * time.Millisecond
+		}
+		if d := res.xchgs.getTimeout(); d != expected {
+			t.Errorf("resolver %s has a timeout of %s instead of %s", res.address, d, expected)
+		}
+	}
+	if d := r.timeoutCheckInterval(); d != 250*time.Millisecond {
+		t.Errorf("the timeout check interval was %s instead of 250ms", d)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	detectorCheckInterval = 15 * time.Second
+	// detectorFailureLimit is the number of consecutive failed health checks of the wildcard
+	// detection resolver that causes another resolver to be promoted
+	detectorFailureLimit = 2
+)
+
+func (r *Resolvers) detectorChecks(stop chan struct{}) {
+	t := time.NewTicker(detectorCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-stop:
+			return
+		case <-t.C:
+			r.checkDetector()
+		}
+	}
+}
+
+// checkDetector sends the health probe to the wildcard detection resolver, and promotes another
+// healthy resolver when the detector has been stopped or has failed consecutive health checks.
+func (r *Resolvers) checkDetector() {
+	d := r.getDetectionResolver()
+	if d == nil {
+		return
+	}
+
+	if !d.stopped() {
+		if r.probeResolver(d) == nil {
+			r.detectorFails.Store(0)
+			r.detectorGood.Store(r.clock.Now().UnixNano())
+			return
+		}
+		if r.detectorFails.Add(1) < detectorFailureLimit {
+			return
+		}
+	}
+	r.failoverDetector(d)
+}
+
+func (r *Resolvers) probeResolver(res *resolver) error {
+	ctx, cancel := context.WithCancel(r.ctx)
+	defer cancel()
+
+	probe := r.healthProbe()
+	return probe.Check(res.address.String(), res.exchangeQuery(ctx, probe.Msg()))
+}
+
+// failoverDetector promotes a healthy resolver of the pool to replace the failed wildcard
+// detection resolver. The subdomains found without a wildcard since the last successful health
+// check of the failed detector are tested again, since the failures cause missed detections.
+func (r *Resolvers) failoverDetector(failed *resolver) bool {
+	for _, res := range r.pool.AllResolvers() {
+		if res == failed || res.stopped() || res.quarantined() || r.probeResolver(res) != nil {
+			continue
+		}
+
+		r.Lock()
+		if r.detector != failed {
+			// the detector was replaced in the meantime
+			r.Unlock()
+			return true
+		}
+		r.detector = res
+		r.Unlock()
+
+		since := time.Unix(0, r.detectorGood.Load())
+		r.detectorFails.Store(0)
+		r.detectorGood.Store(r.clock.Now().UnixNano())
+		r.log.Printf("Wildcard detection resolver %s failed and was replaced by %s", failed.address, res.address)
+
+		affected := r.undetectedSince(since)
+		go runLabeled(func() {
+			for _, sub := range affected {
+				r.RetestWildcard(r.ctx, sub)
+			}
+		}, labelSubsystem, "failover")
+		return true
+	}
+
+	r.log.Printf("Wildcard detection resolver %s failed and no healthy resolver could replace it", failed.address)
+	return false
+}
+
+// undetectedSince returns the subdomains tested without finding a wildcard since the time.
+func (r *Resolvers) undetectedSince(since time.Time) []string {
+	r.Lock()
+	wildcards := make(map[string]*wildcard, len(r.wildcards))
+	for sub, w := range r.wildcards {
+		wildcards[sub] = w
+	}
+	r.Unlock()
+
+	var subs []string
+	for sub, w := range wildcards {
+		w.Lock()
+		if !w.Detected && !w.tested.Before(since) {
+			subs = append(subs, sub)
+		}
+		w.Unlock()
+	}
+	return subs
+}
+
+func (r *resolver) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+	}
+	return false
+}
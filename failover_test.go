@@ -0,0 +1,64 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDetectorFailover(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeServerFailure)
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(wildcardHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	r.SetDetectionResolver(100, addr1)
+	_ = r.AddResolvers(100, addr2)
+	defer r.Stop()
+
+	events, unsubscribe := r.SubscribeWildcards()
+	defer unsubscribe()
+
+	// the failing detector misses the wildcard
+	if w := r.getWildcard(context.Background(), "wildcard.domain.com"); w.Detected {
+		t.Fatalf("the failing detector found the wildcard")
+	}
+
+	for i := 0; i < detectorFailureLimit; i++ {
+		r.checkDetector()
+	}
+	if d := r.getDetectionResolver(); d == nil || d.address.String() != addr2 {
+		t.Fatalf("the healthy resolver was not promoted to replace the detector")
+	}
+
+	select {
+	case ev := <-events:
+		if !ev.Retest || !ev.Detected || ev.Subdomain != "wildcard.domain.com" {
+			t.Errorf("the affected subdomain was not tested again: %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("the affected subdomain was not tested again")
+	}
+}
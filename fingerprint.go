@@ -0,0 +1,154 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Fingerprint contains the software and instance details disclosed by a resolver through the
+// CHAOS class queries and the EDNS name server identifier.
+type Fingerprint struct {
+	Address  string
+	Version  string
+	Hostname string
+	ID       string
+	NSID     string
+	// Software is the name of the implementation identified from the version, when known
+	Software string
+}
+
+var softwareNames = []struct {
+	substr string
+	name   string
+}{
+	{"bind", "BIND"},
+	{"unbound", "Unbound"},
+	{"powerdns", "PowerDNS"},
+	{"dnsmasq", "dnsmasq"},
+	{"knot", "Knot Resolver"},
+	{"microsoft", "Microsoft DNS"},
+	{"coredns", "CoreDNS"},
+	{"nsd", "NSD"},
+}
+
+// Instance returns the identifier of the server instance, which distinguishes the anycast
+// nodes and load balanced servers behind the same address.
+func (f *Fingerprint) Instance() string {
+	for _, id := range []string{f.NSID, f.ID, f.Hostname} {
+		if id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// FingerprintResolver queries version.bind, hostname.bind and id.server using the CHAOS
+// class, along with the NSID option, to identify the software and instance of the resolver.
+// An error is returned when the resolver did not respond to any of the queries.
+func FingerprintResolver(ctx context.Context, addr string) (*Fingerprint, error) {
+	return fingerprint(ctx, addr, func(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, error) {
+		client := dns.Client{
+			Net:     "udp",
+			Timeout: DefaultTimeout,
+		}
+
+		resp, _, err := client.ExchangeContext(ctx, msg, addr)
+		return resp, err
+	})
+}
+
+// FingerprintResolver is the FingerprintResolver function sending the queries using the
+// transport configured for the pool, such as the Tor proxy set by UseTor.
+func (r *Resolvers) FingerprintResolver(ctx context.Context, addr string) (*Fingerprint, error) {
+	return fingerprint(ctx, addr, r.directExchange)
+}
+
+func fingerprint(ctx context.Context, addr string, exchange func(context.Context, *dns.Msg, string) (*dns.Msg, error)) (*Fingerprint, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+
+	names := []string{"version.bind", "hostname.bind", "id.server"}
+	values := make([]string, len(names))
+	nsids := make([]string, len(names))
+	var responses int
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for i, name := range names {
+		wg.Add(1)
+
+		go func(i int, name string) {
+			defer wg.Done()
+
+			msg := QueryMsg(name, dns.TypeTXT, WithClass(dns.ClassCHAOS),
+				WithoutEDNS(), WithEDNSOption(&dns.EDNS0_NSID{Code: dns.EDNS0NSID}))
+			resp, err := exchange(ctx, msg, addr)
+			if err != nil {
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			responses++
+			nsids[i] = extractNSID(resp)
+			if resp.Rcode == dns.RcodeSuccess {
+				values[i] = chaosTXT(resp)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	if responses == 0 {
+		return nil, errors.New("the resolver did not respond to the fingerprinting queries")
+	}
+
+	f := &Fingerprint{
+		Address:  addr,
+		Version:  values[0],
+		Hostname: values[1],
+		ID:       values[2],
+	}
+	for _, nsid := range nsids {
+		if nsid != "" {
+			f.NSID = nsid
+			break
+		}
+	}
+	f.Software = softwareName(f.Version)
+	return f, nil
+}
+
+func chaosTXT(resp *dns.Msg) string {
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			return strings.Join(txt.Txt, " ")
+		}
+	}
+	return ""
+}
+
+func softwareName(version string) string {
+	v := strings.ToLower(version)
+
+	for _, s := range softwareNames {
+		if strings.Contains(v, s.substr) {
+			return s.name
+		}
+	}
+	// BIND commonly reports only the version number
+	if v != "" && v[0] >= '0' && v[0] <= '9' {
+		return "BIND"
+	}
+	return ""
+}
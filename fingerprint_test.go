@@ -0,0 +1,84 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestFingerprintResolver(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(chaosHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	f, err := FingerprintResolver(context.Background(), addrstr)
+	if err != nil {
+		t.Fatalf("failed to fingerprint the resolver: %v", err)
+	}
+	if f.Version != "unbound 1.19.0" || f.Software != "Unbound" || f.Hostname != "" || f.ID != "resolver-7" {
+		t.Errorf("the fingerprint was not correctly built: %+v", f)
+	}
+	if f.NSID != "node-1" || f.Instance() != "node-1" {
+		t.Errorf("the NSID was not included in the fingerprint: %+v", f)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FingerprintResolver(ctx, addrstr); err == nil {
+		t.Errorf("the fingerprint was returned without any responses")
+	}
+}
+
+func TestSoftwareName(t *testing.T) {
+	for version, expected := range map[string]string{
+		"9.18.24-1-Debian":    "BIND",
+		"PowerDNS Recursor":   "PowerDNS",
+		"dnsmasq-2.90":        "dnsmasq",
+		"Knot Resolver 5.7.1": "Knot Resolver",
+		"unknown":             "",
+		"":                    "",
+	} {
+		if got := softwareName(version); got != expected {
+			t.Errorf("the version %q was identified as %q instead of %q", version, got, expected)
+		}
+	}
+}
+
+func chaosHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	if q := req.Question[0]; q.Qclass != dns.ClassCHAOS {
+		m.Rcode = dns.RcodeRefused
+	} else if q.Name == "hostname.bind." {
+		m.Rcode = dns.RcodeRefused
+	} else {
+		value := "unbound 1.19.0"
+		if q.Name == "id.server." {
+			value = "resolver-7"
+		}
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{value},
+		})
+	}
+
+	if hasNSIDOption(req) {
+		m.SetEdns0(dns.DefaultMsgSize, false)
+		m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_NSID{
+			Code: dns.EDNS0NSID,
+			Nsid: hex.EncodeToString([]byte("node-1")),
+		})
+	}
+	_ = w.WriteMsg(m)
+}
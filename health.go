@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// HealthProbe is the query sent by the health checks of Warmup and AddResolversBulk, along
+// with the answers expected in the responses, which identifies the resolvers returning wrong
+// answers in addition to the resolvers that fail to respond.
+type HealthProbe struct {
+	Name  string
+	Qtype uint16
+	// Qclass is the class of the question, and defaults to dns.ClassINET
+	Qclass uint16
+	// Expect contains answer data in the form returned by ExtractAnswers, and one of the
+	// values must be found in the response. An empty list accepts any answers.
+	Expect []string
+}
+
+// DefaultHealthProbe is the benign A lookup sent by the health checks when SetHealthProbe
+// has not provided another probe.
+var DefaultHealthProbe = &HealthProbe{Name: "a.root-servers.net", Qtype: dns.TypeA}
+
+// IDServerProbe returns the probe querying the id.server CHAOS TXT record, which is answered
+// with the identity of the server instead of data obtained through recursion. Providing the
+// identities of the expected instances detects the addresses that were hijacked.
+func IDServerProbe(expect ...string) *HealthProbe {
+	return &HealthProbe{
+		Name:   "id.server",
+		Qtype:  dns.TypeTXT,
+		Qclass: dns.ClassCHAOS,
+		Expect: expect,
+	}
+}
+
+// SetHealthProbe sets the probe sent by the health checks. Providing nil restores the
+// DefaultHealthProbe.
+func (r *Resolvers) SetHealthProbe(p *HealthProbe) {
+	if p == nil {
+		r.probe.Store(nil)
+		return
+	}
+
+	probe := *p
+	probe.Expect = append([]string(nil), p.Expect...)
+	r.probe.Store(&probe)
+}
+
+func (r *Resolvers) healthProbe() *HealthProbe {
+	if p := r.probe.Load(); p != nil {
+		return p
+	}
+	return DefaultHealthProbe
+}
+
+// Msg returns the query sent for the probe.
+func (p *HealthProbe) Msg() *dns.Msg {
+	if p.Qclass != 0 && p.Qclass != dns.ClassINET {
+		return QueryMsg(p.Name, p.Qtype, WithClass(p.Qclass))
+	}
+	return QueryMsg(p.Name, p.Qtype)
+}
+
+// Check returns the error describing why the response to the probe, which is nil when the
+// resolver failed to respond, did not pass the health check.
+func (p *HealthProbe) Check(addr string, resp *dns.Msg) error {
+	rcode := RcodeNoResponse
+	if resp != nil {
+		rcode = resp.Rcode
+	}
+	if err := healthCheckError(addr, rcode); err != nil {
+		return err
+	}
+	if len(p.Expect) == 0 {
+		return nil
+	}
+
+	for _, a := range ExtractAnswers(resp) {
+		for _, e := range p.Expect {
+			if strings.EqualFold(RemoveLastDot(a.Data), RemoveLastDot(strings.TrimSpace(e))) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("resolver %s: returned unexpected answers for the health check", addr)
+}
@@ -0,0 +1,83 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func idServerHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	if q := req.Question[0]; q.Qclass == dns.ClassCHAOS && q.Name == "id.server." {
+		m.Answer = []dns.RR{&dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS},
+			Txt: []string{"ns1.caffix.net"},
+		}}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestHealthProbe(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(idServerHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetHealthProbe(IDServerProbe("ns1.caffix.net"))
+	if err := r.Warmup(context.Background()); err != nil {
+		t.Errorf("the expected answer failed the health check: %v", err)
+	}
+
+	r.SetHealthProbe(IDServerProbe("ns2.caffix.net"))
+	if err := r.Warmup(context.Background()); err == nil || !strings.Contains(err.Error(), "unexpected answers") {
+		t.Errorf("the wrong answer passed the health check: %v", err)
+	}
+
+	r.SetHealthProbe(nil)
+	if p := r.healthProbe(); p != DefaultHealthProbe {
+		t.Errorf("the default probe was not restored")
+	}
+}
+
+func TestHealthProbeCheck(t *testing.T) {
+	probe := &HealthProbe{Name: "caffix.net", Qtype: dns.TypeA, Expect: []string{"192.168.1.1"}}
+
+	if q := probe.Msg().Question[0]; q.Qclass != dns.ClassINET || q.Name != "caffix.net." {
+		t.Errorf("the probe query was not built correctly")
+	}
+	if err := probe.Check("127.0.0.1:53", nil); err == nil {
+		t.Errorf("the missing response passed the health check")
+	}
+
+	resp := ttlReply(probe.Msg(), 30)
+	if err := probe.Check("127.0.0.1:53", resp); err != nil {
+		t.Errorf("the expected answer failed the health check: %v", err)
+	}
+
+	resp.Rcode = dns.RcodeServerFailure
+	if err := probe.Check("127.0.0.1:53", resp); err == nil {
+		t.Errorf("the SERVFAIL response passed the health check")
+	}
+
+	probe.Expect = nil
+	resp.Rcode = dns.RcodeSuccess
+	resp.Answer = nil
+	if err := probe.Check("127.0.0.1:53", resp); err != nil {
+		t.Errorf("the probe without expected answers failed the health check: %v", err)
+	}
+}
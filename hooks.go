@@ -0,0 +1,95 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// ExchangeHook is called with DNS messages exchanged with the resolvers. The message can be
+// modified in place, and returning an error causes the exchange to fail with RcodeNoResponse.
+type ExchangeHook func(msg *dns.Msg) error
+
+// ContextHook is an ExchangeHook that also receives the context provided with the query, which
+// allows request-scoped values, such as trace and scan IDs, to be recorded with the message.
+type ContextHook func(ctx context.Context, msg *dns.Msg) error
+
+// LogContextFunc returns the request-scoped values of the context that are added to the log
+// messages concerning the query, or an empty string when none are available.
+type LogContextFunc func(ctx context.Context) string
+
+// SetPreSendHook sets the hook called with a copy of each query before it is sent, which can
+// be used to add EDNS options or randomize the message ID. The question name must not be
+// changed, since it is used to match the response. Providing nil removes the hook.
+func (r *Resolvers) SetPreSendHook(hook ExchangeHook) {
+	r.setHook(&r.preSend, exchangeContextHook(hook))
+}
+
+// SetPreSendContextHook is SetPreSendHook for a hook receiving the context of the query.
+func (r *Resolvers) SetPreSendContextHook(hook ContextHook) {
+	r.setHook(&r.preSend, hook)
+}
+
+// SetPostReceiveHook sets the hook called with each response matched to a query before it is
+// returned, which can be used to scrub the response. Providing nil removes the hook.
+func (r *Resolvers) SetPostReceiveHook(hook ExchangeHook) {
+	r.setHook(&r.postReceive, exchangeContextHook(hook))
+}
+
+// SetPostReceiveContextHook is SetPostReceiveHook for a hook receiving the context of the query.
+func (r *Resolvers) SetPostReceiveContextHook(hook ContextHook) {
+	r.setHook(&r.postReceive, hook)
+}
+
+// SetLogContext sets the function extracting the request-scoped values added to the log
+// messages concerning a query. Providing nil removes the function.
+func (r *Resolvers) SetLogContext(fn LogContextFunc) {
+	defer r.syncSubPools()
+
+	if fn == nil {
+		r.logCtx.Store(nil)
+		return
+	}
+	r.logCtx.Store(&fn)
+}
+
+// logf writes the log message with the request-scoped values of the context as the prefix.
+func (r *Resolvers) logf(ctx context.Context, format string, args ...interface{}) {
+	if fn := r.logCtx.Load(); fn != nil && ctx != nil {
+		if values := (*fn)(ctx); values != "" {
+			format = "[" + values + "] " + format
+		}
+	}
+	r.log.Printf(format, args...)
+}
+
+func exchangeContextHook(hook ExchangeHook) ContextHook {
+	if hook == nil {
+		return nil
+	}
+	return func(_ context.Context, msg *dns.Msg) error {
+		return hook(msg)
+	}
+}
+
+func (r *Resolvers) setHook(ptr *atomic.Pointer[ContextHook], hook ContextHook) {
+	defer r.syncSubPools()
+
+	if hook == nil {
+		ptr.Store(nil)
+		return
+	}
+	ptr.Store(&hook)
+}
+
+func runHook(ctx context.Context, ptr *atomic.Pointer[ContextHook], msg *dns.Msg) error {
+	if hook := ptr.Load(); hook != nil {
+		return (*hook)(ctx, msg)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestExchangeHooks(t *testing.T) {
+	var wireID atomic.Uint32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			wireID.Store(uint32(req.Id))
+			if !hasNSIDOption(req) {
+				m := new(dns.Msg)
+				m.SetRcode(req, dns.RcodeRefused)
+				_ = w.WriteMsg(m)
+				return
+			}
+			typeAHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetPreSendHook(func(msg *dns.Msg) error {
+		msg.Id = 4321
+		AddNSIDOption(msg)
+		return nil
+	})
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	resp, err := r.QueryBlocking(context.Background(), msg)
+	if err != nil || resp.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the query failed after the pre-send hook was applied: %v", err)
+	}
+	if wireID.Load() != 4321 {
+		t.Errorf("the pre-send hook did not change the message sent to the resolver")
+	}
+	if resp.Id != msg.Id {
+		t.Errorf("the response ID %d does not match the query ID %d", resp.Id, msg.Id)
+	}
+
+	r.SetPostReceiveHook(func(msg *dns.Msg) error {
+		msg.Extra = nil
+		if len(msg.Answer) > 0 {
+			return errors.New("scrubbed")
+		}
+		return nil
+	})
+	if resp, _ := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); resp.Rcode != RcodeNoResponse {
+		t.Errorf("the response rejected by the post-receive hook was returned")
+	}
+
+	r.SetPreSendHook(nil)
+	r.SetPostReceiveHook(nil)
+	if resp, _ := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); resp.Rcode != dns.RcodeRefused {
+		t.Errorf("the hooks were not removed: %d", resp.Rcode)
+	}
+}
+
+type traceCtxKey struct{}
+
+func TestContextHooks(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(bogusHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	var lock sync.Mutex
+	var sent, received []string
+	r.SetPreSendContextHook(func(ctx context.Context, msg *dns.Msg) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		id, _ := ctx.Value(traceCtxKey{}).(string)
+		sent = append(sent, id)
+		return nil
+	})
+	r.SetPostReceiveContextHook(func(ctx context.Context, msg *dns.Msg) error {
+		lock.Lock()
+		defer lock.Unlock()
+
+		id, _ := ctx.Value(traceCtxKey{}).(string)
+		received = append(received, id)
+		return nil
+	})
+
+	var buf bytes.Buffer
+	r.SetLogger(log.New(&buf, "", 0))
+	r.SetLogContext(func(ctx context.Context) string {
+		if id, ok := ctx.Value(traceCtxKey{}).(string); ok {
+			return "trace=" + id
+		}
+		return ""
+	})
+	_ = r.SetAddressValidation(true)
+
+	ctx := context.WithValue(context.Background(), traceCtxKey{}, "abc123")
+	if _, err := r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA)); err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+
+	lock.Lock()
+	if len(sent) != 1 || sent[0] != "abc123" || len(received) != 1 || received[0] != "abc123" {
+		t.Errorf("the hooks did not receive the context of the query: %v %v", sent, received)
+	}
+	lock.Unlock()
+	if !strings.HasPrefix(buf.String(), "[trace=abc123] Bogus addresses removed") {
+		t.Errorf("the log message did not contain the context values: %s", buf.String())
+	}
+}
+
+func hasNSIDOption(msg *dns.Msg) bool {
+	if opt := msg.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if o.Option() == dns.EDNS0NSID {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/miekg/dns"
+)
+
+// PartialLookupError is returned by LookupHost along with the addresses when one of the
+// address families could not be resolved for reasons other than the records not existing.
+type PartialLookupError struct {
+	Name  string
+	Qtype uint16
+	Err   error
+}
+
+func (e *PartialLookupError) Error() string {
+	return fmt.Sprintf("lookup %s: the %s records could not be resolved: %v", e.Name, dns.TypeToString[e.Qtype], e.Err)
+}
+
+func (e *PartialLookupError) Unwrap() error {
+	return e.Err
+}
+
+// ErrWildcardMatch is returned by ResolveIPs when the answers matched a DNS wildcard.
+var ErrWildcardMatch = errors.New("the answers matched a DNS wildcard")
+
+// The destination address precedence values from the default policy table of RFC 6724.
+var addrPolicies = []struct {
+	prefix     *net.IPNet
+	precedence int
+}{
+	{mustCIDR("::1/128"), 50},
+	{mustCIDR("::ffff:0:0/96"), 35},
+	{mustCIDR("2002::/16"), 30},
+	{mustCIDR("2001::/32"), 5},
+	{mustCIDR("fc00::/7"), 3},
+	{mustCIDR("::/96"), 1},
+	{mustCIDR("fec0::/10"), 1},
+	{mustCIDR("3ffe::/16"), 1},
+	{mustCIDR("::/0"), 40},
+}
+
+// LookupHost sends the A and AAAA queries concurrently and returns the merged addresses
+// ordered using the precedence values of RFC 6724. An error is returned without addresses
+// when both lookups fail. When one address family fails for reasons other than the records
+// not existing, the addresses are returned with a *PartialLookupError.
+func (r *Resolvers) LookupHost(ctx context.Context, name string) ([]string, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return []string{ip.String()}, nil
+	}
+
+	return lookupAddrs(name, func(qtype uint16) ([]net.IP, error) {
+		resp, _, err := r.lookup(ctx, name, qtype, nil)
+		return answerIPs(resp, qtype), err
+	}, ErrNoSuchHost)
+}
+
+// ResolveIPs returns the IPv4 and IPv6 addresses for the name, ordered like LookupHost. The
+// CNAME targets are queried when a response does not contain the addresses. When a domain is
+// provided, the answers matching a DNS wildcard of the domain are removed, and ErrWildcardMatch
+// is returned when no addresses remain. The search list of the pool is not applied.
+func (r *Resolvers) ResolveIPs(ctx context.Context, name, domain string) ([]net.IP, error) {
+	if ip := net.ParseIP(name); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := lookupAddrs(name, func(qtype uint16) ([]net.IP, error) {
+		return r.resolveAddrs(ctx, name, domain, qtype)
+	}, ErrNoSuchHost, ErrWildcardMatch)
+
+	var ips []net.IP
+	for _, a := range addrs {
+		ips = append(ips, net.ParseIP(a))
+	}
+	return ips, err
+}
+
+// lookupAddrs runs the lookup for the A and AAAA records concurrently and merges the addresses
+// using sortAddrs. An error is returned without addresses when both lookups fail. When one
+// lookup fails with an error other than the expected errors, the addresses are returned with
+// a *PartialLookupError.
+func lookupAddrs(name string, lookup func(qtype uint16) ([]net.IP, error), expected ...error) ([]string, error) {
+	type result struct {
+		qtype uint16
+		ips   []net.IP
+		err   error
+	}
+
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	ch := make(chan *result, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			ips, err := lookup(qtype)
+			ch <- &result{qtype: qtype, ips: ips, err: err}
+		}(qtype)
+	}
+
+	var ips []net.IP
+	var failed []*result
+	for range qtypes {
+		if res := <-ch; res.err != nil {
+			failed = append(failed, res)
+		} else {
+			ips = append(ips, res.ips...)
+		}
+	}
+
+	if len(ips) == 0 {
+		if len(failed) == 0 {
+			return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+		}
+		var errs []error
+		for _, f := range failed {
+			errs = append(errs, f.err)
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	addrs := sortAddrs(ips)
+loop:
+	for _, f := range failed {
+		for _, e := range expected {
+			if errors.Is(f.err, e) {
+				continue loop
+			}
+		}
+		return addrs, &PartialLookupError{Name: name, Qtype: f.qtype, Err: f.err}
+	}
+	return addrs, nil
+}
+
+// resolveAddrs queries the name for the address type and follows the CNAME targets until
+// the addresses are obtained.
+func (r *Resolvers) resolveAddrs(ctx context.Context, name, domain string, qtype uint16) ([]net.IP, error) {
+	// only the queried name belongs to the domain, since the targets can be located elsewhere
+	check := func(resp *dns.Msg) error {
+		if domain != "" && r.WildcardDetected(ctx, resp, domain) {
+			return fmt.Errorf("lookup %s: %w", name, ErrWildcardMatch)
+		}
+		return nil
+	}
+
+	chain, err := r.followCNAMEs(ctx, name, qtype, check)
+	if err != nil {
+		return nil, err
+	}
+	if ips := answerIPs(chain.Response, qtype); len(ips) > 0 {
+		return ips, nil
+	}
+	return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+}
+
+// sortAddrs orders the addresses by the RFC 6724 precedence values, retaining the order of
+// the addresses with the same precedence, and removes the duplicates.
+func sortAddrs(ips []net.IP) []string {
+	sort.SliceStable(ips, func(i, j int) bool {
+		return addrPrecedence(ips[i]) > addrPrecedence(ips[j])
+	})
+
+	seen := make(map[string]struct{})
+	var addrs []string
+	for _, ip := range ips {
+		if s := ip.String(); s != "" {
+			if _, found := seen[s]; !found {
+				seen[s] = struct{}{}
+				addrs = append(addrs, s)
+			}
+		}
+	}
+	return addrs
+}
+
+func addrPrecedence(ip net.IP) int {
+	ip = ip.To16()
+	if ip == nil {
+		return 0
+	}
+
+	for _, p := range addrPolicies {
+		if p.prefix.Contains(ip) {
+			return p.precedence
+		}
+	}
+	return 0
+}
+
+func mustCIDR(s string) *net.IPNet {
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return ipnet
+}
@@ -0,0 +1,184 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupHost(t *testing.T) {
+	r, stop := lookupPool(t)
+	defer stop()
+
+	addrs, err := r.LookupHost(context.Background(), "www.caffix.net")
+	if err != nil {
+		t.Fatalf("the lookup failed: %v", err)
+	}
+	if expected := []string{"2001:db8::1", "192.168.1.1"}; !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("the addresses were %v instead of %v", addrs, expected)
+	}
+
+	if _, err := r.LookupHost(context.Background(), "www.owasp.org"); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the lookup did not fail for the missing name: %v", err)
+	}
+	if addrs, err := r.LookupHost(context.Background(), "192.168.1.1"); err != nil || len(addrs) != 1 {
+		t.Errorf("the lookup did not return the IP address literal")
+	}
+}
+
+func TestLookupHostPartial(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			if req.Question[0].Qtype == dns.TypeAAAA {
+				m := new(dns.Msg)
+				m.SetRcode(req, dns.RcodeServerFailure)
+				_ = w.WriteMsg(m)
+				return
+			}
+			typeAHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	addrs, err := r.LookupHost(context.Background(), "caffix.net")
+	if len(addrs) != 1 || addrs[0] != "192.168.1.1" {
+		t.Errorf("the lookup did not return the IPv4 address: %v", addrs)
+	}
+
+	var partial *PartialLookupError
+	if !errors.As(err, &partial) || partial.Qtype != dns.TypeAAAA {
+		t.Errorf("the lookup did not report the failure of the AAAA query: %v", err)
+	}
+}
+
+func TestResolveIPs(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(resolveIPsHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	expected := []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("192.168.1.1")}
+	for _, name := range []string{"www.caffix.net", "alias.caffix.net", "ns.wildcard.caffix.net"} {
+		ips, err := r.ResolveIPs(context.Background(), name, "caffix.net")
+		if err != nil {
+			t.Errorf("the addresses for %s were not resolved: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(ips, expected) {
+			t.Errorf("the addresses for %s were %v instead of %v", name, ips, expected)
+		}
+	}
+
+	if _, err := r.ResolveIPs(context.Background(), "foo.wildcard.caffix.net", "caffix.net"); !errors.Is(err, ErrWildcardMatch) {
+		t.Errorf("the wildcard answers were not removed: %v", err)
+	}
+	if ips, err := r.ResolveIPs(context.Background(), "foo.wildcard.caffix.net", ""); err != nil || len(ips) != 1 {
+		t.Errorf("the wildcard answers were removed without a domain: %v", err)
+	}
+	if _, err := r.ResolveIPs(context.Background(), "www.owasp.org", "owasp.org"); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the lookup did not fail for the missing name: %v", err)
+	}
+	if _, err := r.ResolveIPs(context.Background(), "loop.caffix.net", "caffix.net"); err == nil {
+		t.Errorf("the CNAME loop was followed without an error")
+	}
+}
+
+// resolveIPsHandler returns the addresses of www.caffix.net for the names without the
+// targets, and answers the names below wildcard.caffix.net with a wildcard address
+func resolveIPsHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	switch {
+	case q.Name == "alias.caffix.net.":
+		m.Answer = []dns.RR{&dns.CNAME{
+			Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+			Target: "www.caffix.net.",
+		}}
+	case q.Name == "loop.caffix.net.":
+		m.Answer = []dns.RR{&dns.CNAME{
+			Hdr:    dns.RR_Header{Name: q.Name, Rrtype: dns.TypeCNAME, Class: dns.ClassINET},
+			Target: "loop.caffix.net.",
+		}}
+	case q.Name == "www.caffix.net." || q.Name == "ns.wildcard.caffix.net.":
+		hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET}
+		if q.Qtype == dns.TypeA {
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.ParseIP("192.168.1.1")}}
+		} else if q.Qtype == dns.TypeAAAA {
+			m.Answer = []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")}}
+		}
+	case strings.HasSuffix(q.Name, ".wildcard.caffix.net.") && q.Qtype == dns.TypeA:
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET},
+			A:   net.ParseIP("192.168.1.64"),
+		}}
+	case strings.HasSuffix(q.Name, ".wildcard.caffix.net."):
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestLookupAddrs(t *testing.T) {
+	failure := errors.New("the query failed")
+	lookup := func(err error) func(qtype uint16) ([]net.IP, error) {
+		return func(qtype uint16) ([]net.IP, error) {
+			if qtype == dns.TypeAAAA {
+				return nil, err
+			}
+			return []net.IP{net.ParseIP("192.168.1.1")}, nil
+		}
+	}
+
+	addrs, err := lookupAddrs("caffix.net", lookup(ErrWildcardMatch), ErrNoSuchHost, ErrWildcardMatch)
+	if err != nil || !reflect.DeepEqual(addrs, []string{"192.168.1.1"}) {
+		t.Errorf("the expected error was not ignored: %v, %v", addrs, err)
+	}
+
+	var partial *PartialLookupError
+	addrs, err = lookupAddrs("caffix.net", lookup(failure), ErrNoSuchHost)
+	if len(addrs) != 1 || !errors.As(err, &partial) || partial.Qtype != dns.TypeAAAA || !errors.Is(err, failure) {
+		t.Errorf("the failed lookup was not reported with the addresses: %v, %v", addrs, err)
+	}
+
+	none := func(qtype uint16) ([]net.IP, error) { return nil, nil }
+	if _, err := lookupAddrs("caffix.net", none); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the lookups without addresses did not return ErrNoSuchHost: %v", err)
+	}
+}
+
+func TestSortAddrs(t *testing.T) {
+	var ips []net.IP
+	for _, s := range []string{"192.168.1.1", "fd00::1", "2001:db8::1", "192.168.1.1", "2002::1", "::1"} {
+		ips = append(ips, net.ParseIP(s))
+	}
+
+	expected := []string{"::1", "2001:db8::1", "192.168.1.1", "2002::1", "fd00::1"}
+	if addrs := sortAddrs(ips); !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("the addresses were sorted as %v instead of %v", addrs, expected)
+	}
+}
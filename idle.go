@@ -0,0 +1,205 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type powerState struct {
+	sync.Mutex
+	clock   Clock
+	enabled atomic.Bool
+	timeout time.Duration
+	last    time.Time
+	timer   *time.Timer
+	asleep  bool
+	// stop holds the channel closed to stop the background goroutines at the power down
+	stop atomic.Value
+}
+
+func newPowerState(clock Clock) *powerState {
+	p := &powerState{clock: clock, last: clock.Now()}
+
+	p.stop.Store(make(chan struct{}))
+	return p
+}
+
+// current returns the channel watched by the background goroutines started now.
+func (p *powerState) current() chan struct{} {
+	return p.stop.Load().(chan struct{})
+}
+
+func (p *powerState) stopTimer() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// SetIdleTimeout causes the pool to power down once no queries have arrived for the duration
+// and none remain in flight. The power down stops the background goroutines of the pool and
+// the resolvers, and closes the sockets. They are re-established by the next query, which makes
+// the idle pool inexpensive for desktop and agent embeddings. The setting is also applied to the
+// sub-pools. A duration of zero disables the power down.
+func (r *Resolvers) SetIdleTimeout(d time.Duration) {
+	p := r.power
+
+	p.Lock()
+	p.timeout = d
+	p.last = p.clock.Now()
+	p.enabled.Store(d > 0)
+	if d <= 0 {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+		if p.asleep {
+			r.resume()
+		}
+	} else if p.timer == nil {
+		p.timer = time.AfterFunc(d, r.idleCheck)
+	} else {
+		p.timer.Reset(d)
+	}
+	p.Unlock()
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, sub := range r.subpools {
+		sub.SetIdleTimeout(d)
+	}
+}
+
+// Idle returns true when the pool has been powered down by SetIdleTimeout.
+func (r *Resolvers) Idle() bool {
+	p := r.power
+
+	p.Lock()
+	defer p.Unlock()
+
+	return p.asleep
+}
+
+func (r *Resolvers) idleTimeout() time.Duration {
+	p := r.power
+
+	p.Lock()
+	defer p.Unlock()
+
+	return p.timeout
+}
+
+// wake records the activity of the pool and restarts the pool after a power down.
+func (r *Resolvers) wake() {
+	p := r.power
+	// the pool cannot be powered down, so the activity is not recorded
+	if !p.enabled.Load() {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.last = p.clock.Now()
+	if p.asleep {
+		r.resume()
+	}
+}
+
+func (r *Resolvers) idleCheck() {
+	p := r.power
+
+	p.Lock()
+	defer p.Unlock()
+
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+	if p.asleep || p.timeout <= 0 {
+		return
+	}
+
+	if wait := p.timeout - p.clock.Now().Sub(p.last); wait > 0 {
+		p.timer.Reset(wait)
+		return
+	}
+	if r.busy() {
+		p.timer.Reset(p.timeout)
+		return
+	}
+	r.powerDown()
+}
+
+// busy returns true when requests are queued, in flight or waiting to be processed.
+func (r *Resolvers) busy() bool {
+	if !r.queue.Empty() || !r.leases.Empty() || !r.resps.Empty() {
+		return true
+	}
+
+	for _, res := range r.allResolvers() {
+		if !res.queue.Empty() || !res.xchgs.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+// powerDown stops the background goroutines and closes the sockets.
+// The caller must hold the power state lock.
+func (r *Resolvers) powerDown() {
+	p := r.power
+
+	p.asleep = true
+	close(p.current())
+	r.expiry.Stop()
+	if r.conns != nil {
+		r.conns.suspend()
+	}
+	for _, res := range r.allResolvers() {
+		res.closeTLS(nil)
+	}
+	r.log.Printf("The resolver pool powered down after being idle for %s", p.timeout)
+}
+
+// resume restarts the background goroutines and opens new sockets after a power down.
+// The caller must hold the power state lock.
+func (r *Resolvers) resume() {
+	p := r.power
+
+	stop := make(chan struct{})
+	p.stop.Store(stop)
+	if r.conns != nil {
+		if err := r.conns.resume(); err != nil {
+			r.log.Printf("Failed to open the sockets after the power down: %v", err)
+		}
+	}
+
+	r.expiry.Reset(r.timeoutCheckInterval())
+	r.startBackground(stop)
+	for _, res := range r.allResolvers() {
+		res.start(stop)
+	}
+
+	p.asleep = false
+	if p.timeout > 0 {
+		p.timer.Reset(p.timeout)
+	}
+}
+
+// allResolvers returns the resolvers of the pool along with the wildcard detection resolver.
+func (r *Resolvers) allResolvers() []*resolver {
+	all := r.pool.AllResolvers()
+	if d := r.getDetectionResolver(); d != nil {
+		all = append(all, d)
+	}
+	return all
+}
@@ -0,0 +1,160 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetIdleTimeout(200 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA)); err != nil {
+			t.Fatalf("the query failed: %v", err)
+		}
+		if !waitForIdle(r) {
+			t.Fatalf("the pool did not power down after being idle")
+		}
+
+		stop := r.power.current()
+		select {
+		case <-stop:
+		default:
+			t.Errorf("the background goroutines were not signaled to stop")
+		}
+		r.conns.Lock()
+		open := len(r.conns.conns)
+		r.conns.Unlock()
+		if open != 0 {
+			t.Errorf("%d sockets remained open after the power down", open)
+		}
+	}
+
+	// the power down is disabled while the pool is idle
+	r.SetIdleTimeout(0)
+	if r.Idle() {
+		t.Errorf("the pool remained powered down after the idle timeout was disabled")
+	}
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query failed after the pool was restarted: %v", err)
+	}
+	if waitForIdle(r) {
+		t.Errorf("the pool powered down after the idle timeout was disabled")
+	}
+}
+
+func TestIdleTimeoutInflight(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		time.Sleep(500 * time.Millisecond)
+		typeAHandler(w, req)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetIdleTimeout(100 * time.Millisecond)
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the pool powered down with the query in flight: %v", err)
+	}
+}
+
+func TestIdleReconfigure(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetIdleTimeout(100 * time.Millisecond)
+	if !waitForIdle(r) {
+		t.Fatalf("the pool did not power down after being idle")
+	}
+
+	if err := r.SetReadBuffer(1 << 20); err != nil {
+		t.Fatalf("the read buffer could not be set while the pool was idle: %v", err)
+	}
+	r.conns.Lock()
+	open := len(r.conns.conns)
+	r.conns.Unlock()
+	if open != 0 {
+		t.Errorf("%d sockets were opened while the pool was idle", open)
+	}
+	if !r.Idle() {
+		t.Errorf("the pool was restarted by the new socket options")
+	}
+
+	r.wake()
+	r.conns.Lock()
+	open = len(r.conns.conns)
+	r.conns.Unlock()
+	if open == 0 {
+		t.Errorf("the sockets were not opened after the pool was restarted")
+	}
+	if opts := r.conns.getOptions(); opts.rcvbuf != 1<<20 {
+		t.Errorf("the sockets were opened without the read buffer size set while idle")
+	}
+}
+
+func TestWakeDisabled(t *testing.T) {
+	clock := NewSimClock(time.Now())
+	r := NewResolversWithClock(clock)
+	defer r.Stop()
+
+	lastActivity := func() time.Time {
+		r.power.Lock()
+		defer r.power.Unlock()
+		return r.power.last
+	}
+
+	last := lastActivity()
+	clock.Advance(time.Minute)
+	r.wake()
+	if !lastActivity().Equal(last) {
+		t.Errorf("the activity was recorded while the power down was disabled")
+	}
+
+	r.SetIdleTimeout(time.Hour)
+	clock.Advance(time.Minute)
+	r.wake()
+	if !lastActivity().Equal(clock.Now()) {
+		t.Errorf("the activity was not recorded using the pool clock")
+	}
+}
+
+func waitForIdle(r *Resolvers) bool {
+	for i := 0; i < 20; i++ {
+		if r.Idle() {
+			return true
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return false
+}
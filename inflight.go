@@ -0,0 +1,55 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// InflightQuery describes a query that was sent to a resolver and is awaiting the response.
+type InflightQuery struct {
+	Name     string
+	Qtype    uint16
+	Resolver string
+	Age      time.Duration
+	Priority int
+}
+
+// Inflight returns the queries currently awaiting responses from the resolvers in the pool,
+// beginning with the oldest. Queries still waiting in the queues of the pool are not included.
+func (r *Resolvers) Inflight() []*InflightQuery {
+	now := r.clock.Now()
+
+	var queries []*InflightQuery
+	for _, res := range r.pool.AllResolvers() {
+		queries = append(queries, res.xchgs.inflight(res.address.String(), now)...)
+	}
+
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].Age > queries[j].Age
+	})
+	return queries
+}
+
+func (r *xchgMgr) inflight(addr string, now time.Time) []*InflightQuery {
+	r.Lock()
+	defer r.Unlock()
+
+	var queries []*InflightQuery
+	for _, req := range r.xchgs {
+		q := req.Msg.Question[0]
+
+		queries = append(queries, &InflightQuery{
+			Name:     strings.ToLower(RemoveLastDot(q.Name)),
+			Qtype:    q.Qtype,
+			Resolver: addr,
+			Age:      now.Sub(req.Timestamp),
+			Priority: req.Priority,
+		})
+	}
+	return queries
+}
@@ -0,0 +1,62 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/caffix/queue"
+	"github.com/miekg/dns"
+)
+
+func TestInflight(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(timeoutHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	if n := len(r.Inflight()); n != 0 {
+		t.Errorf("%d queries were inflight before any were sent", n)
+	}
+
+	ch := make(chan *dns.Msg, 2)
+	r.Query(context.Background(), QueryMsg("www.caffix.net", dns.TypeA), ch)
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Boost(ctx, "mail.caffix.net")
+	r.Query(context.Background(), QueryMsg("Mail.caffix.net.", dns.TypeMX), ch)
+
+	var inflight []*InflightQuery
+	for i := 0; i < 50; i++ {
+		if inflight = r.Inflight(); len(inflight) == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(inflight) != 2 {
+		t.Fatalf("%d queries were reported as inflight instead of two", len(inflight))
+	}
+
+	first, second := inflight[0], inflight[1]
+	if first.Name != "www.caffix.net" || first.Qtype != dns.TypeA || first.Resolver != addr {
+		t.Errorf("the oldest query was not reported first: %+v", first)
+	}
+	if second.Name != "mail.caffix.net" || second.Qtype != dns.TypeMX || second.Priority != queue.PriorityHigh {
+		t.Errorf("the second query was not correctly reported: %+v", second)
+	}
+	if first.Age < second.Age || first.Age < 100*time.Millisecond {
+		t.Errorf("the ages of the queries were not correct: %s %s", first.Age, second.Age)
+	}
+}
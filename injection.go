@@ -0,0 +1,148 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	injectionWindow     = 10 * time.Second
+	minRTTSamples       = 10
+	implausibleRTTRatio = 4
+)
+
+type completedXchg struct {
+	At   time.Time
+	Data string
+	// Key identifies the original query in the suspects
+	Key string
+}
+
+// The recent exchanges are keyed by the resolver address along with the message ID and name,
+// since the IDs are shared by the queries sent to all the resolvers in the pool. The suspects
+// are keyed by the ID and name of the query, which the caller obtains from the response.
+type injectionTracker struct {
+	sync.Mutex
+	recent   map[string]*completedXchg
+	suspects map[string]time.Time
+}
+
+func recentKey(res *resolver, id uint16, name string) string {
+	return res.address.IP.String() + "/" + xchgKey(id, name)
+}
+
+func newInjectionTracker() *injectionTracker {
+	return &injectionTracker{
+		recent:   make(map[string]*completedXchg),
+		suspects: make(map[string]time.Time),
+	}
+}
+
+// SetInjectionDetection enables heuristics that flag responses likely injected by on-path
+// network filtering, such as responses arriving faster than the resolver could answer and
+// multiple conflicting responses for a single query.
+func (r *Resolvers) SetInjectionDetection(enable bool) {
+	defer r.syncSubPools()
+
+	r.Lock()
+	defer r.Unlock()
+
+	if enable && r.injections.Load() == nil {
+		r.injections.Store(newInjectionTracker())
+	} else if !enable {
+		r.injections.Store(nil)
+	}
+}
+
+// InjectionSuspected returns true when the provided response was flagged by the injection detection heuristics.
+// Conflicting responses can arrive after the first response was returned, so the flag may be set at a later time.
+func (r *Resolvers) InjectionSuspected(resp *dns.Msg) bool {
+	it := r.getInjectionTracker()
+	if it == nil || resp == nil || len(resp.Question) == 0 {
+		return false
+	}
+
+	it.Lock()
+	defer it.Unlock()
+
+	_, found := it.suspects[xchgKey(resp.Id, resp.Question[0].Name)]
+	return found
+}
+
+func (r *Resolvers) getInjectionTracker() *injectionTracker {
+	return r.injections.Load()
+}
+
+// checkResponse is called for responses matching an outstanding request.
+func (it *injectionTracker) checkResponse(res *resolver, req *request, msg *dns.Msg) bool {
+	key := xchgKey(req.Msg.Id, msg.Question[0].Name)
+	rtt := res.pool.clock.Now().Sub(req.Timestamp)
+
+	var suspect bool
+	// the low percentile is used, since slow outliers would inflate the mean
+	if samples, low := res.lowRTT(); samples >= minRTTSamples && rtt < low/implausibleRTTRatio {
+		suspect = true
+	}
+
+	it.Lock()
+	defer it.Unlock()
+
+	it.recent[recentKey(res, msg.Id, msg.Question[0].Name)] = &completedXchg{
+		At:   time.Now(),
+		Data: answerData(msg),
+		Key:  key,
+	}
+	if suspect {
+		it.suspects[key] = time.Now()
+	}
+	return suspect
+}
+
+// checkUnmatched is called for responses from the resolver that did not match an outstanding request.
+func (it *injectionTracker) checkUnmatched(res *resolver, msg *dns.Msg) bool {
+	key := recentKey(res, msg.Id, msg.Question[0].Name)
+
+	it.Lock()
+	defer it.Unlock()
+
+	if c, found := it.recent[key]; found && c.Data != answerData(msg) {
+		it.suspects[c.Key] = time.Now()
+		return true
+	}
+	return false
+}
+
+func (it *injectionTracker) prune() {
+	it.Lock()
+	defer it.Unlock()
+
+	now := time.Now()
+	for key, c := range it.recent {
+		if now.Sub(c.At) > injectionWindow {
+			delete(it.recent, key)
+		}
+	}
+	for key, at := range it.suspects {
+		if now.Sub(at) > 6*injectionWindow {
+			delete(it.suspects, key)
+		}
+	}
+}
+
+func answerData(msg *dns.Msg) string {
+	var data []string
+
+	for _, a := range ExtractAnswers(msg) {
+		data = append(data, a.Data)
+	}
+	sort.Strings(data)
+	return strings.Join(data, ",")
+}
@@ -0,0 +1,151 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestInjectionDetection(t *testing.T) {
+	name := "inject.net."
+	dns.HandleFunc(name, duplicateAnswerHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if r.InjectionSuspected(resp) {
+		t.Errorf("the response was flagged before injection detection was enabled")
+	}
+
+	r.SetInjectionDetection(true)
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if !r.InjectionSuspected(resp) {
+		t.Errorf("the conflicting responses were not flagged")
+	}
+
+	res := r.pool.GetResolver()
+	res.stats.Lock()
+	injections := res.stats.Injections
+	res.stats.Unlock()
+	if injections == 0 {
+		t.Errorf("the resolver statistics did not record the injection")
+	}
+}
+
+func TestInjectionTracker(t *testing.T) {
+	it := newInjectionTracker()
+	r := NewResolvers()
+	defer r.Stop()
+
+	res := r.initializeResolver(10, "192.168.1.1")
+	defer res.stop()
+	// establish a typical round trip time for the resolver
+	for i := 0; i < minRTTSamples; i++ {
+		res.recordRTT(100 * time.Millisecond)
+	}
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	if !it.checkResponse(res, &request{Msg: msg, Timestamp: time.Now()}, msg) {
+		t.Errorf("checkResponse did not detect the implausibly fast response")
+	}
+
+	msg = QueryMsg("www.caffix.net", dns.TypeA)
+	req := &request{Msg: msg, Timestamp: time.Now().Add(-100 * time.Millisecond)}
+	if it.checkResponse(res, req, msg) {
+		t.Errorf("checkResponse flagged a response with a typical round trip time")
+	}
+	// the same response arriving twice is not a conflict
+	if it.checkUnmatched(res, msg) {
+		t.Errorf("checkUnmatched flagged a duplicate response with the same answers")
+	}
+}
+
+func TestInjectionTrackerResolvers(t *testing.T) {
+	it := newInjectionTracker()
+	r := NewResolvers()
+	defer r.Stop()
+
+	first := r.initializeResolver(10, "192.168.1.1")
+	defer first.stop()
+	second := r.initializeResolver(10, "192.168.1.2")
+	defer second.stop()
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	msg.Answer = []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("192.168.1.1"),
+	}}
+	req := &request{Msg: msg, Timestamp: time.Now().Add(-100 * time.Millisecond)}
+	_ = it.checkResponse(first, req, msg)
+
+	// a late response from another resolver to a query sharing the message ID is not a conflict
+	other := msg.Copy()
+	other.Answer[0].(*dns.A).A = net.ParseIP("10.10.10.10")
+	if it.checkUnmatched(second, other) {
+		t.Errorf("checkUnmatched flagged the response from a different resolver")
+	}
+	if !it.checkUnmatched(first, other) {
+		t.Errorf("checkUnmatched did not flag the conflicting response from the same resolver")
+	}
+	if _, found := it.suspects[xchgKey(req.Msg.Id, "caffix.net")]; !found {
+		t.Errorf("the original query was not flagged as suspect")
+	}
+}
+
+func TestInjectionTrackerOutliers(t *testing.T) {
+	it := newInjectionTracker()
+	r := NewResolvers()
+	defer r.Stop()
+
+	res := r.initializeResolver(10, "192.168.1.1")
+	defer res.stop()
+	// a few slow responses should not cause typical responses to appear implausibly fast
+	for i := 0; i < minRTTSamples; i++ {
+		res.recordRTT(20 * time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		res.recordRTT(2 * time.Second)
+	}
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	req := &request{Msg: msg, Timestamp: time.Now().Add(-15 * time.Millisecond)}
+	if it.checkResponse(res, req, msg) {
+		t.Errorf("checkResponse flagged a typical response after slow outliers")
+	}
+}
+
+func duplicateAnswerHandler(w dns.ResponseWriter, req *dns.Msg) {
+	for _, addr := range []string{"192.168.1.1", "10.10.10.10"} {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+			A:   net.ParseIP(addr),
+		}}
+		_ = w.WriteMsg(m)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The operations recorded in the query journal.
+const (
+	JournalStart = "start"
+	JournalDone  = "done"
+)
+
+// JournalEntry is a single line of the query journal. The start entries contain the name and
+// qtype keys used by the ReplayRecord format, so the queries can be provided to Replay.
+type JournalEntry struct {
+	ID    uint64    `json:"id"`
+	Op    string    `json:"op"`
+	Name  string    `json:"name,omitempty"`
+	Qtype string    `json:"qtype,omitempty"`
+	Rcode string    `json:"rcode,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Journal is an append-only file recording each query accepted by the pool before it is sent,
+// along with the completion of the query, which reveals the queries in flight after a crash.
+type Journal struct {
+	sync.Mutex
+	f    *os.File
+	next uint64
+	err  error
+}
+
+// OpenJournal opens the journal file at the provided path for appending, creating it as needed.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// the identifiers begin at the current time, so they remain unique across the processes
+	// appending to the same file
+	return &Journal{f: f, next: uint64(time.Now().UnixNano())}, nil
+}
+
+// Close closes the journal file and returns the first error encountered while writing entries.
+func (j *Journal) Close() error {
+	j.Lock()
+	defer j.Unlock()
+
+	if err := j.f.Close(); err != nil && j.err == nil {
+		j.err = err
+	}
+	return j.err
+}
+
+// Err returns the first error encountered while writing entries to the journal.
+func (j *Journal) Err() error {
+	j.Lock()
+	defer j.Unlock()
+
+	return j.err
+}
+
+// SetJournal causes the pool to record the queries in the journal. Providing nil stops the journaling.
+func (r *Resolvers) SetJournal(j *Journal) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.journal.Store(j)
+	for _, sub := range r.subpools {
+		sub.journal.Store(j)
+	}
+}
+
+// start records the query and returns the identifier used to mark its completion.
+func (j *Journal) start(msg *dns.Msg) uint64 {
+	if j == nil || len(msg.Question) == 0 {
+		return 0
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	j.next++
+	j.write(&JournalEntry{
+		ID:    j.next,
+		Op:    JournalStart,
+		Name:  strings.ToLower(RemoveLastDot(msg.Question[0].Name)),
+		Qtype: dns.TypeToString[msg.Question[0].Qtype],
+		Time:  time.Now(),
+	})
+	return j.next
+}
+
+func (j *Journal) done(id uint64, rcode int) {
+	if j == nil || id == 0 {
+		return
+	}
+
+	rc := dns.RcodeToString[rcode]
+	if rcode == RcodeNoResponse {
+		rc = "NORESPONSE"
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	j.write(&JournalEntry{
+		ID:    id,
+		Op:    JournalDone,
+		Rcode: rc,
+		Time:  time.Now(),
+	})
+}
+
+// write appends the entry without buffering, so it survives a crash of the process.
+// The caller must hold the journal lock.
+func (j *Journal) write(e *JournalEntry) {
+	if j.err != nil {
+		return
+	}
+
+	b, err := json.Marshal(e)
+	if err == nil {
+		_, err = j.f.Write(append(b, '\n'))
+	}
+	j.err = err
+}
+
+// JournalInflight reads a query journal and returns the start entries without a matching
+// completion, in the order the queries were accepted.
+func JournalInflight(rd io.Reader) ([]*JournalEntry, error) {
+	var order []uint64
+	var malformed error
+	started := make(map[uint64]*JournalEntry)
+
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		// only the last line could have been partially written during a crash
+		if malformed != nil {
+			return nil, malformed
+		}
+
+		var e JournalEntry
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			malformed = fmt.Errorf("line %d: %w", line, err)
+			continue
+		}
+
+		switch e.Op {
+		case JournalStart:
+			started[e.ID] = &e
+			order = append(order, e.ID)
+		case JournalDone:
+			delete(started, e.ID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var inflight []*JournalEntry
+	for _, id := range order {
+		if e, found := started[id]; found {
+			inflight = append(inflight, e)
+		}
+	}
+	return inflight, nil
+}
@@ -0,0 +1,110 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestJournal(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	path := filepath.Join(t.TempDir(), "queries.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("failed to open the journal: %v", err)
+	}
+	r.SetJournal(j)
+
+	for _, n := range []string{"www.caffix.net", "mail.caffix.net", "caffix.net"} {
+		if _, err := r.QueryBlocking(context.Background(), QueryMsg(n, dns.TypeA)); err != nil {
+			t.Errorf("the query for %s failed: %v", n, err)
+		}
+	}
+	r.SetJournal(nil)
+	if err := j.Close(); err != nil {
+		t.Fatalf("failed to write the journal: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the journal: %v", err)
+	}
+	if starts := strings.Count(string(data), `"op":"start"`); starts != 3 {
+		t.Errorf("the journal contained %d start entries instead of 3", starts)
+	}
+	if done := strings.Count(string(data), `"rcode":"NOERROR"`); done != 3 {
+		t.Errorf("the journal contained %d completions instead of 3", done)
+	}
+
+	inflight, err := JournalInflight(bytes.NewReader(data))
+	if err != nil || len(inflight) != 0 {
+		t.Errorf("the completed queries were reported in flight: %v", err)
+	}
+}
+
+func TestJournalInflight(t *testing.T) {
+	journal := strings.Join([]string{
+		`{"id":1,"op":"start","name":"www.caffix.net","qtype":"A"}`,
+		`{"id":2,"op":"start","name":"mail.caffix.net","qtype":"MX"}`,
+		`{"id":1,"op":"done","rcode":"NOERROR"}`,
+		`{"id":3,"op":"start","name":"caffix.net","qtype":"AAAA"}`,
+		`{"id":3,"op":"do`,
+	}, "\n")
+
+	inflight, err := JournalInflight(strings.NewReader(journal))
+	if err != nil {
+		t.Fatalf("failed to read the journal with a partial last line: %v", err)
+	}
+	if len(inflight) != 2 || inflight[0].ID != 2 || inflight[1].ID != 3 {
+		t.Fatalf("the queries in flight were not correct: %v", inflight)
+	}
+
+	if _, err := JournalInflight(strings.NewReader("not json\n" + journal)); err == nil {
+		t.Errorf("a malformed line before the end of the journal was accepted")
+	}
+
+	// the entries can be replayed through the pool
+	var buf bytes.Buffer
+	for _, e := range inflight {
+		b, _ := json.Marshal(e)
+		buf.Write(append(b, '\n'))
+	}
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	var names []string
+	for result := range r.Replay(context.Background(), &buf) {
+		if result.Recorded == nil {
+			t.Errorf("the journal entry was not parsed: %v", result.Err)
+			continue
+		}
+		names = append(names, result.Name)
+	}
+	if len(names) != 2 {
+		t.Errorf("%d of the journal entries were replayed", len(names))
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// The pprof labels attached to the goroutines of the pool, so CPU profiles of large scans
+// attribute the time to the subsystems and upstream resolvers. Goroutines started for each
+// query inherit the labels of the goroutine that started them.
+const (
+	labelSubsystem = "subsystem"
+	labelResolver  = "resolver"
+)
+
+// runLabeled calls fn with the provided pprof label key and value pairs on the goroutine.
+func runLabeled(fn func(), labels ...string) {
+	pprof.Do(context.Background(), pprof.Labels(labels...), func(context.Context) {
+		fn()
+	})
+}
+
+// resolverLabels returns the labels applied while processing the responses from the resolver.
+func resolverLabels(addr string) context.Context {
+	return pprof.WithLabels(context.Background(), pprof.Labels(labelSubsystem, "responses", labelResolver, addr))
+}
+
+// applyLabels labels the current goroutine, which must be dedicated to the resolver, such as
+// the goroutine processing a single response.
+func (r *resolver) applyLabels() {
+	if r.labels != nil {
+		pprof.SetGoroutineLabels(r.labels)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func goroutineProfile(t *testing.T) string {
+	var buf bytes.Buffer
+
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatalf("failed to write the goroutine profile: %v", err)
+	}
+	return buf.String()
+}
+
+func TestGoroutineLabels(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.0.2.1")
+	defer r.Stop()
+
+	labels := []string{
+		`"subsystem":"scheduler"`,
+		`"subsystem":"timeouts"`,
+		`"subsystem":"responses"`,
+		`"subsystem":"reader"`,
+		`"resolver":"192.0.2.1:53"`,
+	}
+
+	var profile string
+	// wait for the goroutines to start running under the labels
+	for i := 0; i < 50; i++ {
+		if profile = goroutineProfile(t); strings.Contains(profile, labels[len(labels)-1]) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, label := range labels {
+		if !strings.Contains(profile, label) {
+			t.Errorf("the goroutine profile did not include the label %s", label)
+		}
+	}
+}
+
+func TestResolverLabels(t *testing.T) {
+	ctx := resolverLabels("192.0.2.1:53")
+
+	if v, ok := pprof.Label(ctx, labelResolver); !ok || v != "192.0.2.1:53" {
+		t.Errorf("the resolver label was not set")
+	}
+	if v, ok := pprof.Label(ctx, labelSubsystem); !ok || v != "responses" {
+		t.Errorf("the subsystem label was not set")
+	}
+
+	done := make(chan string, 1)
+	go runLabeled(func() {
+		done <- goroutineProfile(t)
+	}, labelSubsystem, "test-labels")
+	if profile := <-done; !strings.Contains(profile, `"subsystem":"test-labels"`) {
+		t.Errorf("the labels were not applied to the goroutine")
+	}
+}
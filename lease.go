@@ -0,0 +1,125 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.uber.org/ratelimit"
+)
+
+// Lease reserves a portion of the pool QPS for a single caller, so other callers sharing
+// the pool cannot starve it, and it cannot consume more than the reserved rate.
+type Lease struct {
+	pool *Resolvers
+	qps  int
+	rate ratelimit.Limiter
+	done chan struct{}
+}
+
+// Lease reserves the provided QPS from the pool for the duration. The reservation lasts until
+// Release is called when the duration is not greater than zero. An error is returned when the
+// pool cannot reserve the QPS while keeping capacity for the queries sent without a lease.
+func (r *Resolvers) Lease(qps int, d time.Duration) (*Lease, error) {
+	if qps < 1 {
+		return nil, errors.New("failed to provide a lease QPS greater than zero")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if avail := r.qps - r.leased - 1; qps > avail {
+		return nil, fmt.Errorf("the pool only has %d QPS available to lease", avail)
+	}
+	r.leased += qps
+	r.updateRateLimiter()
+
+	l := &Lease{
+		pool: r,
+		qps:  qps,
+		rate: ratelimit.New(qps, ratelimit.WithClock(r.clock)),
+		done: make(chan struct{}),
+	}
+	if d > 0 {
+		go func() {
+			t := time.NewTimer(d)
+			defer t.Stop()
+
+			select {
+			case <-t.C:
+				l.Release()
+			case <-l.done:
+			}
+		}()
+	}
+	return l, nil
+}
+
+// QPS returns the number of queries per second reserved by the lease.
+func (l *Lease) QPS() int {
+	return l.qps
+}
+
+// Active returns true when the lease has not been released or expired.
+func (l *Lease) Active() bool {
+	select {
+	case <-l.done:
+		return false
+	default:
+	}
+	return true
+}
+
+// Release returns the reserved QPS to the pool.
+func (l *Lease) Release() {
+	l.pool.Lock()
+	defer l.pool.Unlock()
+
+	select {
+	case <-l.done:
+		return
+	default:
+	}
+	close(l.done)
+
+	l.pool.leased -= l.qps
+	l.pool.updateRateLimiter()
+}
+
+// Query queues the provided DNS message using the reserved QPS and returns the response on the
+// provided channel. The shared pool QPS is used once the lease is no longer active.
+func (l *Lease) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	if !l.Active() {
+		l.pool.Query(ctx, msg, ch)
+		return
+	}
+
+	// the expired queries are answered by the pool without consuming the reserved QPS
+	if ctx.Err() == nil {
+		_ = l.rate.Take()
+	}
+	l.pool.query(ctx, msg, ch, true)
+}
+
+// QueryBlocking queues the provided DNS message using the reserved QPS and returns the associated
+// response message. The deadline set by SetQueryDeadline applies like it does for the pool.
+func (l *Lease) QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := l.pool.queryContext(ctx)
+	defer cancel()
+
+	select {
+	case <-ctx.Done():
+		return msg, errors.New("the context expired")
+	default:
+	}
+
+	ch := make(chan *dns.Msg, 1)
+	l.Query(ctx, msg, ch)
+	return awaitResponse(ctx, msg, ch)
+}
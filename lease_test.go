@@ -0,0 +1,164 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLease(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	if _, err := r.Lease(0, 0); err == nil {
+		t.Errorf("a lease without QPS was granted")
+	}
+	if _, err := r.Lease(100, 0); err == nil {
+		t.Errorf("a lease for the entire pool QPS was granted")
+	}
+
+	l, err := r.Lease(40, 0)
+	if err != nil {
+		t.Fatalf("failed to obtain the lease: %v", err)
+	}
+	if _, err := r.Lease(60, 0); err == nil {
+		t.Errorf("a lease exceeding the remaining QPS was granted")
+	}
+
+	r.Lock()
+	leased := r.leased
+	r.Unlock()
+	if leased != 40 {
+		t.Errorf("the pool recorded %d leased QPS instead of 40", leased)
+	}
+
+	if resp, err := l.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query sent using the lease failed: %v", err)
+	}
+
+	l.Release()
+	l.Release()
+	r.Lock()
+	leased = r.leased
+	r.Unlock()
+	if l.Active() || leased != 0 {
+		t.Errorf("the lease was not released")
+	}
+	if resp, err := l.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || len(resp.Answer) == 0 {
+		t.Errorf("the query sent after the lease was released failed: %v", err)
+	}
+}
+
+func TestLeaseBacklog(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(20, addrstr)
+	defer r.Stop()
+
+	l, err := r.Lease(10, 0)
+	if err != nil {
+		t.Fatalf("failed to obtain the lease: %v", err)
+	}
+
+	// the backlog takes ten seconds to drain using the unleased QPS
+	r.Pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < 100; i++ {
+		r.Query(ctx, QueryMsg("backlog.caffix.net", dns.TypeA), make(chan *dns.Msg, 1))
+	}
+	ch := make(chan *dns.Msg, 1)
+	l.Query(ctx, QueryMsg("leased.caffix.net", dns.TypeA), ch)
+	r.Resume()
+
+	select {
+	case resp := <-ch:
+		if len(resp.Answer) == 0 {
+			t.Errorf("the leased query failed")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the leased query was delayed by the unleased backlog")
+	}
+}
+
+func TestLeaseExpiration(t *testing.T) {
+	r := NewResolvers()
+	_ = r.AddResolvers(100, "192.168.1.1")
+	defer r.Stop()
+
+	l, err := r.Lease(50, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to obtain the lease: %v", err)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+	if l.Active() {
+		t.Errorf("the lease did not expire")
+	}
+	if _, err := r.Lease(99, 0); err != nil {
+		t.Errorf("the QPS of the expired lease was not returned to the pool: %v", err)
+	}
+}
+
+func TestLeaseQueryDeadline(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer pc.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, pc.LocalAddr().String())
+	r.SetTimeout(time.Minute)
+	r.SetQueryDeadline(100 * time.Millisecond)
+	defer r.Stop()
+
+	l, err := r.Lease(2, 0)
+	if err != nil {
+		t.Fatalf("failed to obtain the lease: %v", err)
+	}
+
+	// the resolver never responds, so only the deadline of the pool ends the query
+	start := time.Now()
+	resp, err := l.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err == nil || resp.Rcode != RcodeNoResponse || time.Since(start) > time.Second {
+		t.Errorf("the query sent using the lease ignored the deadline of the pool: %v", err)
+	}
+
+	// the expired queries do not wait on the reserved QPS
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		ch := make(chan *dns.Msg, 1)
+		l.Query(ctx, QueryMsg("caffix.net", dns.TypeA), ch)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("the expired queries waited on the rate limit of the lease")
+	}
+}
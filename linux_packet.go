@@ -24,8 +24,14 @@ func (r *connections) ListenPacket() (net.PacketConn, error) {
 			}); err != nil {
 				return err
 			}
+			if operr != nil {
+				return operr
+			}
 
-			return operr
+			if control := r.opts.controlFunc(); control != nil {
+				return control(network, address, c)
+			}
+			return nil
 		},
 	}
 
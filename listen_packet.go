@@ -7,9 +7,14 @@
 package resolve
 
 import (
+	"context"
 	"net"
 )
 
 func (r *connections) ListenPacket() (net.PacketConn, error) {
-	return net.ListenPacket("udp", ":0")
+	lc := net.ListenConfig{
+		Control: r.opts.controlFunc(),
+	}
+
+	return lc.ListenPacket(context.Background(), "udp", ":0")
 }
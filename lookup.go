@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNoSuchHost is returned by the lookup helpers when none of the attempted names had records of the requested type.
+var ErrNoSuchHost = errors.New("no such host")
+
+// LookupOptions contains the per-query overrides for the search list settings of the pool.
+type LookupOptions struct {
+	// Search replaces the search domains of the pool when not nil
+	Search []string
+	// Ndots replaces the ndots value of the pool when OverrideNdots is true
+	Ndots         int
+	OverrideNdots bool
+	// NoSearch only attempts the literal name
+	NoSearch bool
+}
+
+// LookupA returns the IPv4 addresses for the name, applying the search list of the pool.
+// The opts parameter can be nil to use the settings of the pool.
+func (r *Resolvers) LookupA(ctx context.Context, name string, opts *LookupOptions) ([]net.IP, error) {
+	resp, _, err := r.lookup(ctx, name, dns.TypeA, opts)
+	if err != nil {
+		return nil, err
+	}
+	return answerIPs(resp, dns.TypeA), nil
+}
+
+// LookupAAAA returns the IPv6 addresses for the name, applying the search list of the pool.
+// The opts parameter can be nil to use the settings of the pool.
+func (r *Resolvers) LookupAAAA(ctx context.Context, name string, opts *LookupOptions) ([]net.IP, error) {
+	resp, _, err := r.lookup(ctx, name, dns.TypeAAAA, opts)
+	if err != nil {
+		return nil, err
+	}
+	return answerIPs(resp, dns.TypeAAAA), nil
+}
+
+// lookup attempts the names produced by the search list in order and returns the first
+// response containing records of the requested type, along with the name that was used.
+func (r *Resolvers) lookup(ctx context.Context, name string, qtype uint16, opts *LookupOptions) (*dns.Msg, string, error) {
+	ndots, search := r.searchDomains()
+	if opts != nil {
+		if opts.Search != nil {
+			search = opts.Search
+		}
+		if opts.OverrideNdots {
+			ndots = opts.Ndots
+		}
+		if opts.NoSearch {
+			search = nil
+		}
+	}
+
+	err := fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	for _, n := range SearchNames(name, ndots, search) {
+		resp, qerr := r.QueryBlocking(ctx, QueryMsg(n, qtype))
+		if qerr != nil {
+			return nil, "", fmt.Errorf("lookup %s: %v", n, qerr)
+		}
+
+		switch resp.Rcode {
+		case dns.RcodeSuccess:
+			if hasAnswerType(resp, qtype) {
+				return resp, n, nil
+			}
+		case dns.RcodeNameError:
+		case RcodeNoResponse:
+			err = fmt.Errorf("lookup %s: the query failed to obtain a response", n)
+		default:
+			err = fmt.Errorf("lookup %s: the server returned %s", n, dns.RcodeToString[resp.Rcode])
+		}
+	}
+	return nil, "", err
+}
+
+func hasAnswerType(resp *dns.Msg, qtype uint16) bool {
+	for _, rr := range resp.Answer {
+		if rr.Header().Rrtype == qtype {
+			return true
+		}
+	}
+	return false
+}
+
+func answerIPs(resp *dns.Msg, qtype uint16) []net.IP {
+	var ips []net.IP
+
+	for _, a := range AnswersByType(ExtractAnswers(resp), qtype) {
+		if ip := net.ParseIP(a.Data); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
@@ -0,0 +1,84 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupA(t *testing.T) {
+	r, stop := lookupPool(t)
+	defer stop()
+
+	r.SetSearchDomains(1, "owasp.org", "caffix.net")
+	// only www.caffix.net exists, so the search list must be followed
+	ips, err := r.LookupA(context.Background(), "www", nil)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.168.1.1")) {
+		t.Errorf("the lookup did not apply the search list: %v", err)
+	}
+
+	if _, err := r.LookupA(context.Background(), "www", &LookupOptions{NoSearch: true}); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the lookup applied the search list after it was disabled: %v", err)
+	}
+	if _, err := r.LookupA(context.Background(), "www", &LookupOptions{Search: []string{"owasp.org"}}); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the lookup did not use the search list override: %v", err)
+	}
+	if _, err := r.LookupA(context.Background(), "www.", nil); !errors.Is(err, ErrNoSuchHost) {
+		t.Errorf("the search list was applied to the fully qualified name: %v", err)
+	}
+}
+
+func TestLookupAAAA(t *testing.T) {
+	r, stop := lookupPool(t)
+	defer stop()
+
+	ips, err := r.LookupAAAA(context.Background(), "www.caffix.net", nil)
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("the lookup did not return the IPv6 address: %v", err)
+	}
+}
+
+func lookupPool(t *testing.T) (*Resolvers, func()) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(lookupHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	return r, func() {
+		r.Stop()
+		_ = s.Shutdown()
+	}
+}
+
+// lookupHandler only returns the addresses for www.caffix.net
+func lookupHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	q := req.Question[0]
+	if q.Name != "www.caffix.net." {
+		m.Rcode = dns.RcodeNameError
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 0}
+	switch q.Qtype {
+	case dns.TypeA:
+		m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.ParseIP("192.168.1.1")}}
+	case dns.TypeAAAA:
+		m.Answer = []dns.RR{&dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")}}
+	}
+	_ = w.WriteMsg(m)
+}
@@ -0,0 +1,183 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "time"
+
+const (
+	memoryCheckInterval = time.Second
+	// approxRequestSize is the estimated bytes of a queued request along with the query message
+	approxRequestSize = 512
+	// approxEntrySize is the estimated overhead in bytes of a map entry and the structure it holds
+	approxEntrySize = 128
+	// approxAnswerSize is the estimated overhead in bytes of an extracted answer
+	approxAnswerSize = 48
+)
+
+// MemoryUsage contains the approximate bytes of memory held by the pool.
+type MemoryUsage struct {
+	// Queued is held by the queries waiting in the queues of the pool and the resolvers
+	Queued int64
+	// Exchanges is held by the queries awaiting responses from the resolvers
+	Exchanges int64
+	// Wildcards is held by the wildcard test results
+	Wildcards int64
+	Total     int64
+}
+
+// MemoryUsage returns the approximate memory held by the queues and the wildcard state of the
+// pool. The memory held by a Cache is returned by the MemoryUsage method of the cache.
+func (r *Resolvers) MemoryUsage() *MemoryUsage {
+	u := &MemoryUsage{Queued: int64(r.queue.Len()+r.leases.Len()) * approxRequestSize}
+
+	for _, res := range r.pool.AllResolvers() {
+		u.Queued += int64(res.queue.Len()) * approxRequestSize
+		u.Exchanges += int64(res.xchgs.len()) * approxRequestSize
+	}
+
+	r.Lock()
+	for sub, w := range r.wildcards {
+		u.Wildcards += w.memory(sub)
+	}
+	r.Unlock()
+
+	u.Total = u.Queued + u.Exchanges + u.Wildcards
+	return u
+}
+
+// SetMemoryLimit sets the approximate bytes of memory the pool can hold. Beyond the limit, the
+// least recently used wildcard test results are removed, and the queries of low priority are
+// rejected with RcodeOverloaded until the usage returns below the limit. Zero removes the limit.
+func (r *Resolvers) SetMemoryLimit(bytes int64) {
+	if bytes < 0 {
+		bytes = 0
+	}
+	r.memLimit.Store(bytes)
+	r.enforceMemoryLimit()
+}
+
+func (r *Resolvers) memoryChecks(stop chan struct{}) {
+	t := time.NewTicker(memoryCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-stop:
+			return
+		case <-t.C:
+			r.enforceMemoryLimit()
+		}
+	}
+}
+
+func (r *Resolvers) enforceMemoryLimit() {
+	limit := r.memLimit.Load()
+	if limit <= 0 {
+		r.overMemory.Store(false)
+		return
+	}
+
+	excess := r.MemoryUsage().Total - limit
+	if excess > 0 {
+		excess -= r.evictWildcardMemory(excess)
+	}
+	r.overMemory.Store(excess > 0)
+}
+
+// evictWildcardMemory removes the least recently used wildcard test results until the bytes
+// are released, and returns the bytes that were released.
+func (r *Resolvers) evictWildcardMemory(bytes int64) int64 {
+	r.Lock()
+	defer r.Unlock()
+
+	var freed int64
+	for freed < bytes {
+		back := r.wildLRU.Back()
+		if back == nil {
+			break
+		}
+
+		sub := back.Value.(string)
+		if w, found := r.wildcards[sub]; found {
+			freed += w.memory(sub)
+		}
+		r.wildLRU.Remove(back)
+		delete(r.wildcards, sub)
+		r.wildEvictions.Add(1)
+	}
+	return freed
+}
+
+// memory returns the approximate bytes held by the wildcard test result.
+func (w *wildcard) memory(sub string) int64 {
+	return approxEntrySize + int64(len(sub)) + w.size.Load()
+}
+
+// setResult assigns the wildcard test result. The caller must hold the wildcard lock.
+func (w *wildcard) setResult(detected bool, answers []*ExtractedAnswer) {
+	w.Detected, w.Answers = detected, answers
+
+	var size int64
+	for _, a := range answers {
+		size += approxAnswerSize + int64(len(a.Name)+len(a.Data))
+	}
+	w.size.Store(size)
+}
+
+// MemoryUsage returns the approximate bytes of memory held by the entries of the cache.
+func (c *Cache) MemoryUsage() int64 {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.bytes
+}
+
+// SetMemoryLimit sets the approximate bytes of memory the entries of the cache can hold, and
+// the least recently used entries are removed beyond the limit. Zero removes the limit.
+func (c *Cache) SetMemoryLimit(bytes int64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if bytes < 0 {
+		bytes = 0
+	}
+	c.limit = bytes
+	c.evict()
+}
+
+// setEntry adds the entry and removes the least recently used entries beyond the memory limit.
+// The caller must hold the cache lock.
+func (c *Cache) setEntry(key monitorKey, e *cacheEntry) {
+	c.deleteEntry(key)
+
+	e.Size = approxEntrySize + int64(len(key.Name)) + int64(e.Msg.Len())
+	e.elem = c.lru.PushFront(key)
+	c.entries[key] = e
+	c.bytes += e.Size
+	c.evict()
+}
+
+// deleteEntry removes the entry. The caller must hold the cache lock.
+func (c *Cache) deleteEntry(key monitorKey) {
+	if e, found := c.entries[key]; found {
+		c.bytes -= e.Size
+		c.lru.Remove(e.elem)
+		delete(c.entries, key)
+	}
+}
+
+// evict removes the least recently used entries beyond the memory limit. The caller must hold
+// the cache lock.
+func (c *Cache) evict() {
+	for c.limit > 0 && c.bytes > c.limit {
+		back := c.lru.Back()
+		if back == nil {
+			break
+		}
+		c.deleteEntry(back.Value.(monitorKey))
+	}
+}
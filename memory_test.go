@@ -0,0 +1,101 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestCacheMemoryLimit(t *testing.T) {
+	c := &Cache{entries: make(map[monitorKey]*cacheEntry)}
+
+	for i := 0; i < 10; i++ {
+		name := strconv.Itoa(i) + ".caffix.net"
+		c.put(monitorKey{Name: name, Qtype: dns.TypeA}, ttlReply(QueryMsg(name, dns.TypeA), 60))
+	}
+	used := c.MemoryUsage()
+	if used <= 0 {
+		t.Fatalf("the cache reported %d bytes for ten entries", used)
+	}
+
+	c.SetMemoryLimit(used / 2)
+	if n := c.MemoryUsage(); n > used/2 {
+		t.Errorf("the cache holds %d bytes beyond the limit of %d", n, used/2)
+	}
+	if len(c.entries) == 0 || len(c.entries) >= 10 {
+		t.Errorf("the cache holds %d entries after enforcing the limit", len(c.entries))
+	}
+
+	c.Lock()
+	for key := range c.entries {
+		c.deleteEntry(key)
+	}
+	c.Unlock()
+	if n := c.MemoryUsage(); n != 0 {
+		t.Errorf("the empty cache reported %d bytes", n)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := &Cache{entries: make(map[monitorKey]*cacheEntry)}
+
+	var keys []monitorKey
+	for i := 0; i < 3; i++ {
+		name := strconv.Itoa(i) + ".caffix.net"
+		key := monitorKey{Name: name, Qtype: dns.TypeA}
+		c.put(key, ttlReply(QueryMsg(name, dns.TypeA), 60))
+		keys = append(keys, key)
+	}
+	// the lookup makes the oldest entry the most recently used
+	if c.get(keys[0]) == nil {
+		t.Fatalf("the cached entry was not returned")
+	}
+
+	c.SetMemoryLimit(c.MemoryUsage() - 1)
+	if _, found := c.entries[keys[1]]; found {
+		t.Errorf("the least recently used entry was not removed")
+	}
+	if _, found := c.entries[keys[0]]; !found {
+		t.Errorf("the recently used entry was removed")
+	}
+}
+
+func TestWildcardMemoryLimit(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.Lock()
+	for i := 0; i < 10; i++ {
+		w := new(wildcard)
+		w.setResult(true, []*ExtractedAnswer{{Name: "caffix.net", Type: dns.TypeA, Data: "192.168.1.1"}})
+		r.storeWildcard(strconv.Itoa(i)+".caffix.net", w)
+	}
+	r.Unlock()
+
+	used := r.MemoryUsage()
+	if used.Wildcards <= 0 || used.Total != used.Queued+used.Exchanges+used.Wildcards {
+		t.Fatalf("the pool reported an unexpected memory usage: %+v", used)
+	}
+
+	r.SetMemoryLimit(used.Total / 2)
+	if n := r.MemoryUsage().Total; n > used.Total/2 {
+		t.Errorf("the pool holds %d bytes beyond the limit of %d", n, used.Total/2)
+	}
+	if r.overloaded() {
+		t.Errorf("the pool rejects queries after releasing the memory")
+	}
+
+	// the wildcard results are released before the queries are rejected
+	r.SetMemoryLimit(1)
+	if n := len(r.wildcards); n != 0 {
+		t.Errorf("the pool kept %d wildcard results beyond the limit", n)
+	}
+	if r.overloaded() {
+		t.Errorf("the pool rejects queries after releasing the memory")
+	}
+}
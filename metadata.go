@@ -0,0 +1,148 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The transports reported in the response metadata.
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+)
+
+type metadataCtxKey struct{}
+
+type wildcardCtxKey struct{}
+
+// Response contains a DNS response message along with the details of how it was obtained.
+type Response struct {
+	Msg *dns.Msg
+	// Resolver is the address of the resolver that provided the response
+	Resolver string
+	// Transport is the protocol used for the last exchange with the resolver
+	Transport string
+	// Attempts counts the exchanges, such as the TCP retry following a truncated response
+	Attempts int
+	RTT      time.Duration
+	CacheHit bool
+	// Stale is set when the cache served an expired response as described by SetServeStale
+	Stale bool
+	// Wildcard is only checked when the query context was provided by WithWildcardCheck
+	Wildcard bool
+	// Raw contains the response bytes received over UDP or TLS when enabled by SetRawResponses
+	Raw []byte
+	// Sinkholes contains the answer records pointing at entries in the list set by SetSinkholes
+	Sinkholes []dns.RR
+	// Blocked describes the evidence when the response was translated into RcodeBlocked
+	Blocked string
+	// Bogus contains the answer records removed by the validation set by SetAddressValidation
+	Bogus []dns.RR
+	// Policy is the rule of the policy set by SetPolicy that modified the response
+	Policy *PolicyRule
+	// ParseError is set when Msg only contains the sections parsed before the message was malformed
+	ParseError error
+}
+
+// metadata collects the details while the query is processed. The exchanges can continue after
+// the caller has given up on the query, so the updates are ignored once the details are returned.
+type metadata struct {
+	sync.Mutex
+	done bool
+	resp Response
+}
+
+func (m *metadata) update(fn func(resp *Response)) {
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.done {
+		fn(&m.resp)
+	}
+}
+
+// finish returns a copy of the details collected for the query and stops the updates.
+func (m *metadata) finish() *Response {
+	m.Lock()
+	defer m.Unlock()
+
+	m.done = true
+	resp := m.resp
+	return &resp
+}
+
+// MetadataResolver is implemented by the types that return responses with metadata.
+type MetadataResolver interface {
+	QueryWithMetadata(ctx context.Context, msg *dns.Msg) (*Response, error)
+}
+
+// WithWildcardCheck returns a context that causes QueryWithMetadata to check whether the
+// response could be a wildcard match within the provided domain.
+func WithWildcardCheck(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, wildcardCtxKey{}, domain)
+}
+
+// QueryWithMetadata queues the provided DNS message and returns the associated response
+// message along with the metadata describing how it was obtained.
+func (r *Resolvers) QueryWithMetadata(ctx context.Context, msg *dns.Msg) (*Response, error) {
+	m := new(metadata)
+
+	resp, err := r.QueryBlocking(context.WithValue(ctx, metadataCtxKey{}, m), msg)
+	meta := m.finish()
+	meta.Msg = resp
+	if err != nil {
+		return meta, err
+	}
+
+	if domain, ok := ctx.Value(wildcardCtxKey{}).(string); ok && resp.Rcode == dns.RcodeSuccess {
+		meta.Wildcard = r.WildcardDetected(ctx, resp, domain)
+	}
+	return meta, nil
+}
+
+// QueryWithMetadata implements the MetadataResolver interface using the cached responses.
+func (c *Cache) QueryWithMetadata(ctx context.Context, msg *dns.Msg) (*Response, error) {
+	if msg == nil || len(msg.Question) == 0 {
+		return &Response{Msg: msg}, errors.New("the message does not contain a question")
+	}
+
+	m := new(metadata)
+	q := msg.Question[0]
+	// the metadata is provided by the pool when the response was not cached
+	resp, cached, err := c.lookup(context.WithValue(ctx, metadataCtxKey{}, m), q.Name, q.Qtype)
+	meta := m.finish()
+	meta.Msg = resp
+	meta.CacheHit = cached
+	return meta, err
+}
+
+func metadataFromContext(ctx context.Context) *metadata {
+	if ctx == nil {
+		return nil
+	}
+
+	meta, _ := ctx.Value(metadataCtxKey{}).(*metadata)
+	return meta
+}
+
+// recordAttempt updates the metadata of the request for another exchange with the resolver.
+func (r *request) recordAttempt(res *resolver, transport string) {
+	r.Meta.update(func(meta *Response) {
+		meta.Resolver = res.address.String()
+		meta.Transport = transport
+		meta.Attempts++
+	})
+}
@@ -0,0 +1,157 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryWithMetadata(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	var _ MetadataResolver = r
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("www.domain.com", dns.TypeA))
+	if err != nil || resp.Msg == nil || resp.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if resp.Resolver != addrstr || resp.Transport != TransportUDP || resp.Attempts != 1 || resp.RTT == 0 {
+		t.Errorf("the metadata was not correctly provided: %+v", resp)
+	}
+	if resp.CacheHit || resp.Wildcard {
+		t.Errorf("the response was flagged without being checked: %+v", resp)
+	}
+
+	ctx := WithWildcardCheck(context.Background(), "domain.com")
+	resp, err = r.QueryWithMetadata(ctx, QueryMsg("jeff_foley.wildcard.domain.com", dns.TypeA))
+	if err != nil || !resp.Wildcard {
+		t.Errorf("the wildcard match was not flagged: %v", err)
+	}
+}
+
+func TestQueryWithMetadataAbandoned(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, func(w dns.ResponseWriter, req *dns.Msg) {
+		time.Sleep(300 * time.Millisecond)
+		typeAHandler(w, req)
+	})
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	resp, err := r.QueryWithMetadata(ctx, QueryMsg("www.caffix.net", dns.TypeA))
+	if err == nil {
+		t.Fatalf("the query succeeded after the context expired")
+	}
+
+	attempts := resp.Attempts
+	// the response arrives after the caller has given up on the query
+	time.Sleep(500 * time.Millisecond)
+	if resp.Attempts != attempts || resp.RTT != 0 {
+		t.Errorf("the metadata was modified after being returned: %+v", resp)
+	}
+}
+
+func TestQueryWithMetadataTCP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for UDP datagrams: %v", err)
+	}
+	l, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		_ = pc.Close()
+		t.Skipf("unable to listen for TCP connections on the same port: %v", err)
+	}
+
+	udp, addrstr, _, err := RunLocalServer(pc, nil, func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(truncatedHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = udp.Shutdown() }()
+
+	tcp, _, _, err := RunLocalServer(nil, l, func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = tcp.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if resp.Transport != TransportTCP || resp.Attempts != 2 {
+		t.Errorf("the TCP fallback was not reported in the metadata: %+v", resp)
+	}
+}
+
+func TestCacheQueryWithMetadata(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			_ = w.WriteMsg(ttlReply(req, 60))
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	c := NewCache(r)
+	defer c.Stop()
+
+	var _ MetadataResolver = c
+	for i, hit := range []bool{false, true} {
+		resp, err := c.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+		if err != nil || len(resp.Msg.Answer) == 0 {
+			t.Fatalf("the lookup failed: %v", err)
+		}
+		if resp.CacheHit != hit {
+			t.Errorf("lookup %d returned the cache hit flag %t", i+1, resp.CacheHit)
+		}
+		if !hit && resp.Resolver != addrstr {
+			t.Errorf("the metadata from the pool was not provided for the cache miss: %+v", resp)
+		}
+	}
+	if _, err := c.QueryWithMetadata(context.Background(), new(dns.Msg)); err == nil {
+		t.Errorf("the message without a question was accepted")
+	}
+}
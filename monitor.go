@@ -0,0 +1,231 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	monitorEventsBuffer = 100
+	// DefaultMonitorInterval is used by the Monitor when the provided interval is not positive.
+	DefaultMonitorInterval = time.Minute
+)
+
+// ChangeEvent describes the records added to and removed from the answers for a monitored name.
+type ChangeEvent struct {
+	Name    string
+	Qtype   uint16
+	Added   []string
+	Removed []string
+	Time    time.Time
+}
+
+type monitorKey struct {
+	Name  string
+	Qtype uint16
+}
+
+type snapshot struct {
+	Resolved bool
+	Records  []string
+}
+
+// Monitor re-resolves a set of names on an interval and emits a ChangeEvent each time the
+// canonical answers differ from the previous snapshot.
+type Monitor struct {
+	sync.Mutex
+	done     chan struct{}
+	pool     *Resolvers
+	interval time.Duration
+	names    map[monitorKey]*snapshot
+	events   chan *ChangeEvent
+}
+
+// NewMonitor returns an active Monitor that uses the provided pool to resolve the names.
+// DefaultMonitorInterval is used when the interval is not positive.
+func NewMonitor(pool *Resolvers, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = DefaultMonitorInterval
+	}
+
+	m := &Monitor{
+		done:     make(chan struct{}, 1),
+		pool:     pool,
+		interval: interval,
+		names:    make(map[monitorKey]*snapshot),
+		events:   make(chan *ChangeEvent, monitorEventsBuffer),
+	}
+
+	go m.checks()
+	return m
+}
+
+// Stop will release the Monitor resources.
+func (m *Monitor) Stop() {
+	select {
+	case <-m.done:
+	default:
+		close(m.done)
+	}
+}
+
+// Events returns the channel that receives the change events.
+func (m *Monitor) Events() <-chan *ChangeEvent {
+	return m.events
+}
+
+// Add starts monitoring the name for the provided record types. The first resolution
+// establishes the snapshot, so changes are reported beginning with the second interval.
+func (m *Monitor) Add(name string, qtypes ...uint16) {
+	m.Lock()
+	defer m.Unlock()
+
+	name = strings.ToLower(RemoveLastDot(name))
+	for _, qtype := range qtypes {
+		key := monitorKey{Name: name, Qtype: qtype}
+
+		if _, found := m.names[key]; !found {
+			m.names[key] = new(snapshot)
+		}
+	}
+}
+
+// Remove stops monitoring the name for the provided record types.
+func (m *Monitor) Remove(name string, qtypes ...uint16) {
+	m.Lock()
+	defer m.Unlock()
+
+	name = strings.ToLower(RemoveLastDot(name))
+	for _, qtype := range qtypes {
+		delete(m.names, monitorKey{Name: name, Qtype: qtype})
+	}
+}
+
+func (m *Monitor) checks() {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-m.done
+		cancel()
+	}()
+
+	for {
+		m.check(ctx)
+
+		select {
+		case <-m.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context) {
+	m.Lock()
+	var keys []monitorKey
+	for key := range m.names {
+		keys = append(keys, key)
+	}
+	m.Unlock()
+
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+
+		go func(key monitorKey) {
+			defer wg.Done()
+
+			resp, err := m.pool.QueryBlocking(ctx, QueryMsg(key.Name, key.Qtype))
+			if err != nil || (resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError) {
+				// failed queries and errors such as SERVFAIL do not reveal a change to the records
+				return
+			}
+			if ev := m.update(key, CanonicalAnswers(resp)); ev != nil {
+				select {
+				case <-m.done:
+				case m.events <- ev:
+				}
+			}
+		}(key)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) update(key monitorKey, records []string) *ChangeEvent {
+	m.Lock()
+	defer m.Unlock()
+
+	snap, found := m.names[key]
+	if !found {
+		return nil
+	}
+	if !snap.Resolved {
+		snap.Resolved = true
+		snap.Records = records
+		return nil
+	}
+
+	added, removed := diffRecords(snap.Records, records)
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	snap.Records = records
+	return &ChangeEvent{
+		Name:    key.Name,
+		Qtype:   key.Qtype,
+		Added:   added,
+		Removed: removed,
+		Time:    time.Now(),
+	}
+}
+
+// diffRecords returns the records only found in cur and the records only found in prev.
+func diffRecords(prev, cur []string) ([]string, []string) {
+	pset := make(map[string]struct{}, len(prev))
+	for _, r := range prev {
+		pset[r] = struct{}{}
+	}
+	cset := make(map[string]struct{}, len(cur))
+	for _, r := range cur {
+		cset[r] = struct{}{}
+	}
+
+	var added, removed []string
+	for _, r := range cur {
+		if _, found := pset[r]; !found {
+			added = append(added, r)
+		}
+	}
+	for _, r := range prev {
+		if _, found := cset[r]; !found {
+			removed = append(removed, r)
+		}
+	}
+	return added, removed
+}
+
+// String implements the Stringer interface for change events.
+func (e *ChangeEvent) String() string {
+	var b strings.Builder
+
+	b.WriteString(e.Name + " " + dns.TypeToString[e.Qtype] + ":")
+	for _, r := range e.Added {
+		b.WriteString("\n+ " + r)
+	}
+	for _, r := range e.Removed {
+		b.WriteString("\n- " + r)
+	}
+	return b.String()
+}
@@ -0,0 +1,145 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestMonitor(t *testing.T) {
+	var queries atomic.Int32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			addr := "192.168.1.1"
+			// the address changes after the first response
+			if queries.Add(1) > 1 {
+				addr = "192.168.1.2"
+			}
+
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Answer = []dns.RR{&dns.A{
+				Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+				A:   net.ParseIP(addr),
+			}}
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	m := NewMonitor(r, 100*time.Millisecond)
+	defer m.Stop()
+	m.Add("Caffix.net.", dns.TypeA)
+
+	timer := time.NewTimer(2 * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		t.Fatalf("the monitor did not emit the change event")
+	case ev := <-m.Events():
+		if ev.Name != "caffix.net" || ev.Qtype != dns.TypeA {
+			t.Errorf("the change event was for the wrong name: %s", ev)
+		}
+		if len(ev.Added) != 1 || ev.Added[0] != "caffix.net. IN A 192.168.1.2" {
+			t.Errorf("the change event did not include the added record: %s", ev)
+		}
+		if len(ev.Removed) != 1 || ev.Removed[0] != "caffix.net. IN A 192.168.1.1" {
+			t.Errorf("the change event did not include the removed record: %s", ev)
+		}
+	}
+	// the answers no longer change, so additional events should not be emitted
+	select {
+	case ev := <-m.Events():
+		t.Errorf("the monitor emitted an event without a change: %s", ev)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	m.Remove("caffix.net", dns.TypeA)
+	// allow a query in flight during the removal to complete
+	time.Sleep(150 * time.Millisecond)
+	before := queries.Load()
+	time.Sleep(300 * time.Millisecond)
+	if queries.Load() != before {
+		t.Errorf("the monitor continued to query the removed name")
+	}
+}
+
+func TestMonitorServFail(t *testing.T) {
+	var queries atomic.Int32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			// the server fails after the first response
+			if queries.Add(1) > 1 {
+				m.Rcode = dns.RcodeServerFailure
+			} else {
+				m.Answer = []dns.RR{&dns.A{
+					Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+					A:   net.ParseIP("192.168.1.1"),
+				}}
+			}
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	m := NewMonitor(r, 100*time.Millisecond)
+	defer m.Stop()
+	m.Add("caffix.net", dns.TypeA)
+
+	select {
+	case ev := <-m.Events():
+		t.Errorf("the monitor emitted an event for the SERVFAIL responses: %s", ev)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if queries.Load() < 2 {
+		t.Errorf("the monitor did not query the name again")
+	}
+}
+
+func TestMonitorDefaultInterval(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		m := NewMonitor(r, interval)
+		if m.interval != DefaultMonitorInterval {
+			t.Errorf("interval %v was not replaced with the default: %v", interval, m.interval)
+		}
+		m.Stop()
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	added, removed := diffRecords([]string{"a", "b"}, []string{"b", "c"})
+
+	if len(added) != 1 || added[0] != "c" {
+		t.Errorf("the added records were incorrect: %v", added)
+	}
+	if len(removed) != 1 || removed[0] != "a" {
+		t.Errorf("the removed records were incorrect: %v", removed)
+	}
+}
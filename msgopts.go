@@ -0,0 +1,98 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "github.com/miekg/dns"
+
+// MsgOption modifies the message generated by QueryMsg.
+type MsgOption func(m *dns.Msg)
+
+// WithClass sets the class of the questions, such as dns.ClassCHAOS for version.bind queries.
+func WithClass(class uint16) MsgOption {
+	return func(m *dns.Msg) {
+		for i := range m.Question {
+			m.Question[i].Qclass = class
+		}
+	}
+}
+
+// WithRecursionDesired sets the RD bit, which is set by default.
+func WithRecursionDesired(rd bool) MsgOption {
+	return func(m *dns.Msg) {
+		m.RecursionDesired = rd
+	}
+}
+
+// WithCheckingDisabled sets the CD bit, requesting the resolver to skip the DNSSEC validation.
+func WithCheckingDisabled(cd bool) MsgOption {
+	return func(m *dns.Msg) {
+		m.CheckingDisabled = cd
+	}
+}
+
+// WithEDNS sets the UDP payload size and the DNSSEC OK bit of the EDNS OPT record,
+// which is added when the message does not have one.
+func WithEDNS(udpSize uint16, do bool) MsgOption {
+	return func(m *dns.Msg) {
+		opt := m.IsEdns0()
+		if opt == nil {
+			m.SetEdns0(udpSize, do)
+			return
+		}
+
+		opt.SetUDPSize(udpSize)
+		opt.SetDo(do)
+	}
+}
+
+// WithEDNSOption adds the option to the EDNS OPT record, replacing an option with the same code.
+func WithEDNSOption(option dns.EDNS0) MsgOption {
+	return func(m *dns.Msg) {
+		opt := m.IsEdns0()
+		if opt == nil {
+			m.SetEdns0(dns.DefaultMsgSize, false)
+			opt = m.IsEdns0()
+		}
+
+		for i, o := range opt.Option {
+			if o.Option() == option.Option() {
+				opt.Option[i] = option
+				return
+			}
+		}
+		opt.Option = append(opt.Option, option)
+	}
+}
+
+// WithoutEDNS removes the EDNS OPT record, including the client subnet option set by default.
+func WithoutEDNS() MsgOption {
+	return func(m *dns.Msg) {
+		var extra []dns.RR
+
+		for _, rr := range m.Extra {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				extra = append(extra, rr)
+			}
+		}
+		m.Extra = extra
+	}
+}
+
+// WithQuestion adds another question using the class of the first question. Few servers
+// support multiple questions, and the responses are matched using the first question.
+func WithQuestion(name string, qtype uint16) MsgOption {
+	return func(m *dns.Msg) {
+		class := uint16(dns.ClassINET)
+		if len(m.Question) > 0 {
+			class = m.Question[0].Qclass
+		}
+
+		m.Question = append(m.Question, dns.Question{
+			Name:   dns.Fqdn(name),
+			Qtype:  qtype,
+			Qclass: class,
+		})
+	}
+}
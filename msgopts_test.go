@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestQueryMsgOptions(t *testing.T) {
+	m := QueryMsg("version.bind", dns.TypeTXT,
+		WithClass(dns.ClassCHAOS),
+		WithRecursionDesired(false),
+		WithCheckingDisabled(true),
+		WithQuestion("hostname.bind", dns.TypeTXT),
+	)
+
+	if len(m.Question) != 2 {
+		t.Fatalf("the message has %d questions instead of two", len(m.Question))
+	}
+	for _, q := range m.Question {
+		if q.Qclass != dns.ClassCHAOS {
+			t.Errorf("the question %s has the class %s", q.Name, dns.ClassToString[q.Qclass])
+		}
+	}
+	if m.RecursionDesired || !m.CheckingDisabled {
+		t.Errorf("the header bits were not correctly set")
+	}
+	if _, err := m.Pack(); err != nil {
+		t.Errorf("failed to pack the message: %v", err)
+	}
+}
+
+func TestQueryMsgEDNSOptions(t *testing.T) {
+	m := QueryMsg("caffix.net", dns.TypeA, WithEDNS(1232, true), WithEDNSOption(&dns.EDNS0_NSID{Code: dns.EDNS0NSID}))
+
+	opt := m.IsEdns0()
+	if opt == nil || opt.UDPSize() != 1232 || !opt.Do() {
+		t.Fatalf("the EDNS configuration was not applied")
+	}
+	// the client subnet option is kept with the new option
+	if len(opt.Option) != 2 {
+		t.Errorf("the OPT record contains %d options instead of two", len(opt.Option))
+	}
+
+	subnet := &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24}
+	m = QueryMsg("caffix.net", dns.TypeA, WithEDNSOption(subnet))
+	if opt := m.IsEdns0(); len(opt.Option) != 1 || opt.Option[0] != subnet {
+		t.Errorf("the option with the same code was not replaced")
+	}
+
+	m = QueryMsg("caffix.net", dns.TypeA, WithoutEDNS())
+	if m.IsEdns0() != nil {
+		t.Errorf("the OPT record was not removed")
+	}
+	m = QueryMsg("caffix.net", dns.TypeA, WithoutEDNS(), WithEDNS(4096, false))
+	if opt := m.IsEdns0(); opt == nil || opt.UDPSize() != 4096 || len(opt.Option) != 0 {
+		t.Errorf("the OPT record was not added after being removed")
+	}
+}
@@ -20,19 +20,24 @@ func RemoveLastDot(name string) string {
 	return name
 }
 
-// QueryMsg generates a message used for a forward DNS query.
-func QueryMsg(name string, qtype uint16) *dns.Msg {
+// QueryMsg generates a message used for a forward DNS query. The options are applied in order
+// and can change the class, header bits, EDNS configuration and questions of the message.
+func QueryMsg(name string, qtype uint16, opts ...MsgOption) *dns.Msg {
 	m := new(dns.Msg)
 	m.SetQuestion(dns.Fqdn(name), qtype)
 	m.Extra = append(m.Extra, SetupOptions())
+
+	for _, opt := range opts {
+		opt(m)
+	}
 	return m
 }
 
 // ReverseMsg generates a message used for a reverse DNS query.
 func ReverseMsg(addr string) *dns.Msg {
-	if net.ParseIP(addr) != nil {
-		if r, err := dns.ReverseAddr(addr); err == nil {
-			return QueryMsg(r, dns.TypePTR)
+	if ip := net.ParseIP(addr); ip != nil {
+		if name, err := ReverseName(ip); err == nil {
+			return QueryMsg(name, dns.TypePTR)
 		}
 	}
 	return nil
@@ -149,3 +149,31 @@ func TestExtractAnswers(t *testing.T) {
 		}
 	}
 }
+
+func FuzzExtractAnswers(f *testing.F) {
+	m := ttlReply(QueryMsg("www.caffix.net", dns.TypeA), 300)
+	m.Answer = append(m.Answer,
+		&dns.CNAME{Hdr: dns.RR_Header{Name: "www.caffix.net.", Rrtype: dns.TypeCNAME, Class: dns.ClassINET}, Target: "caffix.net."},
+		&dns.MX{Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeMX, Class: dns.ClassINET}, Mx: "mail.caffix.net."},
+		&dns.TXT{Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{"v=spf1", "-all"}},
+		&dns.SRV{Hdr: dns.RR_Header{Name: "_sip._tcp.caffix.net.", Rrtype: dns.TypeSRV, Class: dns.ClassINET}, Target: "sip.caffix.net."},
+	)
+	if b, err := m.Pack(); err == nil {
+		f.Add(b)
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		m := new(dns.Msg)
+		if err := m.Unpack(b); err != nil && len(m.Answer) == 0 {
+			return
+		}
+
+		for _, a := range ExtractAnswers(m) {
+			if a == nil || a.Data == "" {
+				t.Errorf("an empty answer was extracted")
+			}
+			_ = AnswersByType([]*ExtractedAnswer{a}, a.Type)
+		}
+	})
+}
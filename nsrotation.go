@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// nsFailureWindow is the duration a failed authoritative server address is tried after the others.
+const nsFailureWindow = time.Minute
+
+// nsRotation spreads the queries sent directly to authoritative name servers across all
+// the addresses of the servers, and tracks the failures of each address.
+type nsRotation struct {
+	sync.Mutex
+	next     int
+	failures map[string]*nsFailures
+}
+
+type nsFailures struct {
+	count int
+	last  time.Time
+}
+
+func newNSRotation() *nsRotation {
+	return &nsRotation{failures: make(map[string]*nsFailures)}
+}
+
+// order returns the server addresses beginning at the next position of the rotation. The
+// addresses that failed recently are moved to the end, beginning with the fewest failures.
+func (n *nsRotation) order(servers []string, now time.Time) []string {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	n.Lock()
+	defer n.Unlock()
+
+	start := n.next % len(servers)
+	n.next++
+
+	var healthy, failed []string
+	for i := 0; i < len(servers); i++ {
+		addr := servers[(start+i)%len(servers)]
+
+		if f, found := n.failures[addr]; found && now.Sub(f.last) < nsFailureWindow {
+			failed = append(failed, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+
+	sort.SliceStable(failed, func(i, j int) bool {
+		return n.failures[failed[i]].count < n.failures[failed[j]].count
+	})
+	return append(healthy, failed...)
+}
+
+func (n *nsRotation) success(addr string) {
+	n.Lock()
+	defer n.Unlock()
+
+	delete(n.failures, addr)
+}
+
+func (n *nsRotation) failure(addr string, now time.Time) {
+	n.Lock()
+	defer n.Unlock()
+
+	f, found := n.failures[addr]
+	if !found {
+		f = new(nsFailures)
+		n.failures[addr] = f
+	}
+	f.count++
+	f.last = now
+}
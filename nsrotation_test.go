@@ -0,0 +1,90 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestNSRotationOrder(t *testing.T) {
+	n := newNSRotation()
+	servers := []string{"192.168.1.1:53", "[2001:db8::1]:53", "192.168.1.2:53"}
+	now := time.Now()
+
+	for i := 0; i < len(servers); i++ {
+		if order := n.order(servers, now); order[0] != servers[i] {
+			t.Errorf("the rotation began with %s instead of %s", order[0], servers[i])
+		}
+	}
+
+	n.failure(servers[0], now)
+	n.failure(servers[0], now)
+	n.failure(servers[1], now)
+	want := []string{servers[2], servers[1], servers[0]}
+	if order := n.order(servers, now); !reflect.DeepEqual(order, want) {
+		t.Errorf("the failed servers were not moved to the end: %v", order)
+	}
+
+	n.success(servers[1])
+	later := now.Add(nsFailureWindow)
+	_ = n.order(servers, later)
+	want = []string{servers[2], servers[0], servers[1]}
+	if order := n.order(servers, later); !reflect.DeepEqual(order, want) {
+		t.Errorf("the failures were still applied after the window: %v", order)
+	}
+}
+
+func TestAuthQueryRotation(t *testing.T) {
+	var refusals, answers atomic.Int32
+
+	bad, badAddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			refusals.Add(1)
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeRefused)
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = bad.Shutdown() }()
+
+	good, goodAddr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			answers.Add(1)
+			m := ttlReply(req, 300)
+			m.Authoritative = true
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = good.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	servers := []string{badAddr, goodAddr}
+	for i := 0; i < 4; i++ {
+		if ans := r.authQueryAttempts(context.Background(), servers, "www.caffix.net", dns.TypeA); len(ans) == 0 {
+			t.Errorf("attempt %d did not return the answers", i+1)
+		}
+	}
+	// the refusing server is only tried until the failure is recorded
+	if n := refusals.Load(); n != 1 {
+		t.Errorf("the failed server received %d queries", n)
+	}
+	if n := answers.Load(); n != 4 {
+		t.Errorf("the working server received %d queries instead of 4", n)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import "sync"
+
+type pauseState struct {
+	sync.Mutex
+	// resume is closed by Resume, and is nil while the pool is not paused
+	resume chan struct{}
+}
+
+// Pause halts the dequeuing of queries, so a scan can be suspended during a maintenance window
+// without tearing down the callers. The queued queries are kept intact and sent after Resume,
+// while the queries already sent continue to receive responses. The sub-pools are also paused.
+func (r *Resolvers) Pause() {
+	p := &r.pause
+
+	p.Lock()
+	if p.resume == nil {
+		p.resume = make(chan struct{})
+		r.log.Printf("The resolver pool was paused")
+	}
+	p.Unlock()
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, sub := range r.subpools {
+		sub.Pause()
+	}
+}
+
+// Resume continues the dequeuing of the queries halted by Pause.
+func (r *Resolvers) Resume() {
+	p := &r.pause
+
+	p.Lock()
+	if p.resume != nil {
+		close(p.resume)
+		p.resume = nil
+		r.log.Printf("The resolver pool was resumed")
+	}
+	p.Unlock()
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, sub := range r.subpools {
+		sub.Resume()
+	}
+}
+
+// Paused returns true while the pool is halted by Pause.
+func (r *Resolvers) Paused() bool {
+	return r.pauseChan() != nil
+}
+
+// pauseChan returns the channel closed by Resume, or nil when the pool is not paused.
+func (r *Resolvers) pauseChan() chan struct{} {
+	p := &r.pause
+
+	p.Lock()
+	defer p.Unlock()
+
+	return p.resume
+}
@@ -0,0 +1,53 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestPauseResume(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.Pause()
+	if !r.Paused() {
+		t.Fatalf("the pool was not reported as paused")
+	}
+
+	ch := make(chan *dns.Msg, 1)
+	r.Query(context.Background(), QueryMsg("caffix.net", dns.TypeA), ch)
+	select {
+	case <-ch:
+		t.Fatalf("the query was sent while the pool was paused")
+	case <-time.After(250 * time.Millisecond):
+	}
+	if r.queue.Len() != 1 {
+		t.Errorf("the query did not remain on the queue while the pool was paused")
+	}
+
+	r.Resume()
+	select {
+	case resp := <-ch:
+		if resp.Rcode != dns.RcodeSuccess {
+			t.Errorf("the query failed after the pool was resumed: %d", resp.Rcode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("the query was not sent after the pool was resumed")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"github.com/miekg/dns"
+)
+
+// The EDNS buffer sizes attempted during payload discovery, from largest to smallest.
+var payloadProbeSizes = []uint16{dns.DefaultMsgSize, 1232, dns.MinMsgSize}
+
+// Responses must exceed the typical Ethernet MTU to demonstrate that fragmented responses arrive.
+const minFragmentedResponse = 1500
+
+// SetPayloadDiscovery enables probing each resolver added to the pool for the largest EDNS UDP
+// payload size that reliably returns complete responses. Sizes beyond 1232 bytes are only used
+// when the resolver returns a response large enough to require fragmentation. The advertised
+// buffer size of queries sent to the resolver is then clamped to the discovered value. The
+// discovery is skipped while UseTor is in effect, since Tor does not carry UDP.
+func (r *Resolvers) SetPayloadDiscovery(enable bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.discovery = enable
+}
+
+func (r *resolver) discoverPayloadSize() {
+	// the probes require UDP, which would bypass the Tor proxy
+	if r.pool.tor.Load() != nil {
+		return
+	}
+
+	for _, size := range payloadProbeSizes {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		msg := new(dns.Msg)
+		msg.SetQuestion(".", dns.TypeDNSKEY)
+		msg.SetEdns0(size, true)
+
+		client := dns.Client{
+			Net:     "udp",
+			UDPSize: size,
+			Timeout: r.xchgs.getTimeout(),
+			Dialer:  r.pool.conns.dialer(r.xchgs.getTimeout()),
+		}
+		_ = r.rate.Take()
+		resp, _, err := client.Exchange(msg, r.address.String())
+		if err != nil || resp.Truncated {
+			continue
+		}
+		// a small response does not show that sizes beyond 1232 bytes survive fragmentation
+		if size > 1232 && resp.Len() <= minFragmentedResponse {
+			continue
+		}
+
+		r.setPayloadSize(size)
+		return
+	}
+	r.setPayloadSize(dns.MinMsgSize)
+}
+
+func (r *resolver) setPayloadSize(size uint16) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.udpSize = size
+}
+
+func (r *resolver) payloadSize() uint16 {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.udpSize
+}
+
+func (r *resolver) clampPayloadSize(msg *dns.Msg) {
+	size := r.payloadSize()
+	if size == 0 {
+		return
+	}
+
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() > size {
+		opt.SetUDPSize(size)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestPayloadDiscovery(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(smallPayloadHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetTimeout(500 * time.Millisecond)
+	r.SetPayloadDiscovery(true)
+	_ = r.AddResolvers(10, addrstr)
+
+	res := r.pool.GetResolver()
+	for i := 0; i < 20 && res.payloadSize() == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	if size := res.payloadSize(); size != 1232 {
+		t.Errorf("the discovered payload size was %d instead of 1232", size)
+	}
+
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	if res.clampPayloadSize(msg); msg.IsEdns0().UDPSize() != 1232 {
+		t.Errorf("the EDNS buffer size was not clamped to the discovered payload size")
+	}
+}
+
+func TestPayloadDiscoveryLarge(t *testing.T) {
+	for _, test := range []struct {
+		handler  dns.HandlerFunc
+		expected uint16
+	}{
+		{largePayloadHandler, dns.DefaultMsgSize},
+		{truncatedHandler, dns.MinMsgSize},
+	} {
+		s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+			s.Handler = test.handler
+		})
+		if err != nil {
+			t.Fatalf("unable to run test server: %v", err)
+		}
+
+		r := NewResolvers()
+		r.SetTimeout(500 * time.Millisecond)
+		r.SetPayloadDiscovery(true)
+		_ = r.AddResolvers(10, addrstr)
+
+		res := r.pool.GetResolver()
+		for i := 0; i < 30 && res.payloadSize() == 0; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		if size := res.payloadSize(); size != test.expected {
+			t.Errorf("the discovered payload size was %d instead of %d", size, test.expected)
+		}
+		r.Stop()
+		_ = s.Shutdown()
+	}
+}
+
+func smallPayloadHandler(w dns.ResponseWriter, req *dns.Msg) {
+	// simulate a middlebox that drops fragmented responses
+	if opt := req.IsEdns0(); opt != nil && opt.UDPSize() > 1232 {
+		return
+	}
+	_ = w.WriteMsg(payloadResponse(req, 1200))
+}
+
+func largePayloadHandler(w dns.ResponseWriter, req *dns.Msg) {
+	_ = w.WriteMsg(payloadResponse(req, 2000))
+}
+
+// payloadResponse returns a reply to the request that is close to the provided length
+func payloadResponse(req *dns.Msg, length int) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	txt := strings.Repeat("a", 200)
+	for m.Len() < length-250 {
+		m.Answer = append(m.Answer, &dns.TXT{
+			Hdr: dns.RR_Header{Name: m.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+			Txt: []string{txt},
+		})
+	}
+	return m
+}
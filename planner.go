@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"math"
+	"time"
+)
+
+// ScanPlan describes the work of a scan, so the cost can be estimated before it is launched.
+type ScanPlan struct {
+	// Names is the number of names queried for each of the Qtypes
+	Names  int
+	Qtypes []uint16
+	// WildcardSubdomains is the number of distinct subdomains tested for DNS wildcards
+	WildcardSubdomains int
+	// Revalidate is set when the positive answers are verified by a Revalidator
+	Revalidate bool
+	// PositiveRatio is the expected fraction of the queries returning answers, which is
+	// only used for the revalidation and defaults to one
+	PositiveRatio float64
+	// RetryRatio is the expected fraction of the queries that are sent again after a failure
+	RetryRatio float64
+}
+
+// ScanEstimate is the cost of a ScanPlan returned by EstimateScan.
+type ScanEstimate struct {
+	Queries      uint64
+	Wildcards    uint64
+	Revalidation uint64
+	Retries      uint64
+	Total        uint64
+	// QPS is the current rate of the pool used for the estimate
+	QPS int
+	// Duration is the time required to send the queries at the QPS, or zero when the rate
+	// of the pool is unknown
+	Duration time.Duration
+}
+
+// EstimateScan returns the number of queries required by the plan and the time required to
+// send them at the current QPS of the pool, so scans can be sized before they are launched.
+// No queries are sent.
+func (r *Resolvers) EstimateScan(plan *ScanPlan) *ScanEstimate {
+	e := &ScanEstimate{QPS: r.QPS()}
+	if plan == nil {
+		return e
+	}
+
+	e.Queries = uint64(max(plan.Names, 0) * len(plan.Qtypes))
+	e.Wildcards = uint64(max(plan.WildcardSubdomains, 0) * numOfWildcardTests * len(wildcardQueryTypes))
+	if plan.Revalidate {
+		ratio := plan.PositiveRatio
+		if ratio <= 0 || ratio > 1 {
+			ratio = 1
+		}
+		e.Revalidation = uint64(math.Ceil(float64(e.Queries) * ratio))
+	}
+	if plan.RetryRatio > 0 {
+		e.Retries = uint64(math.Ceil(float64(e.Queries+e.Revalidation) * plan.RetryRatio))
+	}
+
+	e.Total = e.Queries + e.Wildcards + e.Revalidation + e.Retries
+	if e.QPS > 0 {
+		e.Duration = time.Duration(e.Total) * time.Second / time.Duration(e.QPS)
+	}
+	return e
+}
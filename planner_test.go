@@ -0,0 +1,43 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestEstimateScan(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+	r.SetMaxQPS(100)
+
+	e := r.EstimateScan(&ScanPlan{
+		Names:              1000,
+		Qtypes:             []uint16{dns.TypeA, dns.TypeAAAA},
+		WildcardSubdomains: 10,
+		Revalidate:         true,
+		PositiveRatio:      0.1,
+		RetryRatio:         0.5,
+	})
+
+	wildcards := uint64(10 * numOfWildcardTests * len(wildcardQueryTypes))
+	if e.Queries != 2000 || e.Wildcards != wildcards || e.Revalidation != 200 || e.Retries != 1100 {
+		t.Errorf("the estimate was unexpected: %+v", e)
+	}
+	if total := 2000 + wildcards + 200 + 1100; e.Total != total {
+		t.Errorf("the estimate had %d queries instead of %d", e.Total, total)
+	}
+	if expected := time.Duration(e.Total) * 10 * time.Millisecond; e.Duration != expected {
+		t.Errorf("the estimate was %s instead of %s", e.Duration, expected)
+	}
+
+	r.SetMaxQPS(0)
+	if e := r.EstimateScan(&ScanPlan{Names: 10, Qtypes: []uint16{dns.TypeA}}); e.Duration != 0 {
+		t.Errorf("the estimate without a rate required %s", e.Duration)
+	}
+}
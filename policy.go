@@ -0,0 +1,228 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The actions taken by the policy rules.
+const (
+	PolicyNXDOMAIN = "NXDOMAIN"
+	PolicyNODATA   = "NODATA"
+	PolicyPASSTHRU = "PASSTHRU"
+	PolicyRewrite  = "REWRITE"
+)
+
+const policyTTL = 300
+
+// PolicyRule is a single trigger and action of a Policy.
+type PolicyRule struct {
+	Line int
+	// Name matches the question name, and a leading "*." only matches the subdomains
+	Name string
+	// Net matches the addresses in the A and AAAA answer records
+	Net    *net.IPNet
+	Action string
+	// Data contains the type and record data used by the rewrite action
+	Data string
+}
+
+// Policy is an ordered list of rules, similar to a response policy zone, applied to the
+// responses before they are returned. The first rule triggered by a response is used.
+type Policy struct {
+	Rules []*PolicyRule
+}
+
+// String returns the rule in the format parsed by ParsePolicy.
+func (p *PolicyRule) String() string {
+	trigger := "name:" + p.Name
+	if p.Net != nil {
+		trigger = "ip:" + p.Net.String()
+	}
+	if p.Action == PolicyRewrite {
+		return trigger + " " + p.Data
+	}
+	return trigger + " " + p.Action
+}
+
+// LoadPolicyFile parses the policy in the file at the provided path.
+func LoadPolicyFile(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParsePolicy(f)
+}
+
+// ParsePolicy reads the policy rules, one per line, consisting of a trigger and an action.
+// The triggers are "name:<name>" and "ip:<address or CIDR>", and the actions are NXDOMAIN,
+// NODATA, PASSTHRU or a record type and data used to rewrite the answer, e.g.
+//
+//	name:*.ads.example NXDOMAIN
+//	name:intranet.example A 10.0.0.1
+//	ip:192.0.2.0/24 NODATA
+//
+// Empty lines and lines starting with '#' are ignored.
+func ParsePolicy(rd io.Reader) (*Policy, error) {
+	p := new(Policy)
+
+	scanner := bufio.NewScanner(rd)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		rule, err := parsePolicyRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rule.Line = line
+		p.Rules = append(p.Rules, rule)
+	}
+	return p, scanner.Err()
+}
+
+func parsePolicyRule(text string) (*PolicyRule, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("the rule %q requires a trigger and an action", text)
+	}
+
+	rule := new(PolicyRule)
+	trigger := fields[0]
+	switch {
+	case strings.HasPrefix(trigger, "name:"):
+		rule.Name = strings.ToLower(RemoveLastDot(strings.TrimPrefix(trigger, "name:")))
+		if !validLDHName(strings.TrimPrefix(rule.Name, "*.")) {
+			return nil, fmt.Errorf("the trigger %s does not contain a valid name", trigger)
+		}
+	case strings.HasPrefix(trigger, "ip:"):
+		addr := strings.TrimPrefix(trigger, "ip:")
+		if !strings.Contains(addr, "/") {
+			if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+				addr += "/32"
+			} else {
+				addr += "/128"
+			}
+		}
+
+		_, ipnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("the trigger %s does not contain a valid address", trigger)
+		}
+		rule.Net = ipnet
+	default:
+		return nil, fmt.Errorf("the trigger %s is not supported", trigger)
+	}
+
+	action := strings.ToUpper(fields[1])
+	switch action {
+	case PolicyNXDOMAIN, PolicyNODATA, PolicyPASSTHRU:
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("the %s action does not accept data", action)
+		}
+		rule.Action = action
+	default:
+		if _, found := dns.StringToType[action]; !found || len(fields) < 3 {
+			return nil, fmt.Errorf("the action %s is not supported", fields[1])
+		}
+		rule.Action = PolicyRewrite
+		rule.Data = action + " " + strings.Join(fields[2:], " ")
+		// check that the record data can be parsed
+		if _, err := dns.NewRR("policy.invalid. " + rule.Data); err != nil {
+			return nil, err
+		}
+	}
+	return rule, nil
+}
+
+// SetPolicy causes the pool to apply the policy to the responses before they are returned.
+// Providing nil removes the policy.
+func (r *Resolvers) SetPolicy(p *Policy) {
+	r.policy.Store(p)
+	r.syncSubPools()
+}
+
+// Apply modifies the response according to the first rule triggered by it and returns the
+// rule, or nil when the response did not trigger any of the rules.
+func (p *Policy) Apply(msg *dns.Msg) *PolicyRule {
+	if p == nil || msg == nil || len(msg.Question) == 0 {
+		return nil
+	}
+
+	rule := p.match(msg)
+	if rule == nil {
+		return nil
+	}
+
+	switch rule.Action {
+	case PolicyNXDOMAIN:
+		msg.Rcode = dns.RcodeNameError
+		msg.Answer, msg.Ns = nil, nil
+	case PolicyNODATA:
+		msg.Rcode = dns.RcodeSuccess
+		msg.Answer, msg.Ns = nil, nil
+	case PolicyRewrite:
+		q := msg.Question[0]
+		msg.Rcode = dns.RcodeSuccess
+		msg.Answer, msg.Ns = nil, nil
+
+		if rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s", q.Name, policyTTL, rule.Data)); err == nil {
+			if t := rr.Header().Rrtype; t == q.Qtype || t == dns.TypeCNAME {
+				msg.Answer = []dns.RR{rr}
+			}
+		}
+	}
+	return rule
+}
+
+func (p *Policy) match(msg *dns.Msg) *PolicyRule {
+	name := strings.ToLower(RemoveLastDot(msg.Question[0].Name))
+
+	for _, rule := range p.Rules {
+		if rule.Net != nil {
+			for _, rr := range msg.Answer {
+				var ip net.IP
+
+				switch v := rr.(type) {
+				case *dns.A:
+					ip = v.A
+				case *dns.AAAA:
+					ip = v.AAAA
+				}
+				if ip != nil && rule.Net.Contains(ip) {
+					return rule
+				}
+			}
+			continue
+		}
+
+		if sub := strings.TrimPrefix(rule.Name, "*."); sub != rule.Name {
+			if strings.HasSuffix(name, "."+sub) {
+				return rule
+			}
+		} else if name == rule.Name {
+			return rule
+		}
+	}
+	return nil
+}
+
+func (r *Resolvers) applyPolicy(req *request, msg *dns.Msg) {
+	if rule := r.policy.Load().Apply(msg); rule != nil {
+		req.Meta.update(func(meta *Response) { meta.Policy = rule })
+	}
+}
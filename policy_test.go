@@ -0,0 +1,112 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const testPolicy = `# local policy
+name:www.caffix.net PASSTHRU
+name:*.caffix.net NXDOMAIN
+name:intranet.example A 10.0.0.1
+name:alias.example CNAME www.caffix.net
+
+ip:192.168.1.2 NODATA
+`
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse the policy: %v", err)
+	}
+	if len(p.Rules) != 5 || p.Rules[4].Line != 7 || p.Rules[4].String() != "ip:192.168.1.2/32 NODATA" {
+		t.Errorf("the policy rules were not correctly parsed")
+	}
+
+	for _, bad := range []string{
+		"name:caffix.net",
+		"host:caffix.net NXDOMAIN",
+		"name:bad!name NXDOMAIN",
+		"ip:300.1.1.1 NXDOMAIN",
+		"name:caffix.net NXDOMAIN extra",
+		"name:caffix.net BOGUS data",
+		"name:caffix.net A not-an-address",
+	} {
+		if _, err := ParsePolicy(strings.NewReader(bad)); err == nil {
+			t.Errorf("the invalid rule %q was accepted", bad)
+		}
+	}
+}
+
+func TestPolicyApply(t *testing.T) {
+	p, _ := ParsePolicy(strings.NewReader(testPolicy))
+
+	cases := []struct {
+		name   string
+		qtype  uint16
+		rule   int
+		rcode  int
+		answer int
+	}{
+		{"www.caffix.net", dns.TypeA, 0, dns.RcodeSuccess, 1},
+		{"mail.caffix.net", dns.TypeA, 1, dns.RcodeNameError, 0},
+		{"intranet.example", dns.TypeA, 2, dns.RcodeSuccess, 1},
+		{"intranet.example", dns.TypeAAAA, 2, dns.RcodeSuccess, 0},
+		{"alias.example", dns.TypeAAAA, 3, dns.RcodeSuccess, 1},
+		{"caffix.net", dns.TypeA, -1, dns.RcodeSuccess, 1},
+	}
+	for _, c := range cases {
+		msg := ttlReply(QueryMsg(c.name, c.qtype), 60)
+
+		rule := p.Apply(msg)
+		if (c.rule == -1 && rule != nil) || (c.rule >= 0 && rule != p.Rules[c.rule]) {
+			t.Errorf("%s %s triggered the wrong rule: %v", c.name, dns.TypeToString[c.qtype], rule)
+		}
+		if msg.Rcode != c.rcode || len(msg.Answer) != c.answer {
+			t.Errorf("%s %s was not correctly modified: %v", c.name, dns.TypeToString[c.qtype], msg)
+		}
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	path := filepath.Join(t.TempDir(), "policy.txt")
+	if err := os.WriteFile(path, []byte(testPolicy), 0600); err != nil {
+		t.Fatalf("failed to write the policy file: %v", err)
+	}
+	p, err := LoadPolicyFile(path)
+	if err != nil {
+		t.Fatalf("failed to load the policy file: %v", err)
+	}
+
+	r.SetPolicy(p)
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("owasp.org", dns.TypeA))
+	if err != nil || len(resp.Msg.Answer) != 0 || resp.Policy != p.Rules[4] {
+		t.Errorf("the IP trigger did not apply to the response: %v", err)
+	}
+
+	r.SetPolicy(nil)
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("owasp.org", dns.TypeA)); err != nil || len(resp.Answer) != 2 {
+		t.Errorf("the policy was not removed: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxPrefetchLookups is the number of Prefetch lookups waiting on the pool at once.
+const maxPrefetchLookups = 100
+
+// Prefetch warms the cache by resolving each name for each of the record types, skipping the
+// entries already cached. The queries are sent with WithLowPriority, so the interactive lookups
+// move ahead of them. Prefetch returns the number of responses added to the cache once the
+// lookups complete or the context expires.
+func (c *Cache) Prefetch(ctx context.Context, names []string, qtypes []uint16) int {
+	ctx = WithLowPriority(ctx)
+	sem := make(chan struct{}, maxPrefetchLookups)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var count int
+	seen := make(map[monitorKey]struct{})
+loop:
+	for _, name := range names {
+		for _, qtype := range qtypes {
+			key := monitorKey{Name: strings.ToLower(RemoveLastDot(name)), Qtype: qtype}
+			if _, found := seen[key]; found || c.fresh(key) {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			select {
+			case <-ctx.Done():
+				break loop
+			case <-c.done:
+				break loop
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(key monitorKey) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				if _, cached, err := c.lookup(ctx, key.Name, key.Qtype); err == nil && !cached && c.fresh(key) {
+					lock.Lock()
+					count++
+					lock.Unlock()
+				}
+			}(key)
+		}
+	}
+	wg.Wait()
+	return count
+}
+
+// fresh returns true when the cache holds an entry for the key that has not expired.
+func (c *Cache) fresh(key monitorKey) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	e, found := c.entries[key]
+	return found && time.Now().Before(e.Expires)
+}
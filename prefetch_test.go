@@ -0,0 +1,60 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestPrefetch(t *testing.T) {
+	var queries atomic.Int32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries.Add(1)
+			_ = w.WriteMsg(ttlReply(req, 300))
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	c := NewCache(r)
+	defer c.Stop()
+
+	names := []string{"www.caffix.net", "mail.caffix.net", "WWW.caffix.net."}
+	qtypes := []uint16{dns.TypeA, dns.TypeAAAA}
+	if n := c.Prefetch(context.Background(), names, qtypes); n != 4 {
+		t.Errorf("the prefetch cached %d responses instead of 4", n)
+	}
+	if c.Len() != 4 {
+		t.Errorf("the cache contained %d entries after the prefetch", c.Len())
+	}
+
+	sent := queries.Load()
+	if n := c.Prefetch(context.Background(), names, qtypes); n != 0 {
+		t.Errorf("the prefetch cached %d responses that were already cached", n)
+	}
+	if _, err := c.Lookup(context.Background(), "mail.caffix.net", dns.TypeAAAA); err != nil {
+		t.Errorf("the lookup failed: %v", err)
+	}
+	if queries.Load() != sent {
+		t.Errorf("the queries were sent for the prefetched names")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n := c.Prefetch(ctx, []string{"ns.caffix.net"}, qtypes); n != 0 {
+		t.Errorf("the prefetch continued after the context expired")
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"container/heap"
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/caffix/queue"
+)
+
+const boostCheckInterval = 250 * time.Millisecond
+
+type lowPriorityCtxKey struct{}
+
+// deadlineRequest is queued in place of a request with a context deadline, so the request can
+// be appended to the queue again once escalated. Only the first copy dequeued takes the request.
+type deadlineRequest struct {
+	req      *request
+	deadline time.Time
+	boosted  atomic.Bool
+	taken    atomic.Bool
+	// index is the position in the deadline heap, which is protected by the boost lock
+	index int
+}
+
+// take returns the request for the first copy dequeued and removes it from the deadline heap.
+func (r *Resolvers) take(d *deadlineRequest) (*request, bool) {
+	if !d.taken.CompareAndSwap(false, true) {
+		return nil, false
+	}
+
+	r.boostLock.Lock()
+	if d.index >= 0 {
+		heap.Remove(&r.deadlines, d.index)
+	}
+	r.boostLock.Unlock()
+
+	req := d.req
+	d.req = nil
+	if d.boosted.Load() {
+		req.Priority = queue.PriorityHigh
+	}
+	return req, true
+}
+
+// deadlineHeap orders the deadline requests by the deadline, soonest first.
+type deadlineHeap []*deadlineRequest
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+func (h deadlineHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *deadlineHeap) Push(x interface{}) {
+	d := x.(*deadlineRequest)
+	d.index = len(*h)
+	*h = append(*h, d)
+}
+
+func (h *deadlineHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	d := old[n-1]
+	old[n-1] = nil
+	d.index = -1
+	*h = old[:n-1]
+	return d
+}
+
+// WithLowPriority returns a context that queues the queries behind the queries of normal
+// priority, so they are only sent using the capacity left idle. Boosting the names still
+// escalates the priority of the queries.
+func WithLowPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, lowPriorityCtxKey{}, true)
+}
+
+// Boost escalates the priority of queries for the provided names until the context expires,
+// allowing interactive lookups to move ahead of bulk queries waiting on the rate limiters.
+func (r *Resolvers) Boost(ctx context.Context, names ...string) {
+	var keys []string
+	for _, name := range names {
+		keys = append(keys, strings.ToLower(RemoveLastDot(name)))
+	}
+
+	r.boostLock.Lock()
+	for _, k := range keys {
+		r.boosts[k]++
+	}
+	r.boostLock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.done:
+		}
+
+		r.boostLock.Lock()
+		defer r.boostLock.Unlock()
+
+		for _, k := range keys {
+			if r.boosts[k]--; r.boosts[k] <= 0 {
+				delete(r.boosts, k)
+			}
+		}
+	}()
+}
+
+// SetDeadlineBoost escalates the priority of queries with a context deadline within the provided
+// duration. A duration of zero disables the automatic escalation.
+func (r *Resolvers) SetDeadlineBoost(d time.Duration) {
+	r.boostWindow.Store(int64(d))
+}
+
+func (r *Resolvers) queryPriority(ctx context.Context, name string) int {
+	if window := time.Duration(r.boostWindow.Load()); window > 0 {
+		if deadline, ok := ctx.Deadline(); ok && deadline.Sub(r.clock.Now()) <= window {
+			return queue.PriorityHigh
+		}
+	}
+
+	r.boostLock.Lock()
+	defer r.boostLock.Unlock()
+
+	if _, found := r.boosts[strings.ToLower(RemoveLastDot(name))]; found {
+		return queue.PriorityHigh
+	}
+	if low, ok := ctx.Value(lowPriorityCtxKey{}).(bool); ok && low {
+		return queue.PriorityLow
+	}
+	return queue.PriorityNormal
+}
+
+// enqueue appends the request to the queue. Leased requests have a separate queue served first,
+// since the lease already limited the rate. Requests with a context deadline are tracked, so
+// they can be escalated while waiting without the queue being rebuilt.
+func (r *Resolvers) enqueue(req *request) {
+	if req.Leased {
+		r.leases.AppendPriority(req, req.Priority)
+		return
+	}
+
+	deadline, ok := req.context().Deadline()
+	if !ok || req.Priority >= queue.PriorityHigh || r.boostWindow.Load() <= 0 {
+		r.queue.AppendPriority(req, req.Priority)
+		return
+	}
+
+	d := &deadlineRequest{req: req, deadline: deadline}
+	r.boostLock.Lock()
+	heap.Push(&r.deadlines, d)
+	r.boostLock.Unlock()
+	r.queue.AppendPriority(d, req.Priority)
+}
+
+// dequeued returns the request for an element removed from the queue, or false when the
+// request has already been taken using another copy of the element.
+func (r *Resolvers) dequeued(element interface{}) (*request, bool) {
+	switch e := element.(type) {
+	case *request:
+		return e, e != nil
+	case *deadlineRequest:
+		return r.take(e)
+	}
+	return nil, false
+}
+
+func (r *Resolvers) boostChecks(stop chan struct{}) {
+	t := time.NewTicker(boostCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-stop:
+			return
+		case <-t.C:
+			r.reprioritize()
+		}
+	}
+}
+
+// reprioritize escalates the queued requests that have come close to their deadline while
+// waiting on the rate limiter. Only the requests reaching the window are visited, and each is
+// appended again with the high priority, leaving the copy at the original priority to be skipped.
+func (r *Resolvers) reprioritize() {
+	window := time.Duration(r.boostWindow.Load())
+
+	r.boostLock.Lock()
+	if window <= 0 {
+		// the requests remain queued at the original priority
+		for _, d := range r.deadlines {
+			d.index = -1
+		}
+		r.deadlines = nil
+		r.boostLock.Unlock()
+		return
+	}
+
+	var due []*deadlineRequest
+	cutoff := r.clock.Now().Add(window)
+	for len(r.deadlines) > 0 && !r.deadlines[0].deadline.After(cutoff) {
+		due = append(due, heap.Pop(&r.deadlines).(*deadlineRequest))
+	}
+	r.boostLock.Unlock()
+
+	for _, d := range due {
+		if !d.taken.Load() {
+			d.boosted.Store(true)
+			r.queue.AppendPriority(d, queue.PriorityHigh)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/caffix/queue"
+)
+
+func TestBoost(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	name := "www.caffix.net"
+	if p := r.queryPriority(context.Background(), name); p != queue.PriorityNormal {
+		t.Errorf("the query priority was %d before the name was boosted", p)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.Boost(ctx, "WWW.caffix.net.")
+	if p := r.queryPriority(context.Background(), name); p != queue.PriorityHigh {
+		t.Errorf("the query priority was %d after the name was boosted", p)
+	}
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if p := r.queryPriority(context.Background(), name); p != queue.PriorityNormal {
+		t.Errorf("the query priority was %d after the boost context expired", p)
+	}
+}
+
+func TestDeadlineBoost(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	name := "www.caffix.net"
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if p := r.queryPriority(ctx, name); p != queue.PriorityNormal {
+		t.Errorf("the query priority was %d before the deadline boost was set", p)
+	}
+
+	r.SetDeadlineBoost(2 * time.Second)
+	if p := r.queryPriority(ctx, name); p != queue.PriorityHigh {
+		t.Errorf("the query priority was %d for a context near the deadline", p)
+	}
+	if p := r.queryPriority(context.Background(), name); p != queue.PriorityNormal {
+		t.Errorf("the query priority was %d for a context without a deadline", p)
+	}
+}
+
+func TestLowPriority(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	name := "www.caffix.net"
+	ctx := WithLowPriority(context.Background())
+	if p := r.queryPriority(ctx, name); p != queue.PriorityLow {
+		t.Errorf("the query priority was %d for the low priority context", p)
+	}
+
+	bctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r.Boost(bctx, name)
+	if p := r.queryPriority(ctx, name); p != queue.PriorityHigh {
+		t.Errorf("the query priority was %d after the name was boosted", p)
+	}
+}
+
+func TestReprioritize(t *testing.T) {
+	r := &Resolvers{
+		clock:  realClock{},
+		queue:  queue.NewQueue(),
+		boosts: make(map[string]int),
+	}
+	r.SetDeadlineBoost(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	later, cancelLater := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelLater()
+
+	near := &request{Ctx: ctx, Msg: QueryMsg("near.caffix.net", 1)}
+	other := &request{Msg: QueryMsg("other.caffix.net", 1)}
+	far := &request{Ctx: later, Msg: QueryMsg("far.caffix.net", 1)}
+	for _, req := range []*request{near, other, far} {
+		req.Priority = r.queryPriority(req.context(), req.Msg.Question[0].Name)
+		r.enqueue(req)
+	}
+	if near.Priority != queue.PriorityNormal {
+		t.Fatalf("the request was escalated before approaching the deadline")
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	r.reprioritize()
+	if len(r.deadlines) != 1 {
+		t.Errorf("the requests far from the deadline were not left on the heap")
+	}
+
+	var order []*request
+	for e, found := r.queue.Next(); found; e, found = r.queue.Next() {
+		if req, ok := r.dequeued(e); ok {
+			order = append(order, req)
+		}
+	}
+	if len(order) != 3 {
+		t.Fatalf("%d requests were dequeued instead of three", len(order))
+	}
+	if order[0] != near || near.Priority != queue.PriorityHigh {
+		t.Errorf("the request near the deadline was not escalated while waiting")
+	}
+	if order[1] != other || order[2] != far {
+		t.Errorf("the requests were not dequeued in the original order")
+	}
+
+	r.SetDeadlineBoost(0)
+	r.reprioritize()
+	if len(r.deadlines) != 0 {
+		t.Errorf("the deadline requests were kept after the boost was disabled")
+	}
+}
+
+func TestDeadlineHeapBounded(t *testing.T) {
+	r := &Resolvers{
+		clock:  realClock{},
+		queue:  queue.NewQueue(),
+		boosts: make(map[string]int),
+	}
+	r.SetDeadlineBoost(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	// the answered requests must not remain on the heap until the deadline approaches
+	for i := 0; i < 1000; i++ {
+		r.enqueue(&request{Ctx: ctx, Msg: QueryMsg("caffix.net", 1), Priority: queue.PriorityNormal})
+
+		e, _ := r.queue.Next()
+		if _, ok := r.dequeued(e); !ok {
+			t.Fatalf("the request was not taken from the queue")
+		}
+	}
+	if n := len(r.deadlines); n != 0 {
+		t.Errorf("the heap retained %d requests that were already taken", n)
+	}
+}
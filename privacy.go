@@ -0,0 +1,183 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	dotPort          = "853"
+	paddingBlockSize = 128
+)
+
+// UsePrivacyProfile configures the pool for privacy-sensitive use. All queries are sent using
+// DNS over TLS, padded according to RFC 8467, and stripped of the EDNS client subnet option.
+// Iterative lookups performed by the pool, such as Trace, also use QNAME minimization.
+func (r *Resolvers) UsePrivacyProfile() {
+	r.privacy.Store(true)
+	r.syncSubPools()
+}
+
+// RemoveClientSubnet strips any EDNS0_SUBNET options from the provided message.
+func RemoveClientSubnet(msg *dns.Msg) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return
+	}
+
+	var options []dns.EDNS0
+	for _, o := range opt.Option {
+		if _, ok := o.(*dns.EDNS0_SUBNET); !ok {
+			options = append(options, o)
+		}
+	}
+	opt.Option = options
+}
+
+// PadMsg adds an EDNS0_PADDING option that brings the message length to a multiple of the
+// block size recommended by RFC 8467 for queries.
+func PadMsg(msg *dns.Msg) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		opt = msg.IsEdns0()
+	}
+
+	var options []dns.EDNS0
+	for _, o := range opt.Option {
+		if _, ok := o.(*dns.EDNS0_PADDING); !ok {
+			options = append(options, o)
+		}
+	}
+
+	padding := &dns.EDNS0_PADDING{}
+	opt.Option = append(options, padding)
+	if rem := msg.Len() % paddingBlockSize; rem != 0 {
+		padding.Padding = make([]byte, paddingBlockSize-rem)
+	}
+}
+
+func (r *resolver) tlsExchange(req *request, msg *dns.Msg) {
+	if r.xchgs.addWithID(req, msg.Id) == nil {
+		if err := r.writeTLS(msg); err != nil {
+			_ = r.xchgs.remove(msg.Id, msg.Question[0].Name)
+			req.errNoResponse()
+			req.release()
+		}
+	}
+}
+
+// writeTLS sends the message on the DNS over TLS connection for this resolver, which is
+// established when not already available. Responses are matched by processSingleResp.
+func (r *resolver) writeTLS(msg *dns.Msg) error {
+	r.dotLock.Lock()
+	defer r.dotLock.Unlock()
+
+	if err := r.establishTLS(); err != nil {
+		return err
+	}
+
+	_ = r.dot.SetWriteDeadline(time.Now().Add(r.xchgs.getTimeout()))
+	if err := r.dot.WriteMsg(msg); err != nil {
+		_ = r.dot.Close()
+		r.dot = nil
+		return err
+	}
+	return nil
+}
+
+func (r *resolver) connectTLS() error {
+	r.dotLock.Lock()
+	defer r.dotLock.Unlock()
+
+	return r.establishTLS()
+}
+
+// establishTLS dials the DNS over TLS connection when not already available. The caller must hold the dotLock.
+func (r *resolver) establishTLS() error {
+	if r.dot != nil {
+		return nil
+	}
+
+	conn, err := r.dialTLS()
+	if err != nil {
+		return err
+	}
+
+	r.dot = conn
+	go runLabeled(func() { r.tlsResponses(conn) }, labelSubsystem, "reader", labelResolver, r.address.String())
+	return nil
+}
+
+func (r *resolver) tlsClientConfig() *tls.Config {
+	cfg := new(tls.Config)
+	if r.pool.tlsConfig != nil {
+		cfg = r.pool.tlsConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = r.address.IP.String()
+	}
+	return cfg
+}
+
+func (r *resolver) dialTLS() (*dns.Conn, error) {
+	cfg := r.tlsClientConfig()
+	if r.encrypted.Load() {
+		cfg = r.ddrClientConfig()
+	}
+
+	client := dns.Client{
+		Net:       "tcp-tls",
+		Timeout:   r.xchgs.getTimeout(),
+		TLSConfig: cfg,
+		Dialer:    r.pool.conns.dialer(r.xchgs.getTimeout()),
+	}
+	conn, err := client.Dial(r.getDoTAddr())
+	if err == nil {
+		_ = conn.SetReadDeadline(time.Time{})
+	}
+	return conn, err
+}
+
+func (r *resolver) tlsResponses(conn *dns.Conn) {
+	defer r.closeTLS(conn)
+
+	for {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+
+		b, err := conn.ReadMsgHeader(nil)
+		if err != nil {
+			return
+		}
+		// the designated resolver discovered via DDR can be reached at another address, so the
+		// responses are attributed to the resolver instead of the remote address of the connection
+		if response := r.pool.conns.newResp(b, r.address); response != nil {
+			r.pool.resps.Append(response)
+		}
+	}
+}
+
+func (r *resolver) closeTLS(conn *dns.Conn) {
+	r.dotLock.Lock()
+	defer r.dotLock.Unlock()
+
+	if conn == nil {
+		conn = r.dot
+	}
+	if conn != nil {
+		_ = conn.Close()
+	}
+	if r.dot == conn {
+		r.dot = nil
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRemoveClientSubnet(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	RemoveClientSubnet(msg)
+
+	for _, o := range msg.IsEdns0().Option {
+		if _, ok := o.(*dns.EDNS0_SUBNET); ok {
+			t.Errorf("the EDNS0_SUBNET option was not removed from the message")
+		}
+	}
+	// It should be safe to call on a message without the OPT record
+	m := new(dns.Msg)
+	m.SetQuestion("caffix.net.", dns.TypeA)
+	RemoveClientSubnet(m)
+}
+
+func TestPadMsg(t *testing.T) {
+	for _, name := range []string{"caffix.net", "www.owasp.org", "a.very.long.subdomain.name.within.caffix.net"} {
+		msg := QueryMsg(name, dns.TypeA)
+		RemoveClientSubnet(msg)
+		PadMsg(msg)
+
+		if l := msg.Len(); l%paddingBlockSize != 0 {
+			t.Errorf("the padded message for %s had a length of %d", name, l)
+		}
+		// Padding the message again should not change the length
+		l := msg.Len()
+		if PadMsg(msg); msg.Len() != l {
+			t.Errorf("padding the message for %s twice changed the length", name)
+		}
+	}
+}
+
+func TestUsePrivacyProfile(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if r.privacy.Load() {
+		t.Errorf("the privacy profile was enabled by default")
+	}
+	if r.UsePrivacyProfile(); !r.privacy.Load() {
+		t.Errorf("failed to enable the privacy profile")
+	}
+}
+
+func TestPrivacyProfileTLS(t *testing.T) {
+	cert, roots, err := localCertificate()
+	if err != nil {
+		t.Fatalf("failed to create the test certificate: %v", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("unable to run the test listener: %v", err)
+	}
+
+	queries := make(chan *dns.Msg, 10)
+	s, addrstr, _, err := RunLocalServer(nil, l, func(s *dns.Server) {
+		s.Net = "tcp-tls"
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries <- req
+			typeAHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	r.tlsConfig = &tls.Config{RootCAs: roots}
+	r.UsePrivacyProfile()
+	_ = r.AddResolvers(10, "127.0.0.1")
+	defer r.Stop()
+
+	res := r.pool.LookupResolver("127.0.0.1")
+	res.dotAddr = addrstr
+
+	var conn *dns.Conn
+	for i := 0; i < 2; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		resp, err := r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA))
+		cancel()
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			t.Fatalf("the query over DNS over TLS failed")
+		}
+		if ans := ExtractAnswers(resp); len(ans) == 0 || ans[0].Data != "192.168.1.1" {
+			t.Errorf("the query over DNS over TLS did not return the expected answer")
+		}
+
+		res.dotLock.Lock()
+		if i > 0 && res.dot != conn {
+			t.Errorf("the DNS over TLS connection was not reused")
+		}
+		conn = res.dot
+		res.dotLock.Unlock()
+	}
+
+	req := <-queries
+	if l := req.Len(); l%paddingBlockSize != 0 {
+		t.Errorf("the query arrived with a length of %d", l)
+	}
+	opt := req.IsEdns0()
+	if opt == nil {
+		t.Fatalf("the query arrived without the OPT record")
+	}
+
+	var padded bool
+	for _, o := range opt.Option {
+		switch o.(type) {
+		case *dns.EDNS0_SUBNET:
+			t.Errorf("the query arrived with the EDNS0_SUBNET option")
+		case *dns.EDNS0_PADDING:
+			padded = true
+		}
+	}
+	if !padded {
+		t.Errorf("the query arrived without the EDNS0_PADDING option")
+	}
+	if samples, _ := res.lowRTT(); samples == 0 {
+		t.Errorf("the DNS over TLS responses were not included in the RTT statistics")
+	}
+}
+
+func localCertificate() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, roots, nil
+}
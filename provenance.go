@@ -0,0 +1,127 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// The stages of the verification recorded in the provenance sources.
+const (
+	StageBulk       = "bulk"
+	StageTrusted    = "trusted"
+	StageComparison = "comparison"
+)
+
+// ProvenanceSource describes the response provided by a single resolver for a verified result.
+type ProvenanceSource struct {
+	Resolver string
+	Stage    string
+	Rcode    int
+	Answers  []string
+	CacheHit bool
+	// Agrees is true when the answers match the verified result
+	Agrees bool
+	Time   time.Time
+}
+
+// Provenance records which resolvers contributed to a verified result and how well they
+// agreed, which supports the auditing of the results.
+type Provenance struct {
+	Name    string
+	Qtype   uint16
+	Sources []*ProvenanceSource
+	// Agreement is the fraction of the sources that agree with the result
+	Agreement float64
+	Verified  bool
+	Time      time.Time
+}
+
+func newProvenance(name string, qtype uint16) *Provenance {
+	return &Provenance{
+		Name:  strings.ToLower(RemoveLastDot(name)),
+		Qtype: qtype,
+		Time:  time.Now(),
+	}
+}
+
+func (p *Provenance) add(stage string, resp *Response) {
+	src := &ProvenanceSource{
+		Resolver: resp.Resolver,
+		Stage:    stage,
+		CacheHit: resp.CacheHit,
+		Time:     time.Now(),
+	}
+	if resp.Msg != nil {
+		src.Rcode = resp.Msg.Rcode
+		src.Answers = CanonicalAnswers(resp.Msg)
+	}
+	p.Sources = append(p.Sources, src)
+}
+
+// finalize compares the sources with the result and computes the agreement.
+func (p *Provenance) finalize(result *dns.Msg, verified bool) {
+	p.Verified = verified
+	answers := CanonicalAnswers(result)
+
+	var agree int
+	for _, src := range p.Sources {
+		if src.Agrees = src.Rcode == result.Rcode && equalStrings(src.Answers, answers); src.Agrees {
+			agree++
+		}
+	}
+	if len(p.Sources) > 0 {
+		p.Agreement = float64(agree) / float64(len(p.Sources))
+	}
+}
+
+// Provenance returns the provenance of the consensus, where the sources agree when they
+// successfully responded without records outside of the consensus.
+func (c *ComparisonReport) Provenance() *Provenance {
+	p := newProvenance(c.Name, c.Qtype)
+
+	var agree int
+	for _, res := range c.Results {
+		src := &ProvenanceSource{
+			Resolver: res.Resolver,
+			Stage:    StageComparison,
+			Rcode:    res.Rcode,
+			Time:     res.Time,
+		}
+		for _, a := range res.Answers {
+			src.Answers = append(src.Answers, strings.Trim(a.Data, "."))
+		}
+		sort.Strings(src.Answers)
+
+		if _, found := c.Differences[res.Resolver]; !found && res.Rcode == dns.RcodeSuccess {
+			src.Agrees = true
+			agree++
+		}
+		p.Sources = append(p.Sources, src)
+	}
+
+	if len(p.Sources) > 0 {
+		p.Agreement = float64(agree) / float64(len(p.Sources))
+	}
+	p.Verified = agree > 0 && agree == len(p.Sources)
+	return p
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,101 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRevalidatorProvenance(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			_ = w.WriteMsg(ttlReply(req, 60))
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr1)
+	defer r.Stop()
+
+	trusted, err := r.AddSubPool("trusted", 10, addr2)
+	if err != nil {
+		t.Fatalf("failed to create the sub-pool: %v", err)
+	}
+
+	v := NewRevalidator(r, trusted)
+	defer v.Stop()
+
+	start := time.Now()
+	_, prov, err := v.LookupWithProvenance(context.Background(), "www.caffix.net", dns.TypeA)
+	if err != nil {
+		t.Fatalf("the lookup failed: %v", err)
+	}
+	if !prov.Verified || prov.Name != "www.caffix.net" || prov.Qtype != dns.TypeA {
+		t.Errorf("the provenance was not correctly populated: %+v", prov)
+	}
+	if len(prov.Sources) != 2 || prov.Agreement != 0.5 {
+		t.Fatalf("the provenance did not include both sources: %+v", prov)
+	}
+	if prov.Sources[0].Agrees || !prov.Sources[1].Agrees {
+		t.Errorf("the agreement of the sources was not correctly computed")
+	}
+	if prov.Sources[0].Stage != StageBulk || prov.Sources[1].Stage != StageTrusted {
+		t.Errorf("the provenance stages were not correct")
+	}
+	if prov.Sources[1].Resolver != addr2 || prov.Sources[1].CacheHit {
+		t.Errorf("the trusted source was not correctly recorded: %+v", prov.Sources[1])
+	}
+	if prov.Sources[0].Time.Before(start) || len(prov.Sources[0].Answers) != 2 {
+		t.Errorf("the bulk source was not correctly recorded: %+v", prov.Sources[0])
+	}
+
+	_, prov, err = v.LookupWithProvenance(context.Background(), "www.caffix.net", dns.TypeA)
+	if err != nil {
+		t.Fatalf("the second lookup failed: %v", err)
+	}
+	if len(prov.Sources) != 2 || !prov.Sources[1].CacheHit {
+		t.Errorf("the cached trusted answer was not recorded in the provenance")
+	}
+}
+
+func TestComparisonProvenance(t *testing.T) {
+	report := &ComparisonReport{
+		Name:  "caffix.net",
+		Qtype: dns.TypeA,
+		Results: []*ResolverAnswer{
+			{Resolver: "127.0.0.1", Rcode: dns.RcodeSuccess, Answers: []*ExtractedAnswer{{Data: "192.168.1.1"}}},
+			{Resolver: "127.0.0.2", Rcode: dns.RcodeSuccess, Answers: []*ExtractedAnswer{{Data: "192.168.1.2"}}},
+			{Resolver: "127.0.0.3", Rcode: dns.RcodeSuccess, Answers: []*ExtractedAnswer{{Data: "192.168.1.1"}}},
+			{Resolver: "127.0.0.4", Rcode: RcodeNoResponse},
+		},
+		Differences: map[string][]string{"127.0.0.2": {"192.168.1.2"}},
+	}
+
+	prov := report.Provenance()
+	if len(prov.Sources) != 4 || prov.Agreement != 0.5 || prov.Verified {
+		t.Fatalf("the comparison provenance was not correctly computed: %+v", prov)
+	}
+	for i, agrees := range []bool{true, false, true, false} {
+		if src := prov.Sources[i]; src.Agrees != agrees || src.Stage != StageComparison {
+			t.Errorf("the source %s was not correctly recorded: %+v", src.Resolver, src)
+		}
+	}
+}
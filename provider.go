@@ -0,0 +1,118 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ProviderPattern maps the NS host names or SOA RNAME containing the pattern to a provider.
+type ProviderPattern struct {
+	// Pattern is matched as a substring of the lowercase names without the trailing dot
+	Pattern  string
+	Provider string
+}
+
+// DefaultProviderPatterns contains the patterns of common DNS hosting providers and registrars.
+var DefaultProviderPatterns = []ProviderPattern{
+	{Pattern: "awsdns", Provider: "Amazon Route 53"},
+	{Pattern: "cloudflare.com", Provider: "Cloudflare"},
+	{Pattern: "azure-dns.", Provider: "Azure DNS"},
+	{Pattern: "azuredns-hostmaster.microsoft.com", Provider: "Azure DNS"},
+	{Pattern: "googledomains.com", Provider: "Google Domains"},
+	{Pattern: "cloud-dns-hostmaster.google.com", Provider: "Google Cloud DNS"},
+	{Pattern: "domaincontrol.com", Provider: "GoDaddy"},
+	{Pattern: "dns.jomax.net", Provider: "GoDaddy"},
+	{Pattern: "registrar-servers.com", Provider: "Namecheap"},
+	{Pattern: "nsone.net", Provider: "NS1"},
+	{Pattern: "dynect.net", Provider: "Dyn"},
+	{Pattern: "ultradns.", Provider: "UltraDNS"},
+	{Pattern: "akam.net", Provider: "Akamai"},
+	{Pattern: "digitalocean.com", Provider: "DigitalOcean"},
+	{Pattern: "linode.com", Provider: "Linode"},
+	{Pattern: "hetzner.com", Provider: "Hetzner"},
+	{Pattern: "ovh.net", Provider: "OVH"},
+	{Pattern: "gandi.net", Provider: "Gandi"},
+	{Pattern: "name-services.com", Provider: "Enom"},
+	{Pattern: "wixdns.net", Provider: "Wix"},
+}
+
+// ProviderInference describes the provider inferred for a domain from the DNS records.
+type ProviderInference struct {
+	Domain string
+	// Provider is empty when none of the patterns matched
+	Provider    string
+	Nameservers []string
+	RName       string
+	// Evidence is the name that matched the pattern
+	Evidence string
+}
+
+// InferProvider infers the hosting provider or registrar of the domain from the NS records and
+// the RNAME of the SOA record, which requires no access to WHOIS. The name servers are matched
+// before the RNAME, and nil patterns use DefaultProviderPatterns.
+func (r *Resolvers) InferProvider(ctx context.Context, domain string, patterns []ProviderPattern) (*ProviderInference, error) {
+	if patterns == nil {
+		patterns = DefaultProviderPatterns
+	}
+
+	domain = strings.ToLower(RemoveLastDot(domain))
+	result := &ProviderInference{Domain: domain}
+
+	var errs []error
+	if resp, err := r.QueryBlocking(ctx, QueryMsg(domain, dns.TypeNS)); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, a := range AnswersByType(ExtractAnswers(resp), dns.TypeNS) {
+			result.Nameservers = append(result.Nameservers, strings.ToLower(a.Data))
+		}
+	}
+	if resp, err := r.QueryBlocking(ctx, QueryMsg(domain, dns.TypeSOA)); err != nil {
+		errs = append(errs, err)
+	} else {
+		result.RName = soaRName(resp)
+	}
+	if len(result.Nameservers) == 0 && result.RName == "" {
+		if len(errs) > 0 {
+			return result, errors.Join(errs...)
+		}
+		return result, errors.New("the domain does not have NS or SOA records")
+	}
+
+	result.Provider, result.Evidence = MatchProvider(append(result.Nameservers, result.RName), patterns)
+	return result, nil
+}
+
+// MatchProvider returns the provider of the first pattern contained in the names, along with
+// the name that matched. The names are attempted in order against each of the patterns.
+func MatchProvider(names []string, patterns []ProviderPattern) (string, string) {
+	for _, name := range names {
+		n := strings.ToLower(RemoveLastDot(name))
+		if n == "" {
+			continue
+		}
+
+		for _, p := range patterns {
+			if pattern := strings.ToLower(p.Pattern); pattern != "" && strings.Contains(n, pattern) {
+				return p.Provider, n
+			}
+		}
+	}
+	return "", ""
+}
+
+// soaRName returns the RNAME of the SOA record from the answer or authority section.
+func soaRName(resp *dns.Msg) string {
+	for _, rr := range append(append([]dns.RR(nil), resp.Answer...), resp.Ns...) {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return strings.ToLower(RemoveLastDot(soa.Mbox))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestMatchProvider(t *testing.T) {
+	names := []string{"ns-1234.AWSDNS-12.org.", "dns.cloudflare.com"}
+
+	if p, evidence := MatchProvider(names, DefaultProviderPatterns); p != "Amazon Route 53" || evidence != "ns-1234.awsdns-12.org" {
+		t.Errorf("the provider was %s from %s instead of Amazon Route 53", p, evidence)
+	}
+	if p, _ := MatchProvider(names[1:], DefaultProviderPatterns); p != "Cloudflare" {
+		t.Errorf("the provider was %s instead of Cloudflare", p)
+	}
+
+	custom := []ProviderPattern{{Pattern: "caffix.net", Provider: "Caffix"}}
+	if p, _ := MatchProvider([]string{"ns1.caffix.net"}, custom); p != "Caffix" {
+		t.Errorf("the custom pattern was not matched")
+	}
+	if p, evidence := MatchProvider([]string{"ns1.example.com"}, DefaultProviderPatterns); p != "" || evidence != "" {
+		t.Errorf("an unknown name matched the provider %s", p)
+	}
+}
+
+func TestInferProvider(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+
+			q := req.Question[0]
+			hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 300}
+			switch q.Qtype {
+			case dns.TypeNS:
+				m.Answer = []dns.RR{&dns.NS{Hdr: hdr, Ns: "ns1.example.net."}}
+			case dns.TypeSOA:
+				m.Answer = []dns.RR{&dns.SOA{Hdr: hdr, Ns: "ns1.example.net.", Mbox: "dns.jomax.net."}}
+			}
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	result, err := r.InferProvider(context.Background(), "Caffix.net.", nil)
+	if err != nil {
+		t.Fatalf("the inference failed: %v", err)
+	}
+	if result.Domain != "caffix.net" || len(result.Nameservers) != 1 || result.RName != "dns.jomax.net" {
+		t.Errorf("the records were not returned: %+v", result)
+	}
+	if result.Provider != "GoDaddy" || result.Evidence != "dns.jomax.net" {
+		t.Errorf("the provider was %s instead of GoDaddy", result.Provider)
+	}
+}
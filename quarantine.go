@@ -0,0 +1,62 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sort"
+	"time"
+)
+
+// Quarantine excludes the resolver at the provided address from selection for the duration,
+// after which it is restored automatically. The queries already sent to the resolver are not
+// affected. Providing a duration of zero restores the resolver immediately.
+func (r *Resolvers) Quarantine(addr string, d time.Duration) error {
+	res, err := r.lookupAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	if d <= 0 {
+		res.quarantine.Store(0)
+		return nil
+	}
+	until := r.clock.Now().Add(d)
+	res.quarantine.Store(until.UnixNano())
+	r.log.Printf("Resolver %s quarantined for %s", res.address, d)
+	r.emitEvent(&Event{Type: EventResolverQuarantined, Resolver: res.address.String(), Until: until})
+	return nil
+}
+
+// Quarantined returns the addresses of the resolvers currently excluded from selection.
+func (r *Resolvers) Quarantined() []string {
+	var addrs []string
+
+	for _, res := range r.pool.AllResolvers() {
+		if res.quarantined() {
+			addrs = append(addrs, res.address.String())
+		}
+	}
+
+	sort.Strings(addrs)
+	return addrs
+}
+
+// quarantinedUntil returns the time the quarantine ends or the zero time when the
+// resolver is available for selection.
+func (r *resolver) quarantinedUntil() time.Time {
+	until := r.quarantine.Load()
+	if until == 0 {
+		return time.Time{}
+	}
+
+	if t := time.Unix(0, until); r.pool.clock.Now().Before(t) {
+		return t
+	}
+	return time.Time{}
+}
+
+func (r *resolver) quarantined() bool {
+	return !r.quarantinedUntil().IsZero()
+}
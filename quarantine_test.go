@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuarantine(t *testing.T) {
+	c := NewSimClock(time.Now())
+	r := NewResolversWithClock(c)
+	_ = r.AddResolvers(10, "192.168.1.1", "192.168.1.2")
+	defer r.Stop()
+
+	if err := r.Quarantine("bad address", time.Minute); err == nil {
+		t.Errorf("an invalid resolver address was accepted")
+	}
+	if err := r.Quarantine("192.168.1.3", time.Minute); err == nil {
+		t.Errorf("a resolver outside the pool was quarantined")
+	}
+	if err := r.Quarantine("192.168.1.1:53", time.Minute); err != nil {
+		t.Fatalf("failed to quarantine the resolver: %v", err)
+	}
+
+	selected := func(addr string) bool {
+		for i := 0; i < 100; i++ {
+			if res := r.pool.GetResolver(); res != nil && res.address.String() == addr {
+				return true
+			}
+		}
+		return false
+	}
+	if selected("192.168.1.1:53") {
+		t.Errorf("the quarantined resolver was selected")
+	}
+	if q := r.Quarantined(); len(q) != 1 || q[0] != "192.168.1.1:53" {
+		t.Errorf("the quarantined resolvers were not listed: %v", q)
+	}
+	for _, s := range r.Stats() {
+		if quarantined := !s.QuarantinedUntil.IsZero(); quarantined != (s.Address == "192.168.1.1:53") {
+			t.Errorf("the stats for %s had the quarantine end %v", s.Address, s.QuarantinedUntil)
+		}
+	}
+
+	_ = r.Quarantine("192.168.1.2", time.Minute)
+	if res := r.pool.GetResolver(); res != nil {
+		t.Errorf("a resolver was selected while all were quarantined")
+	}
+
+	c.Advance(time.Minute)
+	if len(r.Quarantined()) != 0 {
+		t.Errorf("the resolvers were not restored after the quarantine")
+	}
+	if !selected("192.168.1.1:53") {
+		t.Errorf("the restored resolver was not selected")
+	}
+
+	_ = r.Quarantine("192.168.1.2", time.Hour)
+	if err := r.Quarantine("192.168.1.2", 0); err != nil || len(r.Quarantined()) != 0 {
+		t.Errorf("the quarantine was not lifted")
+	}
+}
@@ -27,6 +27,7 @@ type rateTrack struct {
 	rate    ratelimit.Limiter
 	success int
 	timeout int
+	clock   Clock
 }
 
 type RateTracker struct {
@@ -36,26 +37,36 @@ type RateTracker struct {
 	serverToLimiter map[string]*rateTrack
 	catchLimiter    *rateTrack
 	isFixedResolver bool
+	clock           Clock
 }
 
 // NewRateTracker returns an active RateTracker that tracks and rate limits per name server.
 func NewRateTracker(isFixedResolver bool) *RateTracker {
+	return NewRateTrackerWithClock(isFixedResolver, realClock{})
+}
+
+// NewRateTrackerWithClock returns an active RateTracker that adjusts the rate limits
+// at intervals measured by the provided Clock.
+func NewRateTrackerWithClock(isFixedResolver bool, clock Clock) *RateTracker {
 	r := &RateTracker{
 		done:            make(chan struct{}, 1),
 		domainToServers: make(map[string][]string),
 		serverToLimiter: make(map[string]*rateTrack),
-		catchLimiter:    newRateTrack(),
+		catchLimiter:    newRateTrack(clock),
 		isFixedResolver: isFixedResolver,
+		clock:           clock,
 	}
 
-	go r.updateRateLimiters()
+	// the ticker is created before returning so that virtual time cannot advance past it
+	go r.updateRateLimiters(clock.NewTicker(rateUpdateInterval))
 	return r
 }
 
-func newRateTrack() *rateTrack {
+func newRateTrack(clock Clock) *rateTrack {
 	return &rateTrack{
-		qps:  maxQPSPerNameserver,
-		rate: ratelimit.New(maxQPSPerNameserver),
+		qps:   maxQPSPerNameserver,
+		rate:  ratelimit.New(maxQPSPerNameserver, ratelimit.WithClock(clock)),
+		clock: clock,
 	}
 }
 
@@ -106,17 +117,15 @@ func (r *RateTracker) Timeout(sub string) {
 	tracker.Unlock()
 }
 
-func (r *RateTracker) updateRateLimiters() {
-	t := time.NewTimer(rateUpdateInterval)
+func (r *RateTracker) updateRateLimiters(t Ticker) {
 	defer t.Stop()
 
 	for {
 		select {
 		case <-r.done:
 			return
-		case <-t.C:
+		case <-t.C():
 			r.updateAllRateLimiters()
-			t.Reset(rateUpdateInterval)
 		}
 	}
 }
@@ -149,7 +158,7 @@ func (rt *rateTrack) update() {
 		rt.qps = rt.qps + 10
 	}
 	// update the QPS rate limiter and reset counters
-	rt.rate = ratelimit.New(rt.qps)
+	rt.rate = ratelimit.New(rt.qps, ratelimit.WithClock(rt.clock))
 	rt.success = 0
 	rt.timeout = 0
 }
@@ -179,7 +188,7 @@ func (r *RateTracker) getDomainRateTracker(sub string) *rateTrack {
 		}
 	}
 	if tracker == nil {
-		tracker = newRateTrack()
+		tracker = newRateTrack(r.clock)
 	}
 	// make sure all the servers are using the same rate limiter
 	for _, name := range servers {
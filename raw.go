@@ -0,0 +1,50 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// SetRawResponses causes the pool to keep the response bytes received from the resolvers,
+// which are provided by QueryWithMetadata. Malformed responses are also returned with the
+// sections parsed before the error, instead of being discarded, when the question is intact.
+func (r *Resolvers) SetRawResponses(enable bool) {
+	if r.conns != nil {
+		r.conns.raw.Store(enable)
+	}
+}
+
+// newResp parses the message received from the address and returns nil when the message
+// cannot be matched to a request.
+func (r *connections) newResp(b []byte, addr net.Addr) *resp {
+	if len(b) < headerSize {
+		return nil
+	}
+
+	raw := r != nil && r.raw.Load()
+	m := new(dns.Msg)
+	err := m.Unpack(b)
+	if err != nil && (!raw || len(m.Question) == 0) {
+		return nil
+	}
+	if err == nil && !acceptableResponse(m) {
+		return nil
+	}
+
+	response := &resp{
+		Msg:  m,
+		Addr: addr,
+		Size: len(b),
+		Err:  err,
+	}
+	if raw {
+		response.Raw = make([]byte, len(b))
+		copy(response.Raw, b)
+	}
+	return response
+}
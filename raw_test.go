@@ -0,0 +1,138 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRawResponses(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(malformedHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	r.SetTimeout(250 * time.Millisecond)
+	defer r.Stop()
+
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("malformed.caffix.net", dns.TypeA))
+	if err != nil || resp.Msg.Rcode != RcodeNoResponse {
+		t.Errorf("the malformed response was returned without enabling the raw responses")
+	}
+
+	r.SetRawResponses(true)
+	resp, err = r.QueryWithMetadata(context.Background(), QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil || resp.ParseError != nil || len(resp.Raw) == 0 {
+		t.Fatalf("the raw bytes were not provided with the response: %v", err)
+	}
+	if b, _ := resp.Msg.Pack(); len(b) != len(resp.Raw) {
+		t.Errorf("the raw bytes do not match the response")
+	}
+
+	resp, err = r.QueryWithMetadata(context.Background(), QueryMsg("malformed.caffix.net", dns.TypeA))
+	if err != nil || resp.ParseError == nil || len(resp.Raw) == 0 {
+		t.Fatalf("the malformed response was not provided with the parse error: %v", err)
+	}
+	if q := resp.Msg.Question; len(q) != 1 || q[0].Name != "malformed.caffix.net." || len(resp.Msg.Answer) != 0 {
+		t.Errorf("the partially parsed message was not returned: %v", resp.Msg)
+	}
+}
+
+func TestNewResp(t *testing.T) {
+	conns := &connections{}
+	if response := conns.newResp([]byte{0, 1, 2}, nil); response != nil {
+		t.Errorf("the short message was accepted")
+	}
+
+	b, _ := ttlReply(QueryMsg("caffix.net", dns.TypeA), 60).Pack()
+	if response := conns.newResp(b[:len(b)-2], nil); response != nil {
+		t.Errorf("the malformed message was accepted without enabling the raw responses")
+	}
+
+	conns.raw.Store(true)
+	response := conns.newResp(b[:len(b)-2], nil)
+	if response == nil || response.Err == nil || len(response.Raw) != len(b)-2 {
+		t.Errorf("the malformed message was not accepted with the parse error")
+	}
+	response = conns.newResp(b, nil)
+	first := b[0]
+	b[0] = ^first
+	if response == nil || response.Err != nil || response.Raw[0] != first {
+		t.Errorf("the raw bytes were not copied from the buffer")
+	}
+}
+
+func malformedHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := ttlReply(req, 60)
+	if !strings.HasPrefix(req.Question[0].Name, "malformed") {
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	// remove the end of the answer record
+	if b, err := m.Pack(); err == nil {
+		_, _ = w.Write(b[:len(b)-2])
+	}
+}
+
+func FuzzProcessSingleResp(f *testing.F) {
+	req := QueryMsg("www.caffix.net", dns.TypeA)
+	for _, m := range []*dns.Msg{ttlReply(req, 300), new(dns.Msg).SetRcode(req, dns.RcodeServerFailure)} {
+		if b, err := m.Pack(); err == nil {
+			f.Add(b)
+		}
+	}
+	empty := new(dns.Msg).SetRcode(req, dns.RcodeRefused)
+	empty.Question = nil
+	if b, err := empty.Pack(); err == nil {
+		f.Add(b)
+	}
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, "192.0.2.1")
+	r.SetRawResponses(true)
+	f.Cleanup(r.Stop)
+
+	res := r.pool.LookupResolver("192.0.2.1")
+	addr := &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 53}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		response := r.conns.newResp(b, addr)
+		if response == nil {
+			return
+		}
+		// the TCP fallback requires a server to be listening
+		response.Msg.Truncated = false
+
+		ch := make(chan *dns.Msg, 1)
+		if len(response.Msg.Question) > 0 {
+			q := response.Msg.Question[0]
+			msg := QueryMsg(q.Name, q.Qtype)
+			msg.Id = response.Msg.Id
+
+			_ = res.xchgs.add(&request{
+				Res:       res,
+				Msg:       msg,
+				Result:    ch,
+				Timestamp: time.Now(),
+			})
+		}
+
+		r.processSingleResp(response)
+		for _, req := range res.xchgs.removeAll() {
+			req.release()
+		}
+	})
+}
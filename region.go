@@ -0,0 +1,188 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type regionCtxKey struct{}
+
+// Location describes where a resolver in the pool is operated.
+type Location struct {
+	// Country is the ISO 3166 country code, such as "US"
+	Country string
+	// Region is an optional user defined name, such as a continent or a cloud region
+	Region string
+	ASN    uint32
+	Org    string
+}
+
+// LocationFunc returns the location of the IP address, such as the result of a lookup in a
+// MaxMind GeoIP database.
+type LocationFunc func(ip net.IP) (*Location, error)
+
+// Matches returns true when the region is the region name, the country code or the ASN of
+// the location, which is formatted as "AS" followed by the number.
+func (l *Location) Matches(region string) bool {
+	if l == nil || region == "" {
+		return false
+	}
+
+	if strings.EqualFold(region, l.Region) || strings.EqualFold(region, l.Country) {
+		return true
+	}
+	if n, found := strings.CutPrefix(strings.ToUpper(region), "AS"); found && l.ASN != 0 {
+		if asn, err := strconv.ParseUint(n, 10, 32); err == nil && uint32(asn) == l.ASN {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotateResolvers sets the location of each resolver in the pool using the provided function.
+// The resolvers added later are not annotated automatically. The first error is returned after
+// the remaining resolvers have been annotated.
+func (r *Resolvers) AnnotateResolvers(fn LocationFunc) error {
+	var first error
+
+	for _, res := range r.pool.AllResolvers() {
+		loc, err := fn(res.address.IP)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("failed to locate %s: %v", res.address, err)
+			}
+			continue
+		}
+		res.location.Store(loc)
+	}
+	return first
+}
+
+// SetLocation annotates the resolver at the provided address with the location.
+func (r *Resolvers) SetLocation(addr string, loc *Location) error {
+	res, err := r.lookupAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	res.location.Store(loc)
+	return nil
+}
+
+// ResolverLocation returns the location of the resolver at the provided address, or nil when
+// the resolver has not been annotated.
+func (r *Resolvers) ResolverLocation(addr string) *Location {
+	if res, err := r.lookupAddr(addr); err == nil {
+		return res.location.Load()
+	}
+	return nil
+}
+
+// Regions returns the number of active resolvers annotated with each region name.
+func (r *Resolvers) Regions() map[string]int {
+	regions := make(map[string]int)
+
+	for _, res := range r.pool.AllResolvers() {
+		if loc := res.location.Load(); loc != nil && loc.Region != "" {
+			regions[loc.Region]++
+		}
+	}
+	return regions
+}
+
+// ResolveByRegion sends the query to one randomly selected resolver in each region returned
+// by Regions, and returns the answers keyed by the region name. This is useful for mapping
+// the answers of CDNs and global load balancers that depend on the location of the client.
+func (r *Resolvers) ResolveByRegion(ctx context.Context, name string, qtype uint16) (map[string]*ResolverAnswer, error) {
+	regions := r.Regions()
+	if len(regions) == 0 {
+		return nil, errors.New("the pool has no resolvers annotated with a region")
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	results := make(map[string]*ResolverAnswer, len(regions))
+	for region := range regions {
+		res := r.pool.GetMatchingResolver(func(res *resolver) bool {
+			loc := res.location.Load()
+			return loc != nil && strings.EqualFold(loc.Region, region)
+		})
+		// the resolvers of the region could have been quarantined or stopped
+		if res == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(region string, res *resolver) {
+			defer wg.Done()
+
+			result := res.compareQuery(ctx, name, qtype)
+
+			lock.Lock()
+			results[region] = result
+			lock.Unlock()
+		}(region, res)
+	}
+	wg.Wait()
+
+	if len(results) == 0 {
+		return nil, errors.New("none of the regions had an active resolver")
+	}
+	return results, nil
+}
+
+// WithRegion returns a context that causes the queries to be sent to the resolvers whose
+// location matches the region, as described by Location.Matches. The queries fail with
+// RcodeNoResponse when the pool has no active resolvers in the region.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionCtxKey{}, region)
+}
+
+func queryRegion(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if region, ok := ctx.Value(regionCtxKey{}).(string); ok {
+		return region
+	}
+	return ""
+}
+
+// selectResolver returns a resolver for the request, restricted to the requested region.
+func (r *Resolvers) selectResolver(req *request) *resolver {
+	region := queryRegion(req.Ctx)
+	if region == "" {
+		return r.pool.GetResolver()
+	}
+
+	return r.pool.GetMatchingResolver(func(res *resolver) bool {
+		return res.location.Load().Matches(region)
+	})
+}
+
+// lookupAddr returns the resolver in the pool at the IP address or host and port.
+func (r *Resolvers) lookupAddr(addr string) (*resolver, error) {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("%s is not a valid resolver address", addr)
+	}
+
+	res := r.pool.LookupResolver(ip.String())
+	if res == nil {
+		return nil, fmt.Errorf("the resolver %s is not in the pool", addr)
+	}
+	return res, nil
+}
@@ -0,0 +1,144 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestLocationMatches(t *testing.T) {
+	loc := &Location{Country: "DE", Region: "eu-central", ASN: 64501}
+
+	for _, region := range []string{"eu-central", "EU-Central", "de", "AS64501", "as64501"} {
+		if !loc.Matches(region) {
+			t.Errorf("the location did not match %s", region)
+		}
+	}
+	for _, region := range []string{"", "us", "AS64500", "ASxyz"} {
+		if loc.Matches(region) {
+			t.Errorf("the location matched %s", region)
+		}
+	}
+
+	var none *Location
+	if none.Matches("de") {
+		t.Errorf("a missing location matched the region")
+	}
+}
+
+func TestWithRegion(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addr1, addr2)
+	defer r.Stop()
+
+	if err := r.AnnotateResolvers(func(ip net.IP) (*Location, error) {
+		if ip.Equal(net.ParseIP("127.0.0.1")) {
+			return &Location{Country: "US", Region: "na", ASN: 64500}, nil
+		}
+		return nil, errors.New("not found")
+	}); err == nil {
+		t.Errorf("the lookup failure was not returned")
+	}
+	if err := r.SetLocation(addr2, &Location{Country: "DE", Region: "eu", ASN: 64501}); err != nil {
+		t.Fatalf("failed to set the location: %v", err)
+	}
+	if err := r.SetLocation("192.168.1.1", &Location{}); err == nil {
+		t.Errorf("the location was set for a resolver outside the pool")
+	}
+	if loc := r.ResolverLocation("127.0.0.1"); loc == nil || loc.Country != "US" {
+		t.Errorf("the location of the resolver was not correct: %+v", loc)
+	}
+	if regions := r.Regions(); len(regions) != 2 || regions["na"] != 1 || regions["eu"] != 1 {
+		t.Errorf("the regions were not correct: %v", regions)
+	}
+
+	cases := []struct {
+		region  string
+		answers int
+	}{
+		{"na", 1},
+		{"DE", 2},
+		{"AS64500", 1},
+	}
+	for _, c := range cases {
+		ctx := WithRegion(context.Background(), c.region)
+
+		for i := 0; i < 10; i++ {
+			resp, err := r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA))
+			if err != nil || len(resp.Answer) != c.answers {
+				t.Errorf("the query for region %s was not sent to the resolver in the region", c.region)
+				break
+			}
+		}
+	}
+
+	resp, err := r.QueryBlocking(WithRegion(context.Background(), "ap"), QueryMsg("caffix.net", dns.TypeA))
+	if err == nil && resp.Rcode != RcodeNoResponse {
+		t.Errorf("the query was answered without a resolver in the region")
+	}
+}
+
+func TestResolveByRegion(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addr1, addr2)
+	defer r.Stop()
+
+	if _, err := r.ResolveByRegion(context.Background(), "caffix.net", dns.TypeA); err == nil {
+		t.Errorf("the answers were mapped without any regions")
+	}
+
+	_ = r.SetLocation(addr1, &Location{Region: "na"})
+	_ = r.SetLocation(addr2, &Location{Region: "eu"})
+	results, err := r.ResolveByRegion(context.Background(), "caffix.net", dns.TypeA)
+	if err != nil {
+		t.Fatalf("failed to resolve the name by region: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("the answers were returned for %d regions instead of 2", len(results))
+	}
+	if res := results["na"]; res == nil || res.Resolver != "127.0.0.1" || len(res.Answers) != 1 {
+		t.Errorf("the answers for the na region were not correct: %+v", res)
+	}
+	if res := results["eu"]; res == nil || res.Resolver != "127.0.0.2" || len(res.Answers) != 2 {
+		t.Errorf("the answers for the eu region were not correct: %+v", res)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+const maxReplayWorkers = 100
+
+// ReplayRecord is a single line of the JSONL query log processed by Replay. The answers are
+// optional and contain the records in the canonical form returned by CanonicalAnswers.
+type ReplayRecord struct {
+	Name    string   `json:"name"`
+	Qtype   string   `json:"qtype"`
+	Rcode   string   `json:"rcode,omitempty"`
+	Answers []string `json:"answers,omitempty"`
+}
+
+// ReplayResult contains the outcome of re-issuing a query from the log and the differences
+// from the recorded answers.
+type ReplayResult struct {
+	Line     int
+	Name     string
+	Qtype    uint16
+	Recorded *ReplayRecord
+	Resp     *dns.Msg
+	Added    []string
+	Removed  []string
+	// RcodeChanged is true when the log recorded a different response code
+	RcodeChanged bool
+	Err          error
+}
+
+// Changed returns true when the new response differs from the recorded response.
+func (r *ReplayResult) Changed() bool {
+	return r.RcodeChanged || len(r.Added) > 0 || len(r.Removed) > 0
+}
+
+// WriteReplayRecord appends the response to a log in the format processed by Replay.
+func WriteReplayRecord(w io.Writer, resp *dns.Msg) error {
+	if resp == nil || len(resp.Question) == 0 {
+		return errors.New("the response does not contain a question")
+	}
+
+	b, err := json.Marshal(&ReplayRecord{
+		Name:    strings.ToLower(RemoveLastDot(resp.Question[0].Name)),
+		Qtype:   dns.TypeToString[resp.Question[0].Qtype],
+		Rcode:   dns.RcodeToString[resp.Rcode],
+		Answers: CanonicalAnswers(resp),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Replay reads the JSONL query log and re-issues the queries through the pool. The returned
+// channel provides a result for each line and is closed once the log has been processed.
+// Lines with recorded answers or response codes are compared with the new responses.
+func (r *Resolvers) Replay(ctx context.Context, rd io.Reader) <-chan *ReplayResult {
+	results := make(chan *ReplayResult, maxReplayWorkers)
+
+	go func() {
+		defer close(results)
+
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, maxReplayWorkers)
+		scanner := bufio.NewScanner(rd)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			case sem <- struct{}{}:
+			}
+
+			wg.Add(1)
+			go func(line int, text string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+
+				select {
+				case <-ctx.Done():
+				case results <- r.replayLine(ctx, line, text):
+				}
+			}(line, text)
+		}
+		wg.Wait()
+
+		if err := scanner.Err(); err != nil {
+			results <- &ReplayResult{Err: err}
+		}
+	}()
+	return results
+}
+
+func (r *Resolvers) replayLine(ctx context.Context, line int, text string) *ReplayResult {
+	result := &ReplayResult{Line: line}
+
+	var rec ReplayRecord
+	if err := json.Unmarshal([]byte(text), &rec); err != nil {
+		result.Err = fmt.Errorf("line %d: %w", line, err)
+		return result
+	}
+	result.Recorded = &rec
+	result.Name = strings.ToLower(RemoveLastDot(rec.Name))
+
+	qtype, found := dns.StringToType[strings.ToUpper(rec.Qtype)]
+	if result.Name == "" || !found {
+		result.Err = fmt.Errorf("line %d: the record does not contain a valid name and qtype", line)
+		return result
+	}
+	result.Qtype = qtype
+
+	resp, err := r.QueryBlocking(ctx, QueryMsg(result.Name, qtype))
+	if err == nil && resp.Rcode == RcodeNoResponse {
+		err = errors.New("the query failed to obtain a response")
+	}
+	if err != nil {
+		result.Err = fmt.Errorf("line %d: %w", line, err)
+		return result
+	}
+	result.Resp = resp
+
+	if rec.Rcode != "" {
+		result.RcodeChanged = !strings.EqualFold(rec.Rcode, dns.RcodeToString[resp.Rcode])
+	}
+	if rec.Answers != nil || rec.Rcode != "" {
+		result.Added, result.Removed = diffRecords(rec.Answers, CanonicalAnswers(resp))
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestReplay(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(lookupHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	var log bytes.Buffer
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.caffix.net", dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if err := WriteReplayRecord(&log, resp); err != nil {
+		t.Fatalf("failed to write the replay record: %v", err)
+	}
+	log.WriteString(`{"name":"www.caffix.net","qtype":"AAAA","rcode":"NOERROR","answers":["www.caffix.net IN AAAA 2001:db8::2"]}` + "\n")
+	log.WriteString(`{"name":"mail.caffix.net","qtype":"A","rcode":"NOERROR"}` + "\n\n")
+	log.WriteString(`{"name":"caffix.net","qtype":"BOGUS"}` + "\n")
+	log.WriteString(`not json` + "\n")
+
+	results := make(map[int]*ReplayResult)
+	for result := range r.Replay(context.Background(), strings.NewReader(log.String())) {
+		results[result.Line] = result
+	}
+	if len(results) != 5 {
+		t.Fatalf("Replay returned %d results for five queries", len(results))
+	}
+
+	if res := results[1]; res.Err != nil || res.Changed() {
+		t.Errorf("the unchanged answer was reported as different: %+v", res)
+	}
+	if res := results[2]; res.Err != nil || len(res.Added) != 1 || len(res.Removed) != 1 || res.RcodeChanged {
+		t.Errorf("the changed answer was not correctly reported: %+v", res)
+	}
+	if res := results[3]; res.Err != nil || !res.RcodeChanged || res.Resp.Rcode != dns.RcodeNameError {
+		t.Errorf("the changed response code was not reported: %+v", res)
+	}
+	for _, line := range []int{5, 6} {
+		if res := results[line]; res.Err == nil {
+			t.Errorf("the invalid record on line %d was accepted", line)
+		}
+	}
+}
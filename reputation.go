@@ -0,0 +1,164 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// The number of queries required before the reputation of a resolver affects its use.
+const minReputationQueries = 10
+
+// Reputation contains the reliability history of a resolver collected across runs.
+type Reputation struct {
+	Queries    uint64        `json:"queries"`
+	Failures   uint64        `json:"failures"`
+	Injections uint64        `json:"injections"`
+	AverageRTT time.Duration `json:"average_rtt"`
+}
+
+// Score returns the fraction of queries that were successful and not flagged as injected.
+// Resolvers without enough history receive the maximum score.
+func (rep *Reputation) Score() float64 {
+	if rep.Queries < minReputationQueries {
+		return 1
+	}
+
+	bad := rep.Failures + rep.Injections
+	if bad >= rep.Queries {
+		return 0
+	}
+	return float64(rep.Queries-bad) / float64(rep.Queries)
+}
+
+func (rep *Reputation) merge(other *Reputation) *Reputation {
+	m := &Reputation{
+		Queries:    rep.Queries + other.Queries,
+		Failures:   rep.Failures + other.Failures,
+		Injections: rep.Injections + other.Injections,
+	}
+	if m.Queries > 0 {
+		total := rep.AverageRTT*time.Duration(rep.Queries) + other.AverageRTT*time.Duration(other.Queries)
+		m.AverageRTT = total / time.Duration(m.Queries)
+	}
+	return m
+}
+
+// ReputationStore persists the resolver reputations keyed by IP address.
+type ReputationStore interface {
+	Load() (map[string]*Reputation, error)
+	Save(reps map[string]*Reputation) error
+}
+
+// FileReputationStore is a ReputationStore that keeps the reputations in a JSON file.
+type FileReputationStore struct {
+	sync.Mutex
+	path string
+}
+
+// NewFileReputationStore returns a ReputationStore using the JSON file at the provided path.
+func NewFileReputationStore(path string) *FileReputationStore {
+	return &FileReputationStore{path: path}
+}
+
+// Load implements the ReputationStore interface. A missing file is treated as an empty store.
+func (s *FileReputationStore) Load() (map[string]*Reputation, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	reps := make(map[string]*Reputation)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return reps, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &reps); err != nil {
+		return nil, err
+	}
+	return reps, nil
+}
+
+// Save implements the ReputationStore interface.
+func (s *FileReputationStore) Save(reps map[string]*Reputation) error {
+	s.Lock()
+	defer s.Unlock()
+
+	data, err := json.MarshalIndent(reps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// SetReputationStore loads the resolver reputations from the store. Resolvers added to the
+// pool afterwards have the QPS reduced by the score, which makes the selection of historically
+// unreliable resolvers less likely.
+func (r *Resolvers) SetReputationStore(store ReputationStore) error {
+	reps, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.repStore = store
+	r.reputations = reps
+	return nil
+}
+
+// SaveReputation merges the statistics collected during this run with the reputations loaded
+// from the store and saves the results.
+func (r *Resolvers) SaveReputation() error {
+	r.Lock()
+	store := r.repStore
+	reps := make(map[string]*Reputation, len(r.reputations))
+	for addr, rep := range r.reputations {
+		reps[addr] = rep
+	}
+	r.Unlock()
+
+	if store == nil {
+		return errors.New("a reputation store has not been provided")
+	}
+
+	for _, res := range r.pool.AllResolvers() {
+		s := res.getStats()
+		cur := &Reputation{
+			Queries:    s.Responses + s.Timeouts,
+			Failures:   s.Timeouts + s.FormatErrors + s.ServerFailures + s.NotImplemented + s.QueryRefusals,
+			Injections: s.Injections,
+			AverageRTT: s.AverageRTT,
+		}
+
+		addr := res.address.IP.String()
+		if prev, found := reps[addr]; found {
+			cur = prev.merge(cur)
+		}
+		reps[addr] = cur
+	}
+	return store.Save(reps)
+}
+
+// reputationQPS returns the QPS for the resolver at the address reduced by the reputation score.
+// The caller must hold the pool lock.
+func (r *Resolvers) reputationQPS(addr string, qps int) int {
+	rep, found := r.reputations[addr]
+	if !found {
+		return qps
+	}
+
+	if scaled := int(float64(qps) * rep.Score()); scaled > 0 {
+		return scaled
+	}
+	return 1
+}
@@ -0,0 +1,81 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReputationScore(t *testing.T) {
+	if s := (&Reputation{Queries: 5, Failures: 5}).Score(); s != 1 {
+		t.Errorf("a resolver without enough history received a score of %f", s)
+	}
+	if s := (&Reputation{Queries: 100, Failures: 20, Injections: 5}).Score(); s != 0.75 {
+		t.Errorf("the score was %f instead of 0.75", s)
+	}
+	if s := (&Reputation{Queries: 10, Failures: 10, Injections: 10}).Score(); s != 0 {
+		t.Errorf("the score was %f instead of zero", s)
+	}
+
+	rep := (&Reputation{Queries: 10, AverageRTT: 100 * time.Millisecond}).merge(&Reputation{Queries: 30, AverageRTT: 200 * time.Millisecond})
+	if rep.Queries != 40 || rep.AverageRTT != 175*time.Millisecond {
+		t.Errorf("the reputations were not correctly merged: %+v", rep)
+	}
+}
+
+func TestFileReputationStore(t *testing.T) {
+	store := NewFileReputationStore(filepath.Join(t.TempDir(), "reputation.json"))
+
+	if reps, err := store.Load(); err != nil || len(reps) != 0 {
+		t.Fatalf("failed to load the missing file as an empty store: %v", err)
+	}
+	if err := store.Save(map[string]*Reputation{"192.168.1.1": {Queries: 100, Failures: 50}}); err != nil {
+		t.Fatalf("failed to save the reputations: %v", err)
+	}
+	if reps, err := store.Load(); err != nil || reps["192.168.1.1"] == nil || reps["192.168.1.1"].Failures != 50 {
+		t.Errorf("failed to load the saved reputations: %v", err)
+	}
+}
+
+func TestReputationQPS(t *testing.T) {
+	store := NewFileReputationStore(filepath.Join(t.TempDir(), "reputation.json"))
+	_ = store.Save(map[string]*Reputation{"192.168.1.1": {Queries: 100, Failures: 90}})
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.SetReputationStore(store); err != nil {
+		t.Fatalf("failed to set the reputation store: %v", err)
+	}
+	_ = r.AddResolvers(100, "192.168.1.1", "192.168.1.2")
+
+	if res := r.pool.LookupResolver("192.168.1.1"); res == nil || res.qps != 10 {
+		t.Errorf("the resolver with a poor reputation was not deprioritized")
+	}
+	if res := r.pool.LookupResolver("192.168.1.2"); res == nil || res.qps != 100 {
+		t.Errorf("the resolver without a reputation was deprioritized")
+	}
+	if qps := r.QPS(); qps != 110 {
+		t.Errorf("the pool QPS was %d instead of 110", qps)
+	}
+
+	res := r.pool.LookupResolver("192.168.1.2")
+	for i := 0; i < 10; i++ {
+		res.recordRTT(100 * time.Millisecond)
+	}
+	if err := r.SaveReputation(); err != nil {
+		t.Fatalf("failed to save the reputations: %v", err)
+	}
+
+	reps, _ := store.Load()
+	if rep := reps["192.168.1.1"]; rep == nil || rep.Queries != 100 {
+		t.Errorf("the reputation from the previous run was not retained")
+	}
+	if rep := reps["192.168.1.2"]; rep == nil || rep.Queries != 10 || rep.AverageRTT != 100*time.Millisecond {
+		t.Errorf("the statistics from this run were not saved")
+	}
+}
@@ -5,13 +5,16 @@
 package resolve
 
 import (
+	"container/list"
 	"context"
+	"crypto/tls"
 	"errors"
 	"io"
 	"log"
 	"net"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/caffix/queue"
@@ -19,35 +22,118 @@ import (
 	"go.uber.org/ratelimit"
 )
 
+// PoolResolver contains the methods of the resolver pool used by most callers, which allows
+// the pool to be replaced by a mock in the tests of the downstream projects.
+type PoolResolver interface {
+	Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg)
+	QueryChan(ctx context.Context, msg *dns.Msg) chan *dns.Msg
+	QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg, error)
+	WildcardDetected(ctx context.Context, resp *dns.Msg, domain string) bool
+	AddResolvers(qps int, addrs ...string) error
+	Stop()
+}
+
 // Resolvers is a pool of DNS resolvers managed for brute forcing using random selection.
 type Resolvers struct {
 	sync.Mutex
-	done      chan struct{}
-	log       *log.Logger
-	conns     *connections
-	pool      selector
-	rmap      map[string]struct{}
-	wildcards map[string]*wildcard
-	queue     queue.Queue
-	resps     queue.Queue
-	qps       int
-	maxSet    bool
-	rate      ratelimit.Limiter
-	servRates *RateTracker
-	detector  *resolver
-	timeout   time.Duration
-	options   *ThresholdOptions
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{}
+	log           *log.Logger
+	conns         *connections
+	pool          selector
+	rmap          map[string]struct{}
+	wildcards     map[string]*wildcard
+	wildLRU       *list.List
+	maxWildcards  int
+	wildEvictions atomic.Uint64
+	wildSubs      *wildSubscribers
+	events        *eventSubscribers
+	domains       *domainStats
+	queue         queue.Queue
+	leases        queue.Queue
+	resps         queue.Queue
+	qps           int
+	maxSet        bool
+	rate          ratelimit.Limiter
+	servRates     *RateTracker
+	detector      *resolver
+	timeout       time.Duration
+	options       *ThresholdOptions
+	boostLock     sync.Mutex
+	boosts        map[string]int
+	boostWindow   atomic.Int64
+	deadlines     deadlineHeap
+	deadline      atomic.Int64
+	demoteLimit   atomic.Uint64
+	demoteHook    atomic.Pointer[DemotionHook]
+	privacy       atomic.Bool
+	injections    atomic.Pointer[injectionTracker]
+	nsid          atomic.Bool
+	discovery     bool
+	ddr           bool
+	authPort      string
+	nsRotate      *nsRotation
+	sessions      *scanSessions
+	journal       atomic.Pointer[Journal]
+	budget        atomic.Pointer[RetryBudget]
+	tlsConfig     *tls.Config
+	ttlOptions    atomic.Pointer[TTLOptions]
+	repStore      ReputationStore
+	reputations   map[string]*Reputation
+	search        []string
+	ndots         int
+	leased        int
+	subpools      map[string]*Resolvers
+	preSend       atomic.Pointer[ContextHook]
+	postReceive   atomic.Pointer[ContextHook]
+	logCtx        atomic.Pointer[LogContextFunc]
+	sinkholes     atomic.Pointer[sinkholeConfig]
+	addrCheck     atomic.Pointer[addrValidation]
+	blocking      atomic.Pointer[blockConfig]
+	policy        atomic.Pointer[Policy]
+	tor           atomic.Pointer[torConfig]
+	shedding      atomic.Pointer[ShedOptions]
+	probe         atomic.Pointer[HealthProbe]
+	truncPolicy   atomic.Pointer[TruncationOptions]
+	shared        atomic.Bool
+	shed          atomic.Uint64
+	waitAvg       atomic.Int64
+	memLimit      atomic.Int64
+	overMemory    atomic.Bool
+	detectorFails atomic.Int32
+	detectorGood  atomic.Int64
+	clock         Clock
+	expiry        Ticker
+	power         *powerState
+	pause         pauseState
+	qtypeTOs      map[uint16]time.Duration
+	resTOs        map[string]time.Duration
 }
 
 type resolver struct {
+	sync.Mutex
 	done    chan struct{}
 	pool    *Resolvers
 	queue   queue.Queue
+	running chan struct{}
 	xchgs   *xchgMgr
 	address *net.UDPAddr
 	qps     int
 	rate    ratelimit.Limiter
 	stats   *stats
+	udpSize uint16
+	dotLock sync.Mutex
+	dot     *dns.Conn
+	dotAddr string
+	// encrypted is set when the resolver was upgraded to DNS over TLS
+	encrypted atomic.Bool
+	labels    context.Context
+	// quarantine holds the time in Unix nanoseconds when the resolver returns to selection
+	quarantine atomic.Int64
+	location   atomic.Pointer[Location]
+	// tcpPreferred is set when the truncation policy switched the resolver to TCP
+	tcpPreferred atomic.Bool
 }
 
 func (r *Resolvers) initializeResolver(qps int, addr string) *resolver {
@@ -62,17 +148,32 @@ func (r *Resolvers) initializeResolver(qps int, addr string) *resolver {
 			done:    make(chan struct{}, 1),
 			pool:    r,
 			queue:   queue.NewQueue(),
-			xchgs:   newXchgMgr(r.timeout),
+			xchgs:   newXchgMgr(r.resolverTimeout(uaddr.IP.String()), r.clock),
 			address: uaddr,
+			dotAddr: net.JoinHostPort(uaddr.IP.String(), dotPort),
 			qps:     qps,
-			rate:    ratelimit.New(qps),
+			rate:    ratelimit.New(qps, ratelimit.WithClock(r.clock)),
 			stats:   new(stats),
+			labels:  resolverLabels(uaddr.String()),
 		}
-		go res.processRequests()
+		res.xchgs.setQtypeTimeouts(r.qtypeTOs)
 	}
 	return res
 }
 
+// start launches the goroutine sending the requests of the resolver until the stop channel is
+// closed, unless it is already running for the same channel.
+func (r *resolver) start(stop chan struct{}) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.running == stop {
+		return
+	}
+	r.running = stop
+	go runLabeled(func() { r.processRequests(stop) }, labelSubsystem, "requests", labelResolver, r.address.String())
+}
+
 func (r *resolver) stop() {
 	select {
 	case <-r.done:
@@ -81,6 +182,7 @@ func (r *resolver) stop() {
 	}
 	// Send the signal to shutdown and close the connection
 	close(r.done)
+	r.closeTLS(nil)
 	// Drain the xchgs of all messages and allow callers to return
 	for _, req := range r.xchgs.removeAll() {
 		req.errNoResponse()
@@ -88,27 +190,90 @@ func (r *resolver) stop() {
 	}
 }
 
-// NewResolvers initializes a Resolvers.
+// NewResolvers initializes a Resolvers. The queries fail when the UDP sockets of the pool
+// cannot be opened, so OpenResolvers should be used when the cause must be reported.
 func NewResolvers() *Resolvers {
+	return NewResolversWithClock(realClock{})
+}
+
+// NewResolversWithClock initializes a Resolvers that uses the provided Clock for rate limiting
+// and for identifying the queries that have timed out.
+// Passing a SimClock allows the pool to be exercised in virtual time without real sleeps.
+func NewResolversWithClock(clock Clock) *Resolvers {
+	r, _ := openResolvers(clock)
+	return r
+}
+
+// OpenResolvers initializes a Resolvers like NewResolversWithClock, and returns the error
+// wrapping the cause when the UDP sockets of the pool cannot be opened. The pool is stopped and
+// nil is returned along with the error. A nil clock uses the real time, so callers outside the
+// package can check the error.
+func OpenResolvers(clock Clock) (*Resolvers, error) {
+	r, err := openResolvers(clock)
+	if err != nil {
+		r.Stop()
+		return nil, err
+	}
+	return r, nil
+}
+
+// openResolvers returns the pool along with the error from opening the UDP sockets, so the pool
+// can still be used for the DNS over TLS and TCP exchanges.
+func openResolvers(clock Clock) (*Resolvers, error) {
+	if clock == nil {
+		clock = realClock{}
+	}
+
 	responses := queue.NewQueue()
+	conns, err := newConnections(runtime.NumCPU(), responses)
+
+	ctx, cancel := context.WithCancel(context.Background())
 	r := &Resolvers{
+		ctx:       ctx,
+		cancel:    cancel,
 		done:      make(chan struct{}, 1),
 		log:       log.New(io.Discard, "", 0),
-		conns:     newConnections(runtime.NumCPU(), responses),
+		conns:     conns,
 		pool:      newRandomSelector(),
 		rmap:      make(map[string]struct{}),
 		wildcards: make(map[string]*wildcard),
+		wildLRU:   list.New(),
+		wildSubs:  new(wildSubscribers),
+		events:    new(eventSubscribers),
+		domains:   new(domainStats),
 		queue:     queue.NewQueue(),
+		leases:    queue.NewQueue(),
 		resps:     responses,
 		timeout:   DefaultTimeout,
+		qtypeTOs:  make(map[uint16]time.Duration),
+		resTOs:    make(map[string]time.Duration),
 		options:   new(ThresholdOptions),
+		boosts:    make(map[string]int),
+		ndots:     defaultNdots,
+		sessions:  new(scanSessions),
+		nsRotate:  newNSRotation(),
+		clock:     clock,
+		power:     newPowerState(clock),
 	}
+	r.demoteLimit.Store(DefaultFormatErrorLimit)
+	r.maxWildcards = DefaultMaxWildcards
 
-	go r.timeouts()
-	go r.enforceMaxQPS()
-	go r.thresholdChecks()
-	go r.processResponses()
-	return r
+	// a SimClock could otherwise be advanced before the timeouts loop obtained its ticker
+	r.expiry = clock.NewTicker(r.timeoutCheckInterval())
+	r.startBackground(r.power.current())
+	return r, err
+}
+
+// startBackground launches the goroutines of the pool, which run until the pool is stopped or
+// the stop channel is closed by the power down.
+func (r *Resolvers) startBackground(stop chan struct{}) {
+	go runLabeled(func() { r.timeouts(stop) }, labelSubsystem, "timeouts")
+	go runLabeled(func() { r.enforceMaxQPS(stop) }, labelSubsystem, "scheduler")
+	go runLabeled(func() { r.thresholdChecks(stop) }, labelSubsystem, "thresholds")
+	go runLabeled(func() { r.processResponses(stop) }, labelSubsystem, "responses")
+	go runLabeled(func() { r.boostChecks(stop) }, labelSubsystem, "boosts")
+	go runLabeled(func() { r.memoryChecks(stop) }, labelSubsystem, "memory")
+	go runLabeled(func() { r.detectorChecks(stop) }, labelSubsystem, "detector")
 }
 
 // Len returns the number of resolvers that have been added to the pool.
@@ -135,6 +300,10 @@ func (r *Resolvers) SetTimeout(d time.Duration) {
 }
 
 func (r *Resolvers) updateResolverTimeouts() {
+	if r.expiry != nil {
+		r.expiry.Reset(r.checkInterval())
+	}
+
 	all := r.pool.AllResolvers()
 	if r.detector != nil {
 		all = append(all, r.detector)
@@ -144,7 +313,8 @@ func (r *Resolvers) updateResolverTimeouts() {
 		select {
 		case <-res.done:
 		default:
-			res.xchgs.setTimeout(r.timeout)
+			res.xchgs.setTimeout(r.resolverTimeout(res.address.IP.String()))
+			res.xchgs.setQtypeTimeouts(r.qtypeTOs)
 		}
 	}
 }
@@ -159,14 +329,36 @@ func (r *Resolvers) QPS() int {
 
 // SetMaxQPS allows a preferred maximum number of queries per second to be specified for the pool.
 func (r *Resolvers) SetMaxQPS(qps int) {
+	r.Lock()
+	defer r.Unlock()
+
 	r.qps = qps
-	if qps > 0 {
-		r.maxSet = true
-		r.rate = ratelimit.New(qps)
+	r.maxSet = qps > 0
+	r.updateRateLimiter()
+}
+
+// updateRateLimiter creates the rate limiter for the QPS not reserved by leases.
+// The caller must hold the pool lock.
+func (r *Resolvers) updateRateLimiter() {
+	if r.qps <= 0 {
+		r.rate = nil
+		r.emitEvent(&Event{Type: EventRateChanged})
 		return
 	}
-	r.maxSet = false
-	r.rate = nil
+
+	qps := r.qps - r.leased
+	if qps < 1 {
+		qps = 1
+	}
+	r.rate = ratelimit.New(qps, ratelimit.WithClock(r.clock))
+	r.emitEvent(&Event{Type: EventRateChanged, QPS: r.qps})
+}
+
+func (r *Resolvers) getRateLimiter() ratelimit.Limiter {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.rate
 }
 
 // AddResolvers initializes and adds new resolvers to the pool of resolvers.
@@ -186,11 +378,19 @@ func (r *Resolvers) AddResolvers(qps int, addrs ...string) error {
 		// check that this address will not create a duplicate resolver
 		if host, _, err := net.SplitHostPort(addr); err == nil {
 			if _, found := r.rmap[host]; !found {
-				if res := r.initializeResolver(qps, addr); res != nil {
+				if res := r.initializeResolver(r.reputationQPS(host, qps), addr); res != nil {
 					r.rmap[res.address.IP.String()] = struct{}{}
 					r.pool.AddResolver(res)
+					res.start(r.power.current())
+					r.emitEvent(&Event{Type: EventResolverAdded, Resolver: res.address.String()})
+					if r.discovery {
+						go runLabeled(res.discoverPayloadSize, labelSubsystem, "discovery", labelResolver, res.address.String())
+					}
+					if r.ddr {
+						go runLabeled(res.upgradeViaDDR, labelSubsystem, "discovery", labelResolver, res.address.String())
+					}
 					if !r.maxSet {
-						r.qps += qps
+						r.qps += res.qps
 					}
 				}
 			}
@@ -198,23 +398,34 @@ func (r *Resolvers) AddResolvers(qps int, addrs ...string) error {
 	}
 	// create the new rate limiter for the updated QPS
 	if !r.maxSet {
-		r.rate = ratelimit.New(r.qps)
+		r.updateRateLimiter()
 	}
 	return nil
 }
 
-// Stop will release resources for the resolver pool and all add resolvers.
+// Stop will release resources for the resolver pool and all add resolvers. The pool returned
+// by DefaultPool is only stopped by StopDefaultPool.
 func (r *Resolvers) Stop() {
+	if r.shared.Load() {
+		return
+	}
+
 	select {
 	case <-r.done:
 		return
 	default:
 	}
+	r.emitEvent(&Event{Type: EventShutdownStarted})
 	close(r.done)
+	r.cancel()
+	r.power.stopTimer()
+	r.stopSubPools()
 	if r.servRates != nil {
 		r.servRates.Stop()
 	}
-	r.conns.Close()
+	if r.conns != nil {
+		r.conns.Close()
+	}
 
 	all := r.pool.AllResolvers()
 	if d := r.getDetectionResolver(); d != nil {
@@ -228,12 +439,34 @@ func (r *Resolvers) Stop() {
 		res.stop()
 	}
 	r.pool.Close()
+	r.emitEvent(&Event{Type: EventShutdownCompleted})
 }
 
 // Query queues the provided DNS message and returns the response on the provided channel.
+// The messages failing ValidateQuery are returned immediately with RcodeInvalidQuery.
+// Each response is parsed from the exchange of the query, so it is owned by the caller and
+// can be modified without affecting other callers or the state of the pool. When a response
+// cannot be obtained, the provided message is returned with the rcode set. The goroutines of
+// the pool never block on the channel: when the caller is not ready to receive, the message is
+// sent by a separate goroutine until the context expires, so the channel should be buffered
+// for the single message, as the one returned by QueryChan.
 func (r *Resolvers) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	r.query(ctx, msg, ch, false)
+}
+
+func (r *Resolvers) query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg, leased bool) {
 	if msg == nil {
-		ch <- msg
+		deliver(ctx, ch, msg)
+		return
+	}
+	if err := ValidateQuery(msg); err != nil {
+		msg.Rcode = RcodeInvalidQuery
+		deliver(ctx, ch, msg)
+		return
+	}
+	if sub := r.subPoolFromContext(ctx); sub != nil {
+		// the lease only reserved the QPS of this pool, so the sub-pool enforces its own limit
+		sub.query(ctx, msg, ch, false)
 		return
 	}
 
@@ -241,22 +474,44 @@ func (r *Resolvers) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
 	case <-ctx.Done():
 	case <-r.done:
 	default:
+		r.wake()
+		priority := r.queryPriority(ctx, msg.Question[0].Name)
+		if priority == queue.PriorityLow && r.overloaded() {
+			r.shed.Add(1)
+			msg.Rcode = RcodeOverloaded
+			deliver(ctx, ch, msg)
+			return
+		}
+
 		req := reqPool.Get().(*request)
 
+		req.Ctx = ctx
 		req.Msg = msg
 		req.Result = ch
+		req.Leased = leased
+		req.Meta = metadataFromContext(ctx)
+		req.Session = r.scanSession(ctx)
+		req.Journal = r.journal.Load()
+		req.JournalID = req.Journal.start(msg)
+		req.Priority = priority
+		req.Queued = r.clock.Now()
+		if !isRetry(ctx) {
+			r.budgetRequest()
+		}
 		if r.servRates != nil {
 			r.servRates.Take(msg.Question[0].Name)
 		}
-		r.queue.Append(req)
+		r.enqueue(req)
 		return
 	}
 
 	msg.Rcode = RcodeNoResponse
-	ch <- msg
+	r.scanSession(ctx).record(msg.Question[0].Name, outcomeFailed)
+	deliver(ctx, ch, msg)
 }
 
-// Query queues the provided DNS message and sends the response on the returned channel.
+// QueryChan queues the provided DNS message and sends the response on the returned channel,
+// which is buffered for the response.
 func (r *Resolvers) QueryChan(ctx context.Context, msg *dns.Msg) chan *dns.Msg {
 	ch := make(chan *dns.Msg, 1)
 	r.Query(ctx, msg, ch)
@@ -265,61 +520,116 @@ func (r *Resolvers) QueryChan(ctx context.Context, msg *dns.Msg) chan *dns.Msg {
 
 // Query queues the provided DNS message and returns the associated response message.
 func (r *Resolvers) QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	ctx, cancel := r.queryContext(ctx)
+	defer cancel()
+
 	select {
 	case <-ctx.Done():
 		return msg, errors.New("the context expired")
 	default:
 	}
 
+	return awaitResponse(ctx, msg, r.QueryChan(ctx, msg))
+}
+
+// awaitResponse waits for the response to the message on the channel until the context expires.
+func awaitResponse(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) (*dns.Msg, error) {
 	var err error
-	resp := <-r.QueryChan(ctx, msg)
+	var resp *dns.Msg
+	select {
+	case resp = <-ch:
+	case <-ctx.Done():
+		// the pool can still set the rcode of the message, so the caller receives a new one
+		resp = new(dns.Msg)
+		resp.Id = msg.Id
+		resp.Question = append([]dns.Question(nil), msg.Question...)
+		resp.Rcode = RcodeNoResponse
+		return resp, errors.New("the context expired")
+	}
 	if resp == nil {
 		err = errors.New("query failed")
+	} else if resp.Rcode == RcodeInvalidQuery {
+		err = ValidateQuery(resp)
+	} else if resp.Rcode == RcodeOverloaded {
+		err = ErrOverloaded
 	}
 	return resp, err
 }
 
-func (r *Resolvers) enforceMaxQPS() {
+func (r *Resolvers) enforceMaxQPS(stop chan struct{}) {
 loop:
 	for {
 		select {
 		case <-r.done:
 			break loop
+		case <-stop:
+			return
+		case <-r.leases.Signal():
 		case <-r.queue.Signal():
-			element, found := r.queue.Next()
-			if !found {
-				continue loop
+		}
+		// the queued requests remain intact while the pool is paused
+		if ch := r.pauseChan(); ch != nil {
+			select {
+			case <-r.done:
+				break loop
+			case <-stop:
+				return
+			case <-ch:
 			}
+		}
 
-			if r.rate != nil {
-				_ = r.rate.Take()
-			}
+		// the leased requests are not held behind the backlog of the shared queue
+		element, found := r.leases.Next()
+		if !found {
+			element, found = r.queue.Next()
+		}
+		if !found {
+			continue loop
+		}
 
-			if req, ok := element.(*request); ok {
-				if res := r.pool.GetResolver(); res != nil {
-					req.Res = res
-					res.queue.Append(req)
-				} else {
-					req.errNoResponse()
-					req.release()
-				}
-			}
+		req, ok := r.dequeued(element)
+		if !ok {
+			continue loop
 		}
-	}
-	// release the requests remaining on the queue
-	r.queue.Process(func(element interface{}) {
-		if req, ok := element.(request); ok {
+		// requests for callers that have given up do not consume the rate limit
+		if req.expired() {
 			req.errNoResponse()
 			req.release()
+			continue loop
 		}
-	})
+
+		// leased requests were already rate limited by the lease
+		if rate := r.getRateLimiter(); rate != nil && !req.Leased {
+			_ = rate.Take()
+		}
+		r.recordWait(req)
+
+		if res := r.selectResolver(req); res != nil {
+			req.Res = res
+			res.queue.AppendPriority(req, req.Priority)
+		} else {
+			req.errNoResponse()
+			req.release()
+		}
+	}
+	// release the requests remaining on the queues
+	for _, q := range []queue.Queue{r.leases, r.queue} {
+		q.Process(func(element interface{}) {
+			if req, ok := r.dequeued(element); ok {
+				req.errNoResponse()
+				req.release()
+			}
+		})
+	}
 }
 
-func (r *Resolvers) processResponses() {
+func (r *Resolvers) processResponses(stop chan struct{}) {
 	for {
 		select {
 		case <-r.done:
 			return
+		case <-stop:
+			return
 		case <-r.resps.Signal():
 		}
 
@@ -345,54 +655,101 @@ func (r *Resolvers) processSingleResp(response *resp) {
 	if res == nil {
 		return
 	}
+	res.applyLabels()
 
 	msg := response.Msg
+	var req *request
+	if len(msg.Question) == 0 {
+		// the broken server did not echo the question, so the ID alone must match a single request
+		if req = res.xchgs.removeByID(msg.Id); req == nil {
+			return
+		}
+		msg.Question = append([]dns.Question(nil), req.Msg.Question...)
+	}
+
 	name := msg.Question[0].Name
-	if req := res.xchgs.remove(msg.Id, name); req != nil {
-		req.Resp = msg
-		if req.Resp.Truncated {
-			go req.Res.tcpExchange(req)
-		} else {
-			req.Result <- req.Resp
-			req.Res.collectStats(req.Resp)
-			if r.servRates != nil {
-				r.servRates.Success(name)
-			}
-			req.release()
+	it := r.getInjectionTracker()
+	if req == nil {
+		req = res.xchgs.remove(msg.Id, name)
+	}
+	if req == nil {
+		if it != nil && it.checkUnmatched(res, msg) {
+			res.recordInjection()
+			r.log.Printf("Possible injected response: Resolver %s: %s", res.address, name)
 		}
+		return
 	}
-}
 
-func (r *Resolvers) timeouts() {
-	r.Lock()
-	d := r.timeout / 2
-	r.Unlock()
+	if it != nil && it.checkResponse(res, req, msg) {
+		res.recordInjection()
+		r.logf(req.context(), "Possible injected response: Resolver %s: %s", res.address, name)
+	}
+	rtt := r.clock.Now().Sub(req.Timestamp)
+	res.recordRTT(rtt)
+	res.recordSize(response.Size, msg.Truncated)
+	res.observeTruncation(msg.Truncated)
+	req.Meta.update(func(meta *Response) {
+		meta.RTT = rtt
+		meta.Raw = response.Raw
+		meta.ParseError = response.Err
+	})
 
-	t := time.NewTicker(d)
-	defer t.Stop()
+	req.Resp = msg
+	msg.Id = req.Msg.Id
+	if err := runHook(req.context(), &r.postReceive, msg); err != nil {
+		req.errNoResponseStats(res)
+		req.release()
+	} else if req.Resp.Truncated {
+		go req.Res.tcpExchange(req)
+	} else {
+		r.checkBlocked(req, req.Resp)
+		r.checkAddresses(req, req.Resp)
+		r.checkSinkholes(req, req.Resp)
+		r.applyPolicy(req, req.Resp)
+		r.rewriteTTLs(req.Resp)
+		req.recordOutcome(req.Resp)
+		// the response belongs to the caller once it has been sent
+		req.Res.collectStats(req.Resp)
+		deliver(req.context(), req.Result, req.Resp)
+		if r.servRates != nil {
+			r.servRates.Success(name)
+		}
+		req.release()
+	}
+}
+
+func (r *Resolvers) timeouts(stop chan struct{}) {
+	t := r.expiry
 
-	for range t.C {
+	for {
 		select {
 		case <-r.done:
+			t.Stop()
 			return
-		default:
+		case <-stop:
+			return
+		case <-t.C():
 		}
+		t.Reset(r.timeoutCheckInterval())
 
 		all := r.pool.AllResolvers()
 		if d := r.getDetectionResolver(); d != nil {
 			all = append(all, d)
 		}
 
+		if it := r.getInjectionTracker(); it != nil {
+			it.prune()
+		}
 		for _, res := range all {
 			select {
 			case <-r.done:
 				return
 			default:
 				for _, req := range res.xchgs.removeExpired() {
-					req.errNoResponse()
-					res.collectStats(req.Msg)
+					name := req.Msg.Question[0].Name
+					req.errNoResponseStats(res)
 					if r.servRates != nil {
-						r.servRates.Timeout(req.Msg.Question[0].Name)
+						r.servRates.Timeout(name)
 					}
 					req.release()
 				}
@@ -401,11 +758,13 @@ func (r *Resolvers) timeouts() {
 	}
 }
 
-func (r *resolver) processRequests() {
+func (r *resolver) processRequests(stop chan struct{}) {
 	for {
 		select {
 		case <-r.done:
 			return
+		case <-stop:
+			return
 		case <-r.queue.Signal():
 		}
 
@@ -419,10 +778,39 @@ func (r *resolver) processRequests() {
 }
 
 func (r *resolver) writeReq(req *request) {
+	r.pool.wake()
 	msg := req.Msg.Copy()
-	req.Timestamp = time.Now()
+	req.Timestamp = r.pool.clock.Now()
+
+	if r.pool.nsid.Load() {
+		AddNSIDOption(msg)
+	}
+	r.clampPayloadSize(msg)
+	if err := runHook(req.context(), &r.pool.preSend, msg); err != nil {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+	if r.pool.privacy.Load() {
+		RemoveClientSubnet(msg)
+		PadMsg(msg)
+	}
+	if cfg := r.pool.tor.Load(); cfg != nil {
+		go r.torExchange(req, msg, cfg)
+		return
+	}
+	if r.useTLS() {
+		req.recordAttempt(r, TransportTLS)
+		r.tlsExchange(req, msg)
+		return
+	}
+	if r.preferTCP() {
+		r.preferredTCPExchange(req, msg)
+		return
+	}
+	req.recordAttempt(r, TransportUDP)
 
-	if r.xchgs.add(req) == nil {
+	if r.xchgs.addWithID(req, msg.Id) == nil {
 		if err := r.pool.conns.WriteMsg(msg, r.address); err != nil {
 			_ = r.xchgs.remove(msg.Id, msg.Question[0].Name)
 			req.errNoResponse()
@@ -432,13 +820,50 @@ func (r *resolver) writeReq(req *request) {
 }
 
 func (r *resolver) tcpExchange(req *request) {
+	msg := req.Msg.Copy()
+	if err := runHook(req.context(), &r.pool.preSend, msg); err != nil {
+		req.errNoResponse()
+		req.release()
+		return
+	}
+
+	m, rtt, err := r.tcpSend(req, msg)
+	r.recordTCPFallback(m)
+	if err == nil && !req.Timestamp.IsZero() {
+		r.recordFallbackRTT(r.pool.clock.Now().Sub(req.Timestamp))
+	}
+	r.finishExchange(req, m, rtt, err)
+}
+
+// tcpSend exchanges the prepared message with the resolver over TCP.
+func (r *resolver) tcpSend(req *request, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	timeout := r.xchgs.getQtypeTimeout(req.Msg.Question[0].Qtype)
 	client := dns.Client{
 		Net:     "tcp",
-		Timeout: time.Minute,
+		Timeout: timeout,
+		Dialer:  r.pool.conns.dialer(timeout),
+	}
+
+	req.recordAttempt(r, TransportTCP)
+	return client.ExchangeContext(req.context(), msg, r.address.String())
+}
+
+// finishExchange delivers the response obtained over a dedicated connection to the request.
+func (r *resolver) finishExchange(req *request, m *dns.Msg, rtt time.Duration, err error) {
+	req.Meta.update(func(meta *Response) { meta.RTT = rtt })
+	if err == nil {
+		m.Id = req.Msg.Id
+		err = runHook(req.context(), &r.pool.postReceive, m)
 	}
-	if m, _, err := client.Exchange(req.Msg, r.address.String()); err == nil {
-		req.Result <- m
+	if err == nil {
+		r.pool.checkBlocked(req, m)
+		r.pool.checkAddresses(req, m)
+		r.pool.checkSinkholes(req, m)
+		r.pool.applyPolicy(req, m)
+		r.pool.rewriteTTLs(m)
+		req.recordOutcome(m)
 		r.collectStats(m)
+		deliver(req.context(), req.Result, m)
 	} else {
 		req.errNoResponse()
 	}
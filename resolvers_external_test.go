@@ -0,0 +1,24 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve_test
+
+import (
+	"testing"
+
+	"github.com/attackercan/resolve"
+)
+
+func TestOpenResolversNilClock(t *testing.T) {
+	r, err := resolve.OpenResolvers(nil)
+	if err != nil {
+		t.Fatalf("failed to open the sockets of the pool: %v", err)
+	}
+	defer r.Stop()
+
+	if err := r.AddResolvers(10, "192.168.1.1"); err != nil {
+		t.Errorf("failed to add a resolver to the pool: %v", err)
+	}
+	r.SetMaxQPS(10)
+}
@@ -27,6 +27,43 @@ func TestInitializeResolver(t *testing.T) {
 	}
 }
 
+func TestOpenResolvers(t *testing.T) {
+	r, err := OpenResolvers(realClock{})
+	if err != nil {
+		t.Fatalf("failed to open the pool: %v", err)
+	}
+	defer r.Stop()
+
+	// a pool without sockets fails the queries instead of panicking
+	r.conns.Close()
+	r.conns = nil
+	_ = r.AddResolvers(10, "192.168.1.1")
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Rcode != RcodeNoResponse {
+		t.Errorf("the query was not failed without the sockets")
+	}
+	if stats := r.ConnectionStats(); stats == nil || stats.WriteErrors != 0 {
+		t.Errorf("the connection stats were not returned without the sockets")
+	}
+}
+
+func TestPoolResolver(t *testing.T) {
+	r := NewResolvers()
+
+	var pool PoolResolver = r
+	if err := pool.AddResolvers(10, "192.168.1.1"); err != nil || r.Len() != 1 {
+		t.Errorf("the resolver was not added through the interface")
+	}
+	pool.Stop()
+
+	select {
+	case <-r.done:
+	default:
+		t.Errorf("the pool was not stopped through the interface")
+	}
+}
+
 func TestSetTimeout(t *testing.T) {
 	r := NewResolvers()
 	_ = r.AddResolvers(1000, "8.8.8.8")
@@ -507,3 +544,46 @@ func RunLocalServer(pc net.PacketConn, l net.Listener, opts ...func(*dns.Server)
 	waitLock.Lock()
 	return server, addr, fin, nil
 }
+
+func TestTCPExchangeContext(t *testing.T) {
+	// accept TCP connections without ever responding
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to run the test listener: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	r := NewResolvers()
+	r.SetTimeout(10 * time.Second)
+	_ = r.AddResolvers(10, l.Addr().String())
+	defer r.Stop()
+	res := r.pool.GetResolver()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan *dns.Msg, 1)
+	start := time.Now()
+	res.tcpExchange(&request{
+		Ctx:    ctx,
+		Res:    res,
+		Msg:    QueryMsg("caffix.net", 1),
+		Result: ch,
+	})
+
+	if resp := <-ch; resp.Rcode != RcodeNoResponse {
+		t.Errorf("the TCP exchange did not fail after the context expired")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the TCP exchange ignored the expired context and took %s", elapsed)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type retryCtxKey struct{}
+
+// RetryBudget limits the number of retries to a percentage of the requests sent, preventing
+// retry storms from amplifying the load on resolvers that have started to degrade.
+type RetryBudget struct {
+	sync.Mutex
+	ratio  float64
+	tokens float64
+	max    float64
+}
+
+// NewRetryBudget returns a RetryBudget allowing retries up to the provided ratio of requests.
+// The budget never holds more than maxTokens, which is available as a burst when it is full.
+func NewRetryBudget(ratio float64, maxTokens int) *RetryBudget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	if maxTokens < 1 {
+		maxTokens = 1
+	}
+
+	return &RetryBudget{
+		ratio:  ratio,
+		tokens: float64(maxTokens),
+		max:    float64(maxTokens),
+	}
+}
+
+// Request deposits the retry ratio into the budget for a request that was sent.
+func (b *RetryBudget) Request() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.tokens += b.ratio; b.tokens > b.max {
+		b.tokens = b.max
+	}
+}
+
+// Retry returns true and withdraws from the budget when a retry is permitted.
+func (b *RetryBudget) Retry() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// SetRetryBudget assigns a budget shared by all the retries performed using the pool.
+func (r *Resolvers) SetRetryBudget(b *RetryBudget) {
+	r.budget.Store(b)
+}
+
+// WithRetry returns a context that marks the queries sent with it as retries, so they do
+// not deposit into the retry budget of the pool.
+func WithRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryCtxKey{}, true)
+}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryCtxKey{}).(bool)
+	return retry
+}
+
+// RetryAllowed returns true when the retry budget of the pool permits another attempt.
+// Queries sent through the pool deposit into the budget, unless marked by WithRetry, and
+// each permitted retry withdraws.
+func (r *Resolvers) RetryAllowed() bool {
+	if b := r.budget.Load(); b != nil {
+		return b.Retry()
+	}
+	return true
+}
+
+func (r *Resolvers) budgetRequest() {
+	if b := r.budget.Load(); b != nil {
+		b.Request()
+	}
+}
+
+// RetryAttempt describes a failed attempt provided to a RetryPolicy.
+type RetryAttempt struct {
+	// Attempt is the number of attempts made, beginning with one
+	Attempt  int
+	Priority int
+	Msg      *dns.Msg
+	// Err is set when the pool failed to obtain a response message
+	Err error
+	// ParseError is set when the response was malformed and only partially parsed
+	ParseError error
+	Resolver   string
+	Transport  string
+	RTT        time.Duration
+	// Elapsed is the time since the first attempt was sent
+	Elapsed time.Duration
+}
+
+// RetryPolicy returns true when the query should be attempted again.
+type RetryPolicy func(a *RetryAttempt) bool
+
+// MaxAttemptsPolicy returns a RetryPolicy that attempts the query up to max times, without
+// retrying the responses that failed to parse since another attempt is unlikely to differ.
+func MaxAttemptsPolicy(max int) RetryPolicy {
+	return func(a *RetryAttempt) bool {
+		return a.ParseError == nil && a.Attempt < max
+	}
+}
+
+// QueryWithRetries sends the query and provides each failed attempt to the policy, which
+// decides whether another attempt is sent. A response is considered failed when the pool did
+// not obtain a response or the rcode is not NOERROR or NXDOMAIN. The retries are marked by
+// WithRetry and also require the permission of the retry budget of the pool.
+func (r *Resolvers) QueryWithRetries(ctx context.Context, msg *dns.Msg, policy RetryPolicy) (*Response, error) {
+	if err := ValidateQuery(msg); err != nil {
+		return &Response{Msg: msg}, err
+	}
+
+	start := time.Now()
+	priority := r.queryPriority(ctx, msg.Question[0].Name)
+
+	for i := 1; ; i++ {
+		qctx := ctx
+		if i > 1 {
+			qctx = WithRetry(ctx)
+		}
+
+		resp, err := r.QueryWithMetadata(qctx, msg.Copy())
+		if err == nil && resp.Msg != nil &&
+			(resp.Msg.Rcode == dns.RcodeSuccess || resp.Msg.Rcode == dns.RcodeNameError) {
+			return resp, nil
+		}
+
+		a := &RetryAttempt{
+			Attempt:    i,
+			Priority:   priority,
+			Msg:        resp.Msg,
+			Err:        err,
+			ParseError: resp.ParseError,
+			Resolver:   resp.Resolver,
+			Transport:  resp.Transport,
+			RTT:        resp.RTT,
+			Elapsed:    time.Since(start),
+		}
+		if policy == nil || ctx.Err() != nil || !policy(a) || !r.RetryAllowed() {
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := NewRetryBudget(0.1, 5)
+	// the maximum number of tokens should be available immediately
+	for i := 0; i < 5; i++ {
+		if !b.Retry() {
+			t.Errorf("retry %d was not permitted by a full budget", i+1)
+		}
+	}
+	if b.Retry() {
+		t.Errorf("a retry was permitted by an exhausted budget")
+	}
+
+	var retries int
+	for i := 0; i < 100; i++ {
+		b.Request()
+		if b.Retry() {
+			retries++
+		}
+	}
+	if retries > 10 {
+		t.Errorf("%d retries were permitted for 100 requests with a ratio of 0.1", retries)
+	}
+	// the budget should not accumulate beyond the maximum number of tokens
+	for i := 0; i < 1000; i++ {
+		b.Request()
+	}
+	retries = 0
+	for b.Retry() {
+		retries++
+	}
+	if retries != 5 {
+		t.Errorf("the budget permitted a burst of %d retries", retries)
+	}
+}
+
+func TestRetryAllowed(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if !r.RetryAllowed() {
+		t.Errorf("a retry was not permitted by a pool without a budget")
+	}
+
+	r.SetRetryBudget(NewRetryBudget(0, 1))
+	if !r.RetryAllowed() {
+		t.Errorf("the first retry was not permitted by the budget")
+	}
+	if r.RetryAllowed() {
+		t.Errorf("a retry was permitted by an exhausted budget")
+	}
+}
+
+func TestRetriesDoNotDeposit(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	b := NewRetryBudget(1, 1)
+	r.SetRetryBudget(b)
+	if !r.RetryAllowed() {
+		t.Fatalf("the first retry was not permitted by the budget")
+	}
+
+	ch := make(chan *dns.Msg, 2)
+	r.Query(WithRetry(context.Background()), QueryMsg("caffix.net", dns.TypeA), ch)
+	<-ch
+	if r.RetryAllowed() {
+		t.Errorf("a retry deposited into the budget")
+	}
+
+	r.Query(context.Background(), QueryMsg("caffix.net", dns.TypeA), ch)
+	<-ch
+	if !r.RetryAllowed() {
+		t.Errorf("a first attempt did not deposit into the budget")
+	}
+}
+
+func TestQueryWithRetries(t *testing.T) {
+	var queries atomic.Int32
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			if queries.Add(1) < 3 {
+				m := new(dns.Msg)
+				m.SetRcode(req, dns.RcodeServerFailure)
+				_ = w.WriteMsg(m)
+				return
+			}
+			typeAHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	var attempts []*RetryAttempt
+	policy := func(a *RetryAttempt) bool {
+		attempts = append(attempts, a)
+		return MaxAttemptsPolicy(5)(a)
+	}
+
+	resp, err := r.QueryWithRetries(context.Background(), QueryMsg("caffix.net", dns.TypeA), policy)
+	if err != nil || resp.Msg.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the query did not succeed after the retries: %v", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("the policy received %d attempts instead of two", len(attempts))
+	}
+
+	a := attempts[1]
+	if a.Attempt != 2 || a.Msg.Rcode != dns.RcodeServerFailure || a.Err != nil {
+		t.Errorf("the attempt did not describe the failure: %+v", a)
+	}
+	if a.Resolver != addr || a.Transport != TransportUDP || a.Elapsed < attempts[0].Elapsed {
+		t.Errorf("the attempt did not include the exchange details: %+v", a)
+	}
+
+	// the budget can deny the retries permitted by the policy
+	queries.Store(0)
+	r.SetRetryBudget(NewRetryBudget(0, 1))
+	_ = r.RetryAllowed()
+	resp, _ = r.QueryWithRetries(context.Background(), QueryMsg("caffix.net", dns.TypeA), MaxAttemptsPolicy(5))
+	if resp.Msg.Rcode != dns.RcodeServerFailure || queries.Load() != 1 {
+		t.Errorf("the retry was sent without the permission of the budget")
+	}
+}
+
+func TestMaxAttemptsPolicy(t *testing.T) {
+	policy := MaxAttemptsPolicy(2)
+
+	if !policy(&RetryAttempt{Attempt: 1}) {
+		t.Errorf("the first attempt was not retried")
+	}
+	if policy(&RetryAttempt{Attempt: 2}) {
+		t.Errorf("the query was retried beyond the maximum attempts")
+	}
+	if policy(&RetryAttempt{Attempt: 1, ParseError: dns.ErrRdata}) {
+		t.Errorf("the malformed response was retried")
+	}
+}
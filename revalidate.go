@@ -0,0 +1,112 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/miekg/dns"
+)
+
+// ErrNotVerified is returned by the Revalidator when the trusted resolvers did not confirm
+// the records obtained through the bulk pool.
+var ErrNotVerified = errors.New("the answer was not verified by the trusted resolvers")
+
+// RevalidationStats contains the counters maintained by the Revalidator.
+type RevalidationStats struct {
+	Verified   uint64
+	Rejected   uint64
+	CacheHits  uint64
+	Mismatches uint64
+}
+
+// Revalidator resolves names using the untrusted bulk pool and re-verifies the positive
+// answers against the trusted resolvers before returning them. The trusted responses are
+// cached for the TTL, so popular names do not cause repeated load on the trusted resolvers.
+type Revalidator struct {
+	pool       *Resolvers
+	trusted    *Cache
+	verified   atomic.Uint64
+	rejected   atomic.Uint64
+	hits       atomic.Uint64
+	mismatches atomic.Uint64
+}
+
+// NewRevalidator returns a Revalidator that sends the queries to the pool and verifies the
+// answers using the trusted resolvers, such as a sub-pool obtained from AddSubPool.
+func NewRevalidator(pool, trusted *Resolvers) *Revalidator {
+	return &Revalidator{
+		pool:    pool,
+		trusted: NewCache(trusted),
+	}
+}
+
+// Stop will release the Revalidator resources. The pools are not stopped.
+func (v *Revalidator) Stop() {
+	v.trusted.Stop()
+}
+
+// Stats returns the current counters of the Revalidator.
+func (v *Revalidator) Stats() RevalidationStats {
+	return RevalidationStats{
+		Verified:   v.verified.Load(),
+		Rejected:   v.rejected.Load(),
+		CacheHits:  v.hits.Load(),
+		Mismatches: v.mismatches.Load(),
+	}
+}
+
+// Lookup resolves the name using the pool. Responses without answers are returned as is,
+// while the answers are replaced with the response from the trusted resolvers. ErrNotVerified
+// is returned along with the trusted response when it does not contain records of the type.
+func (v *Revalidator) Lookup(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	resp, _, err := v.LookupWithProvenance(ctx, name, qtype)
+	return resp, err
+}
+
+// LookupWithProvenance performs the Lookup and also returns the provenance of the response,
+// which records the resolvers that contributed to the result and whether they agreed.
+func (v *Revalidator) LookupWithProvenance(ctx context.Context, name string, qtype uint16) (*dns.Msg, *Provenance, error) {
+	prov := newProvenance(name, qtype)
+
+	bulk, err := v.pool.QueryWithMetadata(ctx, QueryMsg(name, qtype))
+	resp := bulk.Msg
+	if err != nil {
+		return resp, prov, err
+	}
+	if resp.Rcode == RcodeNoResponse {
+		return resp, prov, errors.New("the query failed to obtain a response")
+	}
+	prov.add(StageBulk, bulk)
+	if resp.Rcode != dns.RcodeSuccess || !hasAnswerType(resp, qtype) {
+		prov.finalize(resp, false)
+		return resp, prov, nil
+	}
+
+	trusted, err := v.trusted.QueryWithMetadata(ctx, QueryMsg(name, qtype))
+	tresp := trusted.Msg
+	if err != nil {
+		return tresp, prov, err
+	}
+	prov.add(StageTrusted, trusted)
+	if trusted.CacheHit {
+		v.hits.Add(1)
+	}
+
+	if tresp.Rcode != dns.RcodeSuccess || !hasAnswerType(tresp, qtype) {
+		v.rejected.Add(1)
+		prov.finalize(tresp, false)
+		return tresp, prov, ErrNotVerified
+	}
+
+	v.verified.Add(1)
+	if AnswerHash(resp) != AnswerHash(tresp) {
+		v.mismatches.Add(1)
+	}
+	prov.finalize(tresp, true)
+	return tresp, prov, nil
+}
@@ -0,0 +1,77 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestRevalidator(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	var queries atomic.Int32
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries.Add(1)
+			if req.Question[0].Name == "www.caffix.net." {
+				_ = w.WriteMsg(ttlReply(req, 60))
+				return
+			}
+
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeNameError)
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr1)
+	defer r.Stop()
+
+	trusted, err := r.AddSubPool("trusted", 10, addr2)
+	if err != nil {
+		t.Fatalf("failed to create the sub-pool: %v", err)
+	}
+
+	v := NewRevalidator(r, trusted)
+	defer v.Stop()
+
+	for i := 0; i < 2; i++ {
+		resp, err := v.Lookup(context.Background(), "www.caffix.net", dns.TypeA)
+		if err != nil {
+			t.Fatalf("the lookup failed: %v", err)
+		}
+		if len(resp.Answer) != 1 {
+			t.Errorf("the trusted answer was not returned: %v", resp.Answer)
+		}
+	}
+	if n := queries.Load(); n != 1 {
+		t.Errorf("the trusted resolvers received %d queries instead of one", n)
+	}
+
+	if _, err := v.Lookup(context.Background(), "bad.caffix.net", dns.TypeA); !errors.Is(err, ErrNotVerified) {
+		t.Errorf("the unverified answer was returned: %v", err)
+	}
+
+	stats := v.Stats()
+	if stats.Verified != 2 || stats.Rejected != 1 || stats.CacheHits != 1 || stats.Mismatches != 2 {
+		t.Errorf("the revalidation stats were not correct: %+v", stats)
+	}
+}
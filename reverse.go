@@ -0,0 +1,133 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// maxReverseLookups is the number of ReverseLookup queries waiting on the pool at once.
+const maxReverseLookups = 100
+
+const hexDigits = "0123456789abcdef"
+
+// ReverseResult contains the PTR names found for an address by ReverseLookup.
+type ReverseResult struct {
+	IP    net.IP
+	Names []string
+	Err   error
+}
+
+// ReverseName returns the in-addr.arpa name of the IPv4 address, or the ip6.arpa name in the
+// nibble format of RFC 3596 for the IPv6 address, without the trailing dot.
+func ReverseName(ip net.IP) (string, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ReverseIPv4Name(ip4), nil
+	}
+	if ip6 := ip.To16(); ip6 != nil {
+		return ReverseIPv6Name(ip6), nil
+	}
+	return "", fmt.Errorf("%v is not a valid IP address", ip)
+}
+
+// ReverseIPv4Name returns the in-addr.arpa name of the IPv4 address, without the trailing dot.
+func ReverseIPv4Name(ip net.IP) string {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0])
+}
+
+// ReverseIPv6Name returns the ip6.arpa name of the IPv6 address in the nibble format, without
+// the trailing dot. IPv4 addresses are provided in the IPv4-mapped form.
+func ReverseIPv6Name(ip net.IP) string {
+	ip6 := ip.To16()
+	if ip6 == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := len(ip6) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[ip6[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip6[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String()
+}
+
+// ReverseLookup concurrently queries the PTR records of the addresses and returns a result for
+// each address in the order provided. The results of the addresses not queried before the
+// context expired contain the error of the context.
+func (r *Resolvers) ReverseLookup(ctx context.Context, ips []net.IP) []*ReverseResult {
+	results := make([]*ReverseResult, len(ips))
+	sem := make(chan struct{}, maxReverseLookups)
+
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		results[i] = &ReverseResult{IP: ip}
+
+		name, err := ReverseName(ip)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(result *ReverseResult, name string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			result.Names, result.Err = r.reverseNames(ctx, name)
+		}(results[i], name)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Resolvers) reverseNames(ctx context.Context, name string) ([]string, error) {
+	resp, err := r.QueryBlocking(ctx, QueryMsg(name, dns.TypePTR))
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	case RcodeNoResponse:
+		return nil, fmt.Errorf("lookup %s: the query failed to obtain a response", name)
+	default:
+		return nil, fmt.Errorf("lookup %s: the server returned %s", name, dns.RcodeToString[resp.Rcode])
+	}
+
+	var names []string
+	for _, a := range ExtractAnswers(resp) {
+		if a.Type == dns.TypePTR {
+			names = append(names, strings.ToLower(a.Data))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("lookup %s: %w", name, ErrNoSuchHost)
+	}
+	return names, nil
+}
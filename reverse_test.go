@@ -0,0 +1,83 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestReverseName(t *testing.T) {
+	cases := []struct {
+		ip   string
+		want string
+	}{
+		{"192.168.1.10", "10.1.168.192.in-addr.arpa"},
+		{"::ffff:10.0.0.1", "1.0.0.10.in-addr.arpa"},
+		{"2001:db8::567:89ab", "b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"},
+	}
+
+	for _, c := range cases {
+		name, err := ReverseName(net.ParseIP(c.ip))
+		if err != nil || name != c.want {
+			t.Errorf("the reverse name of %s was %s instead of %s: %v", c.ip, name, c.want, err)
+		}
+		if want, _ := dns.ReverseAddr(c.ip); RemoveLastDot(want) != name {
+			t.Errorf("the reverse name of %s did not match %s", c.ip, want)
+		}
+	}
+	if _, err := ReverseName(net.IP{1, 2}); err == nil {
+		t.Errorf("the invalid address did not return an error")
+	}
+	if name := ReverseIPv6Name(net.ParseIP("10.0.0.1")); name == "" || name[len(name)-8:] != "ip6.arpa" {
+		t.Errorf("the IPv4-mapped address was not converted to the nibble format")
+	}
+}
+
+func TestReverseLookup(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+
+			if q := req.Question[0]; q.Name == "1.1.168.192.in-addr.arpa." {
+				m.Answer = []dns.RR{&dns.PTR{
+					Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypePTR, Class: dns.ClassINET, Ttl: 60},
+					Ptr: "www.caffix.net.",
+				}}
+			} else {
+				m.Rcode = dns.RcodeNameError
+			}
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	ips := []net.IP{net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.2"), nil}
+	results := r.ReverseLookup(context.Background(), ips)
+	if len(results) != len(ips) {
+		t.Fatalf("%d results were returned for %d addresses", len(results), len(ips))
+	}
+	if res := results[0]; res.Err != nil || len(res.Names) != 1 || res.Names[0] != "www.caffix.net" {
+		t.Errorf("the PTR name was not returned: %v %v", res.Names, res.Err)
+	}
+	if res := results[1]; !errors.Is(res.Err, ErrNoSuchHost) {
+		t.Errorf("the missing PTR record did not return ErrNoSuchHost: %v", res.Err)
+	}
+	if res := results[2]; res.Err == nil {
+		t.Errorf("the invalid address did not return an error")
+	}
+}
@@ -0,0 +1,292 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// AddHoldDown is the RFC 5011 hold-down time required before a new key becomes a trust anchor.
+const AddHoldDown = 30 * 24 * time.Hour
+
+const rootHintsData = `
+.                        3600000      NS    A.ROOT-SERVERS.NET.
+A.ROOT-SERVERS.NET.      3600000      A     198.41.0.4
+A.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:ba3e::2:30
+.                        3600000      NS    B.ROOT-SERVERS.NET.
+B.ROOT-SERVERS.NET.      3600000      A     170.247.170.2
+B.ROOT-SERVERS.NET.      3600000      AAAA  2801:1b8:10::b
+.                        3600000      NS    C.ROOT-SERVERS.NET.
+C.ROOT-SERVERS.NET.      3600000      A     192.33.4.12
+C.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2::c
+.                        3600000      NS    D.ROOT-SERVERS.NET.
+D.ROOT-SERVERS.NET.      3600000      A     199.7.91.13
+D.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2d::d
+.                        3600000      NS    E.ROOT-SERVERS.NET.
+E.ROOT-SERVERS.NET.      3600000      A     192.203.230.10
+E.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:a8::e
+.                        3600000      NS    F.ROOT-SERVERS.NET.
+F.ROOT-SERVERS.NET.      3600000      A     192.5.5.241
+F.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:2f::f
+.                        3600000      NS    G.ROOT-SERVERS.NET.
+G.ROOT-SERVERS.NET.      3600000      A     192.112.36.4
+G.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:12::d0d
+.                        3600000      NS    H.ROOT-SERVERS.NET.
+H.ROOT-SERVERS.NET.      3600000      A     198.97.190.53
+H.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:1::53
+.                        3600000      NS    I.ROOT-SERVERS.NET.
+I.ROOT-SERVERS.NET.      3600000      A     192.36.148.17
+I.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fe::53
+.                        3600000      NS    J.ROOT-SERVERS.NET.
+J.ROOT-SERVERS.NET.      3600000      A     192.58.128.30
+J.ROOT-SERVERS.NET.      3600000      AAAA  2001:503:c27::2:30
+.                        3600000      NS    K.ROOT-SERVERS.NET.
+K.ROOT-SERVERS.NET.      3600000      A     193.0.14.129
+K.ROOT-SERVERS.NET.      3600000      AAAA  2001:7fd::1
+.                        3600000      NS    L.ROOT-SERVERS.NET.
+L.ROOT-SERVERS.NET.      3600000      A     199.7.83.42
+L.ROOT-SERVERS.NET.      3600000      AAAA  2001:500:9f::42
+.                        3600000      NS    M.ROOT-SERVERS.NET.
+M.ROOT-SERVERS.NET.      3600000      A     202.12.27.33
+M.ROOT-SERVERS.NET.      3600000      AAAA  2001:dc3::35
+`
+
+const trustAnchorData = `
+. IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D
+. IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16
+`
+
+var roots struct {
+	sync.Mutex
+	hints   []dns.RR
+	anchors []*dns.DS
+	pending map[uint16]time.Time
+}
+
+func init() {
+	if err := LoadRootHints(strings.NewReader(rootHintsData)); err != nil {
+		panic(err)
+	}
+	if err := LoadTrustAnchors(strings.NewReader(trustAnchorData)); err != nil {
+		panic(err)
+	}
+}
+
+// RootHints returns the NS, A and AAAA records currently used to locate the root name servers.
+func RootHints() []dns.RR {
+	roots.Lock()
+	defer roots.Unlock()
+
+	var hints []dns.RR
+	for _, rr := range roots.hints {
+		hints = append(hints, dns.Copy(rr))
+	}
+	return hints
+}
+
+// RootServers returns the IP addresses and port numbers of the root name servers.
+func RootServers() []string {
+	var servers []string
+
+	for _, rr := range RootHints() {
+		var ip net.IP
+
+		switch t := rr.(type) {
+		case *dns.A:
+			ip = t.A
+		case *dns.AAAA:
+			ip = t.AAAA
+		}
+		if ip != nil {
+			servers = append(servers, net.JoinHostPort(ip.String(), "53"))
+		}
+	}
+	return servers
+}
+
+// LoadRootHints replaces the root hints using the zone file data read from the provided Reader.
+func LoadRootHints(r io.Reader) error {
+	var hints []dns.RR
+	var servers int
+
+	zp := dns.NewZoneParser(r, ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch rr.Header().Rrtype {
+		case dns.TypeNS:
+			hints = append(hints, rr)
+		case dns.TypeA, dns.TypeAAAA:
+			servers++
+			hints = append(hints, rr)
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return fmt.Errorf("failed to parse the root hints: %v", err)
+	}
+	if servers == 0 {
+		return errors.New("the root hints did not contain any server addresses")
+	}
+
+	roots.Lock()
+	defer roots.Unlock()
+
+	roots.hints = hints
+	return nil
+}
+
+// TrustAnchors returns the DS records currently trusted for DNSSEC validation of the root zone.
+func TrustAnchors() []*dns.DS {
+	roots.Lock()
+	defer roots.Unlock()
+
+	var anchors []*dns.DS
+	for _, ds := range roots.anchors {
+		anchors = append(anchors, dns.Copy(ds).(*dns.DS))
+	}
+	return anchors
+}
+
+// LoadTrustAnchors replaces the trust anchors using the DS or DNSKEY records read from the provided Reader.
+func LoadTrustAnchors(r io.Reader) error {
+	var anchors []*dns.DS
+
+	zp := dns.NewZoneParser(r, ".", "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		switch t := rr.(type) {
+		case *dns.DS:
+			anchors = append(anchors, t)
+		case *dns.DNSKEY:
+			if ds := t.ToDS(dns.SHA256); ds != nil {
+				anchors = append(anchors, ds)
+			}
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return fmt.Errorf("failed to parse the trust anchors: %v", err)
+	}
+	if len(anchors) == 0 {
+		return errors.New("no DS or DNSKEY records were provided as trust anchors")
+	}
+
+	roots.Lock()
+	defer roots.Unlock()
+
+	roots.anchors = anchors
+	roots.pending = make(map[uint16]time.Time)
+	return nil
+}
+
+// ObserveRootKeys performs RFC 5011 rollover tracking using the root DNSKEY RRset and signatures.
+// The RRset must be signed by a key matching a current trust anchor. New SEP keys become trust
+// anchors once observed for the hold-down time, and keys are removed once they revoke themselves.
+func ObserveRootKeys(keys []*dns.DNSKEY, sigs []*dns.RRSIG, now time.Time) error {
+	roots.Lock()
+	defer roots.Unlock()
+
+	var rrset []dns.RR
+	for _, k := range keys {
+		rrset = append(rrset, k)
+	}
+
+	var verified bool
+	for _, k := range keys {
+		if k.Flags&dns.REVOKE == 0 && anchorMatches(roots.anchors, k) && signedBy(k, rrset, sigs, now) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return errors.New("the root DNSKEY RRset was not signed by a trusted key")
+	}
+
+	anchors := roots.anchors
+	observed := make(map[uint16]struct{})
+	for _, k := range keys {
+		if k.Flags&dns.SEP == 0 {
+			continue
+		}
+
+		if k.Flags&dns.REVOKE != 0 {
+			// the revocation is only valid when the revoked key signed the RRset (RFC 5011 section 2.1)
+			if signedBy(k, rrset, sigs, now) {
+				anchors = removeAnchor(anchors, k)
+			}
+			continue
+		}
+
+		tag := k.KeyTag()
+		if anchorMatches(anchors, k) {
+			continue
+		}
+
+		observed[tag] = struct{}{}
+		first, found := roots.pending[tag]
+		if !found {
+			roots.pending[tag] = now
+		} else if now.Sub(first) >= AddHoldDown {
+			if ds := k.ToDS(dns.SHA256); ds != nil {
+				anchors = append(anchors, ds)
+			}
+			delete(roots.pending, tag)
+		}
+	}
+	if len(anchors) == 0 {
+		return errors.New("the update would remove all the trust anchors")
+	}
+	// keys that disappear during the hold-down time must start over (RFC 5011 section 2.4.1)
+	for tag := range roots.pending {
+		if _, found := observed[tag]; !found {
+			delete(roots.pending, tag)
+		}
+	}
+
+	roots.anchors = anchors
+	return nil
+}
+
+func signedBy(key *dns.DNSKEY, rrset []dns.RR, sigs []*dns.RRSIG, now time.Time) bool {
+	tag := key.KeyTag()
+
+	for _, sig := range sigs {
+		if sig.KeyTag == tag && sig.Algorithm == key.Algorithm &&
+			sig.ValidityPeriod(now) && sig.Verify(key, rrset) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func anchorMatches(anchors []*dns.DS, key *dns.DNSKEY) bool {
+	for _, ds := range anchors {
+		if ds.KeyTag != key.KeyTag() || ds.Algorithm != key.Algorithm {
+			continue
+		}
+		if kds := key.ToDS(ds.DigestType); kds != nil && strings.EqualFold(kds.Digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeAnchor(anchors []*dns.DS, key *dns.DNSKEY) []*dns.DS {
+	// the revoke bit changes the key tag, so the key is compared without it
+	k := dns.Copy(key).(*dns.DNSKEY)
+	k.Flags &^= dns.REVOKE
+
+	var remaining []*dns.DS
+	for _, ds := range anchors {
+		if !anchorMatches([]*dns.DS{ds}, k) {
+			remaining = append(remaining, ds)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,165 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"crypto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestRootHints(t *testing.T) {
+	if num := len(AnswersByType(extractRRs(RootHints()), dns.TypeNS)); num != 13 {
+		t.Errorf("the embedded root hints contained %d NS records", num)
+	}
+	if num := len(RootServers()); num != 26 {
+		t.Errorf("the embedded root hints contained %d server addresses", num)
+	}
+	if err := LoadRootHints(strings.NewReader(". 3600000 NS A.ROOT-SERVERS.NET.")); err == nil {
+		t.Errorf("root hints without server addresses were accepted")
+	}
+	defer func() { _ = LoadRootHints(strings.NewReader(rootHintsData)) }()
+
+	hints := ". 3600000 NS A.ROOT-SERVERS.NET.\nA.ROOT-SERVERS.NET. 3600000 A 198.41.0.4"
+	if err := LoadRootHints(strings.NewReader(hints)); err != nil {
+		t.Errorf("failed to load the root hints: %v", err)
+	}
+	if servers := RootServers(); len(servers) != 1 || servers[0] != "198.41.0.4:53" {
+		t.Errorf("the loaded root hints returned the servers %v", servers)
+	}
+}
+
+func TestTrustAnchors(t *testing.T) {
+	if num := len(TrustAnchors()); num != 2 {
+		t.Errorf("the embedded trust anchors contained %d DS records", num)
+	}
+	if err := LoadTrustAnchors(strings.NewReader("")); err == nil {
+		t.Errorf("an empty set of trust anchors was accepted")
+	}
+}
+
+func TestObserveRootKeys(t *testing.T) {
+	defer func() { _ = LoadTrustAnchors(strings.NewReader(trustAnchorData)) }()
+
+	current, cpriv := generateRootKey(t)
+	if err := LoadTrustAnchors(strings.NewReader(current.String())); err != nil {
+		t.Fatalf("failed to load the trust anchor: %v", err)
+	}
+
+	next, _ := generateRootKey(t)
+	keys := []*dns.DNSKEY{current, next}
+	now := time.Now()
+	sig := signRootKeys(t, keys, current, cpriv, now)
+
+	if err := ObserveRootKeys(keys, nil, now); err == nil {
+		t.Errorf("an unsigned DNSKEY RRset was accepted")
+	}
+	if err := ObserveRootKeys(keys, []*dns.RRSIG{sig}, now); err != nil || len(TrustAnchors()) != 1 {
+		t.Errorf("the new key was added as a trust anchor before the hold-down time: %v", err)
+	}
+	if err := ObserveRootKeys(keys, []*dns.RRSIG{sig}, now.Add(AddHoldDown)); err != nil || len(TrustAnchors()) != 2 {
+		t.Errorf("the new key was not added as a trust anchor after the hold-down time: %v", err)
+	}
+
+	// the new key must start the hold-down time over after disappearing from the RRset
+	other, opriv := generateRootKey(t)
+	keys = []*dns.DNSKEY{current, next, other}
+	_ = ObserveRootKeys(keys, []*dns.RRSIG{signRootKeys(t, keys, current, cpriv, now)}, now)
+	keys = []*dns.DNSKEY{current, next}
+	_ = ObserveRootKeys(keys, []*dns.RRSIG{signRootKeys(t, keys, current, cpriv, now)}, now)
+	keys = []*dns.DNSKEY{current, next, other}
+	later := now.Add(AddHoldDown)
+	_ = ObserveRootKeys(keys, []*dns.RRSIG{signRootKeys(t, keys, current, cpriv, later)}, later)
+	if anchorMatches(TrustAnchors(), other) {
+		t.Errorf("the key was added as a trust anchor after missing from the RRset during the hold-down time")
+	}
+
+	revoked := dns.Copy(current).(*dns.DNSKEY)
+	revoked.Flags |= dns.REVOKE
+	keys = []*dns.DNSKEY{revoked, next}
+	// the replacement key is already trusted, so a signature from an unrelated key must fail
+	if err := ObserveRootKeys(keys, []*dns.RRSIG{signRootKeys(t, keys, next, opriv, now)}, now); err == nil {
+		t.Errorf("a DNSKEY RRset signed with the wrong private key was accepted")
+	}
+}
+
+func TestObserveRootKeysRevocation(t *testing.T) {
+	defer func() { _ = LoadTrustAnchors(strings.NewReader(trustAnchorData)) }()
+
+	current, cpriv := generateRootKey(t)
+	next, npriv := generateRootKey(t)
+	if err := LoadTrustAnchors(strings.NewReader(current.String() + "\n" + next.String())); err != nil {
+		t.Fatalf("failed to load the trust anchors: %v", err)
+	}
+
+	revoked := dns.Copy(current).(*dns.DNSKEY)
+	revoked.Flags |= dns.REVOKE
+	keys := []*dns.DNSKEY{revoked, next}
+	now := time.Now()
+
+	// a revocation that is not signed by the revoked key must be ignored
+	if err := ObserveRootKeys(keys, []*dns.RRSIG{signRootKeys(t, keys, next, npriv, now)}, now); err != nil {
+		t.Fatalf("failed to observe the DNSKEY RRset: %v", err)
+	}
+	if !anchorMatches(TrustAnchors(), current) {
+		t.Errorf("the trust anchor was removed without a valid revocation")
+	}
+
+	sigs := []*dns.RRSIG{
+		signRootKeys(t, keys, next, npriv, now),
+		signRootKeys(t, keys, revoked, cpriv, now),
+	}
+	if err := ObserveRootKeys(keys, sigs, now); err != nil {
+		t.Fatalf("failed to observe the revocation: %v", err)
+	}
+	if anchorMatches(TrustAnchors(), current) {
+		t.Errorf("the revoked key was not removed from the trust anchors")
+	}
+	if !anchorMatches(TrustAnchors(), next) || len(TrustAnchors()) != 1 {
+		t.Errorf("the remaining trust anchor was not retained")
+	}
+}
+
+func generateRootKey(t *testing.T) (*dns.DNSKEY, crypto.Signer) {
+	key := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: ".", Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 172800},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+
+	priv, err := key.Generate(256)
+	if err != nil {
+		t.Fatalf("failed to generate the DNSKEY: %v", err)
+	}
+	return key, priv.(crypto.Signer)
+}
+
+func signRootKeys(t *testing.T, keys []*dns.DNSKEY, signer *dns.DNSKEY, priv crypto.Signer, now time.Time) *dns.RRSIG {
+	var rrset []dns.RR
+	for _, k := range keys {
+		rrset = append(rrset, k)
+	}
+
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Name: ".", Rrtype: dns.TypeRRSIG, Class: dns.ClassINET, Ttl: 172800},
+		Algorithm:  signer.Algorithm,
+		Expiration: uint32(now.Add(2 * AddHoldDown).Unix()),
+		Inception:  uint32(now.Add(-time.Hour).Unix()),
+		KeyTag:     signer.KeyTag(),
+		SignerName: ".",
+	}
+	if err := sig.Sign(priv, rrset); err != nil {
+		t.Fatalf("failed to sign the DNSKEY RRset: %v", err)
+	}
+	return sig
+}
+
+func extractRRs(rrs []dns.RR) []*ExtractedAnswer {
+	return ExtractAnswers(&dns.Msg{Answer: rrs})
+}
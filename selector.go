@@ -13,6 +13,9 @@ type selector interface {
 	// GetResolver returns a resolver managed by the selector.
 	GetResolver() *resolver
 
+	// GetMatchingResolver returns a resolver managed by the selector that is accepted by the match function.
+	GetMatchingResolver(match func(res *resolver) bool) *resolver
+
 	// LookupResolver returns the resolver with the matching address.
 	LookupResolver(addr string) *resolver
 
@@ -41,8 +44,13 @@ func newRandomSelector() *randomSelector {
 
 // GetResolver performs random selection on the pool of resolvers.
 func (r *randomSelector) GetResolver() *resolver {
-	max := r.maxQPS()
-	if max == -1 {
+	return r.GetMatchingResolver(nil)
+}
+
+// GetMatchingResolver performs random selection on the resolvers accepted by the match function.
+func (r *randomSelector) GetMatchingResolver(match func(res *resolver) bool) *resolver {
+	max := r.maxQPS(match)
+	if max <= 0 {
 		return nil
 	}
 	sel := rand.Intn(max)
@@ -52,12 +60,9 @@ func (r *randomSelector) GetResolver() *resolver {
 
 	var cur int
 	var chosen *resolver
-loop:
 	for _, res := range r.list {
-		select {
-		case <-res.done:
-			continue loop
-		default:
+		if !selectable(res, match) {
+			continue
 		}
 
 		cur += res.qps
@@ -69,7 +74,7 @@ loop:
 	return chosen
 }
 
-func (r *randomSelector) maxQPS() int {
+func (r *randomSelector) maxQPS(match func(res *resolver) bool) int {
 	r.Lock()
 	defer r.Unlock()
 
@@ -79,15 +84,26 @@ func (r *randomSelector) maxQPS() int {
 
 	var max int
 	for _, res := range r.list {
-		select {
-		case <-res.done:
-		default:
+		if selectable(res, match) {
 			max += res.qps
 		}
 	}
 	return max
 }
 
+// selectable returns true when the resolver is active, not quarantined and accepted by the match function.
+func selectable(res *resolver, match func(res *resolver) bool) bool {
+	select {
+	case <-res.done:
+		return false
+	default:
+	}
+	if res.quarantined() {
+		return false
+	}
+	return match == nil || match(res)
+}
+
 func (r *randomSelector) LookupResolver(addr string) *resolver {
 	r.Lock()
 	defer r.Unlock()
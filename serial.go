@@ -0,0 +1,201 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// SerialObservation is a SOA serial number observed for a zone.
+type SerialObservation struct {
+	Serial uint32
+	Time   time.Time
+}
+
+// SerialChange describes a change to the SOA serial number of a tracked zone.
+type SerialChange struct {
+	Zone     string
+	Previous uint32
+	Serial   uint32
+	// Rollback is true when the new serial is older according to RFC 1982 serial arithmetic
+	Rollback bool
+	Time     time.Time
+}
+
+// SerialTracker queries the SOA records of a set of zones on an interval and emits a
+// SerialChange each time the serial number differs from the previous observation, which
+// detects zone changes without the need for zone transfers.
+type SerialTracker struct {
+	sync.Mutex
+	done     chan struct{}
+	pool     *Resolvers
+	interval time.Duration
+	zones    map[string][]SerialObservation
+	events   chan *SerialChange
+}
+
+// NewSerialTracker returns an active SerialTracker that uses the provided pool to query the zones.
+func NewSerialTracker(pool *Resolvers, interval time.Duration) *SerialTracker {
+	s := &SerialTracker{
+		done:     make(chan struct{}, 1),
+		pool:     pool,
+		interval: interval,
+		zones:    make(map[string][]SerialObservation),
+		events:   make(chan *SerialChange, monitorEventsBuffer),
+	}
+
+	go s.checks()
+	return s
+}
+
+// Stop will release the SerialTracker resources.
+func (s *SerialTracker) Stop() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
+
+// Changes returns the channel that receives the serial change notifications.
+func (s *SerialTracker) Changes() <-chan *SerialChange {
+	return s.events
+}
+
+// TrackSerial starts tracking the SOA serial number of the zone. The first observation
+// establishes the serial, so changes are reported beginning with the second interval.
+func (s *SerialTracker) TrackSerial(zone string) {
+	s.Lock()
+	defer s.Unlock()
+
+	zone = strings.ToLower(RemoveLastDot(zone))
+	if _, found := s.zones[zone]; !found {
+		s.zones[zone] = nil
+	}
+}
+
+// Untrack stops tracking the SOA serial number of the zone and discards the history.
+func (s *SerialTracker) Untrack(zone string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.zones, strings.ToLower(RemoveLastDot(zone)))
+}
+
+// History returns the distinct serial numbers observed for the zone in the order observed.
+func (s *SerialTracker) History(zone string) []SerialObservation {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]SerialObservation(nil), s.zones[strings.ToLower(RemoveLastDot(zone))]...)
+}
+
+func (s *SerialTracker) checks() {
+	t := time.NewTicker(s.interval)
+	defer t.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.done
+		cancel()
+	}()
+
+	for {
+		s.check(ctx)
+
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (s *SerialTracker) check(ctx context.Context) {
+	s.Lock()
+	var zones []string
+	for zone := range s.zones {
+		zones = append(zones, zone)
+	}
+	s.Unlock()
+
+	var wg sync.WaitGroup
+	for _, zone := range zones {
+		wg.Add(1)
+
+		go func(zone string) {
+			defer wg.Done()
+
+			resp, err := s.pool.QueryBlocking(ctx, QueryMsg(zone, dns.TypeSOA))
+			if err != nil || resp.Rcode != dns.RcodeSuccess {
+				// failed queries do not reveal a change to the zone
+				return
+			}
+
+			serial, found := zoneSerial(resp, zone)
+			if !found {
+				return
+			}
+			if ev := s.update(zone, serial); ev != nil {
+				select {
+				case <-s.done:
+				case s.events <- ev:
+				}
+			}
+		}(zone)
+	}
+	wg.Wait()
+}
+
+func (s *SerialTracker) update(zone string, serial uint32) *SerialChange {
+	s.Lock()
+	defer s.Unlock()
+
+	history, found := s.zones[zone]
+	if !found {
+		return nil
+	}
+
+	now := time.Now()
+	obs := SerialObservation{Serial: serial, Time: now}
+	if len(history) == 0 {
+		s.zones[zone] = append(history, obs)
+		return nil
+	}
+
+	prev := history[len(history)-1].Serial
+	if prev == serial {
+		return nil
+	}
+
+	s.zones[zone] = append(history, obs)
+	return &SerialChange{
+		Zone:     zone,
+		Previous: prev,
+		Serial:   serial,
+		Rollback: serialLess(serial, prev),
+		Time:     now,
+	}
+}
+
+func zoneSerial(resp *dns.Msg, zone string) (uint32, bool) {
+	for _, rr := range resp.Answer {
+		if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(RemoveLastDot(soa.Hdr.Name), zone) {
+			return soa.Serial, true
+		}
+	}
+	return 0, false
+}
+
+// serialLess returns true when serial a precedes serial b according to RFC 1982.
+func serialLess(a, b uint32) bool {
+	return a != b && int32(a-b) < 0
+}
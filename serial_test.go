@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSerialTracker(t *testing.T) {
+	var serial atomic.Uint32
+	serial.Store(2024010100)
+
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Answer = []dns.RR{&dns.SOA{
+				Hdr:    dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 0},
+				Ns:     "ns1.caffix.net.",
+				Mbox:   "admin.caffix.net.",
+				Serial: serial.Load(),
+			}}
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	st := NewSerialTracker(r, 50*time.Millisecond)
+	defer st.Stop()
+	st.TrackSerial("Caffix.net.")
+
+	time.Sleep(200 * time.Millisecond)
+	select {
+	case ev := <-st.Changes():
+		t.Fatalf("a change was reported without a new serial: %+v", ev)
+	default:
+	}
+
+	for _, next := range []uint32{2024010101, 2024010100} {
+		serial.Store(next)
+
+		select {
+		case ev := <-st.Changes():
+			if ev.Zone != "caffix.net" || ev.Serial != next {
+				t.Errorf("the serial change was not correct: %+v", ev)
+			}
+			if rollback := next == 2024010100; ev.Rollback != rollback {
+				t.Errorf("the rollback of the serial was not correctly reported: %+v", ev)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("the serial change was not reported")
+		}
+	}
+
+	history := st.History("caffix.net")
+	if len(history) != 3 || history[1].Serial != 2024010101 || history[1].Time.Before(history[0].Time) {
+		t.Errorf("the serial history was not correct: %+v", history)
+	}
+
+	st.Untrack("caffix.net")
+	if len(st.History("caffix.net")) != 0 {
+		t.Errorf("the zone was not removed from the tracker")
+	}
+}
+
+func TestSerialLess(t *testing.T) {
+	for _, test := range []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, false},
+		{5, 5, false},
+		{0xfffffff0, 5, true},
+		{5, 0xfffffff0, false},
+	} {
+		if got := serialLess(test.a, test.b); got != test.want {
+			t.Errorf("serialLess(%d, %d) returned %t", test.a, test.b, got)
+		}
+	}
+}
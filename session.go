@@ -0,0 +1,196 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type scanSessionCtxKey struct{}
+
+// The outcomes of the names in a scan session, ordered so a better outcome replaces a worse one.
+const (
+	outcomeFailed int = iota + 1
+	outcomeNotFound
+	outcomeSucceeded
+	outcomeWildcard
+)
+
+// ScanReport summarizes the outcomes of the names queried during a scan session. Each name
+// is counted once using the best outcome across the queries for the name, so a name that
+// failed and then resolved on a retry is counted as succeeded.
+type ScanReport struct {
+	Tag       string
+	Succeeded int
+	// NotFound counts the names that returned NXDOMAIN
+	NotFound int
+	// Failed counts the names that never obtained a response other than SERVFAIL or REFUSED
+	Failed int
+	// Wildcards counts the names dropped as possible wildcard matches
+	Wildcards int
+	Start     time.Time
+	End       time.Time
+}
+
+// Total returns the number of names counted in the report.
+func (s *ScanReport) Total() int {
+	return s.Succeeded + s.NotFound + s.Failed + s.Wildcards
+}
+
+// FailureRate returns the fraction of the names that failed terminally.
+func (s *ScanReport) FailureRate() float64 {
+	if total := s.Total(); total > 0 {
+		return float64(s.Failed) / float64(total)
+	}
+	return 0
+}
+
+type scanSession struct {
+	sync.Mutex
+	start, last time.Time
+	names       map[string]int
+}
+
+// scanSessions is shared by the pool and the sub-pools, so the sessions cover all the queries.
+type scanSessions struct {
+	sync.Mutex
+	sessions map[string]*scanSession
+}
+
+// WithScanSession returns a context that attributes the outcomes of the queries sent with it,
+// and the wildcard checks performed with it, to the tagged scan session.
+func WithScanSession(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, scanSessionCtxKey{}, tag)
+}
+
+// ScanReport returns the summary of the tagged scan session, or nil when no queries were
+// sent using the tag.
+func (r *Resolvers) ScanReport(tag string) *ScanReport {
+	r.sessions.Lock()
+	s, found := r.sessions.sessions[tag]
+	r.sessions.Unlock()
+
+	if !found {
+		return nil
+	}
+	return s.report(tag)
+}
+
+// EndScanSession returns the summary of the tagged scan session and discards the session.
+func (r *Resolvers) EndScanSession(tag string) *ScanReport {
+	r.sessions.Lock()
+	s, found := r.sessions.sessions[tag]
+	delete(r.sessions.sessions, tag)
+	r.sessions.Unlock()
+
+	if !found {
+		return nil
+	}
+	return s.report(tag)
+}
+
+// withoutScanSession returns a context that does not attribute the queries to a scan session,
+// such as the queries sent while testing for wildcards.
+func withoutScanSession(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(scanSessionCtxKey{}).(string); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, scanSessionCtxKey{}, nil)
+}
+
+func (r *Resolvers) scanSession(ctx context.Context) *scanSession {
+	tag, ok := ctx.Value(scanSessionCtxKey{}).(string)
+	if !ok {
+		return nil
+	}
+
+	r.sessions.Lock()
+	defer r.sessions.Unlock()
+
+	if r.sessions.sessions == nil {
+		r.sessions.sessions = make(map[string]*scanSession)
+	}
+
+	s, found := r.sessions.sessions[tag]
+	if !found {
+		s = &scanSession{
+			start: time.Now(),
+			names: make(map[string]int),
+		}
+		r.sessions.sessions[tag] = s
+	}
+	return s
+}
+
+func (s *scanSession) record(name string, outcome int) {
+	if s == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.last = time.Now()
+	name = strings.ToLower(RemoveLastDot(name))
+	if outcome > s.names[name] {
+		s.names[name] = outcome
+	}
+}
+
+func (s *scanSession) report(tag string) *ScanReport {
+	s.Lock()
+	defer s.Unlock()
+
+	rep := &ScanReport{
+		Tag:   tag,
+		Start: s.start,
+		End:   s.last,
+	}
+	for _, outcome := range s.names {
+		switch outcome {
+		case outcomeSucceeded:
+			rep.Succeeded++
+		case outcomeNotFound:
+			rep.NotFound++
+		case outcomeFailed:
+			rep.Failed++
+		case outcomeWildcard:
+			rep.Wildcards++
+		}
+	}
+	return rep
+}
+
+func rcodeOutcome(rcode int) int {
+	switch rcode {
+	case dns.RcodeSuccess:
+		return outcomeSucceeded
+	case dns.RcodeNameError, RcodeBlocked:
+		return outcomeNotFound
+	}
+	return outcomeFailed
+}
+
+// recordOutcome attributes the response delivered for the request to the scan session and
+// marks the completion of the query in the journal.
+func (r *request) recordOutcome(msg *dns.Msg) {
+	if r.Msg == nil || len(r.Msg.Question) == 0 {
+		return
+	}
+
+	rcode := RcodeNoResponse
+	if msg != nil {
+		rcode = msg.Rcode
+	}
+	r.Journal.done(r.JournalID, rcode)
+	if r.Session != nil {
+		r.Session.record(r.Msg.Question[0].Name, rcodeOutcome(rcode))
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func sessionHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	switch name := req.Question[0].Name; {
+	case name == "www.caffix.net." || name == "mail.caffix.net.":
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.168.1.1"),
+		}}
+	case name == "broken.caffix.net.":
+		m.Rcode = dns.RcodeServerFailure
+	case dns.IsSubDomain("wildcard.caffix.net.", name):
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.168.1.2"),
+		}}
+	default:
+		m.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestScanSession(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(sessionHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	if r.ScanReport("scan1") != nil {
+		t.Errorf("a report was returned for a session that was not used")
+	}
+
+	ctx := WithScanSession(context.Background(), "scan1")
+	for _, name := range []string{"www.caffix.net", "mail.caffix.net", "mail.caffix.net", "none.caffix.net", "broken.caffix.net"} {
+		if _, err := r.QueryBlocking(ctx, QueryMsg(name, dns.TypeA)); err != nil {
+			t.Fatalf("the query for %s failed: %v", name, err)
+		}
+	}
+
+	resp, err := r.QueryWithMetadata(WithWildcardCheck(ctx, "caffix.net"), QueryMsg("foo.wildcard.caffix.net", dns.TypeA))
+	if err != nil || !resp.Wildcard {
+		t.Fatalf("the wildcard was not detected: %v", err)
+	}
+	// queries outside of the session are not counted
+	_, _ = r.QueryBlocking(context.Background(), QueryMsg("other.caffix.net", dns.TypeA))
+
+	rep := r.EndScanSession("scan1")
+	if rep == nil {
+		t.Fatalf("the report was not returned for the session")
+	}
+	if rep.Tag != "scan1" || rep.Succeeded != 2 || rep.NotFound != 1 || rep.Failed != 1 || rep.Wildcards != 1 {
+		t.Errorf("the report was not correct: %+v", rep)
+	}
+	if rep.Total() != 5 || rep.FailureRate() != 0.2 || rep.End.Before(rep.Start) {
+		t.Errorf("the report totals were not correct: %+v", rep)
+	}
+	if r.ScanReport("scan1") != nil {
+		t.Errorf("the session was not discarded")
+	}
+}
+
+func TestScanSessionOutcomes(t *testing.T) {
+	s := &scanSession{names: make(map[string]int)}
+
+	s.record("www.caffix.net.", outcomeFailed)
+	s.record("WWW.caffix.net", outcomeSucceeded)
+	s.record("www.caffix.net", outcomeNotFound)
+	if rep := s.report("test"); rep.Succeeded != 1 || rep.Total() != 1 {
+		t.Errorf("the best outcome for the name was not kept: %+v", rep)
+	}
+}
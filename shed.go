@@ -0,0 +1,91 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"time"
+)
+
+// RcodeOverloaded is a special status code used to indicate the query was rejected by the
+// load shedding set by SetLoadShedding.
+const RcodeOverloaded int = 53
+
+// ErrOverloaded is returned for the queries rejected by the load shedding.
+var ErrOverloaded = errors.New("the pool is overloaded")
+
+// waitWeight is the weight of the previous average when the wait times are smoothed.
+const waitWeight = 7
+
+// ShedOptions specifies when the pool is considered overloaded. The zero value of a field
+// disables the corresponding threshold.
+type ShedOptions struct {
+	// MaxQueueLen is the number of queries waiting in the queue of the pool
+	MaxQueueLen int
+	// MaxWait is the average time spent by the queries waiting in the queue of the pool
+	MaxWait time.Duration
+}
+
+// SetLoadShedding causes the pool to reject the queries of low priority with RcodeOverloaded
+// while the thresholds are exceeded, which protects the latency of the other queries under
+// sustained overload. QueryBlocking returns ErrOverloaded for the rejected queries.
+// Providing nil disables the load shedding.
+func (r *Resolvers) SetLoadShedding(opts *ShedOptions) {
+	if opts == nil {
+		r.shedding.Store(nil)
+		return
+	}
+
+	o := *opts
+	r.shedding.Store(&o)
+}
+
+// ShedQueries returns the number of queries rejected by the load shedding.
+func (r *Resolvers) ShedQueries() uint64 {
+	return r.shed.Load()
+}
+
+// AverageWait returns the smoothed time spent by the queries waiting in the queue of the pool.
+func (r *Resolvers) AverageWait() time.Duration {
+	return time.Duration(r.waitAvg.Load())
+}
+
+// overloaded returns true when a query of low priority must be rejected.
+func (r *Resolvers) overloaded() bool {
+	if r.overMemory.Load() {
+		return true
+	}
+
+	opts := r.shedding.Load()
+	if opts == nil {
+		return false
+	}
+
+	qlen := r.queue.Len()
+	if opts.MaxQueueLen > 0 && qlen > opts.MaxQueueLen {
+		return true
+	}
+	// the average is not updated once the queue is empty
+	return opts.MaxWait > 0 && qlen > 0 && r.AverageWait() > opts.MaxWait
+}
+
+// recordWait updates the average with the time the request spent in the queue of the pool.
+func (r *Resolvers) recordWait(req *request) {
+	if req.Queued.IsZero() {
+		return
+	}
+
+	wait := int64(r.clock.Now().Sub(req.Queued))
+	for {
+		prev := r.waitAvg.Load()
+		avg := wait
+		if prev > 0 {
+			avg = (prev*waitWeight + wait) / (waitWeight + 1)
+		}
+		if r.waitAvg.CompareAndSwap(prev, avg) {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/caffix/queue"
+	"github.com/miekg/dns"
+)
+
+func TestLoadShedding(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer pc.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, pc.LocalAddr().String())
+	r.SetMaxQPS(1)
+	r.SetLoadShedding(&ShedOptions{MaxQueueLen: 2})
+	defer r.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the rate limit keeps most of the queries waiting in the queue
+	for i := 0; i < 5; i++ {
+		r.Query(ctx, QueryMsg("caffix.net", dns.TypeA), make(chan *dns.Msg, 1))
+	}
+
+	resp, err := r.QueryBlocking(WithLowPriority(context.Background()), QueryMsg("www.caffix.net", dns.TypeA))
+	if !errors.Is(err, ErrOverloaded) || resp.Rcode != RcodeOverloaded {
+		t.Errorf("the low priority query was not rejected: %v", err)
+	}
+	if n := r.ShedQueries(); n != 1 {
+		t.Errorf("%d queries were counted as rejected instead of one", n)
+	}
+}
+
+func TestOverloadedWait(t *testing.T) {
+	clock := NewSimClock(time.Now())
+	// the queue is not processed without the goroutines of the pool
+	r := &Resolvers{clock: clock, queue: queue.NewQueue()}
+
+	r.recordWait(&request{Queued: clock.Now().Add(-time.Second)})
+	if avg := r.AverageWait(); avg != time.Second {
+		t.Errorf("the average wait was %s instead of one second", avg)
+	}
+	r.recordWait(&request{Queued: clock.Now()})
+	if avg := r.AverageWait(); avg != time.Second*waitWeight/(waitWeight+1) {
+		t.Errorf("the average wait was not smoothed: %s", avg)
+	}
+
+	r.SetLoadShedding(&ShedOptions{MaxWait: 100 * time.Millisecond})
+	if r.overloaded() {
+		t.Errorf("the pool was overloaded with an empty queue")
+	}
+	r.queue.Append(&request{})
+	if !r.overloaded() {
+		t.Errorf("the pool was not overloaded by the average wait")
+	}
+
+	r.SetLoadShedding(nil)
+	if r.overloaded() {
+		t.Errorf("the pool was overloaded after the load shedding was disabled")
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// SinkholeAction specifies how the pool handles answers pointing at known sinkholes.
+type SinkholeAction int
+
+// The actions taken for answers pointing at known sinkholes.
+const (
+	// SinkholeAnnotate reports the records in the response metadata
+	SinkholeAnnotate SinkholeAction = iota
+	// SinkholeDrop removes the records from the answer section and reports them in the metadata
+	SinkholeDrop
+)
+
+// DefaultSinkholes contains the addresses and names of well-known sinkholes, blocking pages
+// and domain parking providers.
+var DefaultSinkholes = []string{
+	"0.0.0.0/8",
+	"127.0.53.53/32",
+	"::/128",
+	"146.112.61.104/29",
+	"199.2.137.0/24",
+	"sedoparking.com",
+	"parkingcrew.net",
+	"bodis.com",
+	"above.com",
+	"parklogic.com",
+}
+
+// SinkholeList is an extensible set of addresses and names used by known sinkholes.
+type SinkholeList struct {
+	sync.Mutex
+	nets  []*net.IPNet
+	names map[string]struct{}
+}
+
+type sinkholeConfig struct {
+	list   *SinkholeList
+	action SinkholeAction
+}
+
+// NewSinkholeList returns a SinkholeList containing the provided entries.
+func NewSinkholeList(entries ...string) (*SinkholeList, error) {
+	s := &SinkholeList{names: make(map[string]struct{})}
+
+	if err := s.Add(entries...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add inserts IP addresses, CIDR blocks or domain names into the list. The names match the
+// targets of CNAME, NS and similar records at or below the name.
+func (s *SinkholeList) Add(entries ...string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+
+		if ipnet := parseIPNet(entry); ipnet != nil {
+			s.nets = append(s.nets, ipnet)
+		} else if name := strings.ToLower(RemoveLastDot(entry)); validLDHName(name) {
+			s.names[name] = struct{}{}
+		} else {
+			return fmt.Errorf("%s is not a valid IP address, CIDR block or domain name", entry)
+		}
+	}
+	return nil
+}
+
+// Contains returns true when the record data points at an entry in the list.
+func (s *SinkholeList) Contains(rr dns.RR) bool {
+	var ip net.IP
+	var target string
+
+	switch v := rr.(type) {
+	case *dns.A:
+		ip = v.A
+	case *dns.AAAA:
+		ip = v.AAAA
+	case *dns.CNAME:
+		target = v.Target
+	case *dns.NS:
+		target = v.Ns
+	case *dns.MX:
+		target = v.Mx
+	default:
+		return false
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if ip != nil {
+		for _, ipnet := range s.nets {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	labels := strings.Split(strings.ToLower(RemoveLastDot(target)), ".")
+	for i := range labels {
+		if _, found := s.names[strings.Join(labels[i:], ".")]; found {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSinkholes causes the pool to check the answers against the list and take the action for
+// the records pointing at known sinkholes. Providing a nil list disables the checks.
+func (r *Resolvers) SetSinkholes(list *SinkholeList, action SinkholeAction) {
+	defer r.syncSubPools()
+
+	if list == nil {
+		r.sinkholes.Store(nil)
+		return
+	}
+	r.sinkholes.Store(&sinkholeConfig{list: list, action: action})
+}
+
+func (r *Resolvers) checkSinkholes(req *request, msg *dns.Msg) {
+	cfg := r.sinkholes.Load()
+	if cfg == nil || msg == nil {
+		return
+	}
+
+	var kept, matched []dns.RR
+	for _, rr := range msg.Answer {
+		if cfg.list.Contains(rr) {
+			matched = append(matched, rr)
+		} else {
+			kept = append(kept, rr)
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+
+	if cfg.action == SinkholeDrop {
+		msg.Answer = kept
+	}
+	req.Meta.update(func(meta *Response) { meta.Sinkholes = matched })
+}
+
+// parseIPNet returns the network for a CIDR block or a single IP address, or nil for other entries.
+func parseIPNet(entry string) *net.IPNet {
+	if _, ipnet, err := net.ParseCIDR(entry); err == nil {
+		return ipnet
+	}
+	if ip := net.ParseIP(entry); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	return nil
+}
+
+func validLDHName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || strings.Trim(label, LDHChars+"_") != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSinkholeList(t *testing.T) {
+	list, err := NewSinkholeList(DefaultSinkholes...)
+	if err != nil {
+		t.Fatalf("failed to create the list using the defaults: %v", err)
+	}
+	if err := list.Add("192.168.1.2", "parked.example"); err != nil {
+		t.Fatalf("failed to add the entries: %v", err)
+	}
+	if err := list.Add("not a valid entry!"); err == nil {
+		t.Errorf("the invalid entry was accepted")
+	}
+
+	hdr := dns.RR_Header{Name: "www.caffix.net.", Class: dns.ClassINET}
+	cases := []struct {
+		rr   dns.RR
+		want bool
+	}{
+		{&dns.A{Hdr: hdr, A: net.ParseIP("127.0.53.53")}, true},
+		{&dns.A{Hdr: hdr, A: net.ParseIP("192.168.1.2")}, true},
+		{&dns.A{Hdr: hdr, A: net.ParseIP("192.168.1.1")}, false},
+		{&dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("::")}, true},
+		{&dns.AAAA{Hdr: hdr, AAAA: net.ParseIP("2001:db8::1")}, false},
+		{&dns.CNAME{Hdr: hdr, Target: "www.Parked.Example."}, true},
+		{&dns.NS{Hdr: hdr, Ns: "ns1.sedoparking.com."}, true},
+		{&dns.CNAME{Hdr: hdr, Target: "notparked.example."}, false},
+		{&dns.TXT{Hdr: hdr, Txt: []string{"192.168.1.2"}}, false},
+	}
+	for _, c := range cases {
+		if got := list.Contains(c.rr); got != c.want {
+			t.Errorf("Contains returned %t for %s", got, c.rr)
+		}
+	}
+}
+
+func TestSetSinkholes(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	list, _ := NewSinkholeList("192.168.1.2")
+	r.SetSinkholes(list, SinkholeAnnotate)
+	resp, err := r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Msg.Answer) != 2 || len(resp.Sinkholes) != 1 {
+		t.Errorf("the sinkhole answer was not annotated: %v", err)
+	}
+
+	r.SetSinkholes(list, SinkholeDrop)
+	resp, err = r.QueryWithMetadata(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Msg.Answer) != 1 || len(resp.Sinkholes) != 1 {
+		t.Errorf("the sinkhole answer was not dropped: %v", err)
+	}
+
+	r.SetSinkholes(nil, SinkholeDrop)
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA)); err != nil || len(resp.Answer) != 2 {
+		t.Errorf("the sinkhole checks were not disabled: %v", err)
+	}
+}
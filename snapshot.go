@@ -0,0 +1,129 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// WildcardSnapshot is the persisted result of the wildcard detection for a subdomain.
+type WildcardSnapshot struct {
+	Detected bool               `json:"detected"`
+	Answers  []*ExtractedAnswer `json:"answers,omitempty"`
+}
+
+type cacheSnapshot struct {
+	Name    string    `json:"name"`
+	Qtype   uint16    `json:"qtype"`
+	Msg     []byte    `json:"msg"`
+	Fetched time.Time `json:"fetched"`
+	Expires time.Time `json:"expires"`
+}
+
+// SaveWildcards writes the wildcard detection results as JSON, so a long-running process can
+// resume after a restart without testing the subdomains again.
+func (r *Resolvers) SaveWildcards(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r.wildcardSnapshot())
+}
+
+func (r *Resolvers) wildcardSnapshot() map[string]*WildcardSnapshot {
+	r.Lock()
+	wildcards := make(map[string]*wildcard, len(r.wildcards))
+	for sub, wc := range r.wildcards {
+		wildcards[sub] = wc
+	}
+	r.Unlock()
+
+	snap := make(map[string]*WildcardSnapshot, len(wildcards))
+	for sub, wc := range wildcards {
+		wc.Lock()
+		snap[sub] = &WildcardSnapshot{
+			Detected: wc.Detected,
+			Answers:  wc.Answers,
+		}
+		wc.Unlock()
+	}
+	return snap
+}
+
+// LoadWildcards restores the wildcard detection results written by SaveWildcards. Subdomains
+// already tested by the pool keep the current results.
+func (r *Resolvers) LoadWildcards(rd io.Reader) error {
+	var snap map[string]*WildcardSnapshot
+	if err := json.NewDecoder(rd).Decode(&snap); err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for sub, s := range snap {
+		if _, found := r.wildcards[sub]; !found && s != nil {
+			w := new(wildcard)
+			w.setResult(s.Detected, s.Answers)
+			r.storeWildcard(sub, w)
+		}
+	}
+	return nil
+}
+
+// Save writes the unexpired entries of the cache as JSON, so the cache can be restored by a
+// process resuming the work.
+func (c *Cache) Save(w io.Writer) error {
+	c.Lock()
+	now := time.Now()
+	var snap []*cacheSnapshot
+	for key, e := range c.entries {
+		if !now.Before(e.Expires) {
+			continue
+		}
+		if b, err := e.Msg.Pack(); err == nil {
+			snap = append(snap, &cacheSnapshot{
+				Name:    key.Name,
+				Qtype:   key.Qtype,
+				Msg:     b,
+				Fetched: e.Fetched,
+				Expires: e.Expires,
+			})
+		}
+	}
+	c.Unlock()
+
+	return json.NewEncoder(w).Encode(snap)
+}
+
+// Load restores the cache entries written by Save that have not expired since.
+func (c *Cache) Load(rd io.Reader) error {
+	var snap []*cacheSnapshot
+	if err := json.NewDecoder(rd).Decode(&snap); err != nil {
+		return err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	for _, s := range snap {
+		if s == nil || !now.Before(s.Expires) {
+			continue
+		}
+
+		msg := new(dns.Msg)
+		if err := msg.Unpack(s.Msg); err != nil {
+			continue
+		}
+		c.setEntry(monitorKey{Name: s.Name, Qtype: s.Qtype}, &cacheEntry{
+			Msg:      msg,
+			Fetched:  s.Fetched,
+			Expires:  s.Expires,
+			LastUsed: s.Fetched,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestWildcardSnapshot(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.wildcards["caffix.net"] = &wildcard{
+		Detected: true,
+		Answers:  []*ExtractedAnswer{{Name: "caffix.net", Type: dns.TypeA, Data: "192.168.1.1"}},
+	}
+	r.wildcards["owasp.org"] = &wildcard{}
+
+	var buf bytes.Buffer
+	if err := r.SaveWildcards(&buf); err != nil {
+		t.Fatalf("failed to save the wildcards: %v", err)
+	}
+
+	restored := NewResolvers()
+	defer restored.Stop()
+
+	restored.wildcards["owasp.org"] = &wildcard{Detected: true}
+	if err := restored.LoadWildcards(&buf); err != nil {
+		t.Fatalf("failed to load the wildcards: %v", err)
+	}
+	if w := restored.wildcards["caffix.net"]; w == nil || !w.Detected || len(w.Answers) != 1 || w.Answers[0].Data != "192.168.1.1" {
+		t.Errorf("the wildcard was not restored: %+v", w)
+	}
+	if w := restored.wildcards["owasp.org"]; !w.Detected {
+		t.Errorf("the existing wildcard result was replaced")
+	}
+	if err := restored.LoadWildcards(strings.NewReader("not json")); err == nil {
+		t.Errorf("the invalid snapshot was accepted")
+	}
+}
+
+func TestCacheSnapshot(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			_ = w.WriteMsg(ttlReply(req, 60))
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	c := NewCache(r)
+	defer c.Stop()
+
+	if _, err := c.Lookup(context.Background(), "caffix.net", dns.TypeA); err != nil {
+		t.Fatalf("the lookup failed: %v", err)
+	}
+	c.Lock()
+	c.setEntry(monitorKey{Name: "expired.caffix.net", Qtype: dns.TypeA}, &cacheEntry{
+		Msg:     QueryMsg("expired.caffix.net", dns.TypeA),
+		Expires: time.Now().Add(-time.Second),
+	})
+	c.Unlock()
+
+	var buf bytes.Buffer
+	if err := c.Save(&buf); err != nil {
+		t.Fatalf("failed to save the cache: %v", err)
+	}
+
+	restored := &Cache{entries: make(map[monitorKey]*cacheEntry)}
+	if err := restored.Load(&buf); err != nil {
+		t.Fatalf("failed to load the cache: %v", err)
+	}
+	if restored.Len() != 1 {
+		t.Fatalf("the restored cache has %d entries instead of one", restored.Len())
+	}
+	if msg := restored.get(monitorKey{Name: "caffix.net", Qtype: dns.TypeA}); msg == nil || len(msg.Answer) != 1 {
+		t.Errorf("the cached response was not restored")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// SocketControl is called with each socket created by the pool before it is bound or
+// connected, so platform-specific options, such as IP_TOS or SO_RCVBUF, can be set.
+type SocketControl func(network, address string, c syscall.RawConn) error
+
+type socketOpts struct {
+	device  string
+	tos     int
+	rcvbuf  int
+	control SocketControl
+}
+
+// SetSocketControl sets the function called with the UDP sockets and TCP connections created
+// by the pool. The current sockets are replaced, and an error is returned when the new
+// sockets cannot be created with the function. Providing nil removes the function.
+func (r *Resolvers) SetSocketControl(fn SocketControl) error {
+	if r.conns == nil {
+		return errors.New("the resolver pool has no connections")
+	}
+	return r.conns.reconfigure(func(opts *socketOpts) {
+		opts.control = fn
+	})
+}
+
+// reconfigure replaces the sockets with new ones created using the updated options. The
+// current sockets and options are kept when the new sockets cannot be opened. While the
+// connections are suspended, only the options are updated for the sockets opened at the resume.
+func (r *connections) reconfigure(update func(opts *socketOpts)) error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.suspended {
+		update(&r.opts)
+		return nil
+	}
+
+	prev, old := r.opts, r.conns
+	update(&r.opts)
+	r.conns = []*connection{}
+	for i := 0; i < r.cpus; i++ {
+		if err := r.Add(); err != nil {
+			for _, c := range r.conns {
+				close(c.done)
+				// unblocks the reader waiting on the socket
+				_ = c.conn.Close()
+			}
+			r.opts, r.conns = prev, old
+			return err
+		}
+	}
+
+	for _, c := range old {
+		go r.retire(c)
+	}
+	return nil
+}
+
+func (r *connections) getOptions() socketOpts {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.opts
+}
+
+// dialer returns the dialer used for the TCP connections, which are created with the same
+// options as the sockets used for the UDP queries.
+func (r *connections) dialer(timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	if r != nil {
+		d.Control = r.getOptions().controlFunc()
+	}
+	return d
+}
+
+// controlFunc returns the function that applies the options to a socket, or nil when no
+// options were set.
+func (o socketOpts) controlFunc() func(network, address string, c syscall.RawConn) error {
+	if o.device == "" && o.tos == 0 && o.control == nil {
+		return nil
+	}
+
+	return func(network, address string, c syscall.RawConn) error {
+		if o.device != "" || o.tos != 0 {
+			var operr error
+
+			if err := c.Control(func(fd uintptr) {
+				if o.device != "" {
+					operr = bindToDevice(fd, o.device)
+				}
+				if operr == nil && o.tos != 0 {
+					operr = setTOS(fd, network, o.tos)
+				}
+			}); err != nil {
+				return err
+			}
+			if operr != nil {
+				return operr
+			}
+		}
+		if o.control != nil {
+			return o.control(network, address, c)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/caffix/queue"
+	"github.com/miekg/dns"
+)
+
+func TestSetSocketControl(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	var calls atomic.Int32
+	if err := r.SetSocketControl(func(network, address string, c syscall.RawConn) error {
+		calls.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to set the socket control function: %v", err)
+	}
+	if n := int(calls.Load()); n != r.conns.cpus {
+		t.Errorf("the control function was called for %d of the %d sockets", n, r.conns.cpus)
+	}
+	if resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil || resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("the query failed using the new sockets: %v", err)
+	}
+
+	before := r.conns.Next()
+	if err := r.SetSocketControl(func(network, address string, c syscall.RawConn) error {
+		return errors.New("failed")
+	}); err == nil {
+		t.Errorf("the failing control function was accepted")
+	}
+	r.conns.Lock()
+	kept := false
+	for _, c := range r.conns.conns {
+		if c == before {
+			kept = true
+		}
+	}
+	r.conns.Unlock()
+	if !kept || r.conns.getOptions().control == nil {
+		t.Errorf("the sockets were not kept after the control function failed")
+	}
+}
+
+func TestIterativeExchangeSocketControl(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	var calls atomic.Int32
+	if err := r.SetSocketControl(func(network, address string, c syscall.RawConn) error {
+		calls.Add(1)
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to set the socket control function: %v", err)
+	}
+
+	before := calls.Load()
+	if resp, _, err := r.iterativeExchange(context.Background(), QueryMsg(name, dns.TypeA), addrstr); err != nil || resp.Rcode != dns.RcodeSuccess {
+		t.Fatalf("the iterative exchange failed: %v", err)
+	}
+	if calls.Load() == before {
+		t.Errorf("the iterative exchange did not use the options of the pool")
+	}
+}
+
+func TestReconfigureFailure(t *testing.T) {
+	conns, err := newConnections(2, queue.NewQueue())
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+
+	fds := func() int {
+		entries, err := os.ReadDir("/proc/self/fd")
+		if err != nil {
+			return -1
+		}
+		return len(entries)
+	}
+	beforeFDs, beforeRoutines := fds(), runtime.NumGoroutine()
+
+	for i := 0; i < 5; i++ {
+		var calls atomic.Int32
+		// the first new socket is opened before the second one fails
+		if err := conns.reconfigure(func(opts *socketOpts) {
+			opts.control = func(network, address string, c syscall.RawConn) error {
+				if calls.Add(1) > 1 {
+					return errors.New("failed")
+				}
+				return nil
+			}
+		}); err == nil {
+			t.Fatalf("the failing reconfigure was accepted")
+		}
+	}
+
+	var leakedFDs, leakedRoutines int
+	for i := 0; i < 20; i++ {
+		leakedFDs, leakedRoutines = fds()-beforeFDs, runtime.NumGoroutine()-beforeRoutines
+		if leakedFDs <= 0 && leakedRoutines <= 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if beforeFDs >= 0 && leakedFDs > 0 {
+		t.Errorf("the failed reconfigures leaked %d sockets", leakedFDs)
+	}
+	if leakedRoutines > 0 {
+		t.Errorf("the failed reconfigures leaked %d goroutines", leakedRoutines)
+	}
+}
+
+func TestDialerSocketControl(t *testing.T) {
+	conns, err := newConnections(1, queue.NewQueue())
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+
+	if d := conns.dialer(time.Second); d.Control != nil || d.Timeout != time.Second {
+		t.Errorf("the dialer was not correctly configured without socket options")
+	}
+
+	var calls atomic.Int32
+	_ = conns.reconfigure(func(opts *socketOpts) {
+		opts.control = func(network, address string, c syscall.RawConn) error {
+			calls.Add(1)
+			return nil
+		}
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen for TCP connections: %v", err)
+	}
+	defer l.Close()
+
+	c, err := conns.dialer(time.Second).Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial the TCP connection: %v", err)
+	}
+	_ = c.Close()
+	// the function was called for the UDP socket and then the TCP connection
+	if calls.Load() != 2 {
+		t.Errorf("the control function was not called for the TCP connection")
+	}
+}
+
+// TestListenPacketParity checks the behavior that must be consistent across the platforms
+func TestListenPacketParity(t *testing.T) {
+	conns, err := newConnections(2, queue.NewQueue())
+	if err != nil {
+		t.Fatalf("failed to open the sockets: %v", err)
+	}
+	defer conns.Close()
+
+	for _, c := range conns.conns {
+		addr, ok := c.conn.LocalAddr().(*net.UDPAddr)
+		if !ok || addr.Port == 0 {
+			t.Errorf("the socket was not bound to a UDP port: %v", c.conn.LocalAddr())
+		}
+	}
+
+	var networks []string
+	_ = conns.reconfigure(func(opts *socketOpts) {
+		opts.control = func(network, address string, c syscall.RawConn) error {
+			networks = append(networks, network)
+			return c.Control(func(fd uintptr) {})
+		}
+	})
+	if len(networks) != 2 {
+		t.Fatalf("the control function was called %d times for two sockets", len(networks))
+	}
+	for _, network := range networks {
+		if network != "udp4" && network != "udp6" {
+			t.Errorf("the control function was called for the %s network", network)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// SPFLookupLimit is the number of mechanisms and modifiers causing DNS lookups permitted by
+// RFC 7208 during the evaluation of an SPF record.
+const SPFLookupLimit = 10
+
+var (
+	// ErrSPFLoop is returned when an include or redirect refers to a record being expanded.
+	ErrSPFLoop = errors.New("the SPF record includes itself")
+	// ErrSPFLookupLimit is returned when the expansion exceeds SPFLookupLimit.
+	ErrSPFLookupLimit = errors.New("the SPF record exceeds the DNS lookup limit")
+	// ErrNoSPFRecord is returned when a domain does not publish exactly one SPF record.
+	ErrNoSPFRecord = errors.New("the domain does not have a single SPF record")
+)
+
+// SPFExpansion contains the networks authorized by the SPF record of a domain.
+type SPFExpansion struct {
+	Domain string
+	// Networks contains the ip4, ip6, a and mx networks of the pass mechanisms
+	Networks []*net.IPNet
+	// Domains contains the domains of the records expanded, beginning with Domain
+	Domains []string
+	// Unexpanded contains the ptr and exists mechanisms and the terms containing macros
+	Unexpanded []string
+	// Lookups is the number of terms that counted toward SPFLookupLimit
+	Lookups int
+}
+
+type spfState struct {
+	pool      *Resolvers
+	result    *SPFExpansion
+	expanding map[string]struct{}
+	seen      map[string]struct{}
+}
+
+// ExpandSPF resolves the SPF record of the domain, recursively following the include mechanisms
+// and the redirect modifier, and returns the flattened set of authorized networks. Loops and
+// expansions exceeding SPFLookupLimit return an error along with the networks found so far.
+func (r *Resolvers) ExpandSPF(ctx context.Context, domain string) (*SPFExpansion, error) {
+	domain = strings.ToLower(RemoveLastDot(domain))
+	s := &spfState{
+		pool:      r,
+		result:    &SPFExpansion{Domain: domain},
+		expanding: make(map[string]struct{}),
+		seen:      make(map[string]struct{}),
+	}
+
+	err := s.expand(ctx, domain)
+	return s.result, err
+}
+
+func (s *spfState) expand(ctx context.Context, domain string) error {
+	domain = strings.ToLower(RemoveLastDot(domain))
+	if _, found := s.expanding[domain]; found {
+		return fmt.Errorf("spf %s: %w", domain, ErrSPFLoop)
+	}
+	s.expanding[domain] = struct{}{}
+	defer delete(s.expanding, domain)
+
+	record, err := s.pool.spfRecord(ctx, domain)
+	if err != nil {
+		return err
+	}
+	s.result.Domains = append(s.result.Domains, domain)
+
+	var redirect string
+	var all bool
+	for _, term := range strings.Fields(record)[1:] {
+		if strings.Contains(term, "%") {
+			s.result.Unexpanded = append(s.result.Unexpanded, term)
+			continue
+		}
+
+		lterm := strings.ToLower(term)
+		if strings.HasPrefix(lterm, "redirect=") {
+			redirect = term[len("redirect="):]
+			continue
+		}
+		if strings.Contains(lterm, "=") {
+			// unknown modifiers are ignored as required by RFC 7208
+			continue
+		}
+
+		pass := true
+		switch lterm[0] {
+		case '+':
+			lterm, term = lterm[1:], term[1:]
+		case '-', '~', '?':
+			pass = false
+			lterm, term = lterm[1:], term[1:]
+		}
+
+		mech, arg := lterm, ""
+		if i := strings.IndexAny(lterm, ":/"); i >= 0 {
+			mech, arg = lterm[:i], term[i:]
+			arg = strings.TrimPrefix(arg, ":")
+		}
+
+		switch mech {
+		case "all":
+			all = true
+		case "ip4", "ip6":
+			if ipnet := parseIPNet(arg); ipnet != nil && pass {
+				s.add(ipnet)
+			}
+		case "a", "mx":
+			if err := s.count(); err != nil {
+				return err
+			}
+			if pass {
+				if err := s.addHosts(ctx, domain, mech, arg); err != nil {
+					return err
+				}
+			}
+		case "include":
+			if err := s.count(); err != nil {
+				return err
+			}
+			if pass {
+				if err := s.expand(ctx, arg); err != nil {
+					return err
+				}
+			}
+		case "ptr", "exists":
+			if err := s.count(); err != nil {
+				return err
+			}
+			s.result.Unexpanded = append(s.result.Unexpanded, term)
+		}
+	}
+
+	if redirect != "" && !all {
+		if err := s.count(); err != nil {
+			return err
+		}
+		return s.expand(ctx, redirect)
+	}
+	return nil
+}
+
+func (s *spfState) count() error {
+	if s.result.Lookups++; s.result.Lookups > SPFLookupLimit {
+		return fmt.Errorf("spf %s: %w", s.result.Domain, ErrSPFLookupLimit)
+	}
+	return nil
+}
+
+func (s *spfState) add(ipnet *net.IPNet) {
+	key := ipnet.String()
+	if _, found := s.seen[key]; found {
+		return
+	}
+	s.seen[key] = struct{}{}
+	s.result.Networks = append(s.result.Networks, ipnet)
+}
+
+// addHosts adds the networks of the addresses for the a and mx mechanisms. The argument can
+// provide the target name and the dual CIDR lengths, such as mail.caffix.net/24//64.
+func (s *spfState) addHosts(ctx context.Context, domain, mech, arg string) error {
+	target, v4len, v6len := domain, 32, 128
+	parts := strings.Split(arg, "/")
+	if parts[0] != "" {
+		target = parts[0]
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if n, err := strconv.Atoi(parts[1]); err == nil && n >= 0 && n <= 32 {
+			v4len = n
+		}
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		if n, err := strconv.Atoi(parts[3]); err == nil && n >= 0 && n <= 128 {
+			v6len = n
+		}
+	}
+
+	hosts := []string{target}
+	if mech == "mx" {
+		resp, err := s.pool.QueryBlocking(ctx, QueryMsg(target, dns.TypeMX))
+		if err != nil {
+			return fmt.Errorf("spf %s: %v", target, err)
+		}
+		hosts = nil
+		for _, a := range AnswersByType(ExtractAnswers(resp), dns.TypeMX) {
+			hosts = append(hosts, a.Data)
+		}
+	}
+
+	opts := &LookupOptions{NoSearch: true}
+	for _, host := range hosts {
+		ips4, err := s.pool.LookupA(ctx, host, opts)
+		if err != nil && !errors.Is(err, ErrNoSuchHost) {
+			return err
+		}
+		for _, ip := range ips4 {
+			s.add(&net.IPNet{IP: ip.To4().Mask(net.CIDRMask(v4len, 32)), Mask: net.CIDRMask(v4len, 32)})
+		}
+
+		ips6, err := s.pool.LookupAAAA(ctx, host, opts)
+		if err != nil && !errors.Is(err, ErrNoSuchHost) {
+			return err
+		}
+		for _, ip := range ips6 {
+			s.add(&net.IPNet{IP: ip.Mask(net.CIDRMask(v6len, 128)), Mask: net.CIDRMask(v6len, 128)})
+		}
+	}
+	return nil
+}
+
+// spfRecord returns the single SPF record published in the TXT records of the domain.
+func (r *Resolvers) spfRecord(ctx context.Context, domain string) (string, error) {
+	resp, err := r.QueryBlocking(ctx, QueryMsg(domain, dns.TypeTXT))
+	if err != nil {
+		return "", fmt.Errorf("spf %s: %v", domain, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return "", fmt.Errorf("spf %s: the server returned %s", domain, dns.RcodeToString[resp.Rcode])
+	}
+
+	var records []string
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			// the strings of a record are concatenated without spaces as described by RFC 7208
+			record := strings.Join(txt.Txt, "")
+			if l := strings.ToLower(record); l == "v=spf1" || strings.HasPrefix(l, "v=spf1 ") {
+				records = append(records, record)
+			}
+		}
+	}
+	if len(records) != 1 {
+		return "", fmt.Errorf("spf %s: %w", domain, ErrNoSPFRecord)
+	}
+	return records[0], nil
+}
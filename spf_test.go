@@ -0,0 +1,92 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func spfHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	records := map[string]string{
+		"caffix.net.":       "v=spf1 ip4:192.0.2.0/24 include:_spf.caffix.net a:mail.caffix.net/28 ~ip4:198.51.100.1 -all",
+		"_spf.caffix.net.":  "v=spf1 ip6:2001:db8::/32 ptr redirect=other.caffix.net",
+		"other.caffix.net.": "v=spf1 ip4:203.0.113.5 -all",
+		"loop.caffix.net.":  "v=spf1 include:loop.caffix.net -all",
+	}
+	for i := 0; i < 12; i++ {
+		records[fmt.Sprintf("n%d.caffix.net.", i)] = fmt.Sprintf("v=spf1 include:n%d.caffix.net -all", i+1)
+	}
+
+	q := req.Question[0]
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET, Ttl: 300}
+	switch q.Qtype {
+	case dns.TypeTXT:
+		if rec, found := records[strings.ToLower(q.Name)]; found && rec != "" {
+			// split the record to check that the strings are concatenated
+			m.Answer = []dns.RR{
+				&dns.TXT{Hdr: hdr, Txt: []string{"google-site-verification=token"}},
+				&dns.TXT{Hdr: hdr, Txt: []string{rec[:10], rec[10:]}},
+			}
+		}
+	case dns.TypeA:
+		if q.Name == "mail.caffix.net." {
+			m.Answer = []dns.RR{&dns.A{Hdr: hdr, A: net.ParseIP("192.0.2.77")}}
+		}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestExpandSPF(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(spfHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(50, addrstr)
+	defer r.Stop()
+
+	result, err := r.ExpandSPF(context.Background(), "caffix.net")
+	if err != nil {
+		t.Fatalf("the expansion failed: %v", err)
+	}
+
+	var nets []string
+	for _, n := range result.Networks {
+		nets = append(nets, n.String())
+	}
+	if got, want := strings.Join(nets, " "), "192.0.2.0/24 2001:db8::/32 203.0.113.5/32 192.0.2.64/28"; got != want {
+		t.Errorf("the networks were %s instead of %s", got, want)
+	}
+	if len(result.Domains) != 3 || result.Lookups != 4 {
+		t.Errorf("the expansion visited %v with %d lookups", result.Domains, result.Lookups)
+	}
+	if len(result.Unexpanded) != 1 || result.Unexpanded[0] != "ptr" {
+		t.Errorf("the ptr mechanism was not reported as unexpanded: %v", result.Unexpanded)
+	}
+
+	if _, err := r.ExpandSPF(context.Background(), "loop.caffix.net"); !errors.Is(err, ErrSPFLoop) {
+		t.Errorf("the loop was not detected: %v", err)
+	}
+	if _, err := r.ExpandSPF(context.Background(), "n0.caffix.net"); !errors.Is(err, ErrSPFLookupLimit) {
+		t.Errorf("the lookup limit was not detected: %v", err)
+	}
+	if _, err := r.ExpandSPF(context.Background(), "www.caffix.net"); !errors.Is(err, ErrNoSPFRecord) {
+		t.Errorf("the missing record was not detected: %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// ResolverStats contains the statistics collected for a single resolver in the pool.
+type ResolverStats struct {
+	Address        string
+	Timeouts       uint64
+	FormatErrors   uint64
+	ServerFailures uint64
+	NotImplemented uint64
+	QueryRefusals  uint64
+	Responses      uint64
+	AverageRTT     time.Duration
+	Injections     uint64
+	// AverageSize and MaxSize describe the wire format size in bytes of the UDP responses
+	AverageSize int
+	MaxSize     int
+	// Truncations counts the UDP responses that had the TC bit set
+	Truncations uint64
+	// TCPFallbacks counts the queries retried over TCP, and MaxTCPSize is the largest response obtained
+	TCPFallbacks uint64
+	MaxTCPSize   int
+	// NSIDs counts the responses received from each anycast instance identified by EDNS NSID
+	NSIDs map[string]uint64
+	// QuarantinedUntil is the time the resolver returns to selection, or the zero time
+	QuarantinedUntil time.Time
+	// Location is the annotation set by SetLocation or AnnotateResolvers
+	Location *Location
+	// BogusAnswers counts the records removed by the validation set by SetAddressValidation
+	BogusAnswers uint64
+	// TCPPreferred is set while the policy set by SetTruncationPolicy sends the queries over TCP
+	TCPPreferred bool
+}
+
+// Stats returns the statistics collected for each active resolver in the pool.
+func (r *Resolvers) Stats() []*ResolverStats {
+	var all []*ResolverStats
+
+	for _, res := range r.pool.AllResolvers() {
+		all = append(all, res.getStats())
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Address < all[j].Address
+	})
+	return all
+}
+
+func (r *resolver) getStats() *ResolverStats {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	s := &ResolverStats{
+		Address:        r.address.String(),
+		Timeouts:       r.stats.Timeouts,
+		FormatErrors:   r.stats.FormatErrors,
+		ServerFailures: r.stats.ServerFailures,
+		NotImplemented: r.stats.NotImplemented,
+		QueryRefusals:  r.stats.QueryRefusals,
+		Responses:      r.stats.Responses,
+		Injections:     r.stats.Injections,
+		BogusAnswers:   r.stats.BogusAnswers,
+		MaxSize:        r.stats.MaxSize,
+		Truncations:    r.stats.Truncations,
+		TCPFallbacks:   r.stats.TCPFallbacks,
+		MaxTCPSize:     r.stats.MaxTCPSize,
+		NSIDs:          make(map[string]uint64),
+	}
+	if s.Responses > 0 {
+		s.AverageRTT = r.stats.TotalRTT / time.Duration(s.Responses)
+	}
+	if r.stats.SizedResponses > 0 {
+		s.AverageSize = int(r.stats.TotalSize / r.stats.SizedResponses)
+	}
+	for id, count := range r.stats.NSIDs {
+		s.NSIDs[id] = count
+	}
+	s.QuarantinedUntil = r.quarantinedUntil()
+	s.Location = r.location.Load()
+	s.TCPPreferred = r.tcpPreferred.Load()
+	return s
+}
+
+// SetNSIDCollection requests the EDNS name server identifier on all queries sent by the pool,
+// so the anycast instances that served each resolver are reported by Stats.
+func (r *Resolvers) SetNSIDCollection(enable bool) {
+	r.nsid.Store(enable)
+	r.syncSubPools()
+}
+
+// AddNSIDOption adds the EDNS0_NSID option to the provided message.
+func AddNSIDOption(msg *dns.Msg) {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		msg.SetEdns0(dns.DefaultMsgSize, false)
+		opt = msg.IsEdns0()
+	}
+
+	for _, o := range opt.Option {
+		if _, ok := o.(*dns.EDNS0_NSID); ok {
+			return
+		}
+	}
+	opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+}
+
+func extractNSID(msg *dns.Msg) string {
+	opt := msg.IsEdns0()
+	if opt == nil {
+		return ""
+	}
+
+	for _, o := range opt.Option {
+		if n, ok := o.(*dns.EDNS0_NSID); ok && n.Nsid != "" {
+			if b, err := hex.DecodeString(n.Nsid); err == nil {
+				return string(b)
+			}
+			return n.Nsid
+		}
+	}
+	return ""
+}
@@ -0,0 +1,131 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestStats(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	if _, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA)); err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+
+	stats := r.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("Stats returned %d entries for a pool with a single resolver", len(stats))
+	}
+	if stats[0].Address != addrstr || stats[0].Responses != 1 || stats[0].AverageRTT == 0 {
+		t.Errorf("the statistics were not correctly collected: %+v", stats[0])
+	}
+	if stats[0].AverageSize == 0 || stats[0].MaxSize != stats[0].AverageSize || stats[0].Truncations != 0 {
+		t.Errorf("the response sizes were not correctly collected: %+v", stats[0])
+	}
+}
+
+func TestTruncationStats(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, truncatedHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	// the TCP exchange fails, since only the UDP server is running
+	_, _ = r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+
+	stats := r.Stats()[0]
+	if stats.Truncations != 1 || stats.TCPFallbacks != 1 || stats.MaxTCPSize != 0 {
+		t.Errorf("the truncation statistics were not correctly collected: %+v", stats)
+	}
+}
+
+func TestNSIDCollection(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, nsidHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	_, _ = r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if stats := r.Stats(); len(stats[0].NSIDs) > 0 {
+		t.Errorf("an NSID was collected before the option was enabled")
+	}
+
+	r.SetNSIDCollection(true)
+	for i := 0; i < 2; i++ {
+		_, _ = r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	}
+	if stats := r.Stats(); stats[0].NSIDs["instance-1"] != 2 {
+		t.Errorf("the NSID values were not collected: %v", stats[0].NSIDs)
+	}
+}
+
+func TestAddNSIDOption(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+	AddNSIDOption(msg)
+	AddNSIDOption(msg)
+
+	var count int
+	for _, o := range msg.IsEdns0().Option {
+		if _, ok := o.(*dns.EDNS0_NSID); ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("the message contained %d NSID options", count)
+	}
+}
+
+func nsidHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	if opt := req.IsEdns0(); opt != nil {
+		for _, o := range opt.Option {
+			if _, ok := o.(*dns.EDNS0_NSID); ok {
+				m.SetEdns0(dns.DefaultMsgSize, false)
+				m.IsEdns0().Option = []dns.EDNS0{&dns.EDNS0_NSID{
+					Code: dns.EDNS0NSID,
+					Nsid: hex.EncodeToString([]byte("instance-1")),
+				}}
+			}
+		}
+	}
+	_ = w.WriteMsg(m)
+}
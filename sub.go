@@ -21,8 +21,16 @@ loop:
 	for i := 0; i < len(labels)-1; i++ {
 		sub := strings.Join(labels[i:], ".")
 
+		qctx := ctx
 		for i := 0; i < maxQueryAttempts; i++ {
-			resp, err := r.QueryBlocking(ctx, QueryMsg(sub, dns.TypeNS))
+			if i > 0 {
+				if !r.RetryAllowed() {
+					break
+				}
+				qctx = WithRetry(ctx)
+			}
+
+			resp, err := r.QueryBlocking(qctx, QueryMsg(sub, dns.TypeNS))
 			if err != nil || resp.Rcode == dns.RcodeNameError {
 				continue loop
 			}
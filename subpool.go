@@ -0,0 +1,110 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"fmt"
+)
+
+type subPoolCtxKey struct{}
+
+// WithSubPool returns a context that directs the queries sent with it to the named sub-pool.
+// Queries are sent using the pool itself when the sub-pool does not exist.
+func WithSubPool(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, subPoolCtxKey{}, name)
+}
+
+// AddSubPool creates a named sub-pool containing the provided resolvers, such as a "trusted"
+// set used for validation next to the pool used for discovery. The sub-pool has its own
+// scheduler and rate limits, and it is stopped along with the parent pool. The settings of
+// the pool applied to the queries and responses, such as the hooks, the policy and the privacy
+// profile, are inherited by the sub-pool, including the later changes made to the pool.
+func (r *Resolvers) AddSubPool(name string, qps int, addrs ...string) (*Resolvers, error) {
+	idle := r.idleTimeout()
+
+	r.Lock()
+	defer r.Unlock()
+
+	if _, found := r.subpools[name]; found {
+		return nil, fmt.Errorf("the sub-pool %s already exists", name)
+	}
+
+	sub := NewResolversWithClock(r.clock)
+	if err := sub.AddResolvers(qps, addrs...); err != nil {
+		sub.Stop()
+		return nil, err
+	}
+	sub.SetLogger(r.log)
+	sub.SetTimeout(r.timeout)
+	sub.sessions = r.sessions
+	sub.journal.Store(r.journal.Load())
+	sub.tlsConfig = r.tlsConfig
+	r.inheritSettings(sub)
+	if idle > 0 {
+		sub.SetIdleTimeout(idle)
+	}
+
+	if r.subpools == nil {
+		r.subpools = make(map[string]*Resolvers)
+	}
+	r.subpools[name] = sub
+	return sub, nil
+}
+
+// SubPool returns the named sub-pool or nil when it does not exist.
+func (r *Resolvers) SubPool(name string) *Resolvers {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.subpools[name]
+}
+
+func (r *Resolvers) subPoolFromContext(ctx context.Context) *Resolvers {
+	name, ok := ctx.Value(subPoolCtxKey{}).(string)
+	if !ok {
+		return nil
+	}
+	return r.SubPool(name)
+}
+
+func (r *Resolvers) stopSubPools() {
+	r.Lock()
+	subs := r.subpools
+	r.subpools = nil
+	r.Unlock()
+
+	for _, sub := range subs {
+		sub.Stop()
+	}
+}
+
+// inheritSettings copies the settings of the pool applied to the queries and responses into
+// the sub-pool, so the queries directed to the sub-pool receive the same treatment.
+func (r *Resolvers) inheritSettings(sub *Resolvers) {
+	sub.privacy.Store(r.privacy.Load())
+	sub.tor.Store(r.tor.Load())
+	sub.preSend.Store(r.preSend.Load())
+	sub.postReceive.Store(r.postReceive.Load())
+	sub.logCtx.Store(r.logCtx.Load())
+	sub.policy.Store(r.policy.Load())
+	sub.sinkholes.Store(r.sinkholes.Load())
+	sub.addrCheck.Store(r.addrCheck.Load())
+	sub.blocking.Store(r.blocking.Load())
+	sub.ttlOptions.Store(r.ttlOptions.Load())
+	sub.nsid.Store(r.nsid.Load())
+	// the tracker is shared, so the injections are flagged regardless of the pool used
+	sub.injections.Store(r.injections.Load())
+}
+
+// syncSubPools applies the inherited settings to the sub-pools after the pool was changed.
+func (r *Resolvers) syncSubPools() {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, sub := range r.subpools {
+		r.inheritSettings(sub)
+	}
+}
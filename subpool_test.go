@@ -0,0 +1,129 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSubPool(t *testing.T) {
+	s1, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s1.Shutdown() }()
+
+	s2, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(otherAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = s2.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr1)
+	defer r.Stop()
+
+	trusted, err := r.AddSubPool("trusted", 10, addr2)
+	if err != nil {
+		t.Fatalf("failed to create the sub-pool: %v", err)
+	}
+	if _, err := r.AddSubPool("trusted", 10, addr2); err == nil {
+		t.Errorf("a duplicate sub-pool was created")
+	}
+	if r.SubPool("trusted") != trusted || r.Len() != 1 || trusted.Len() != 1 {
+		t.Errorf("the resolvers were not partitioned into the sub-pool")
+	}
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Answer) != 1 {
+		t.Errorf("the query was not sent using the pool: %v", err)
+	}
+
+	ctx := WithSubPool(context.Background(), "trusted")
+	resp, err = r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Answer) != 2 {
+		t.Errorf("the query was not sent using the sub-pool: %v", err)
+	}
+
+	ctx = WithSubPool(context.Background(), "missing")
+	resp, err = r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || len(resp.Answer) != 1 {
+		t.Errorf("the query for a missing sub-pool was not sent using the pool: %v", err)
+	}
+
+	r.Stop()
+	select {
+	case <-trusted.done:
+	default:
+		t.Errorf("the sub-pool was not stopped with the parent pool")
+	}
+}
+
+func TestSubPoolInheritance(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	r.SetNSIDCollection(true)
+	r.SetPolicy(&Policy{})
+	sub, err := r.AddSubPool("trusted", 10, "192.168.1.1")
+	if err != nil {
+		t.Fatalf("failed to create the sub-pool: %v", err)
+	}
+	if !sub.nsid.Load() || sub.policy.Load() != r.policy.Load() {
+		t.Errorf("the sub-pool did not inherit the settings at creation")
+	}
+
+	r.UsePrivacyProfile()
+	r.SetInjectionDetection(true)
+	r.SetPreSendHook(func(msg *dns.Msg) error { return nil })
+	r.SetTTLOptions(&TTLOptions{ZeroTTLs: true})
+	if err := r.SetAddressValidation(true); err != nil {
+		t.Fatalf("failed to set the address validation: %v", err)
+	}
+	if !sub.privacy.Load() || sub.injections.Load() != r.injections.Load() || sub.preSend.Load() == nil ||
+		sub.ttlOptions.Load() != r.ttlOptions.Load() || sub.addrCheck.Load() == nil {
+		t.Errorf("the sub-pool did not inherit the later changes")
+	}
+
+	r.SetPolicy(nil)
+	r.SetPreSendHook(nil)
+	if sub.policy.Load() != nil || sub.preSend.Load() != nil {
+		t.Errorf("the sub-pool kept the settings removed from the pool")
+	}
+}
+
+func TestSubPoolLeased(t *testing.T) {
+	s, addr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	sub, err := r.AddSubPool("trusted", 10, addr)
+	if err != nil {
+		t.Fatalf("failed to create the sub-pool: %v", err)
+	}
+	// the query remains on the queue of the paused sub-pool to be inspected
+	sub.Pause()
+
+	ch := make(chan *dns.Msg, 1)
+	r.query(WithSubPool(context.Background(), "trusted"), QueryMsg("caffix.net", dns.TypeA), ch, true)
+	// the lease reserved the QPS of the parent pool, so the sub-pool applies its own limit
+	if elem, found := sub.queue.Next(); !found || elem.(*request).Leased {
+		t.Errorf("the query was not queued on the sub-pool using the QPS of the sub-pool")
+	}
+}
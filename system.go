@@ -0,0 +1,105 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+const (
+	defaultNdots      = 1
+	maxNdots          = 15
+	systemResolverQPS = 50
+)
+
+// SystemConfig contains the resolver configuration obtained from the operating system.
+type SystemConfig struct {
+	Servers []string
+	Search  []string
+	Ndots   int
+}
+
+// NewResolversFromSystem returns a Resolvers populated with the name servers, search domains
+// and ndots value configured in the operating system.
+func NewResolversFromSystem() (*Resolvers, error) {
+	conf, err := SystemResolverConfig()
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.Servers) == 0 {
+		return nil, errors.New("no name servers were found in the system configuration")
+	}
+
+	r, err := OpenResolvers(realClock{})
+	if err != nil {
+		return nil, err
+	}
+	if err := r.AddResolvers(systemResolverQPS, conf.Servers...); err != nil {
+		r.Stop()
+		return nil, err
+	}
+	r.SetSearchDomains(conf.Ndots, conf.Search...)
+	return r, nil
+}
+
+// SetSearchDomains sets the search list and ndots value used for expanding names in the lookup helpers.
+func (r *Resolvers) SetSearchDomains(ndots int, domains ...string) {
+	r.Lock()
+	defer r.Unlock()
+
+	if ndots < 0 {
+		ndots = 0
+	} else if ndots > maxNdots {
+		ndots = maxNdots
+	}
+
+	r.ndots = ndots
+	r.search = nil
+	for _, d := range domains {
+		if d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), "."); d != "" {
+			r.search = append(r.search, d)
+		}
+	}
+}
+
+func (r *Resolvers) searchDomains() (int, []string) {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.ndots, r.search
+}
+
+// SearchNames returns the names to be attempted, in order, for the provided name using the
+// search list as described in resolv.conf(5). Names ending with a dot are never expanded.
+// Names with at least ndots dots are attempted literally before the search domains are applied.
+func SearchNames(name string, ndots int, search []string) []string {
+	name = strings.TrimSpace(name)
+	if strings.HasSuffix(name, ".") || len(search) == 0 {
+		return []string{RemoveLastDot(name)}
+	}
+
+	var names []string
+	for _, d := range search {
+		names = append(names, name+"."+d)
+	}
+	if strings.Count(name, ".") >= ndots {
+		return append([]string{name}, names...)
+	}
+	return append(names, name)
+}
+
+func normalizeServerAddr(addr string) string {
+	addr = strings.TrimSpace(addr)
+	// remove the zone from link-local IPv6 addresses
+	if i := strings.Index(addr, "%"); i != -1 {
+		addr = addr[:i]
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
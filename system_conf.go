@@ -0,0 +1,38 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package resolve
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+const resolvConfPath = "/etc/resolv.conf"
+
+// SystemResolverConfig returns the resolver configuration found in /etc/resolv.conf.
+func SystemResolverConfig() (*SystemConfig, error) {
+	return resolvConf(resolvConfPath)
+}
+
+func resolvConf(path string) (*SystemConfig, error) {
+	cc, err := dns.ClientConfigFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &SystemConfig{
+		Search: cc.Search,
+		Ndots:  cc.Ndots,
+	}
+	for _, s := range cc.Servers {
+		if addr := normalizeServerAddr(s); addr != "" {
+			conf.Servers = append(conf.Servers, net.JoinHostPort(addr, cc.Port))
+		}
+	}
+	return conf, nil
+}
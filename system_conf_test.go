@@ -0,0 +1,40 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !windows
+
+package resolve
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolvConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	data := "nameserver 192.168.1.1\nnameserver fe80::1%eth0\nsearch caffix.net owasp.org\noptions ndots:2\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("failed to write the test configuration: %v", err)
+	}
+
+	conf, err := resolvConf(path)
+	if err != nil {
+		t.Fatalf("failed to parse the configuration: %v", err)
+	}
+	if expected := []string{"192.168.1.1:53", "[fe80::1]:53"}; !reflect.DeepEqual(conf.Servers, expected) {
+		t.Errorf("the name servers were %v instead of %v", conf.Servers, expected)
+	}
+	if expected := []string{"caffix.net", "owasp.org"}; !reflect.DeepEqual(conf.Search, expected) {
+		t.Errorf("the search domains were %v instead of %v", conf.Search, expected)
+	}
+	if conf.Ndots != 2 {
+		t.Errorf("the ndots value was %d instead of 2", conf.Ndots)
+	}
+
+	if _, err := resolvConf(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Errorf("the missing configuration file did not return an error")
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchNames(t *testing.T) {
+	search := []string{"caffix.net", "owasp.org"}
+
+	for _, test := range []struct {
+		name     string
+		ndots    int
+		expected []string
+	}{
+		{"www", 1, []string{"www.caffix.net", "www.owasp.org", "www"}},
+		{"www.example", 1, []string{"www.example", "www.example.caffix.net", "www.example.owasp.org"}},
+		{"www.example", 2, []string{"www.example.caffix.net", "www.example.owasp.org", "www.example"}},
+		{"www.example.", 5, []string{"www.example"}},
+	} {
+		if names := SearchNames(test.name, test.ndots, search); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("the search names for %s were %v instead of %v", test.name, names, test.expected)
+		}
+	}
+	if names := SearchNames("www", 1, nil); !reflect.DeepEqual(names, []string{"www"}) {
+		t.Errorf("the name was expanded without a search list: %v", names)
+	}
+}
+
+func TestSetSearchDomains(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if ndots, search := r.searchDomains(); ndots != defaultNdots || len(search) != 0 {
+		t.Errorf("the default search settings were incorrect")
+	}
+
+	r.SetSearchDomains(20, " Caffix.NET. ", "", "owasp.org")
+	if ndots, search := r.searchDomains(); ndots != maxNdots || !reflect.DeepEqual(search, []string{"caffix.net", "owasp.org"}) {
+		t.Errorf("the search settings were not normalized: %d %v", ndots, search)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build windows
+
+package resolve
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+const tcpipParameters = `SYSTEM\CurrentControlSet\Services\Tcpip\Parameters`
+
+// SystemResolverConfig returns the resolver configuration found in the Windows registry.
+func SystemResolverConfig() (*SystemConfig, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, tcpipParameters, registry.READ)
+	if err != nil {
+		return nil, err
+	}
+	defer k.Close()
+
+	conf := &SystemConfig{Ndots: defaultNdots}
+	conf.Servers = append(conf.Servers, registryServers(k)...)
+	if search, _, err := k.GetStringValue("SearchList"); err == nil {
+		conf.Search = splitRegistryList(search)
+	}
+	if domain, _, err := k.GetStringValue("Domain"); err == nil && domain != "" && len(conf.Search) == 0 {
+		conf.Search = []string{domain}
+	}
+
+	if ifaces, err := registry.OpenKey(k, "Interfaces", registry.READ); err == nil {
+		defer ifaces.Close()
+
+		if names, err := ifaces.ReadSubKeyNames(-1); err == nil {
+			for _, name := range names {
+				if ik, err := registry.OpenKey(ifaces, name, registry.READ); err == nil {
+					conf.Servers = append(conf.Servers, registryServers(ik)...)
+					ik.Close()
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]struct{})
+	var servers []string
+	for _, s := range conf.Servers {
+		if _, found := seen[s]; !found {
+			seen[s] = struct{}{}
+			servers = append(servers, s)
+		}
+	}
+	conf.Servers = servers
+	return conf, nil
+}
+
+func registryServers(k registry.Key) []string {
+	var servers []string
+
+	for _, value := range []string{"NameServer", "DhcpNameServer"} {
+		if list, _, err := k.GetStringValue(value); err == nil {
+			for _, s := range splitRegistryList(list) {
+				if addr := normalizeServerAddr(s); addr != "" {
+					servers = append(servers, net.JoinHostPort(addr, "53"))
+				}
+			}
+		}
+	}
+	return servers
+}
+
+func splitRegistryList(list string) []string {
+	return strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -5,13 +5,17 @@
 package resolve
 
 import (
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/miekg/dns"
 )
 
-const thresholdCheckInterval time.Duration = 3 * time.Second
+const (
+	thresholdCheckInterval time.Duration = 3 * time.Second
+	rttWindowSize          int           = 64
+)
 
 type ThresholdOptions struct {
 	ThresholdValue         uint64
@@ -36,6 +40,25 @@ type stats struct {
 	NotImplemented      uint64
 	CountQueryRefusals  bool
 	QueryRefusals       uint64
+	FormatFailures      uint64
+	Responses           uint64
+	TotalRTT            time.Duration
+	RecentRTTs          []time.Duration
+	NextRTT             int
+	Injections          uint64
+	BogusAnswers        uint64
+	NSIDs               map[string]uint64
+	SizedResponses      uint64
+	TotalSize           uint64
+	MaxSize             int
+	Truncations         uint64
+	TCPFallbacks        uint64
+	MaxTCPSize          int
+	WindowResponses     uint64
+	WindowTruncations   uint64
+	FallbackRTT         time.Duration
+	PreferredRTT        time.Duration
+	PreferredSamples    int
 }
 
 // SetThresholdOptions updates the settings used for discontinuing use of a resolver due to poor performance.
@@ -63,7 +86,7 @@ func (r *Resolvers) updateThresholdOptions() {
 	}
 }
 
-func (r *Resolvers) thresholdChecks() {
+func (r *Resolvers) thresholdChecks(stop chan struct{}) {
 	t := time.NewTicker(thresholdCheckInterval)
 	defer t.Stop()
 
@@ -71,8 +94,11 @@ func (r *Resolvers) thresholdChecks() {
 		select {
 		case <-r.done:
 			return
+		case <-stop:
+			return
 		case <-t.C:
 			r.shutdownIfThresholdViolated()
+			r.demoteBrokenResolvers()
 		}
 	}
 }
@@ -135,10 +161,29 @@ func (r *resolver) collectStats(resp *dns.Msg) {
 	if resp == nil {
 		return
 	}
+	if r.pool != nil && r.pool.domains != nil && len(resp.Question) > 0 {
+		r.pool.domains.record(r.address.String(), resp.Question[0].Name, resp.Rcode)
+	}
 
 	r.stats.Lock()
 	defer r.stats.Unlock()
 
+	if nsid := extractNSID(resp); nsid != "" {
+		if r.stats.NSIDs == nil {
+			r.stats.NSIDs = make(map[string]uint64)
+		}
+		r.stats.NSIDs[nsid]++
+	}
+
+	// count the consecutive format errors that reveal a broken path to the resolver
+	if resp.Rcode == dns.RcodeFormatError || resp.Rcode == dns.RcodeNotImplemented {
+		if standardQuery(resp) {
+			r.stats.FormatFailures++
+		}
+	} else if resp.Rcode != RcodeNoResponse {
+		r.stats.FormatFailures = 0
+	}
+
 	switch resp.Rcode {
 	case RcodeNoResponse:
 		r.stats.Timeouts++
@@ -169,3 +214,82 @@ func (r *resolver) collectStats(resp *dns.Msg) {
 		r.stats.LastSuccess = 0
 	}
 }
+
+func (r *resolver) recordRTT(rtt time.Duration) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.Responses++
+	r.stats.TotalRTT += rtt
+
+	if len(r.stats.RecentRTTs) < rttWindowSize {
+		r.stats.RecentRTTs = append(r.stats.RecentRTTs, rtt)
+	} else {
+		r.stats.RecentRTTs[r.stats.NextRTT] = rtt
+	}
+	r.stats.NextRTT = (r.stats.NextRTT + 1) % rttWindowSize
+}
+
+// lowRTT returns the number of recent round trip time samples and the tenth percentile of them.
+func (r *resolver) lowRTT() (int, time.Duration) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	n := len(r.stats.RecentRTTs)
+	if n == 0 {
+		return 0, 0
+	}
+
+	samples := make([]time.Duration, n)
+	copy(samples, r.stats.RecentRTTs)
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+	return n, samples[n/10]
+}
+
+func (r *resolver) recordSize(size int, truncated bool) {
+	if size <= 0 {
+		return
+	}
+
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.SizedResponses++
+	r.stats.TotalSize += uint64(size)
+	if size > r.stats.MaxSize {
+		r.stats.MaxSize = size
+	}
+	if truncated {
+		r.stats.Truncations++
+	}
+}
+
+// recordTCPFallback counts the exchange over TCP and the size of the response, which
+// indicates the EDNS buffer size that would have avoided the truncation.
+func (r *resolver) recordTCPFallback(resp *dns.Msg) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.TCPFallbacks++
+	if resp != nil {
+		if size := resp.Len(); size > r.stats.MaxTCPSize {
+			r.stats.MaxTCPSize = size
+		}
+	}
+}
+
+func (r *resolver) recordBogus(n int) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.BogusAnswers += uint64(n)
+}
+
+func (r *resolver) recordInjection() {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.Injections++
+}
@@ -0,0 +1,146 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/proxy"
+	"golang.org/x/net/publicsuffix"
+)
+
+type torConfig struct {
+	addr  string
+	nonce string
+}
+
+// UseTor sends all queries of the pool through the Tor SOCKS proxy at the provided address,
+// including the traces, fingerprints and authoritative wildcard tests. Queries are sent using
+// TCP, or DNS over TLS when the privacy profile is used, since Tor does not carry UDP. The
+// payload discovery and DDR upgrades require direct connections, so they are skipped. Each
+// target domain is provided distinct SOCKS credentials, which Tor uses for stream isolation to
+// place the queries for different domains on separate circuits. The package level functions,
+// such as Trace and FingerprintResolver, do not use the pool and are not affected. An empty
+// address disables the Tor transport.
+func (r *Resolvers) UseTor(addr string) error {
+	defer r.syncSubPools()
+
+	if addr == "" {
+		r.tor.Store(nil)
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("the Tor proxy address %s is invalid: %v", addr, err)
+	}
+
+	// the nonce keeps the circuits of this pool separate from other users of the proxy
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate the stream isolation nonce: %v", err)
+	}
+
+	r.tor.Store(&torConfig{
+		addr:  addr,
+		nonce: hex.EncodeToString(nonce),
+	})
+	return nil
+}
+
+// isolationKey returns the SOCKS username shared by all queries for the same target domain.
+func isolationKey(name string) string {
+	name = strings.ToLower(RemoveLastDot(name))
+
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+		return domain
+	}
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func (r *resolver) torExchange(req *request, msg *dns.Msg, cfg *torConfig) {
+	timeout := r.xchgs.getQtypeTimeout(msg.Question[0].Qtype)
+	ctx, cancel := context.WithTimeout(req.context(), timeout)
+	defer cancel()
+
+	addr, transport := r.address.String(), TransportTCP
+	if r.useTLS() {
+		addr, transport = r.getDoTAddr(), TransportTLS
+	}
+	req.recordAttempt(r, transport)
+
+	start := time.Now()
+	var tlsConfig *tls.Config
+	if transport == TransportTLS {
+		tlsConfig = r.tlsClientConfig()
+	}
+	m, err := r.pool.torQuery(ctx, msg, cfg, addr, tlsConfig)
+	r.finishExchange(req, m, time.Since(start), err)
+}
+
+// torDirect sends the message to a server outside of the pool through the Tor proxy. Tor does
+// not carry UDP, so the message is sent using TCP.
+func (r *Resolvers) torDirect(ctx context.Context, msg *dns.Msg, cfg *torConfig, addr string) (*dns.Msg, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+
+	return r.torQuery(ctx, msg, cfg, addr, nil)
+}
+
+// torQuery exchanges the message with the server at addr through the Tor proxy, using DNS over
+// TLS when the TLS configuration is provided.
+func (r *Resolvers) torQuery(ctx context.Context, msg *dns.Msg, cfg *torConfig, addr string, tlsConfig *tls.Config) (*dns.Msg, error) {
+	auth := &proxy.Auth{
+		User:     isolationKey(msg.Question[0].Name),
+		Password: cfg.nonce,
+	}
+
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	d, err := proxy.SOCKS5("tcp", cfg.addr, auth, r.conns.dialer(timeout))
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("the SOCKS dialer does not support contexts")
+	}
+
+	conn, err := cd.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if tlsConfig != nil {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	dc := &dns.Conn{Conn: conn}
+	defer func() { _ = dc.Close() }()
+
+	if err := dc.WriteMsg(msg); err != nil {
+		return nil, err
+	}
+
+	m, err := dc.ReadMsg()
+	if err == nil && m.Id != msg.Id {
+		err = errors.New("the response ID did not match the query")
+	}
+	return m, err
+}
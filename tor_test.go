@@ -0,0 +1,240 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+type socksRecord struct {
+	User, Password, Dest string
+}
+
+// runSOCKSServer runs a minimal SOCKS5 proxy that requires username/password authentication
+// and records the credentials and destination of each stream.
+func runSOCKSServer(t *testing.T) (string, func() []socksRecord) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to run the SOCKS server: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	var lock sync.Mutex
+	var records []socksRecord
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+
+				rec, err := socksHandshake(conn)
+				if err != nil {
+					return
+				}
+				lock.Lock()
+				records = append(records, *rec)
+				lock.Unlock()
+
+				upstream, err := net.Dial("tcp", rec.Dest)
+				if err != nil {
+					return
+				}
+				defer upstream.Close()
+
+				_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+				go func() { _, _ = io.Copy(upstream, conn) }()
+				_, _ = io.Copy(conn, upstream)
+			}()
+		}
+	}()
+
+	return l.Addr().String(), func() []socksRecord {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]socksRecord(nil), records...)
+	}
+}
+
+func socksHandshake(conn net.Conn) (*socksRecord, error) {
+	buf := make([]byte, 256)
+	// the version and authentication methods
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, buf[:buf[1]]); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 2}); err != nil {
+		return nil, err
+	}
+
+	rec := new(socksRecord)
+	// the username and password subnegotiation
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	ulen := int(buf[1])
+	if _, err := io.ReadFull(conn, buf[:ulen]); err != nil {
+		return nil, err
+	}
+	rec.User = string(buf[:ulen])
+	if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+		return nil, err
+	}
+	plen := int(buf[0])
+	if _, err := io.ReadFull(conn, buf[:plen]); err != nil {
+		return nil, err
+	}
+	rec.Password = string(buf[:plen])
+	if _, err := conn.Write([]byte{1, 0}); err != nil {
+		return nil, err
+	}
+
+	// the connect request with an IPv4 destination
+	if _, err := io.ReadFull(conn, buf[:10]); err != nil {
+		return nil, err
+	}
+	port := binary.BigEndian.Uint16(buf[8:10])
+	rec.Dest = net.JoinHostPort(net.IP(buf[4:8]).String(), strconv.Itoa(int(port)))
+	return rec, nil
+}
+
+func TestUseTor(t *testing.T) {
+	s, addr, _, err := RunLocalTCPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	proxyAddr, records := runSOCKSServer(t)
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addr)
+	defer r.Stop()
+
+	if err := r.UseTor("127.0.0.1"); err == nil {
+		t.Errorf("the invalid proxy address was accepted")
+	}
+	if err := r.UseTor(proxyAddr); err != nil {
+		t.Fatalf("failed to configure the Tor proxy: %v", err)
+	}
+
+	for _, name := range []string{"www.caffix.net", "mail.caffix.net", "www.owasp.org"} {
+		resp, err := r.QueryWithMetadata(context.Background(), QueryMsg(name, dns.TypeA))
+		if err != nil || resp.Msg.Rcode != dns.RcodeSuccess || len(resp.Msg.Answer) == 0 {
+			t.Fatalf("the query for %s through the proxy failed: %v", name, err)
+		}
+		if resp.Transport != TransportTCP {
+			t.Errorf("the query for %s was sent using %s", name, resp.Transport)
+		}
+	}
+
+	recs := records()
+	if len(recs) != 3 {
+		t.Fatalf("the proxy received %d streams instead of three", len(recs))
+	}
+	for i, user := range []string{"caffix.net", "caffix.net", "owasp.org"} {
+		if recs[i].User != user || recs[i].Dest != addr {
+			t.Errorf("the stream was not correctly isolated: %+v", recs[i])
+		}
+		if recs[i].Password == "" || recs[i].Password != recs[0].Password {
+			t.Errorf("the stream isolation nonce was not consistent")
+		}
+	}
+
+	_ = r.UseTor("")
+	if r.tor.Load() != nil {
+		t.Errorf("the Tor transport was not disabled")
+	}
+}
+
+func TestUseTorNoDirect(t *testing.T) {
+	s, addr, _, err := RunLocalTCPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	// any datagram received on the port of the server was sent outside of the proxy
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Skipf("unable to listen for the datagrams: %v", err)
+	}
+	defer pc.Close()
+
+	var direct atomic.Int32
+	go func() {
+		buf := make([]byte, dns.MaxMsgSize)
+		for {
+			if _, _, err := pc.ReadFrom(buf); err != nil {
+				return
+			}
+			direct.Add(1)
+		}
+	}()
+
+	proxyAddr, records := runSOCKSServer(t)
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.UseTor(proxyAddr); err != nil {
+		t.Fatalf("failed to configure the Tor proxy: %v", err)
+	}
+	r.SetPayloadDiscovery(true)
+	r.SetDDRUpgrade(true)
+	_ = r.AddResolvers(10, addr)
+
+	if _, err := r.FingerprintResolver(context.Background(), addr); err != nil {
+		t.Errorf("the fingerprint through the proxy failed: %v", err)
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	if steps, err := trace(context.Background(), r.iterativeExchange, "www.caffix.net.",
+		dns.TypeA, []string{"127.0.0.1"}, port, 0, false); err != nil || len(steps) == 0 {
+		t.Errorf("the trace through the proxy failed: %v", err)
+	}
+
+	// allow the payload discovery and DDR upgrade to run
+	time.Sleep(250 * time.Millisecond)
+	if n := direct.Load(); n != 0 {
+		t.Errorf("%d queries bypassed the proxy", n)
+	}
+	// three fingerprint queries, along with the trace query and the DNSKEY query
+	if n := len(records()); n != 5 {
+		t.Errorf("the proxy carried %d streams instead of five", n)
+	}
+}
+
+func TestIsolationKey(t *testing.T) {
+	for _, test := range []struct {
+		name, expected string
+	}{
+		{"www.caffix.net.", "caffix.net"},
+		{"WWW.Example.co.uk", "example.co.uk"},
+		{"net.", "net"},
+		{".", "."},
+	} {
+		if key := isolationKey(test.name); key != test.expected {
+			t.Errorf("the isolation key for %s was %s instead of %s", test.name, key, test.expected)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const maxTraceDepth int = 16
+
+// exchangeFunc sends the message to the server at addr and returns the response and round trip time.
+type exchangeFunc func(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, time.Duration, error)
+
+// TraceStep describes a single DNS server consulted while tracing the delegation chain.
+type TraceStep struct {
+	Zone      string
+	Server    string
+	RTT       time.Duration
+	Rcode     int
+	Referral  []string
+	HasDS     bool
+	HasDNSKEY bool
+	Msg       *dns.Msg
+}
+
+// Trace follows the chain of referrals from the root name servers to the final answer for
+// the provided name and type, similar to the dig +trace command.
+func Trace(ctx context.Context, name string, qtype uint16) ([]*TraceStep, error) {
+	return trace(ctx, iterativeExchange, dns.Fqdn(name), qtype, rootAddrs(), "53", 0, false)
+}
+
+// Trace follows the chain of referrals from the root name servers to the final answer for
+// the provided name and type. When the privacy profile is enabled, QNAME minimization is used
+// to only reveal the next label of the name to each server along the way (RFC 9156).
+func (r *Resolvers) Trace(ctx context.Context, name string, qtype uint16) ([]*TraceStep, error) {
+	return trace(ctx, r.iterativeExchange, dns.Fqdn(name), qtype, rootAddrs(), "53", 0, r.privacy.Load())
+}
+
+func trace(ctx context.Context, exchange exchangeFunc, name string, qtype uint16, servers []string, port string, depth int, minimize bool) ([]*TraceStep, error) {
+	var steps []*TraceStep
+
+	zone, qname := ".", name
+	if minimize {
+		qname = nextMinimizedName(name, zone)
+	}
+
+	for referrals := 0; referrals < maxTraceDepth; {
+		qt := qtype
+		if qname != name {
+			// RFC 9156 recommends the A type for the minimized queries
+			qt = dns.TypeA
+		}
+
+		step, err := traceExchange(ctx, exchange, qname, qt, zone, servers, port)
+		if err != nil {
+			return steps, err
+		}
+		steps = append(steps, step)
+
+		resp := step.Msg
+		if qname != name && len(step.Referral) == 0 {
+			// the minimized name is not a zone cut, so reveal another label to the same servers
+			if resp.Rcode != dns.RcodeSuccess {
+				return steps, nil
+			}
+			qname = nextMinimizedName(name, qname)
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 || len(step.Referral) == 0 {
+			return steps, nil
+		}
+
+		next := referralZone(resp)
+		if next == "" || !dns.IsSubDomain(zone, next) || strings.EqualFold(next, zone) {
+			return steps, fmt.Errorf("Trace: %s returned an invalid referral for %s", step.Server, zone)
+		}
+
+		servers = referralAddrs(ctx, exchange, resp, step.Referral, port, depth)
+		if len(servers) == 0 {
+			return steps, fmt.Errorf("Trace: failed to obtain addresses for the %s name servers", next)
+		}
+
+		zone = next
+		if minimize {
+			qname = nextMinimizedName(name, zone)
+		}
+		referrals++
+	}
+	return steps, fmt.Errorf("Trace: exceeded the maximum of %d referrals", maxTraceDepth)
+}
+
+// nextMinimizedName returns the ancestor of name that is one label below the parent.
+func nextMinimizedName(name, parent string) string {
+	labels := dns.SplitDomainName(name)
+	if n := dns.CountLabel(parent); n < len(labels) {
+		return dns.Fqdn(strings.Join(labels[len(labels)-n-1:], "."))
+	}
+	return name
+}
+
+func traceExchange(ctx context.Context, exchange exchangeFunc, name string, qtype uint16, zone string, servers []string, port string) (*TraceStep, error) {
+	for _, server := range servers {
+		select {
+		case <-ctx.Done():
+			return nil, errors.New("Trace: the context expired")
+		default:
+		}
+
+		addr := net.JoinHostPort(server, port)
+		resp, rtt, err := exchange(ctx, WalkMsg(name, qtype), addr)
+		if err != nil {
+			continue
+		}
+
+		step := &TraceStep{
+			Zone:   zone,
+			Server: addr,
+			RTT:    rtt,
+			Rcode:  resp.Rcode,
+			Msg:    resp,
+		}
+		referral := isReferral(resp)
+		for _, rr := range resp.Ns {
+			switch t := rr.(type) {
+			case *dns.NS:
+				if referral {
+					step.Referral = append(step.Referral, strings.ToLower(t.Ns))
+				}
+			case *dns.DS:
+				step.HasDS = true
+			}
+		}
+		if keys, _, err := exchange(ctx, WalkMsg(zone, dns.TypeDNSKEY), addr); err == nil {
+			for _, rr := range keys.Answer {
+				if _, ok := rr.(*dns.DNSKEY); ok {
+					step.HasDNSKEY = true
+					break
+				}
+			}
+		}
+		return step, nil
+	}
+	return nil, fmt.Errorf("Trace: none of the %s name servers responded", zone)
+}
+
+// iterativeExchange sends the message to the server at addr without the recursion desired flag.
+// The pool sends the messages through the Tor proxy when configured by UseTor, and otherwise
+// opens the sockets with the options set by SetSocketControl and the related methods.
+func (r *Resolvers) iterativeExchange(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	if cfg := r.tor.Load(); cfg != nil {
+		msg.RecursionDesired = false
+
+		start := time.Now()
+		resp, err := r.torDirect(ctx, msg, cfg, addr)
+		return resp, time.Since(start), err
+	}
+	return dialerExchange(ctx, msg, addr, r.conns.dialer(DefaultTimeout))
+}
+
+func iterativeExchange(ctx context.Context, msg *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	return dialerExchange(ctx, msg, addr, nil)
+}
+
+// dialerExchange sends the message over UDP using the sockets created by the dialer, and retries
+// over TCP when the response is truncated. A nil dialer uses the default options.
+func dialerExchange(ctx context.Context, msg *dns.Msg, addr string, d *net.Dialer) (*dns.Msg, time.Duration, error) {
+	msg.RecursionDesired = false
+
+	client := dns.Client{
+		Net:     "udp",
+		Timeout: DefaultTimeout,
+		Dialer:  d,
+	}
+	resp, rtt, err := client.ExchangeContext(ctx, msg, addr)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, rtt, err = client.ExchangeContext(ctx, msg, addr)
+	}
+	return resp, rtt, err
+}
+
+// isReferral returns true when the NS records in the authority section delegate the name to
+// another zone, rather than accompanying an authoritative answer or NODATA response.
+func isReferral(resp *dns.Msg) bool {
+	if resp.Authoritative || len(resp.Answer) > 0 {
+		return false
+	}
+
+	var ns bool
+	for _, rr := range resp.Ns {
+		switch rr.(type) {
+		case *dns.SOA:
+			return false
+		case *dns.NS:
+			ns = true
+		}
+	}
+	return ns
+}
+
+func referralZone(resp *dns.Msg) string {
+	for _, rr := range resp.Ns {
+		if ns, ok := rr.(*dns.NS); ok {
+			return strings.ToLower(ns.Header().Name)
+		}
+	}
+	return ""
+}
+
+func referralAddrs(ctx context.Context, exchange exchangeFunc, resp *dns.Msg, nameservers []string, port string, depth int) []string {
+	var addrs []string
+
+	for _, rr := range resp.Extra {
+		if a, ok := rr.(*dns.A); ok {
+			for _, ns := range nameservers {
+				if strings.EqualFold(a.Hdr.Name, ns) {
+					addrs = append(addrs, a.A.String())
+				}
+			}
+		}
+	}
+	if len(addrs) > 0 || depth >= 2 {
+		return addrs
+	}
+	// resolve the name server addresses when glue records were not provided
+	for _, ns := range nameservers {
+		steps, err := trace(ctx, exchange, ns, dns.TypeA, rootAddrs(), port, depth+1, false)
+		if err != nil || len(steps) == 0 {
+			continue
+		}
+		for _, rr := range steps[len(steps)-1].Msg.Answer {
+			if a, ok := rr.(*dns.A); ok {
+				addrs = append(addrs, a.A.String())
+			}
+		}
+		if len(addrs) > 0 {
+			break
+		}
+	}
+	return addrs
+}
+
+func rootAddrs() []string {
+	var addrs []string
+
+	for _, addr := range RootServers() {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+				addrs = append(addrs, host)
+			}
+		}
+	}
+	return addrs
+}
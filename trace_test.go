@@ -0,0 +1,205 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestTrace(t *testing.T) {
+	root, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(traceRootHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = root.Shutdown() }()
+
+	_, port, _ := net.SplitHostPort(addrstr)
+	child, _, _, err := RunLocalUDPServer(net.JoinHostPort("127.0.0.2", port), func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = child.Shutdown() }()
+
+	steps, err := trace(context.Background(), iterativeExchange, "www.trace.net.", dns.TypeA, []string{"127.0.0.1"}, port, 0, false)
+	if err != nil {
+		t.Fatalf("the trace failed: %v", err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("the trace returned %d steps instead of two", len(steps))
+	}
+	if s := steps[0]; s.Zone != "." || !s.HasDS || len(s.Referral) != 1 || s.Referral[0] != "ns.trace.net." {
+		t.Errorf("the referral step was not correctly recorded: %+v", s)
+	}
+	if s := steps[1]; s.Zone != "trace.net." || s.Server != net.JoinHostPort("127.0.0.2", port) {
+		t.Errorf("the final step was not sent to the delegated server: %+v", s)
+	}
+	if ans := ExtractAnswers(steps[1].Msg); len(ans) == 0 || ans[0].Data != "192.168.1.1" {
+		t.Errorf("the trace did not return the expected answer")
+	}
+}
+
+func traceRootHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+
+	if req.Question[0].Qtype == dns.TypeDNSKEY {
+		_ = w.WriteMsg(m)
+		return
+	}
+
+	hdr := dns.RR_Header{Name: "trace.net.", Class: dns.ClassINET, Ttl: 0}
+	nsHdr, dsHdr := hdr, hdr
+	nsHdr.Rrtype = dns.TypeNS
+	dsHdr.Rrtype = dns.TypeDS
+	m.Ns = []dns.RR{
+		&dns.NS{Hdr: nsHdr, Ns: "ns.trace.net."},
+		&dns.DS{Hdr: dsHdr, KeyTag: 1, Algorithm: dns.RSASHA256, DigestType: dns.SHA256, Digest: "00"},
+	}
+	m.Extra = []dns.RR{&dns.A{
+		Hdr: dns.RR_Header{Name: "ns.trace.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+		A:   net.ParseIP("127.0.0.2"),
+	}}
+	_ = w.WriteMsg(m)
+}
+
+func TestTraceNoData(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(apexNSHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	_, port, _ := net.SplitHostPort(addrstr)
+	steps, err := trace(context.Background(), iterativeExchange, "trace.net.", dns.TypeAAAA, []string{"127.0.0.1"}, port, 0, false)
+	if err != nil {
+		t.Fatalf("the trace failed on a NODATA response: %v", err)
+	}
+	if len(steps) != 1 || len(steps[0].Referral) != 0 {
+		t.Errorf("the apex NS records were treated as a referral")
+	}
+}
+
+func TestIsReferral(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("www.trace.net.", dns.TypeA)
+	m.Ns = []dns.RR{&dns.NS{
+		Hdr: dns.RR_Header{Name: "trace.net.", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+		Ns:  "ns.trace.net.",
+	}}
+
+	if !isReferral(m) {
+		t.Errorf("failed to identify the referral")
+	}
+	if m.Authoritative = true; isReferral(m) {
+		t.Errorf("the authoritative response was identified as a referral")
+	}
+
+	m.Authoritative = false
+	m.Ns = append(m.Ns, &dns.SOA{
+		Hdr: dns.RR_Header{Name: "trace.net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+		Ns:  "ns.trace.net.",
+	})
+	if isReferral(m) {
+		t.Errorf("the response containing a SOA record was identified as a referral")
+	}
+}
+
+// apexNSHandler returns NODATA responses that include the apex NS records in the authority section
+func apexNSHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.Authoritative = true
+
+	if req.Question[0].Qtype != dns.TypeDNSKEY {
+		m.Ns = []dns.RR{&dns.NS{
+			Hdr: dns.RR_Header{Name: "trace.net.", Rrtype: dns.TypeNS, Class: dns.ClassINET},
+			Ns:  "ns.trace.net.",
+		}}
+	}
+	_ = w.WriteMsg(m)
+}
+
+func TestTraceMinimized(t *testing.T) {
+	var lock sync.Mutex
+	var names []string
+	root, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			name := req.Question[0].Name
+			lock.Lock()
+			names = append(names, name)
+			lock.Unlock()
+
+			if name == "net." && req.Question[0].Qtype != dns.TypeDNSKEY {
+				m := new(dns.Msg)
+				m.SetReply(req)
+				m.Authoritative = true
+				m.Ns = []dns.RR{&dns.SOA{
+					Hdr:  dns.RR_Header{Name: "net.", Rrtype: dns.TypeSOA, Class: dns.ClassINET},
+					Ns:   "a.root-servers.net.",
+					Mbox: "hostmaster.net.",
+				}}
+				_ = w.WriteMsg(m)
+				return
+			}
+			traceRootHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = root.Shutdown() }()
+
+	_, port, _ := net.SplitHostPort(addrstr)
+	child, _, _, err := RunLocalUDPServer(net.JoinHostPort("127.0.0.2", port), func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = child.Shutdown() }()
+
+	steps, err := trace(context.Background(), iterativeExchange, "www.trace.net.", dns.TypeA, []string{"127.0.0.1"}, port, 0, true)
+	if err != nil {
+		t.Fatalf("the minimized trace failed: %v", err)
+	}
+	if s := steps[len(steps)-1]; s.Zone != "trace.net." {
+		t.Errorf("the minimized trace did not reach the delegated server: %+v", s)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, name := range names {
+		if name == "www.trace.net." {
+			t.Errorf("the full name was revealed to the root server")
+		}
+	}
+}
+
+func TestNextMinimizedName(t *testing.T) {
+	for _, test := range []struct {
+		parent   string
+		expected string
+	}{
+		{".", "net."},
+		{"net.", "trace.net."},
+		{"trace.net.", "www.trace.net."},
+		{"www.trace.net.", "www.trace.net."},
+	} {
+		if got := nextMinimizedName("www.trace.net.", test.parent); got != test.expected {
+			t.Errorf("expected %s below %s, but got %s", test.expected, test.parent, got)
+		}
+	}
+}
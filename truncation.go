@@ -0,0 +1,130 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultTruncationSamples is the number of UDP responses used to evaluate the truncation rate.
+	DefaultTruncationSamples = 100
+	// minPreferredSamples is the number of TCP exchanges needed before the latencies are compared.
+	minPreferredSamples = 10
+	// rttWeight is the weight of the previous average when the latencies are smoothed.
+	rttWeight = 7
+)
+
+// TruncationOptions specifies when the resolvers that chronically truncate the responses are
+// switched to sending the queries over TCP.
+type TruncationOptions struct {
+	// Rate is the fraction of the UDP responses truncated that switches the resolver to TCP
+	Rate float64
+	// Samples is the number of UDP responses evaluated, and defaults to DefaultTruncationSamples
+	Samples uint64
+}
+
+// SetTruncationPolicy causes the resolvers that truncate the UDP responses at the provided
+// rate to receive the queries over TCP, which avoids sending each query twice. A resolver
+// returns to UDP once the latency over TCP becomes worse than the latency of the UDP exchanges
+// followed by the TCP retries. Providing nil disables the policy and returns the resolvers
+// to UDP.
+func (r *Resolvers) SetTruncationPolicy(opts *TruncationOptions) {
+	if opts == nil {
+		r.truncPolicy.Store(nil)
+		for _, res := range r.pool.AllResolvers() {
+			res.resetTruncation()
+		}
+		return
+	}
+
+	o := *opts
+	if o.Samples == 0 {
+		o.Samples = DefaultTruncationSamples
+	}
+	r.truncPolicy.Store(&o)
+}
+
+func (r *resolver) preferTCP() bool {
+	return r.tcpPreferred.Load() && r.pool.truncPolicy.Load() != nil
+}
+
+// preferredTCPExchange sends the prepared message over TCP in place of UDP.
+func (r *resolver) preferredTCPExchange(req *request, msg *dns.Msg) {
+	m, rtt, err := r.tcpSend(req, msg)
+	if err == nil {
+		r.recordPreferredRTT(rtt)
+	}
+	r.finishExchange(req, m, rtt, err)
+}
+
+// observeTruncation counts the UDP response, and switches the resolver to TCP once the
+// truncation rate of the evaluated responses reaches the rate of the policy.
+func (r *resolver) observeTruncation(truncated bool) {
+	opts := r.pool.truncPolicy.Load()
+	if opts == nil || r.tcpPreferred.Load() {
+		return
+	}
+
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.WindowResponses++
+	if truncated {
+		r.stats.WindowTruncations++
+	}
+	if r.stats.WindowResponses < opts.Samples {
+		return
+	}
+
+	rate := float64(r.stats.WindowTruncations) / float64(r.stats.WindowResponses)
+	r.stats.WindowResponses = 0
+	r.stats.WindowTruncations = 0
+	if rate >= opts.Rate {
+		r.stats.PreferredRTT = 0
+		r.stats.PreferredSamples = 0
+		r.tcpPreferred.Store(true)
+	}
+}
+
+// recordFallbackRTT smooths the latency of the UDP exchanges followed by the TCP retries.
+func (r *resolver) recordFallbackRTT(d time.Duration) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.FallbackRTT = smoothRTT(r.stats.FallbackRTT, d)
+}
+
+// recordPreferredRTT smooths the latency of the TCP exchanges, and returns the resolver to UDP
+// when the latency is worse than the latency of the TCP retries.
+func (r *resolver) recordPreferredRTT(d time.Duration) {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.PreferredRTT = smoothRTT(r.stats.PreferredRTT, d)
+	r.stats.PreferredSamples++
+	if r.stats.FallbackRTT > 0 && r.stats.PreferredSamples >= minPreferredSamples &&
+		r.stats.PreferredRTT > r.stats.FallbackRTT {
+		r.tcpPreferred.Store(false)
+	}
+}
+
+func (r *resolver) resetTruncation() {
+	r.stats.Lock()
+	defer r.stats.Unlock()
+
+	r.stats.WindowResponses = 0
+	r.stats.WindowTruncations = 0
+	r.tcpPreferred.Store(false)
+}
+
+func smoothRTT(avg, d time.Duration) time.Duration {
+	if avg == 0 {
+		return d
+	}
+	return (avg*rttWeight + d) / (rttWeight + 1)
+}
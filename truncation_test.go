@@ -0,0 +1,73 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTruncationPolicy(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	res := r.initializeResolver(10, "192.168.1.1")
+	res.observeTruncation(true)
+	if res.preferTCP() {
+		t.Errorf("the resolver was switched to TCP without a policy")
+	}
+
+	r.SetTruncationPolicy(&TruncationOptions{Rate: 0.5, Samples: 4})
+	res.observeTruncation(true)
+	res.observeTruncation(false)
+	res.observeTruncation(false)
+	res.observeTruncation(false)
+	if res.preferTCP() {
+		t.Errorf("the resolver was switched to TCP below the truncation rate")
+	}
+
+	for i := 0; i < 4; i++ {
+		res.observeTruncation(i%2 == 0)
+	}
+	if !res.preferTCP() {
+		t.Fatalf("the resolver was not switched to TCP at the truncation rate")
+	}
+
+	res.recordFallbackRTT(100 * time.Millisecond)
+	for i := 0; i < minPreferredSamples; i++ {
+		res.recordPreferredRTT(50 * time.Millisecond)
+	}
+	if !res.preferTCP() {
+		t.Errorf("the resolver returned to UDP while TCP was faster")
+	}
+	for i := 0; i < 3*minPreferredSamples; i++ {
+		res.recordPreferredRTT(500 * time.Millisecond)
+	}
+	if res.preferTCP() {
+		t.Errorf("the resolver did not return to UDP once TCP became slower")
+	}
+	if s := res.getStats(); s.TCPPreferred {
+		t.Errorf("the stats reported the resolver as preferring TCP")
+	}
+}
+
+func TestTruncationPolicyDisabled(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	_ = r.AddResolvers(10, "192.168.1.1")
+	res := r.pool.LookupResolver("192.168.1.1")
+	r.SetTruncationPolicy(&TruncationOptions{Rate: 0.5, Samples: 1})
+
+	res.observeTruncation(true)
+	if !res.getStats().TCPPreferred {
+		t.Fatalf("the resolver was not switched to TCP")
+	}
+
+	r.SetTruncationPolicy(nil)
+	if res.preferTCP() || res.getStats().TCPPreferred {
+		t.Errorf("disabling the policy did not return the resolver to UDP")
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"github.com/miekg/dns"
+)
+
+// TTLOptions specifies how the TTLs of resource records are rewritten before responses are returned.
+type TTLOptions struct {
+	// MinTTL raises smaller TTLs to this value, avoiding cache churn caused by zero TTLs
+	MinTTL uint32
+	// MaxTTL lowers larger TTLs to this value when greater than zero
+	MaxTTL uint32
+	// ZeroTTLs sets all TTLs to zero for export and takes precedence over the clamping
+	ZeroTTLs bool
+}
+
+// SetTTLOptions updates the settings used for rewriting the TTLs in responses returned by the pool.
+// Providing nil disables the rewriting.
+func (r *Resolvers) SetTTLOptions(opt *TTLOptions) {
+	r.ttlOptions.Store(opt)
+	r.syncSubPools()
+}
+
+func (r *Resolvers) rewriteTTLs(msg *dns.Msg) {
+	opt := r.ttlOptions.Load()
+	if opt == nil || msg == nil {
+		return
+	}
+
+	if opt.ZeroTTLs {
+		ZeroTTLs(msg)
+		return
+	}
+	ClampTTLs(msg, opt.MinTTL, opt.MaxTTL)
+}
+
+// ClampTTLs limits the TTLs of the resource records in the message to the provided range.
+// A max value of zero leaves the TTLs without an upper bound.
+func ClampTTLs(msg *dns.Msg, min, max uint32) {
+	eachRR(msg, func(hdr *dns.RR_Header) {
+		if hdr.Ttl < min {
+			hdr.Ttl = min
+		}
+		if max > 0 && hdr.Ttl > max {
+			hdr.Ttl = max
+		}
+	})
+}
+
+// ZeroTTLs sets the TTLs of the resource records in the message to zero.
+func ZeroTTLs(msg *dns.Msg) {
+	eachRR(msg, func(hdr *dns.RR_Header) {
+		hdr.Ttl = 0
+	})
+}
+
+func eachRR(msg *dns.Msg, callback func(hdr *dns.RR_Header)) {
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			// the TTL field of the OPT record carries the extended rcode and flags
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			callback(rr.Header())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestClampTTLs(t *testing.T) {
+	msg := ttlMsg(0, 300, 86400)
+	ClampTTLs(msg, 60, 3600)
+
+	for i, expected := range []uint32{60, 300, 3600} {
+		if ttl := msg.Answer[i].Header().Ttl; ttl != expected {
+			t.Errorf("the TTL was clamped to %d instead of %d", ttl, expected)
+		}
+	}
+	if opt := msg.IsEdns0(); opt == nil || !opt.Do() {
+		t.Errorf("the OPT record was modified by the TTL clamping")
+	}
+
+	msg = ttlMsg(86400)
+	if ClampTTLs(msg, 0, 0); msg.Answer[0].Header().Ttl != 86400 {
+		t.Errorf("the TTL was clamped without a maximum value")
+	}
+}
+
+func TestZeroTTLs(t *testing.T) {
+	msg := ttlMsg(0, 300, 86400)
+	ZeroTTLs(msg)
+
+	for _, rr := range msg.Answer {
+		if ttl := rr.Header().Ttl; ttl != 0 {
+			t.Errorf("the TTL was %d instead of zero", ttl)
+		}
+	}
+	if opt := msg.IsEdns0(); opt == nil || !opt.Do() {
+		t.Errorf("the OPT record was modified by zeroing the TTLs")
+	}
+}
+
+func TestSetTTLOptions(t *testing.T) {
+	name := "caffix.net."
+	dns.HandleFunc(name, typeAHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	r.SetTTLOptions(&TTLOptions{MinTTL: 30})
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if ttl := resp.Answer[0].Header().Ttl; ttl != 30 {
+		t.Errorf("the TTL in the response was %d instead of 30", ttl)
+	}
+
+	r.SetTTLOptions(nil)
+	resp, err = r.QueryBlocking(context.Background(), QueryMsg(name, dns.TypeA))
+	if err != nil || len(resp.Answer) == 0 {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if ttl := resp.Answer[0].Header().Ttl; ttl != 0 {
+		t.Errorf("the TTL was rewritten after the options were removed")
+	}
+}
+
+func ttlMsg(ttls ...uint32) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetQuestion("caffix.net.", dns.TypeA)
+	msg.SetEdns0(dns.DefaultMsgSize, true)
+
+	for _, ttl := range ttls {
+		msg.Answer = append(msg.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+			A:   net.ParseIP("192.168.1.1"),
+		})
+	}
+	return msg
+}
@@ -0,0 +1,111 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The kinds of TXT records identified by ClassifyTXT.
+const (
+	TXTUnknown      = "unknown"
+	TXTSPF          = "spf"
+	TXTDKIM         = "dkim"
+	TXTDMARC        = "dmarc"
+	TXTMTASTS       = "mta-sts"
+	TXTTLSRPT       = "tls-rpt"
+	TXTBIMI         = "bimi"
+	TXTVerification = "verification"
+)
+
+// TXTPattern maps the TXT records beginning with the prefix to a kind and service.
+type TXTPattern struct {
+	// Prefix is matched against the beginning of the record without regard to case
+	Prefix  string
+	Kind    string
+	Service string
+}
+
+// DefaultTXTPatterns contains the prefixes of the email policy records and the site
+// verification tokens of common services.
+var DefaultTXTPatterns = []TXTPattern{
+	{Prefix: "v=spf1", Kind: TXTSPF},
+	{Prefix: "v=DKIM1", Kind: TXTDKIM},
+	{Prefix: "v=DMARC1", Kind: TXTDMARC},
+	{Prefix: "v=STSv1", Kind: TXTMTASTS},
+	{Prefix: "v=TLSRPTv1", Kind: TXTTLSRPT},
+	{Prefix: "v=BIMI1", Kind: TXTBIMI},
+	{Prefix: "google-site-verification=", Kind: TXTVerification, Service: "Google"},
+	{Prefix: "MS=", Kind: TXTVerification, Service: "Microsoft 365"},
+	{Prefix: "facebook-domain-verification=", Kind: TXTVerification, Service: "Facebook"},
+	{Prefix: "apple-domain-verification=", Kind: TXTVerification, Service: "Apple"},
+	{Prefix: "atlassian-domain-verification=", Kind: TXTVerification, Service: "Atlassian"},
+	{Prefix: "adobe-idp-site-verification=", Kind: TXTVerification, Service: "Adobe"},
+	{Prefix: "docusign=", Kind: TXTVerification, Service: "DocuSign"},
+	{Prefix: "globalsign-domain-verification=", Kind: TXTVerification, Service: "GlobalSign"},
+	{Prefix: "stripe-verification=", Kind: TXTVerification, Service: "Stripe"},
+	{Prefix: "ZOOM_verify_", Kind: TXTVerification, Service: "Zoom"},
+	{Prefix: "slack-domain-verification=", Kind: TXTVerification, Service: "Slack"},
+	{Prefix: "dropbox-domain-verification=", Kind: TXTVerification, Service: "Dropbox"},
+	{Prefix: "yandex-verification:", Kind: TXTVerification, Service: "Yandex"},
+	{Prefix: "yandex-verification=", Kind: TXTVerification, Service: "Yandex"},
+	{Prefix: "pinterest-site-verification=", Kind: TXTVerification, Service: "Pinterest"},
+	{Prefix: "hubspot-developer-verification=", Kind: TXTVerification, Service: "HubSpot"},
+	{Prefix: "cisco-ci-domain-verification=", Kind: TXTVerification, Service: "Cisco"},
+	{Prefix: "onetrust-domain-verification=", Kind: TXTVerification, Service: "OneTrust"},
+	{Prefix: "have-i-been-pwned-verification=", Kind: TXTVerification, Service: "Have I Been Pwned"},
+}
+
+// TXTClassification describes a TXT record identified by ClassifyTXT.
+type TXTClassification struct {
+	Kind string
+	// Service is the provider of the verification tokens
+	Service string
+	// Token is the value following the prefix of the verification records
+	Token string
+	Value string
+}
+
+// ClassifyTXT identifies the TXT record using the first matching pattern, and nil patterns use
+// DefaultTXTPatterns. The records without a match have the TXTUnknown kind.
+func ClassifyTXT(value string, patterns []TXTPattern) *TXTClassification {
+	if patterns == nil {
+		patterns = DefaultTXTPatterns
+	}
+
+	value = strings.TrimSpace(value)
+	c := &TXTClassification{Kind: TXTUnknown, Value: value}
+	for _, p := range patterns {
+		if p.Prefix == "" || len(value) < len(p.Prefix) || !strings.EqualFold(value[:len(p.Prefix)], p.Prefix) {
+			continue
+		}
+
+		c.Kind = p.Kind
+		c.Service = p.Service
+		if p.Kind == TXTVerification {
+			c.Token = strings.TrimSpace(value[len(p.Prefix):])
+		}
+		break
+	}
+	return c
+}
+
+// ClassifyTXTRecords identifies each of the TXT records in the answer section of the response.
+// The strings of each record are concatenated without spaces.
+func ClassifyTXTRecords(resp *dns.Msg, patterns []TXTPattern) []*TXTClassification {
+	var results []*TXTClassification
+
+	if resp == nil {
+		return results
+	}
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			results = append(results, ClassifyTXT(strings.Join(txt.Txt, ""), patterns))
+		}
+	}
+	return results
+}
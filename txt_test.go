@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestClassifyTXT(t *testing.T) {
+	cases := []struct {
+		value   string
+		kind    string
+		service string
+		token   string
+	}{
+		{"v=spf1 include:_spf.google.com ~all", TXTSPF, "", ""},
+		{"V=DMARC1; p=reject", TXTDMARC, "", ""},
+		{"v=DKIM1; k=rsa; p=MIGf", TXTDKIM, "", ""},
+		{"google-site-verification=abc123", TXTVerification, "Google", "abc123"},
+		{"MS=ms12345678", TXTVerification, "Microsoft 365", "ms12345678"},
+		{"ZOOM_verify_xyz", TXTVerification, "Zoom", "xyz"},
+		{"some other text", TXTUnknown, "", ""},
+	}
+
+	for _, c := range cases {
+		got := ClassifyTXT(c.value, nil)
+		if got.Kind != c.kind || got.Service != c.service || got.Token != c.token || got.Value != c.value {
+			t.Errorf("%s was classified as %+v", c.value, got)
+		}
+	}
+
+	custom := []TXTPattern{{Prefix: "caffix-verification=", Kind: TXTVerification, Service: "Caffix"}}
+	if got := ClassifyTXT("caffix-verification=token", custom); got.Service != "Caffix" || got.Token != "token" {
+		t.Errorf("the custom pattern was not matched: %+v", got)
+	}
+}
+
+func TestClassifyTXTRecords(t *testing.T) {
+	resp := new(dns.Msg)
+	resp.SetReply(QueryMsg("caffix.net", dns.TypeTXT))
+	hdr := dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET}
+	resp.Answer = []dns.RR{
+		&dns.TXT{Hdr: hdr, Txt: []string{"v=spf1 ip4:192.0.2.0/24", " -all"}},
+		&dns.CNAME{Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeCNAME}, Target: "www.caffix.net."},
+		&dns.TXT{Hdr: hdr, Txt: []string{"facebook-domain-verification=fb"}},
+	}
+
+	results := ClassifyTXTRecords(resp, nil)
+	if len(results) != 2 {
+		t.Fatalf("%d records were classified instead of two", len(results))
+	}
+	if results[0].Kind != TXTSPF || results[0].Value != "v=spf1 ip4:192.0.2.0/24 -all" {
+		t.Errorf("the SPF record was classified as %+v", results[0])
+	}
+	if results[1].Service != "Facebook" || results[1].Token != "fb" {
+		t.Errorf("the verification record was classified as %+v", results[1])
+	}
+	if results := ClassifyTXTRecords(nil, nil); len(results) != 0 {
+		t.Errorf("records were classified for a nil response")
+	}
+}
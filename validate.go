@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// RcodeInvalidQuery is a special status code used to indicate the query failed validation
+// and was not sent to a resolver.
+const RcodeInvalidQuery int = 52
+
+// The reasons wrapped by InvalidQueryError.
+var (
+	ErrQuestionCount    = errors.New("the message must contain a question")
+	ErrInvalidName      = errors.New("the name is not a valid domain name")
+	ErrUnsupportedQtype = errors.New("the record type is not supported")
+	ErrQueryTooLarge    = errors.New("the message exceeds the UDP payload size")
+)
+
+// InvalidQueryError is returned when the message provided to the pool fails validation.
+type InvalidQueryError struct {
+	Name  string
+	Qtype uint16
+	Err   error
+}
+
+func (e *InvalidQueryError) Error() string {
+	return fmt.Sprintf("invalid query %s %s: %v", e.Name, dns.TypeToString[e.Qtype], e.Err)
+}
+
+func (e *InvalidQueryError) Unwrap() error {
+	return e.Err
+}
+
+// ValidateQuery checks that the message contains questions for legal domain names and record
+// types that can be queried over UDP, and that the message fits in the payload size advertised
+// by the message. The additional questions added by WithQuestion are accepted and validated,
+// since the pool matches the responses, sets the timeouts and applies the rate limits using only
+// the first question. The queries failing validation are answered immediately by the pool
+// with RcodeInvalidQuery, and QueryBlocking returns the *InvalidQueryError.
+func ValidateQuery(msg *dns.Msg) error {
+	if len(msg.Question) == 0 {
+		return &InvalidQueryError{Err: ErrQuestionCount}
+	}
+
+	for _, q := range msg.Question {
+		// IsDomainName checks the length limits of the labels and the name
+		if _, ok := dns.IsDomainName(q.Name); !ok || q.Name == "" {
+			return &InvalidQueryError{Name: q.Name, Qtype: q.Qtype, Err: ErrInvalidName}
+		}
+		if !queryableType(q.Qtype) {
+			return &InvalidQueryError{Name: q.Name, Qtype: q.Qtype, Err: ErrUnsupportedQtype}
+		}
+	}
+
+	q := msg.Question[0]
+	fail := func(err error) error {
+		return &InvalidQueryError{Name: q.Name, Qtype: q.Qtype, Err: err}
+	}
+
+	limit := dns.MinMsgSize
+	if opt := msg.IsEdns0(); opt != nil && int(opt.UDPSize()) > limit {
+		limit = int(opt.UDPSize())
+	}
+	if msg.Len() > limit {
+		return fail(ErrQueryTooLarge)
+	}
+	return nil
+}
+
+// queryableType returns false for the unknown record types, the pseudo types and the zone
+// transfers, which require TCP.
+func queryableType(qtype uint16) bool {
+	switch qtype {
+	case dns.TypeNone, dns.TypeOPT, dns.TypeTSIG, dns.TypeTKEY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeReserved:
+		return false
+	}
+	_, known := dns.TypeToString[qtype]
+	return known
+}
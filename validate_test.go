@@ -0,0 +1,92 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestValidateQuery(t *testing.T) {
+	long := QueryMsg("caffix.net", dns.TypeA)
+	long.Question[0].Name = strings.Repeat("a", 64) + ".caffix.net."
+
+	none := QueryMsg("caffix.net", dns.TypeA)
+	none.Question = nil
+
+	two := QueryMsg("caffix.net", dns.TypeA, WithQuestion("www.caffix.net", dns.TypeAAAA))
+	badSecond := QueryMsg("caffix.net", dns.TypeA, WithQuestion("caffix.net", dns.TypeAXFR))
+
+	large := new(dns.Msg)
+	large.SetQuestion("caffix.net.", dns.TypeA)
+	for i := 0; i < 50; i++ {
+		large.Extra = append(large.Extra, &dns.TXT{
+			Hdr: dns.RR_Header{Name: "caffix.net.", Rrtype: dns.TypeTXT, Class: dns.ClassINET},
+			Txt: []string{"padding"},
+		})
+	}
+
+	cases := []struct {
+		label string
+		msg   *dns.Msg
+		err   error
+	}{
+		{"valid", QueryMsg("www.caffix.net", dns.TypeAAAA), nil},
+		{"no question", none, ErrQuestionCount},
+		{"two questions", two, nil},
+		{"invalid second question", badSecond, ErrUnsupportedQtype},
+		{"long label", long, ErrInvalidName},
+		{"unknown type", QueryMsg("caffix.net", 4000), ErrUnsupportedQtype},
+		{"zone transfer", QueryMsg("caffix.net", dns.TypeAXFR), ErrUnsupportedQtype},
+		{"too large", large, ErrQueryTooLarge},
+	}
+	for _, c := range cases {
+		err := ValidateQuery(c.msg)
+		if c.err == nil {
+			if err != nil {
+				t.Errorf("%s: the valid query failed validation: %v", c.label, err)
+			}
+			continue
+		}
+
+		var verr *InvalidQueryError
+		if !errors.As(err, &verr) || !errors.Is(err, c.err) {
+			t.Errorf("%s: the expected validation error was not returned: %v", c.label, err)
+		}
+	}
+}
+
+func TestQueryValidation(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	resp, err := r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeAXFR))
+	if !errors.Is(err, ErrUnsupportedQtype) {
+		t.Errorf("the validation error was not returned: %v", err)
+	}
+	if resp == nil || resp.Rcode != RcodeInvalidQuery {
+		t.Errorf("the response did not have the invalid query rcode")
+	}
+
+	// the questions added by WithQuestion are accepted by the pool
+	resp, err = r.QueryBlocking(ctx, QueryMsg("caffix.net", dns.TypeA, WithQuestion("www.caffix.net", dns.TypeA)))
+	if errors.Is(err, ErrQuestionCount) || resp == nil || resp.Rcode == RcodeInvalidQuery {
+		t.Errorf("the message with the additional question was rejected: %v", err)
+	}
+
+	none := QueryMsg("caffix.net", dns.TypeA)
+	none.Question = nil
+	if _, err := r.QueryWithRetries(ctx, none, MaxAttemptsPolicy(3)); !errors.Is(err, ErrQuestionCount) {
+		t.Errorf("the message without a question was not rejected: %v", err)
+	}
+}
@@ -50,8 +50,16 @@ func (r *Resolvers) NsecTraversal(ctx context.Context, domain string) ([]*dns.NS
 }
 
 func (r *Resolvers) searchGap(ctx context.Context, name, domain string) (*dns.NSEC, error) {
+	qctx := ctx
 	for i := 0; i < maxQueryAttempts; i++ {
-		resp, err := r.QueryBlocking(ctx, WalkMsg(name, dns.TypeNSEC))
+		if i > 0 {
+			if !r.RetryAllowed() {
+				break
+			}
+			qctx = WithRetry(ctx)
+		}
+
+		resp, err := r.QueryBlocking(qctx, WalkMsg(name, dns.TypeNSEC))
 		if err != nil || resp.Rcode == dns.RcodeNameError {
 			break
 		}
@@ -0,0 +1,74 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// Warmup prepares the pool before a scan starts by dialing the transports, sending the health
+// probe set by SetHealthProbe to each resolver and selecting the wildcard detection resolver. The returned
+// error joins the problems found, so dead upstreams are identified before the work begins.
+func (r *Resolvers) Warmup(ctx context.Context) error {
+	var errs []error
+
+	if r.conns == nil {
+		errs = append(errs, errors.New("failed to open the UDP sockets for the pool"))
+	}
+
+	all := r.pool.AllResolvers()
+	if len(all) == 0 {
+		return errors.Join(append(errs, errors.New("no resolvers have been added to the pool"))...)
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	for _, res := range all {
+		wg.Add(1)
+
+		go func(res *resolver) {
+			defer wg.Done()
+
+			if err := res.warmup(ctx); err != nil {
+				lock.Lock()
+				errs = append(errs, err)
+				lock.Unlock()
+			}
+		}(res)
+	}
+	wg.Wait()
+
+	if !r.goodDetector() {
+		errs = append(errs, errors.New("failed to select the wildcard detection resolver"))
+	}
+	return errors.Join(errs...)
+}
+
+func (r *resolver) warmup(ctx context.Context) error {
+	if r.pool.privacy.Load() {
+		if err := r.connectTLS(); err != nil {
+			return fmt.Errorf("resolver %s: failed to establish the DNS over TLS connection: %v", r.address.IP, err)
+		}
+	}
+
+	probe := r.pool.healthProbe()
+	return probe.Check(r.address.String(), r.exchangeQuery(ctx, probe.Msg()))
+}
+
+// healthCheckError returns the error for the rcode of the response to the health check query.
+func healthCheckError(addr string, rcode int) error {
+	switch rcode {
+	case RcodeNoResponse:
+		return fmt.Errorf("resolver %s: failed to respond to the health check", addr)
+	case dns.RcodeServerFailure, dns.RcodeNotImplemented, dns.RcodeRefused:
+		return fmt.Errorf("resolver %s: returned %s for the health check", addr, dns.RcodeToString[rcode])
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestWarmup(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	defer r.Stop()
+
+	if err := r.Warmup(context.Background()); err == nil {
+		t.Errorf("the warm-up did not fail for an empty pool")
+	}
+
+	_ = r.AddResolvers(10, addrstr)
+	if err := r.Warmup(context.Background()); err != nil {
+		t.Errorf("the warm-up failed: %v", err)
+	}
+	if r.getDetectionResolver() == nil {
+		t.Errorf("the warm-up did not select the wildcard detection resolver")
+	}
+}
+
+func TestWarmupErrors(t *testing.T) {
+	good, addr1, _, err := RunLocalUDPServer("127.0.0.1:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(typeAHandler)
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = good.Shutdown() }()
+
+	bad, addr2, _, err := RunLocalUDPServer("127.0.0.2:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(refusedHandler)
+	})
+	if err != nil {
+		t.Skipf("unable to run the second test server: %v", err)
+	}
+	defer func() { _ = bad.Shutdown() }()
+
+	r := NewResolvers()
+	r.SetTimeout(500 * time.Millisecond)
+	_ = r.AddResolvers(10, addr1, addr2, "127.0.0.3:1")
+	defer r.Stop()
+
+	err = r.Warmup(context.Background())
+	if err == nil {
+		t.Fatalf("the warm-up did not report the unhealthy resolvers")
+	}
+	if msg := err.Error(); strings.Contains(msg, addr1) {
+		t.Errorf("the warm-up reported the healthy resolver: %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, addr2) || !strings.Contains(msg, "REFUSED") {
+		t.Errorf("the warm-up did not report the resolver that refused the query: %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "127.0.0.3:1") {
+		t.Errorf("the warm-up did not report the resolver that failed to respond: %v", err)
+	}
+}
+
+func refusedHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetRcode(req, dns.RcodeRefused)
+	_ = w.WriteMsg(m)
+}
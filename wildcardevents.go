@@ -0,0 +1,114 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WildcardEvent describes the result of testing a subdomain for a DNS wildcard.
+type WildcardEvent struct {
+	Subdomain string
+	Detected  bool
+	// Answers contains the records returned for the unlikely names
+	Answers []*ExtractedAnswer
+	// Retest is set when the event was caused by RetestWildcard
+	Retest bool
+	Time   time.Time
+}
+
+type wildSubscribers struct {
+	sync.Mutex
+	subs map[chan *WildcardEvent]struct{}
+}
+
+// SubscribeWildcards returns a channel receiving an event each time a new wildcard is detected
+// or a subdomain is retested, along with the function that ends the subscription. Events are
+// dropped when the channel buffer is full, so the detection is never delayed by a slow reader.
+func (r *Resolvers) SubscribeWildcards() (<-chan *WildcardEvent, func()) {
+	ch := make(chan *WildcardEvent, monitorEventsBuffer)
+
+	ws := r.wildSubs
+	ws.Lock()
+	if ws.subs == nil {
+		ws.subs = make(map[chan *WildcardEvent]struct{})
+	}
+	ws.subs[ch] = struct{}{}
+	ws.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			ws.Lock()
+			delete(ws.subs, ch)
+			ws.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// RetestWildcard performs the wildcard test for the subdomain again and replaces the result
+// used by WildcardDetected. It returns true when the subdomain has a DNS wildcard.
+func (r *Resolvers) RetestWildcard(ctx context.Context, sub string) bool {
+	if !r.goodDetector() {
+		return false
+	}
+
+	sub = strings.ToLower(RemoveLastDot(sub))
+	detected, answers := r.wildcardTest(withoutScanSession(ctx), sub)
+
+	r.Lock()
+	w, found := r.lookupWildcard(sub)
+	if !found {
+		w = &wildcard{}
+		r.storeWildcard(sub, w)
+	}
+	r.Unlock()
+
+	w.Lock()
+	w.setResult(detected, answers)
+	w.tested = r.clock.Now()
+	w.Unlock()
+
+	r.emitWildcardEvent(sub, detected, answers, true)
+	return detected
+}
+
+func (r *Resolvers) emitWildcardEvent(sub string, detected bool, answers []*ExtractedAnswer, retest bool) {
+	if detected {
+		r.emitEvent(&Event{Type: EventWildcardDetected, Subdomain: sub})
+	}
+
+	ws := r.wildSubs
+	ws.Lock()
+	defer ws.Unlock()
+
+	if len(ws.subs) == 0 {
+		return
+	}
+
+	var ans []*ExtractedAnswer
+	for _, a := range answers {
+		c := *a
+		ans = append(ans, &c)
+	}
+
+	ev := &WildcardEvent{
+		Subdomain: sub,
+		Detected:  detected,
+		Answers:   ans,
+		Retest:    retest,
+		Time:      r.clock.Now(),
+	}
+	for ch := range ws.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2024. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolve
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestSubscribeWildcards(t *testing.T) {
+	name := "domain.com."
+	dns.HandleFunc(name, wildcardHandler)
+	defer dns.HandleRemove(name)
+
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0")
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+
+	events, unsubscribe := r.SubscribeWildcards()
+	defer unsubscribe()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("jeff_foley.wildcard.domain.com", dns.TypeA))
+	if err != nil {
+		t.Fatalf("the query failed: %v", err)
+	}
+	if !r.WildcardDetected(context.Background(), resp, "domain.com") {
+		t.Fatalf("the wildcard was not detected")
+	}
+
+	next := func() *WildcardEvent {
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatalf("no wildcard event was received")
+		}
+		return nil
+	}
+
+	ev := next()
+	if ev.Subdomain != "wildcard.domain.com" || !ev.Detected || ev.Retest {
+		t.Errorf("the wildcard event was not correct: %+v", ev)
+	}
+	if len(ev.Answers) == 0 || ev.Answers[0].Data != "192.168.1.64" {
+		t.Errorf("the wildcard event did not include the answers")
+	}
+	// the subdomains without a wildcard do not produce events
+	select {
+	case ev := <-events:
+		t.Errorf("an event was received for %s", ev.Subdomain)
+	default:
+	}
+
+	if !r.RetestWildcard(context.Background(), "Wildcard.Domain.com.") {
+		t.Errorf("the retest did not detect the wildcard")
+	}
+	if ev := next(); ev.Subdomain != "wildcard.domain.com" || !ev.Retest {
+		t.Errorf("the retest event was not correct: %+v", ev)
+	}
+	if r.RetestWildcard(context.Background(), "www.domain.com") {
+		t.Errorf("the retest detected a wildcard that does not exist")
+	}
+	if ev := next(); ev.Detected {
+		t.Errorf("the retest event reported a wildcard for %s", ev.Subdomain)
+	}
+
+	unsubscribe()
+	if _, ok := <-events; ok {
+		t.Errorf("the channel was not closed after ending the subscription")
+	}
+}
@@ -5,11 +5,14 @@
 package resolve
 
 import (
+	"container/list"
 	"context"
 	"math/rand"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
@@ -39,6 +42,16 @@ type wildcard struct {
 	sync.Mutex
 	Detected bool
 	Answers  []*ExtractedAnswer
+	// elem is the position in the LRU list of the pool, which is protected by the pool lock
+	elem *list.Element
+	// size is the approximate bytes held by the answers
+	size atomic.Int64
+	// tested is the time of the test performed by the pool
+	tested time.Time
+	// ready is closed once the result of the test performed by the first caller is assigned
+	ready chan struct{}
+	// abandoned is set when the context of the tester expired before the result was obtained
+	abandoned bool
 }
 
 // UnlikelyName takes a subdomain name and returns an unlikely DNS name within that subdomain.
@@ -85,14 +98,18 @@ func (r *Resolvers) WildcardDetected(ctx context.Context, resp *dns.Msg, domain
 	}
 
 	var found bool
+	tctx := withoutScanSession(ctx)
 	// Check for a DNS wildcard at each label starting with the registered domain
 	RegisteredToFQDN(domain, name, func(sub string) bool {
-		if w := r.getWildcard(ctx, sub); w.respMatchesWildcard(resp) {
+		if w := r.getWildcard(tctx, sub); w.respMatchesWildcard(resp) {
 			found = true
 			return true
 		}
 		return false
 	})
+	if found {
+		r.scanSession(ctx).record(resp.Question[0].Name, outcomeWildcard)
+	}
 	return found
 }
 
@@ -109,12 +126,15 @@ func (r *Resolvers) SetDetectionResolver(qps int, addr string) {
 	if uaddr, err := net.ResolveUDPAddr("udp", addr); err == nil {
 		if _, found := r.rmap[uaddr.IP.String()]; found {
 			r.detector = r.pool.LookupResolver(uaddr.IP.String())
+			r.detectorGood.Store(r.clock.Now().UnixNano())
 			return
 		}
 		if res := r.initializeResolver(qps, addr); res != nil {
 			r.rmap[res.address.IP.String()] = struct{}{}
 			r.pool.AddResolver(res)
 			r.detector = res
+			r.detectorGood.Store(r.clock.Now().UnixNano())
+			res.start(r.power.current())
 		}
 	}
 }
@@ -129,7 +149,9 @@ func (r *Resolvers) getDetectionResolver() *resolver {
 func (r *Resolvers) goodDetector() bool {
 	success := true
 
-	if d := r.getDetectionResolver(); d == nil {
+	if d := r.getDetectionResolver(); d != nil && d.stopped() {
+		success = r.failoverDetector(d)
+	} else if d == nil {
 		success = false
 
 		if d = r.pool.GetResolver(); d != nil {
@@ -143,23 +165,80 @@ func (r *Resolvers) goodDetector() bool {
 	return success
 }
 
+// getWildcard returns the wildcard test result for the subdomain. Only the first caller
+// performs the test, and the concurrent callers wait for the result. When the context of the
+// tester expires during the test, the result is not kept and the next caller tests again.
 func (r *Resolvers) getWildcard(ctx context.Context, sub string) *wildcard {
-	r.Lock()
-	w, found := r.wildcards[sub]
-	if !found {
-		w = &wildcard{}
-		r.wildcards[sub] = w
+	for {
+		r.Lock()
+		w, found := r.lookupWildcard(sub)
+		if !found {
+			w = &wildcard{ready: make(chan struct{})}
+			r.storeWildcard(sub, w)
+		}
+		r.Unlock()
+
+		if !found {
+			return r.testWildcard(ctx, sub, w)
+		}
+		if !w.wait(ctx) || !w.wasAbandoned() {
+			return w
+		}
 	}
-	r.Unlock()
+}
+
+// testWildcard performs the test for the subdomain and assigns the result to the wildcard.
+func (r *Resolvers) testWildcard(ctx context.Context, sub string, w *wildcard) *wildcard {
+	detected, answers := r.wildcardTest(ctx, sub)
+	if ctx.Err() != nil {
+		r.Lock()
+		if cur, found := r.wildcards[sub]; found && cur == w {
+			r.wildLRU.Remove(w.elem)
+			delete(r.wildcards, sub)
+		}
+		r.Unlock()
 
-	if !found {
 		w.Lock()
-		w.Detected, w.Answers = r.wildcardTest(ctx, sub)
+		w.abandoned = true
 		w.Unlock()
+		close(w.ready)
+		return w
+	}
+
+	w.Lock()
+	w.setResult(detected, answers)
+	w.tested = r.clock.Now()
+	w.Unlock()
+	close(w.ready)
+
+	if detected {
+		r.emitWildcardEvent(sub, detected, answers, false)
 	}
 	return w
 }
 
+// wait blocks until the test performed by another caller has finished, and returns false
+// when the context expired first.
+func (w *wildcard) wait(ctx context.Context) bool {
+	if w.ready == nil {
+		return true
+	}
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-w.ready:
+	}
+	return true
+}
+
+func (w *wildcard) wasAbandoned() bool {
+	w.Lock()
+	defer w.Unlock()
+
+	return w.abandoned
+}
+
 func (w *wildcard) respMatchesWildcard(resp *dns.Msg) bool {
 	w.Lock()
 	defer w.Unlock()
@@ -185,9 +264,26 @@ func (w *wildcard) respMatchesWildcard(resp *dns.Msg) bool {
 func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*ExtractedAnswer) {
 	var detected bool
 	var answers []*ExtractedAnswer
+	// the queries are abandoned when the pool is stopped during the test
+	ctx, cancel := r.poolContext(ctx)
+	defer cancel()
 
 	set := stringset.New()
 	defer set.Close()
+
+	var source string
+	query := r.makeQueryAttempts
+	if servers := r.wildcardServers(ctx, sub); len(servers) > 0 {
+		source = strings.Join(servers, ",")
+		query = func(ctx context.Context, name string, qtype uint16) []*ExtractedAnswer {
+			return r.authQueryAttempts(ctx, servers, name, qtype)
+		}
+	} else if detector := r.getDetectionResolver(); detector != nil {
+		source = detector.address.String()
+	} else {
+		// the wildcard cannot be tested without the detection resolver
+		return false, nil
+	}
 	// Query multiple times with unlikely names against this subdomain
 	for i := 0; i < numOfWildcardTests; i++ {
 		var name string
@@ -200,7 +296,7 @@ func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*Extr
 
 		var ans []*ExtractedAnswer
 		for _, t := range wildcardQueryTypes {
-			if a := r.makeQueryAttempts(ctx, name, t); len(a) > 0 {
+			if a := query(ctx, name, t); len(a) > 0 {
 				detected = true
 				ans = append(ans, a...)
 			}
@@ -228,7 +324,7 @@ func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*Extr
 		}
 	}
 	if detected {
-		r.log.Printf("DNS wildcard detected: Resolver %s: %s", r.detector.address, "*."+sub)
+		r.logf(ctx, "DNS wildcard detected: Resolver %s: %s", source, "*."+sub)
 	}
 	return detected, final
 }
@@ -236,9 +332,19 @@ func (r *Resolvers) wildcardTest(ctx context.Context, sub string) (bool, []*Extr
 func (r *Resolvers) makeQueryAttempts(ctx context.Context, name string, qtype uint16) []*ExtractedAnswer {
 	ch := make(chan *dns.Msg, 1)
 	detector := r.getDetectionResolver()
+	if detector == nil {
+		return nil
+	}
 loop:
 	for i := 0; i < maxQueryAttempts; i++ {
+		if i > 0 && !r.RetryAllowed() {
+			break
+		} else if i == 0 {
+			r.budgetRequest()
+		}
+
 		req := &request{
+			Ctx:    ctx,
 			Res:    detector,
 			Msg:    QueryMsg(name, qtype),
 			Result: ch,
@@ -283,3 +389,18 @@ func insertRecordData(set *stringset.Set, ans []*ExtractedAnswer) {
 	}
 	set.Union(records)
 }
+
+// poolContext returns a context that is also canceled when the pool is stopped, so the work
+// performed on behalf of the caller does not outlive the pool.
+func (r *Resolvers) poolContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if r.ctx == nil {
+		return ctx, cancel
+	}
+
+	stop := context.AfterFunc(r.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"context"
 	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/miekg/dns"
 )
@@ -72,6 +75,138 @@ func TestWildcardDetected(t *testing.T) {
 	}
 }
 
+func TestWildcardTestStop(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer pc.Close()
+
+	r := NewResolvers()
+	r.SetTimeout(time.Minute)
+	r.SetDetectionResolver(10, pc.LocalAddr().String())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = r.getWildcard(context.Background(), "caffix.net")
+	}()
+
+	// the test is waiting for the unanswered queries when the pool is stopped
+	time.Sleep(100 * time.Millisecond)
+	r.Stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Errorf("the wildcard test was not abandoned when the pool was stopped")
+	}
+}
+
+func TestWildcardTestOnce(t *testing.T) {
+	var queries atomic.Int32
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			queries.Add(1)
+			wildcardHandler(w, req)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetDetectionResolver(100, addrstr)
+
+	var wg sync.WaitGroup
+	var detected atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			w := r.getWildcard(context.Background(), "wildcard.domain.com")
+			w.Lock()
+			if w.Detected {
+				detected.Add(1)
+			}
+			w.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if n := detected.Load(); n != 10 {
+		t.Errorf("%d of the concurrent callers obtained the detected wildcard", n)
+	}
+	if n, expected := queries.Load(), int32(numOfWildcardTests*len(wildcardQueryTypes)); n != expected {
+		t.Errorf("the concurrent callers sent %d queries instead of %d", n, expected)
+	}
+}
+
+func TestWildcardTestNoDetector(t *testing.T) {
+	r := NewResolvers()
+	defer r.Stop()
+
+	if w := r.getWildcard(context.Background(), "caffix.net"); w.Detected {
+		t.Errorf("a wildcard was detected without the detection resolver")
+	}
+}
+
+func TestWildcardTestCanceled(t *testing.T) {
+	var unanswered atomic.Bool
+	unanswered.Store(true)
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			// the queries of the first test are never answered
+			if !unanswered.Load() {
+				wildcardHandler(w, req)
+			}
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	r.SetTimeout(time.Minute)
+	_ = r.AddResolvers(100, addrstr)
+	defer r.Stop()
+	r.SetDetectionResolver(100, addrstr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// the tester remains waiting on the first query after the server begins to respond
+	time.AfterFunc(100*time.Millisecond, func() { unanswered.Store(false) })
+	// the waiter is provided the result of the test performed after the tester gave up
+	waiter := make(chan *wildcard, 1)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		waiter <- r.getWildcard(context.Background(), "wildcard.domain.com")
+	}()
+
+	if w := r.getWildcard(ctx, "wildcard.domain.com"); w.Detected {
+		t.Fatalf("the wildcard was detected without responses")
+	}
+
+	select {
+	case w := <-waiter:
+		w.Lock()
+		detected := w.Detected
+		w.Unlock()
+		if !detected {
+			t.Errorf("the waiter obtained the result of the canceled test")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the waiter did not obtain the wildcard test result")
+	}
+	if w := r.getWildcard(context.Background(), "wildcard.domain.com"); !w.Detected {
+		t.Errorf("the result of the canceled test was kept")
+	}
+}
+
 func wildcardHandler(w dns.ResponseWriter, req *dns.Msg) {
 	m := new(dns.Msg)
 	m.SetReply(req)
@@ -103,3 +238,29 @@ func wildcardHandler(w dns.ResponseWriter, req *dns.Msg) {
 	}
 	_ = w.WriteMsg(m)
 }
+
+func FuzzRespMatchesWildcard(f *testing.F) {
+	m := ttlReply(QueryMsg("foo.wildcard.caffix.net", dns.TypeA), 300)
+	if b, err := m.Pack(); err == nil {
+		f.Add(b, "192.168.1.1", true)
+		f.Add(b, "", false)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte, data string, detected bool) {
+		resp := new(dns.Msg)
+		if err := resp.Unpack(b); err != nil && len(resp.Question) == 0 {
+			return
+		}
+
+		w := &wildcard{Detected: detected}
+		for _, d := range strings.Split(data, ",") {
+			if d != "" {
+				w.Answers = append(w.Answers, &ExtractedAnswer{Type: dns.TypeA, Data: d})
+			}
+		}
+
+		if w.respMatchesWildcard(resp) && !detected {
+			t.Errorf("the response matched a wildcard that was not detected")
+		}
+	})
+}
@@ -5,6 +5,7 @@
 package resolve
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -26,17 +27,75 @@ var reqPool = sync.Pool{
 }
 
 type request struct {
+	Ctx       context.Context
 	Res       *resolver
+	Priority  int
+	Leased    bool
+	Meta      *metadata
+	Session   *scanSession
+	Journal   *Journal
+	JournalID uint64
+	Queued    time.Time
 	Timestamp time.Time
 	Msg, Resp *dns.Msg
 	Result    chan *dns.Msg
 }
 
+func (r *request) expired() bool {
+	if r.Ctx == nil {
+		return false
+	}
+
+	select {
+	case <-r.Ctx.Done():
+		return true
+	default:
+	}
+	return false
+}
+
+func (r *request) context() context.Context {
+	if r.Ctx == nil {
+		return context.Background()
+	}
+	return r.Ctx
+}
+
 func (r *request) errNoResponse() {
 	if r.Msg != nil {
 		r.Msg.Rcode = RcodeNoResponse
 	}
-	r.Result <- r.Msg
+	r.recordOutcome(r.Msg)
+	deliver(r.context(), r.Result, r.Msg)
+}
+
+// errNoResponseStats is errNoResponse for the requests sent to the resolver, which counts the
+// failure in the stats before the message is returned to the caller.
+func (r *request) errNoResponseStats(res *resolver) {
+	if r.Msg != nil {
+		r.Msg.Rcode = RcodeNoResponse
+	}
+	res.collectStats(r.Msg)
+	r.errNoResponse()
+}
+
+// deliver sends the message on the result channel without blocking the goroutines of the pool.
+// When the caller is not ready to receive, the message is sent by a new goroutine, which gives
+// up once the context of the query expires, so callers abandoning the channel cannot stall
+// the pool.
+func deliver(ctx context.Context, ch chan *dns.Msg, msg *dns.Msg) {
+	select {
+	case ch <- msg:
+		return
+	default:
+	}
+
+	go func() {
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+		}
+	}()
 }
 
 func (r *request) release() {
@@ -48,16 +107,30 @@ func (r *request) release() {
 type xchgMgr struct {
 	sync.Mutex
 	timeout time.Duration
+	qtypes  map[uint16]time.Duration
 	xchgs   map[string]*request
+	peak    int
+	clock   Clock
 }
 
-func newXchgMgr(d time.Duration) *xchgMgr {
+func newXchgMgr(d time.Duration, clock Clock) *xchgMgr {
 	return &xchgMgr{
+		clock:   clock,
 		timeout: d,
+		qtypes:  make(map[uint16]time.Duration),
 		xchgs:   make(map[string]*request),
 	}
 }
 
+// acceptableResponse returns true when the message can be matched to a request. Responses
+// without a question section are only accepted when they carry nothing but an error rcode.
+func acceptableResponse(m *dns.Msg) bool {
+	if len(m.Question) > 0 {
+		return true
+	}
+	return m.Response && m.Rcode != dns.RcodeSuccess && len(m.Answer) == 0 && len(m.Ns) == 0
+}
+
 func xchgKey(id uint16, name string) string {
 	return fmt.Sprintf("%d:%s", id, strings.ToLower(RemoveLastDot(name)))
 }
@@ -69,15 +142,56 @@ func (r *xchgMgr) setTimeout(d time.Duration) {
 	r.timeout = d
 }
 
+func (r *xchgMgr) getTimeout() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.timeout
+}
+
+func (r *xchgMgr) setQtypeTimeouts(timeouts map[uint16]time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.qtypes = make(map[uint16]time.Duration, len(timeouts))
+	for qtype, d := range timeouts {
+		r.qtypes[qtype] = d
+	}
+}
+
+func (r *xchgMgr) getQtypeTimeout(qtype uint16) time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.qtypeTimeout(qtype)
+}
+
+// qtypeTimeout returns the expiration for queries of the type. The caller must hold the lock.
+func (r *xchgMgr) qtypeTimeout(qtype uint16) time.Duration {
+	if d, found := r.qtypes[qtype]; found {
+		return d
+	}
+	return r.timeout
+}
+
 func (r *xchgMgr) add(req *request) error {
+	return r.addWithID(req, req.Msg.Id)
+}
+
+// addWithID tracks the request using the message ID sent on the wire, which can differ
+// from the ID of the query after the pre-send hook was called.
+func (r *xchgMgr) addWithID(req *request, id uint16) error {
 	r.Lock()
 	defer r.Unlock()
 
-	key := xchgKey(req.Msg.Id, req.Msg.Question[0].Name)
+	key := xchgKey(id, req.Msg.Question[0].Name)
 	if _, found := r.xchgs[key]; found {
 		return fmt.Errorf("key %s is already in use", key)
 	}
 	r.xchgs[key] = req
+	if n := len(r.xchgs); n > r.peak {
+		r.peak = n
+	}
 	return nil
 }
 
@@ -89,7 +203,7 @@ func (r *xchgMgr) updateTimestamp(id uint16, name string) {
 	if _, found := r.xchgs[key]; !found {
 		return
 	}
-	r.xchgs[key].Timestamp = time.Now()
+	r.xchgs[key].Timestamp = r.clock.Now()
 }
 
 func (r *xchgMgr) remove(id uint16, name string) *request {
@@ -103,20 +217,49 @@ func (r *xchgMgr) remove(id uint16, name string) *request {
 	return nil
 }
 
+// removeByID removes the request with the message ID when it is the only outstanding
+// request using the ID, since responses without a question cannot be matched otherwise.
+func (r *xchgMgr) removeByID(id uint16) *request {
+	r.Lock()
+	defer r.Unlock()
+
+	prefix := fmt.Sprintf("%d:", id)
+	var keys []string
+	for key := range r.xchgs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) != 1 {
+		return nil
+	}
+	return r.delete(keys)[0]
+}
+
 func (r *xchgMgr) removeExpired() []*request {
 	r.Lock()
 	defer r.Unlock()
 
-	now := time.Now()
+	now := r.clock.Now()
 	var keys []string
 	for key, req := range r.xchgs {
-		if !req.Timestamp.IsZero() && now.After(req.Timestamp.Add(r.timeout)) {
+		if req.Timestamp.IsZero() {
+			continue
+		}
+		if now.After(req.Timestamp.Add(r.qtypeTimeout(req.Msg.Question[0].Qtype))) {
 			keys = append(keys, key)
 		}
 	}
 	return r.delete(keys)
 }
 
+func (r *xchgMgr) empty() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return len(r.xchgs) == 0
+}
+
 func (r *xchgMgr) removeAll() []*request {
 	r.Lock()
 	defer r.Unlock()
@@ -136,5 +279,10 @@ func (r *xchgMgr) delete(keys []string) []*request {
 		r.xchgs[k] = nil
 		delete(r.xchgs, k)
 	}
+	// replace the map after a burst, since deleting the entries does not release the memory
+	if len(r.xchgs) == 0 && r.peak > xchgShrinkSize {
+		r.xchgs = make(map[string]*request)
+		r.peak = 0
+	}
 	return removed
 }
@@ -5,6 +5,8 @@
 package resolve
 
 import (
+	"context"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -15,7 +17,7 @@ import (
 
 func TestXchgAddRemove(t *testing.T) {
 	name := "caffix.net"
-	xchg := newXchgMgr(DefaultTimeout)
+	xchg := newXchgMgr(DefaultTimeout, realClock{})
 	msg := QueryMsg(name, dns.TypeA)
 	req := &request{Msg: msg}
 	if err := xchg.add(req); err != nil {
@@ -40,7 +42,7 @@ func TestXchgAddRemove(t *testing.T) {
 
 func TestXchgUpdateTimestamp(t *testing.T) {
 	name := "caffix.net"
-	xchg := newXchgMgr(DefaultTimeout)
+	xchg := newXchgMgr(DefaultTimeout, realClock{})
 	msg := QueryMsg(name, dns.TypeA)
 	req := &request{Msg: msg}
 
@@ -61,14 +63,15 @@ func TestXchgUpdateTimestamp(t *testing.T) {
 }
 
 func TestXchgRemoveExpired(t *testing.T) {
-	xchg := newXchgMgr(time.Second)
+	clock := NewSimClock(time.Now())
+	xchg := newXchgMgr(time.Second, clock)
 	names := []string{"caffix.net", "www.caffix.net", "blog.caffix.net"}
 
 	for _, name := range names {
 		msg := QueryMsg(name, dns.TypeA)
 		if err := xchg.add(&request{
 			Msg:       msg,
-			Timestamp: time.Now(),
+			Timestamp: clock.Now(),
 		}); err != nil {
 			t.Errorf("Failed to add the request")
 		}
@@ -78,7 +81,7 @@ func TestXchgRemoveExpired(t *testing.T) {
 	msg := QueryMsg(name, dns.TypeA)
 	if err := xchg.add(&request{
 		Msg:       msg,
-		Timestamp: time.Now().Add(3 * time.Second),
+		Timestamp: clock.Now().Add(3 * time.Second),
 	}); err != nil {
 		t.Errorf("Failed to add the request")
 	}
@@ -86,7 +89,7 @@ func TestXchgRemoveExpired(t *testing.T) {
 		t.Errorf("The removeExpired method returned requests too early")
 	}
 
-	time.Sleep(1500 * time.Millisecond)
+	clock.Advance(1500 * time.Millisecond)
 	set := stringset.New(names...)
 	defer set.Close()
 
@@ -101,7 +104,7 @@ func TestXchgRemoveExpired(t *testing.T) {
 }
 
 func TestXchgRemoveAll(t *testing.T) {
-	xchg := newXchgMgr(time.Second)
+	xchg := newXchgMgr(time.Second, realClock{})
 	names := []string{"caffix.net", "www.caffix.net", "blog.caffix.net"}
 
 	for _, name := range names {
@@ -123,3 +126,165 @@ func TestXchgRemoveAll(t *testing.T) {
 		t.Errorf("Not all expected requests were returned by removeAll")
 	}
 }
+
+func TestXchgNameVariants(t *testing.T) {
+	xchg := newXchgMgr(DefaultTimeout, realClock{})
+
+	for _, variant := range []string{"WWW.Caffix.Net.", "www.caffix.net", "www.CAFFIX.net"} {
+		msg := QueryMsg("www.caffix.net", dns.TypeA)
+		if err := xchg.add(&request{Msg: msg}); err != nil {
+			t.Fatalf("failed to add the request")
+		}
+		if req := xchg.remove(msg.Id, variant); req == nil {
+			t.Errorf("the request was not matched using the name %s", variant)
+		}
+	}
+}
+
+func TestQueryNameVariants(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := ttlReply(req, 60)
+			m.Question[0].Name = strings.ToUpper(m.Question[0].Name)
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("www.Caffix.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeSuccess || len(resp.Answer) == 0 {
+		t.Errorf("the response with a different case in the question was dropped: %v", err)
+	}
+}
+
+func TestXchgRemoveByID(t *testing.T) {
+	xchg := newXchgMgr(DefaultTimeout, realClock{})
+
+	first := QueryMsg("caffix.net", dns.TypeA)
+	second := QueryMsg("www.caffix.net", dns.TypeA)
+	second.Id = first.Id
+	_ = xchg.add(&request{Msg: first})
+	_ = xchg.add(&request{Msg: second})
+
+	if req := xchg.removeByID(first.Id); req != nil {
+		t.Errorf("an ambiguous message ID was matched to a request")
+	}
+	_ = xchg.remove(second.Id, second.Question[0].Name)
+	if req := xchg.removeByID(first.Id); req == nil || req.Msg != first {
+		t.Errorf("the request was not matched using the message ID")
+	}
+	if req := xchg.removeByID(first.Id); req != nil {
+		t.Errorf("the request was removed twice")
+	}
+}
+
+func TestAcceptableResponse(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetRcode(QueryMsg("caffix.net", dns.TypeA), dns.RcodeServerFailure)
+	if !acceptableResponse(m) {
+		t.Errorf("the response with a question was not accepted")
+	}
+
+	m.Question = nil
+	if !acceptableResponse(m) {
+		t.Errorf("the rcode-only response was not accepted")
+	}
+
+	m.Rcode = dns.RcodeSuccess
+	if acceptableResponse(m) {
+		t.Errorf("the successful response without a question was accepted")
+	}
+
+	m.Rcode = dns.RcodeRefused
+	m.Response = false
+	if acceptableResponse(m) {
+		t.Errorf("the query without a question was accepted")
+	}
+}
+
+func TestQueryEmptyQuestion(t *testing.T) {
+	s, addrstr, _, err := RunLocalUDPServer("localhost:0", func(s *dns.Server) {
+		s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeRefused)
+			m.Question = nil
+			_ = w.WriteMsg(m)
+		})
+	})
+	if err != nil {
+		t.Fatalf("unable to run test server: %v", err)
+	}
+	defer func() { _ = s.Shutdown() }()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, addrstr)
+	defer r.Stop()
+
+	start := time.Now()
+	resp, err := r.QueryBlocking(context.Background(), QueryMsg("caffix.net", dns.TypeA))
+	if err != nil || resp.Rcode != dns.RcodeRefused {
+		t.Errorf("the rcode from the response without a question was not returned: %v", err)
+	}
+	if resp != nil && (len(resp.Question) == 0 || resp.Question[0].Name != "caffix.net.") {
+		t.Errorf("the question was not restored in the response")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the response was not returned promptly: %s", elapsed)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	msg := QueryMsg("caffix.net", dns.TypeA)
+
+	buffered := make(chan *dns.Msg, 1)
+	deliver(context.Background(), buffered, msg)
+	if m := <-buffered; m != msg {
+		t.Errorf("the message was not sent on the buffered channel")
+	}
+
+	unbuffered := make(chan *dns.Msg)
+	deliver(context.Background(), unbuffered, msg)
+	select {
+	case m := <-unbuffered:
+		if m != msg {
+			t.Errorf("the wrong message was sent on the unbuffered channel")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("the message was not sent once the caller was ready to receive")
+	}
+}
+
+func TestAbandonedCaller(t *testing.T) {
+	// the resolver never responds, so the queries are returned by the timeouts loop
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer pc.Close()
+
+	r := NewResolvers()
+	_ = r.AddResolvers(10, pc.LocalAddr().String())
+	r.SetTimeout(100 * time.Millisecond)
+	defer r.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the unbuffered channel is never read by the caller
+	r.Query(ctx, QueryMsg("abandoned.caffix.net", dns.TypeA), make(chan *dns.Msg))
+
+	select {
+	case resp := <-r.QueryChan(context.Background(), QueryMsg("caffix.net", dns.TypeA)):
+		if resp.Rcode != RcodeNoResponse {
+			t.Errorf("the query to the silent resolver returned rcode %d", resp.Rcode)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("the abandoned channel stalled the pool")
+	}
+}